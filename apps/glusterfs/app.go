@@ -11,10 +11,12 @@ package glusterfs
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -22,10 +24,17 @@ import (
 	"github.com/lpabon/godbc"
 
 	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/gd2exec"
 	"github.com/heketi/heketi/executors/injectexec"
+	"github.com/heketi/heketi/executors/localexec"
 	"github.com/heketi/heketi/executors/mockexec"
+	"github.com/heketi/heketi/executors/recordexec"
+	"github.com/heketi/heketi/executors/simexec"
 	"github.com/heketi/heketi/executors/sshexec"
+	"github.com/heketi/heketi/pkg/idgen"
 	"github.com/heketi/heketi/pkg/logging"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+	"github.com/heketi/heketi/pkg/utils"
 	"github.com/heketi/heketi/server/rest"
 )
 
@@ -52,6 +61,11 @@ var (
 	// TODO: make a global not needed
 	currentNodeHealthCache *NodeHealthCache
 
+	// global var to track the circuits to the nodes of the executor
+	// of the active app, if the executor breaks circuits. Same
+	// caveats as above.
+	currentHostCircuits executors.CircuitBreakerReporter
+
 	// global var to enable the use of the health cache + monitor
 	// when the GlusterFS App is created. This is mildly hacky but
 	// avoids having to update config files to enable the feature
@@ -83,9 +97,31 @@ type App struct {
 	nhealth *NodeHealthCache
 	// background operations cleaner
 	bgcleaner *backgroundOperationCleaner
+	// stuck operations watchdog
+	watchdog *operationWatchdog
 
 	// operations tracker
 	optracker *OpTracker
+	// retries of failed operations
+	retries retryPolicy
+	// deadlines of in-flight operations
+	deadlines operationDeadlines
+
+	// trail of commands run on the nodes
+	commandTrail *rex.CommandTrail
+	// file the commands are recorded to, if any
+	recording io.Closer
+
+	// totals of the operations run by the server
+	opstats *operationStats
+
+	// results of completed volume batches
+	volumeBatches *volumeBatchResults
+
+	// journal of the events reported to clients
+	events *eventJournal
+	// posts events to the configured webhooks
+	webhooks *webhookNotifier
 
 	// For testing only.  Keep access to the object
 	// not through the interface
@@ -129,8 +165,18 @@ func (app *App) setup(conf *GlusterFSConfig) error {
 		logger.Err(err)
 	}
 
+	switch app.conf.Metrics.Access {
+	case "", MetricsAccessAdmin, MetricsAccessUser, MetricsAccessPublic:
+	default:
+		return fmt.Errorf("invalid metrics access: %v",
+			app.conf.Metrics.Access)
+	}
+
 	// Setup asynchronous manager
 	app.asyncManager = rest.NewAsyncHttpManager(ASYNC_ROUTE)
+	app.asyncManager.ErrorFunc = func(err error) error {
+		return apiError(err)
+	}
 
 	// Setup executor
 	switch app.conf.Executor {
@@ -143,6 +189,42 @@ func (app *App) setup(conf *GlusterFSConfig) error {
 		app.executor, err = sshexec.NewSshExecutor(&app.conf.SshConfig)
 		app.executor = injectexec.NewInjectExecutor(
 			app.executor, &app.conf.InjectConfig)
+	case "local":
+		app.executor, err = localexec.NewLocalExecutor(&app.conf.LocalConfig)
+	case "inject/local":
+		app.executor, err = localexec.NewLocalExecutor(&app.conf.LocalConfig)
+		app.executor = injectexec.NewInjectExecutor(
+			app.executor, &app.conf.InjectConfig)
+	case "record/ssh":
+		app.executor, err = sshexec.NewSshExecutor(&app.conf.SshConfig)
+		if err == nil {
+			app.recording, err = recordexec.WrapRecorder(
+				app.executor, &app.conf.RecordConfig)
+		}
+	case "record/local":
+		app.executor, err = localexec.NewLocalExecutor(&app.conf.LocalConfig)
+		if err == nil {
+			app.recording, err = recordexec.WrapRecorder(
+				app.executor, &app.conf.RecordConfig)
+		}
+	case "gd2/ssh":
+		app.executor, err = newGd2Executor(&app.conf.Gd2Config,
+			func() (executors.Executor, error) {
+				return sshexec.NewSshExecutor(&app.conf.SshConfig)
+			})
+	case "gd2/local":
+		app.executor, err = newGd2Executor(&app.conf.Gd2Config,
+			func() (executors.Executor, error) {
+				return localexec.NewLocalExecutor(&app.conf.LocalConfig)
+			})
+	case "replay":
+		app.executor, err = recordexec.NewReplayExecutor(&app.conf.RecordConfig)
+	case "sim":
+		app.executor, err = simexec.NewSimExecutor(&app.conf.SimConfig)
+	case "inject/sim":
+		// the simulator runs no commands for the hooks to act on
+		err = fmt.Errorf("invalid executor: %v: use the failures of "+
+			"simexec to inject faults into the simulator", app.conf.Executor)
 	case "inject/mock":
 		app.executor, err = mockexec.NewMockExecutor()
 		app.executor = injectexec.NewInjectExecutor(
@@ -155,6 +237,15 @@ func (app *App) setup(conf *GlusterFSConfig) error {
 		return err
 	}
 	logger.Info("Loaded %v executor", app.conf.Executor)
+	if r, ok := app.executor.(executors.CircuitBreakerReporter); ok {
+		currentHostCircuits = r
+	}
+
+	err = app.initCommandTrail()
+	if err != nil {
+		logger.Err(err)
+		return err
+	}
 
 	// Set db is set in the configuration file
 	if app.conf.DBfile != "" {
@@ -167,6 +258,28 @@ func (app *App) setup(conf *GlusterFSConfig) error {
 		return err
 	}
 
+	// recordings only replay if the server generates the same ids.
+	// The generated ids start over in every run, so they would
+	// clash with the entries of a db that is not empty.
+	if app.conf.RecordConfig.DeterministicIds &&
+		(strings.HasPrefix(app.conf.Executor, "record/") ||
+			app.conf.Executor == "replay") {
+		if !dbIsEmpty(app.db) {
+			return logger.LogError(
+				"Deterministic ids require an empty db, %v has entries",
+				dbfilename)
+		}
+		idgen.Randomness = &idgen.NonRandom{}
+	}
+
+	app.initEvents()
+
+	err = app.initNodeConnections()
+	if err != nil {
+		logger.Err(err)
+		return err
+	}
+
 	// Drop a note that the system had pending operations in the db
 	// at start up time. Even though we now have auto-cleanup
 	// This note can be helpful for curious users and or a debugging
@@ -187,8 +300,11 @@ func (app *App) setup(conf *GlusterFSConfig) error {
 
 	// initialize sub-objects and background tasks
 	app.initOpTracker()
+	app.initOpWatchdog()
+	app.volumeBatches = newVolumeBatchResults(VOLUME_BATCH_RESULTS_LIMIT)
 	app.initNodeMonitor()
 	app.initBackgroundCleaner()
+	app.initWebhooks()
 
 	// Show application has loaded
 	logger.Info("GlusterFS Application Loaded")
@@ -243,6 +359,28 @@ func (app *App) initDB() error {
 	return err
 }
 
+// initEvents sets up the journal of events. Events are only
+// journaled to a db that can be written.
+func (app *App) initEvents() {
+	if app.dbReadOnly {
+		return
+	}
+	app.events = newEventJournal(app.db, app.conf.Events.JournalSize)
+	currentEventJournal = app.events
+}
+
+func (app *App) initWebhooks() {
+	if len(app.conf.Webhooks) == 0 {
+		return
+	}
+	if app.events == nil {
+		logger.Warning("Webhooks are disabled with a read-only db")
+		return
+	}
+	app.webhooks = newWebhookNotifier(app.events, app.conf.Webhooks)
+	app.webhooks.Start()
+}
+
 func (app *App) initNodeMonitor() {
 	//default monitor gluster node refresh time
 	var timer uint32 = 120
@@ -255,6 +393,7 @@ func (app *App) initNodeMonitor() {
 	}
 	if MonitorGlusterNodes {
 		app.nhealth = NewNodeHealthCache(timer, startDelay, app.db, app.executor)
+		app.nhealth.events = app.events
 		app.nhealth.Monitor()
 		currentNodeHealthCache = app.nhealth
 	}
@@ -274,12 +413,127 @@ func (app *App) initBackgroundCleaner() {
 	}
 }
 
+// newGd2Executor returns an executor that manages gluster through
+// glusterd2 and the storage of the nodes through the executor
+// returned by newNodes.
+func newGd2Executor(config *gd2exec.Gd2Config,
+	newNodes func() (executors.Executor, error)) (executors.Executor, error) {
+
+	gd2, err := gd2exec.NewGd2Executor(config)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := newNodes()
+	if err != nil {
+		return nil, err
+	}
+	return gd2exec.NewGd2Stack(gd2, nodes), nil
+}
+
+// initCommandTrail sets up the trail of commands run on the nodes
+// and hands it to the executor, if the executor can use one.
+func (app *App) initCommandTrail() error {
+	c := app.conf.CommandTrail
+	app.commandTrail = rex.NewCommandTrail(c.Size, c.OutputLimit)
+	if c.File != "" {
+		err := app.commandTrail.SetFile(
+			c.File, c.FileMaxSizeMb*1024*1024, c.FileMaxBackups)
+		if err != nil {
+			return fmt.Errorf("Unable to open command trail file %v: %v",
+				c.File, err)
+		}
+	}
+	if t, ok := app.executor.(executors.CommandTrailSetter); ok {
+		t.SetCommandTrail(app.commandTrail)
+	}
+	return nil
+}
+
+// initNodeConnections passes the connection settings and host keys
+// stored for the nodes to the executor, if the executor supports them.
+func (app *App) initNodeConnections() error {
+	setter, hasSetter := app.executor.(executors.HostConnectionSetter)
+	pinner, hasPinner := app.executor.(executors.HostKeyPinner)
+	if !hasSetter && !hasPinner {
+		return nil
+	}
+	unpinned := 0
+	err := app.db.View(func(tx *bolt.Tx) error {
+		nodes, err := NodeList(tx)
+		if err != nil {
+			return err
+		}
+		for _, id := range nodes {
+			if strings.HasPrefix(id, "MANAGE") ||
+				strings.HasPrefix(id, "STORAGE") {
+				continue
+			}
+			node, err := NewNodeEntryFromId(tx, id)
+			if err != nil {
+				return err
+			}
+			if hasSetter {
+				cluster, err := NewClusterEntryFromId(tx, node.Info.ClusterId)
+				if err != nil {
+					return err
+				}
+				hc := node.HostConnection(cluster)
+				if hc != nil {
+					err = setter.SetHostConnection(node.ManageHostName(), hc)
+					if err != nil {
+						// do not prevent the server from starting,
+						// the node is left with the default settings
+						logger.LogError("Unable to set connection for node %v: %v",
+							node.Info.Id, err)
+					}
+				}
+			}
+			if !hasPinner {
+				continue
+			}
+			if node.HostKey == "" {
+				unpinned++
+				continue
+			}
+			err = pinner.PinHostKey(node.ManageHostName(), node.HostKey)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if unpinned > 0 {
+		logger.Warning("%v node(s) have no pinned host key."+
+			" Use the node hostkey endpoint to pin them.", unpinned)
+	}
+	return err
+}
+
 func (app *App) initOpTracker() {
 	oplimit := app.conf.MaxInflightOperations
 	if oplimit == 0 {
 		oplimit = DEFAULT_OP_LIMIT
 	}
 	app.optracker = newOpTracker(oplimit)
+	app.opstats = newOperationStats()
+}
+
+func (app *App) initOpWatchdog() {
+	app.deadlines = newOperationDeadlines(app.conf.OperationTimeouts)
+	if !app.deadlines.enabled() {
+		return
+	}
+	interval := app.conf.OperationTimeouts.CheckInterval
+	if interval == 0 {
+		interval = 60
+	}
+	app.watchdog = &operationWatchdog{
+		optracker:     app.optracker,
+		executor:      app.executor,
+		CheckInterval: time.Second * time.Duration(interval),
+		Cancel:        app.conf.OperationTimeouts.CancelStuck,
+	}
+	app.watchdog.Start()
 }
 
 func SetLogLevel(level string) error {
@@ -387,6 +641,11 @@ func (a *App) setFromEnvironmentalVariable() {
 			logger.LogError("Error: While parsing HEKETI_GLUSTER_MAX_VOLUMES_PER_CLUSTER: %v", err)
 		}
 	}
+
+	env = os.Getenv("HEKETI_METRICS_ACCESS")
+	if "" != env {
+		a.conf.Metrics.Access = env
+	}
 }
 
 func (a *App) setAdvSettings() {
@@ -435,6 +694,10 @@ func (a *App) setAdvSettings() {
 		logger.Info("Volumes per cluster limit is set to %v", a.conf.MaxVolumesPerCluster)
 		maxVolumesPerCluster = a.conf.MaxVolumesPerCluster
 	}
+	a.retries = newRetryPolicy(a.conf.RetryLimits)
+	if a.retries.backoff.Initial > 0 {
+		logger.Info("Adv: Operation retry backoff starts at %v", a.retries.backoff.Initial)
+	}
 }
 
 func (a *App) setBlockSettings() {
@@ -496,6 +759,30 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Pattern:     "/clusters/{id:[A-Fa-f0-9]+}",
 			HandlerFunc: a.ClusterDelete},
 
+		// Events
+		rest.Route{
+			Name:        "Events",
+			Method:      "GET",
+			Pattern:     "/events",
+			HandlerFunc: a.Events},
+
+		// Topology
+		rest.Route{
+			Name:        "TopologyInfo",
+			Method:      "GET",
+			Pattern:     "/topology",
+			HandlerFunc: a.Topology},
+		rest.Route{
+			Name:        "TopologyExport",
+			Method:      "GET",
+			Pattern:     "/topology/export",
+			HandlerFunc: a.TopologyExport},
+		rest.Route{
+			Name:        "TopologyApply",
+			Method:      "POST",
+			Pattern:     "/topology/apply",
+			HandlerFunc: a.TopologyApply},
+
 		// Node
 		rest.Route{
 			Name:        "NodeAdd",
@@ -522,6 +809,16 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "POST",
 			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}/tags",
 			HandlerFunc: a.NodeSetTags},
+		rest.Route{
+			Name:        "NodeSetHostKey",
+			Method:      "POST",
+			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}/hostkey",
+			HandlerFunc: a.NodeSetHostKey},
+		rest.Route{
+			Name:        "NodeResync",
+			Method:      "GET",
+			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}/resync",
+			HandlerFunc: a.NodeResync},
 
 		// Devices
 		rest.Route{
@@ -561,6 +858,16 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "POST",
 			Pattern:     "/volumes",
 			HandlerFunc: a.VolumeCreate},
+		rest.Route{
+			Name:        "VolumeBatchCreate",
+			Method:      "POST",
+			Pattern:     "/volumes/batch",
+			HandlerFunc: a.VolumeBatchCreate},
+		rest.Route{
+			Name:        "VolumeBatchInfo",
+			Method:      "GET",
+			Pattern:     "/volumes/batch/{id:[A-Fa-f0-9]+}",
+			HandlerFunc: a.VolumeBatchInfo},
 		rest.Route{
 			Name:        "VolumeInfo",
 			Method:      "GET",
@@ -646,6 +953,40 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "POST",
 			Pattern:     "/internal/logging",
 			HandlerFunc: a.SetLogLevel},
+		// Metrics
+		rest.Route{
+			Name:        "Metrics",
+			Method:      "GET",
+			Pattern:     "/metrics",
+			HandlerFunc: a.Metrics},
+		// Executor connections
+		rest.Route{
+			Name:        "ExecutorConnections",
+			Method:      "GET",
+			Pattern:     "/internal/executor/connections",
+			HandlerFunc: a.ExecutorConnections},
+		rest.Route{
+			Name:        "ExecutorCircuits",
+			Method:      "GET",
+			Pattern:     "/internal/executor/circuits",
+			HandlerFunc: a.ExecutorCircuits},
+		// Commands run on the nodes
+		rest.Route{
+			Name:        "CommandTrail",
+			Method:      "GET",
+			Pattern:     "/internal/commands",
+			HandlerFunc: a.CommandTrail},
+		// Fault injection
+		rest.Route{
+			Name:        "InjectState",
+			Method:      "GET",
+			Pattern:     "/internal/injectexec",
+			HandlerFunc: a.InjectState},
+		rest.Route{
+			Name:        "InjectSetScenario",
+			Method:      "POST",
+			Pattern:     "/internal/injectexec/scenario",
+			HandlerFunc: a.InjectSetScenario},
 		// Operations state on server
 		rest.Route{
 			Name:        "OperationsInfo",
@@ -705,6 +1046,23 @@ func (a *App) Close() {
 	if a.bgcleaner != nil {
 		a.bgcleaner.Stop()
 	}
+	if a.watchdog != nil {
+		a.watchdog.Stop()
+	}
+	if a.webhooks != nil {
+		a.webhooks.Stop()
+	}
+	if c, ok := a.executor.(executors.Closer); ok {
+		if err := c.Close(); err != nil {
+			logger.LogError("Unable to close executor: %v", err)
+		}
+	}
+	a.commandTrail.Close()
+	if a.recording != nil {
+		if err := a.recording.Close(); err != nil {
+			logger.LogError("Unable to close recording: %v", err)
+		}
+	}
 
 	// Close the DB
 	a.db.Close()
@@ -720,13 +1078,13 @@ func (a *App) Backup(w http.ResponseWriter, r *http.Request) {
 		return err
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
 func (a *App) NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Warning("Invalid path or request %v", r.URL.Path)
-	http.Error(w, "Invalid path or request", http.StatusNotFound)
+	utils.HttpError(w, "Invalid path or request", http.StatusNotFound)
 }
 
 // ServerReset resets the app and its components to the state desired
@@ -821,6 +1179,15 @@ func (a *App) BackgroundCleaner() *backgroundOperationCleaner {
 	}
 }
 
+// runner returns a runner for the operations started by
+// state changes that uses the executor and retry policy of the app.
+func (a *App) runner() operationRunner {
+	return operationRunner{
+		executor: a.executor,
+		retries:  a.retries,
+	}
+}
+
 // currentNodeHealthStatus returns a map of node ids to the most
 // recently known health status (true is up, false is not up).
 // If a node is not found in the map its status is unknown.
@@ -834,3 +1201,13 @@ func currentNodeHealthStatus() (nodeUp map[string]bool) {
 	}
 	return
 }
+
+// currentHostCircuit returns the state of the executor's circuit to
+// the given host. If the executor does not break circuits the
+// circuit is always closed.
+func currentHostCircuit(host string) rex.CircuitState {
+	if currentHostCircuits == nil {
+		return rex.CircuitClosed
+	}
+	return currentHostCircuits.HostCircuit(host)
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/boltdb/bolt"
 	"github.com/gorilla/mux"
+	wdb "github.com/heketi/heketi/pkg/db"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/heketi/pkg/utils"
 )
@@ -24,19 +25,19 @@ func (a *App) BlockVolumeCreate(w http.ResponseWriter, r *http.Request) {
 	var msg api.BlockVolumeCreateRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
 
 	if msg.Size < 1 {
-		http.Error(w, "Invalid volume size", http.StatusBadRequest)
+		utils.HttpError(w, "Invalid volume size", http.StatusBadRequest)
 		logger.LogError("Invalid volume size")
 		return
 	}
@@ -48,12 +49,12 @@ func (a *App) BlockVolumeCreate(w http.ResponseWriter, r *http.Request) {
 		// :TODO: All we need to do is check for one instead of gathering all keys
 		clusters, err := ClusterList(tx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 		if len(clusters) == 0 {
 			err := logger.LogError("No clusters configured")
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			utils.HttpError(w, err.Error(), http.StatusBadRequest)
 			return ErrNotFound
 		}
 
@@ -62,7 +63,7 @@ func (a *App) BlockVolumeCreate(w http.ResponseWriter, r *http.Request) {
 			_, err := NewClusterEntryFromId(tx, clusterid)
 			if err != nil {
 				err := logger.LogError("Cluster id %v not found", clusterid)
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				utils.HttpError(w, err.Error(), http.StatusBadRequest)
 				return err
 			}
 		}
@@ -75,6 +76,14 @@ func (a *App) BlockVolumeCreate(w http.ResponseWriter, r *http.Request) {
 
 	blockVolume := NewBlockVolumeEntryFromRequest(&msg)
 
+	if isDryRun(r) {
+		a.PlanHttpResponse(w, "Create Block Volume",
+			planOperation(func(db wdb.DB) Operation {
+				return NewBlockVolumeCreateOperation(blockVolume, db)
+			}))
+		return
+	}
+
 	bvc := NewBlockVolumeCreateOperation(blockVolume, a.db)
 	if err := AsyncHttpOperation(a, w, r, bvc); err != nil {
 		OperationHttpErrorf(w, err, "Failed to allocate new block volume: %v", err)
@@ -82,14 +91,26 @@ func (a *App) BlockVolumeCreate(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// BlockVolumeList lists the block volumes. The query parameters
+// select, order and page the block volumes, as described by
+// api.ListOptions.
 func (a *App) BlockVolumeList(w http.ResponseWriter, r *http.Request) {
 
-	var list api.BlockVolumeListResponse
+	opts, err := listOptions(r.URL.Query())
+	if err == nil {
+		err = blockVolumeListFilters.check(opts)
+	}
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	err := a.db.View(func(tx *bolt.Tx) error {
+	var list *api.BlockVolumeListResponse
+
+	err = a.db.View(func(tx *bolt.Tx) error {
 		var err error
 
-		list.BlockVolumes, err = ListCompleteBlockVolumes(tx)
+		list, err = listBlockVolumes(tx, opts)
 		if err != nil {
 			return err
 		}
@@ -99,7 +120,7 @@ func (a *App) BlockVolumeList(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		logger.Err(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -121,16 +142,16 @@ func (a *App) BlockVolumeInfo(w http.ResponseWriter, r *http.Request) {
 	err := a.db.View(func(tx *bolt.Tx) error {
 		entry, err := NewBlockVolumeEntryFromId(tx, id)
 		if err == ErrNotFound || !entry.Visible() {
-			http.Error(w, "Id not found", http.StatusNotFound)
+			utils.HttpError(w, "Id not found", http.StatusNotFound)
 			return ErrNotFound
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
 		info, err = entry.NewInfoResponse(tx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -157,10 +178,10 @@ func (a *App) BlockVolumeDelete(w http.ResponseWriter, r *http.Request) {
 		var err error
 		blockVolume, err = NewBlockVolumeEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -170,6 +191,14 @@ func (a *App) BlockVolumeDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		a.PlanHttpResponse(w, "Delete Block Volume",
+			planOperation(func(db wdb.DB) Operation {
+				return NewBlockVolumeDeleteOperation(blockVolume, db)
+			}))
+		return
+	}
+
 	vdel := NewBlockVolumeDeleteOperation(blockVolume, a.db)
 	if err := AsyncHttpOperation(a, w, r, vdel); err != nil {
 		OperationHttpErrorf(w, err, "Failed to set up block volume delete: %v", err)
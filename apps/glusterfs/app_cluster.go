@@ -24,7 +24,14 @@ func (a *App) ClusterCreate(w http.ResponseWriter, r *http.Request) {
 
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
+		return
+	}
+
+	err = msg.Validate()
+	if err != nil {
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		logger.LogError("validation failed: " + err.Error())
 		return
 	}
 
@@ -35,7 +42,7 @@ func (a *App) ClusterCreate(w http.ResponseWriter, r *http.Request) {
 	err = a.db.Update(func(tx *bolt.Tx) error {
 		err := entry.Save(tx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -62,17 +69,17 @@ func (a *App) ClusterSetFlags(w http.ResponseWriter, r *http.Request) {
 
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 
 	err = a.db.Update(func(tx *bolt.Tx) error {
 		entry, err := NewClusterEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -81,7 +88,7 @@ func (a *App) ClusterSetFlags(w http.ResponseWriter, r *http.Request) {
 
 		err = entry.Save(tx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -95,15 +102,26 @@ func (a *App) ClusterSetFlags(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// ClusterList lists the clusters. The query parameters select, order
+// and page the clusters, as described by api.ListOptions.
 func (a *App) ClusterList(w http.ResponseWriter, r *http.Request) {
 
-	var list api.ClusterListResponse
+	opts, err := listOptions(r.URL.Query())
+	if err == nil {
+		err = clusterListFilters.check(opts)
+	}
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var list *api.ClusterListResponse
 
-	// Get all the cluster ids from the DB
-	err := a.db.View(func(tx *bolt.Tx) error {
+	// Get the selected clusters from the DB
+	err = a.db.View(func(tx *bolt.Tx) error {
 		var err error
 
-		list.Clusters, err = ClusterList(tx)
+		list, err = listClusters(tx, opts)
 		if err != nil {
 			return err
 		}
@@ -113,7 +131,7 @@ func (a *App) ClusterList(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		logger.Err(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -138,10 +156,10 @@ func (a *App) ClusterInfo(w http.ResponseWriter, r *http.Request) {
 		// Create a db entry from the id
 		entry, err := NewClusterEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -182,19 +200,19 @@ func (a *App) ClusterDelete(w http.ResponseWriter, r *http.Request) {
 		// Access cluster entry
 		entry, err := NewClusterEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return logger.Err(err)
 		}
 
 		err = entry.Delete(tx)
 		if err != nil {
 			if err == ErrConflict {
-				http.Error(w, entry.ConflictString(), http.StatusConflict)
+				utils.HttpError(w, entry.ConflictString(), http.StatusConflict)
 			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			}
 			return err
 		}
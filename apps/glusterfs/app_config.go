@@ -10,12 +10,100 @@
 package glusterfs
 
 import (
+	"github.com/heketi/heketi/executors/gd2exec"
 	"github.com/heketi/heketi/executors/injectexec"
+	"github.com/heketi/heketi/executors/localexec"
+	"github.com/heketi/heketi/executors/recordexec"
+	"github.com/heketi/heketi/executors/simexec"
 	"github.com/heketi/heketi/executors/sshexec"
 )
 
 type RetryLimitConfig struct {
-	VolumeCreate int `json:"volume_create"`
+	VolumeCreate      int `json:"volume_create"`
+	VolumeDelete      int `json:"volume_delete"`
+	VolumeExpand      int `json:"volume_expand"`
+	VolumeClone       int `json:"volume_clone"`
+	BlockVolumeCreate int `json:"block_volume_create"`
+	BlockVolumeDelete int `json:"block_volume_delete"`
+	DeviceRemove      int `json:"device_remove"`
+
+	// delay between attempts
+	Backoff RetryBackoffConfig `json:"backoff"`
+}
+
+type RetryBackoffConfig struct {
+	InitialMs  uint32  `json:"initial_ms"`
+	MaxMs      uint32  `json:"max_ms"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// OperationTimeoutConfig holds the deadlines, in seconds, for
+// each kind of operation. A deadline of zero uses the default
+// deadline and a default of zero disables the deadline.
+type OperationTimeoutConfig struct {
+	Default           uint32 `json:"default"`
+	VolumeCreate      uint32 `json:"volume_create"`
+	VolumeDelete      uint32 `json:"volume_delete"`
+	VolumeExpand      uint32 `json:"volume_expand"`
+	VolumeClone       uint32 `json:"volume_clone"`
+	BlockVolumeCreate uint32 `json:"block_volume_create"`
+	BlockVolumeDelete uint32 `json:"block_volume_delete"`
+	DeviceRemove      uint32 `json:"device_remove"`
+
+	// watchdog behavior
+	CheckInterval uint32 `json:"check_interval"`
+	CancelStuck   bool   `json:"cancel_stuck"`
+}
+
+// CommandTrailConfig holds the settings of the trail of commands run
+// on the nodes. Sizes of zero use the defaults and an empty file
+// keeps the trail in memory only.
+type CommandTrailConfig struct {
+	Size           int    `json:"size"`
+	OutputLimit    int    `json:"output_limit"`
+	File           string `json:"file"`
+	FileMaxSizeMb  int64  `json:"file_max_size_mb"`
+	FileMaxBackups int    `json:"file_max_backups"`
+}
+
+// Who may read the metrics endpoint
+const (
+	MetricsAccessAdmin  = "admin"
+	MetricsAccessUser   = "user"
+	MetricsAccessPublic = "public"
+)
+
+// MetricsConfig holds the settings of the metrics endpoint.
+type MetricsConfig struct {
+	// Access is one of admin (the default), user for any valid
+	// token, or public for anyone, without a token
+	Access string `json:"access"`
+}
+
+// EventsConfig holds the settings of the event stream.
+type EventsConfig struct {
+	// number of events kept in the db for clients to resume from.
+	// Zero uses the default.
+	JournalSize uint64 `json:"journal_size"`
+}
+
+// WebhookConfig holds the settings of a url the server posts events
+// to. Retries, the backoff and the timeout, in seconds, use the
+// defaults if zero.
+type WebhookConfig struct {
+	URL string `json:"url"`
+	// types of the events to send. An entry of the form type:state
+	// only sends events that changed the state of a device or node
+	// to state. Empty sends the finished and failed operations, node
+	// health changes and failed devices.
+	Events []string `json:"events"`
+	// key of the HMAC-SHA256 signature of the events
+	Secret         string             `json:"secret"`
+	Retries        int                `json:"retries"`
+	Backoff        RetryBackoffConfig `json:"backoff"`
+	TimeoutSeconds uint32             `json:"timeout_seconds"`
+	// file the undelivered events are appended to
+	DeadLetterFile string `json:"dead_letter_file"`
 }
 
 type GlusterFSConfig struct {
@@ -24,7 +112,11 @@ type GlusterFSConfig struct {
 	Executor     string                  `json:"executor"`
 	Allocator    string                  `json:"allocator"`
 	SshConfig    sshexec.SshConfig       `json:"sshexec"`
+	LocalConfig  localexec.LocalConfig   `json:"localexec"`
+	RecordConfig recordexec.RecordConfig `json:"recordexec"`
+	SimConfig    simexec.SimConfig       `json:"simexec"`
 	InjectConfig injectexec.InjectConfig `json:"injectexec"`
+	Gd2Config    gd2exec.Gd2Config       `json:"gd2exec"`
 	Loglevel     string                  `json:"loglevel"`
 
 	// advanced settings
@@ -54,4 +146,19 @@ type GlusterFSConfig struct {
 
 	// operation retry amounts
 	RetryLimits RetryLimitConfig `json:"operation_retry_limits"`
+
+	// operation deadlines
+	OperationTimeouts OperationTimeoutConfig `json:"operation_timeouts"`
+
+	// commands run on the nodes
+	CommandTrail CommandTrailConfig `json:"command_trail"`
+
+	// metrics endpoint
+	Metrics MetricsConfig `json:"metrics"`
+
+	// event stream
+	Events EventsConfig `json:"events"`
+
+	// urls the events are posted to
+	Webhooks []WebhookConfig `json:"webhooks"`
 }
@@ -12,6 +12,8 @@ package glusterfs
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/heketi/heketi/pkg/utils"
 )
 
 // DbDump ... Creates a JSON output representing the state of DB
@@ -19,7 +21,7 @@ import (
 func (a *App) DbDump(w http.ResponseWriter, r *http.Request) {
 	dump, err := dbDumpInternal(a.db)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -39,7 +41,7 @@ func (a *App) DbDump(w http.ResponseWriter, r *http.Request) {
 func (a *App) DbCheck(w http.ResponseWriter, r *http.Request) {
 	checkResponse, err := dbCheckConsistency(a.db)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
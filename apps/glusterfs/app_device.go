@@ -28,20 +28,20 @@ func (a *App) DeviceAdd(w http.ResponseWriter, r *http.Request) {
 	var msg api.DeviceAddRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
 
 	// Check the message has devices
 	if msg.Name == "" {
-		http.Error(w, "no devices added", http.StatusBadRequest)
+		utils.HttpError(w, "no devices added", http.StatusBadRequest)
 		return
 	}
 
@@ -54,10 +54,10 @@ func (a *App) DeviceAdd(w http.ResponseWriter, r *http.Request) {
 		var err error
 		node, err = NewNodeEntryFromId(tx, msg.NodeId)
 		if err == ErrNotFound {
-			http.Error(w, "Node id does not exist", http.StatusNotFound)
+			utils.HttpError(w, "Node id does not exist", http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -67,75 +67,104 @@ func (a *App) DeviceAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Devices are set up from the json reports of the lvm tools.
+	// The lvm tools may have been upgraded since the node was last
+	// probed, so probe it again before refusing the device.
+	if !node.hasLvmJson() {
+		err := node.probeCapabilities(
+			executors.WithContext(a.executor, r.Context()))
+		if err == nil {
+			err = node.saveCapabilities(a.db)
+		}
+		if err != nil {
+			logger.Warning("Unable to probe node %v again: %v",
+				msg.NodeId, err)
+		}
+	}
+	if !node.hasLvmJson() {
+		utils.HttpError(w, fmt.Sprintf(
+			"LVM (version %q) on node %v does not support json reports",
+			node.Capabilities.LvmVersion, msg.NodeId),
+			http.StatusBadRequest)
+		return
+	}
+
 	// Log the devices are being added
 	logger.Info("Adding device %v to node %v", msg.Name, msg.NodeId)
 
 	// Add device in an asynchronous function
-	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (seeOtherUrl string, e error) {
-
-		// Setup device on node
-		info, err := a.executor.DeviceSetup(node.ManageHostName(),
-			device.Info.Name, device.Info.Id, false)
-		if err != nil && msg.DestroyData {
-			errReason := allowDestroyDevice(a.db, err)
-			if errReason != nil {
-				// not allowed to destroy the device. return reason
-				// as our error
-				return "", errReason
-			}
-			info, err = a.executor.DeviceSetup(node.ManageHostName(),
-				device.Info.Name, device.Info.Id, true)
-		}
+	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
+		err := setupDevice(a.db, a.requestExecutor(r), node, device,
+			msg.DestroyData)
 		if err != nil {
 			return "", err
 		}
-		device.UpdateInfo(info)
 
-		// Setup garbage collector on error
-		defer func() {
-			if e != nil {
-				a.executor.DeviceTeardown(node.ManageHostName(), device.ToHandle())
-			}
-		}()
+		// Done
+		// Returning a null string instructs the async manager
+		// to return http status of 204 (No Content)
+		return "", nil
+	})
 
-		// Save on db
-		err = a.db.Update(func(tx *bolt.Tx) error {
+}
 
-			nodeEntry, err := NewNodeEntryFromId(tx, msg.NodeId)
-			if err != nil {
-				return err
-			}
+// setupDevice sets up a new device on its node and saves it in the
+// db. If destroy is set and the device is not empty, the data on the
+// device is destroyed when allowDestroyDevice permits it.
+func setupDevice(db wdb.DB, executor executors.Executor,
+	node *NodeEntry, device *DeviceEntry, destroy bool) (e error) {
+
+	// Setup device on node
+	info, err := executor.DeviceSetup(node.ManageHostName(),
+		device.Info.Name, device.Info.Id, false)
+	if err != nil && destroy {
+		errReason := allowDestroyDevice(db, err)
+		if errReason != nil {
+			// not allowed to destroy the device. return reason
+			// as our error
+			return errReason
+		}
+		info, err = executor.DeviceSetup(node.ManageHostName(),
+			device.Info.Name, device.Info.Id, true)
+	}
+	if err != nil {
+		return err
+	}
+	device.UpdateInfo(info)
 
-			// Add device to node
-			nodeEntry.DeviceAdd(device.Info.Id)
+	// Setup garbage collector on error
+	defer func() {
+		if e != nil {
+			executor.DeviceTeardown(node.ManageHostName(), device.ToHandle())
+		}
+	}()
 
-			// Commit
-			err = nodeEntry.Save(tx)
-			if err != nil {
-				return err
-			}
+	// Save on db
+	err = db.Update(func(tx *bolt.Tx) error {
 
-			// Save drive
-			err = device.Save(tx)
-			if err != nil {
-				return err
-			}
+		nodeEntry, err := NewNodeEntryFromId(tx, device.NodeId)
+		if err != nil {
+			return err
+		}
 
-			return nil
+		// Add device to node
+		nodeEntry.DeviceAdd(device.Info.Id)
 
-		})
+		// Commit
+		err = nodeEntry.Save(tx)
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		logger.Info("Added device %v", msg.Name)
-
-		// Done
-		// Returning a null string instructs the async manager
-		// to return http status of 204 (No Content)
-		return "", nil
+		// Save drive
+		return device.Save(tx)
 	})
+	if err != nil {
+		return err
+	}
 
+	logger.Info("Added device %v", device.Info.Name)
+	return nil
 }
 
 func (a *App) DeviceInfo(w http.ResponseWriter, r *http.Request) {
@@ -149,16 +178,16 @@ func (a *App) DeviceInfo(w http.ResponseWriter, r *http.Request) {
 	err := a.db.View(func(tx *bolt.Tx) error {
 		entry, err := NewDeviceEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, "Id not found", http.StatusNotFound)
+			utils.HttpError(w, "Id not found", http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
 		info, err = entry.NewInfoResponse(tx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -186,7 +215,7 @@ func (a *App) DeviceDelete(w http.ResponseWriter, r *http.Request) {
 	if r.ContentLength > 0 {
 		err := utils.GetJsonFromRequest(r, &opts)
 		if err != nil {
-			http.Error(w, "request unable to be parsed", 422)
+			utils.HttpError(w, "request unable to be parsed", 422)
 			return
 		}
 	}
@@ -201,26 +230,26 @@ func (a *App) DeviceDelete(w http.ResponseWriter, r *http.Request) {
 		// Access device entry
 		device, err = NewDeviceEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return logger.Err(err)
 		}
 
 		// Access node entry
 		node, err = NewNodeEntryFromId(tx, device.NodeId)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return logger.Err(err)
 		}
 
 		// Check if we can delete the device
 		if err := device.CheckDelete(); err != nil {
 			if err == ErrConflict {
-				http.Error(w, device.ConflictString(), http.StatusConflict)
+				utils.HttpError(w, device.ConflictString(), http.StatusConflict)
 			} else {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				utils.HttpError(w, err.Error(), http.StatusBadRequest)
 			}
 			return err
 		}
@@ -234,62 +263,76 @@ func (a *App) DeviceDelete(w http.ResponseWriter, r *http.Request) {
 	// Delete device
 	logger.Info("Deleting device %v on node %v", device.Info.Id, device.NodeId)
 	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
-
-		// Teardown device
-		var err error
-		dh := device.ToHandle()
 		if opts.ForceForget {
 			logger.Info("Delete request set force-forget option")
-			err = a.executor.DeviceForget(node.ManageHostName(), dh)
-		} else {
-			err = a.executor.DeviceTeardown(node.ManageHostName(), dh)
-		}
-		if err != nil {
-			return "", err
 		}
+		return "", deleteDevice(a.db, a.requestExecutor(r), node, device,
+			opts.ForceForget)
+	})
 
-		// Get info from db
-		err = a.db.Update(func(tx *bolt.Tx) error {
+}
 
-			// Access node entry
-			node, err := NewNodeEntryFromId(tx, device.NodeId)
-			if err == ErrNotFound {
-				logger.Critical(
-					"Node id %v pointed to by device %v, but it is not in the db",
-					device.NodeId,
-					device.Info.Id)
-				return err
-			} else if err != nil {
-				logger.Err(err)
-				return err
-			}
+// deleteDevice tears down a device that has no bricks and removes it
+// from its node in the db. If forget is set the device is only
+// forgotten by the node rather than torn down.
+func deleteDevice(db wdb.DB, executor executors.Executor,
+	node *NodeEntry, device *DeviceEntry, forget bool) error {
+
+	// Teardown device
+	var err error
+	dh := device.ToHandle()
+	if forget {
+		err = executor.DeviceForget(node.ManageHostName(), dh)
+	} else {
+		err = executor.DeviceTeardown(node.ManageHostName(), dh)
+	}
+	if err != nil {
+		return err
+	}
 
-			// Delete device from node
-			node.DeviceDelete(device.Info.Id)
+	// Get info from db
+	err = db.Update(func(tx *bolt.Tx) error {
 
-			// Save node
-			node.Save(tx)
+		// Access node entry
+		node, err := NewNodeEntryFromId(tx, device.NodeId)
+		if err == ErrNotFound {
+			logger.Critical(
+				"Node id %v pointed to by device %v, but it is not in the db",
+				device.NodeId,
+				device.Info.Id)
+			return err
+		} else if err != nil {
+			logger.Err(err)
+			return err
+		}
 
-			// Delete device from db
-			err = device.Delete(tx)
-			if err != nil {
-				logger.Err(err)
-				return err
-			}
+		// Delete device from node
+		node.DeviceDelete(device.Info.Id)
 
-			return nil
+		// Save node
+		err = node.Save(tx)
+		if err != nil {
+			logger.Err(err)
+			return err
+		}
 
-		})
+		// Delete device from db
+		err = device.Delete(tx)
 		if err != nil {
-			return "", err
+			logger.Err(err)
+			return err
 		}
 
-		// Show that the key has been deleted
-		logger.Info("Deleted node [%s]", id)
+		return nil
 
-		return "", nil
 	})
+	if err != nil {
+		return err
+	}
 
+	// Show that the key has been deleted
+	logger.Info("Deleted device [%s]", device.Info.Id)
+	return nil
 }
 
 func (a *App) DeviceSetState(w http.ResponseWriter, r *http.Request) {
@@ -302,12 +345,12 @@ func (a *App) DeviceSetState(w http.ResponseWriter, r *http.Request) {
 	var msg api.StateRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
@@ -316,10 +359,10 @@ func (a *App) DeviceSetState(w http.ResponseWriter, r *http.Request) {
 	err = a.db.View(func(tx *bolt.Tx) error {
 		device, err = NewDeviceEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, "Id not found", http.StatusNotFound)
+			utils.HttpError(w, "Id not found", http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -329,6 +372,14 @@ func (a *App) DeviceSetState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		a.PlanHttpResponse(w, "Set Device State",
+			func(db wdb.DB, executor executors.Executor) error {
+				return device.SetState(db, operationRunner{executor: executor}, msg.State)
+			})
+		return
+	}
+
 	// Setting the state to failed can involve long running operations
 	// and thus needs to be checked for operations throttle
 	// However, we don't want to block "cheap" changes like setting
@@ -350,7 +401,7 @@ func (a *App) DeviceSetState(w http.ResponseWriter, r *http.Request) {
 				a.optracker.Remove(token)
 			}
 		}()
-		err = device.SetState(a.db, a.executor, msg.State)
+		err = device.SetState(a.db, a.runner(), msg.State)
 		if err != nil {
 			return "", err
 		}
@@ -379,10 +430,10 @@ func (a *App) DeviceResync(w http.ResponseWriter, r *http.Request) {
 		return err
 	})
 	if err == ErrNotFound {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		utils.HttpError(w, err.Error(), http.StatusNotFound)
 		return
 	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		logger.Err(err)
 		return
 	}
@@ -393,7 +444,7 @@ func (a *App) DeviceResync(w http.ResponseWriter, r *http.Request) {
 	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (seeOtherUrl string, e error) {
 
 		// Get actual device info from manage host
-		info, err := a.executor.GetDeviceInfo(
+		info, err := a.requestExecutor(r).GetDeviceInfo(
 			node.ManageHostName(), device.ToHandle())
 		if err != nil {
 			return "", err
@@ -464,13 +515,13 @@ func (a *App) DeviceSetTags(w http.ResponseWriter, r *http.Request) {
 	var msg api.TagsChangeRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
@@ -478,15 +529,15 @@ func (a *App) DeviceSetTags(w http.ResponseWriter, r *http.Request) {
 	err = a.db.Update(func(tx *bolt.Tx) error {
 		device, err = NewDeviceEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, "Id not found", http.StatusNotFound)
+			utils.HttpError(w, "Id not found", http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 		ApplyTags(device, msg)
 		if err := device.Save(tx); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 		return nil
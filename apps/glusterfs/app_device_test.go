@@ -0,0 +1,134 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/mockexec"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+// probingExecutor reports the given capabilities for every node.
+type probingExecutor struct {
+	*mockexec.MockExecutor
+	caps *executors.NodeCapabilities
+	err  error
+}
+
+func (pe *probingExecutor) NodeCapabilities(
+	host string) (*executors.NodeCapabilities, error) {
+
+	return pe.caps, pe.err
+}
+
+// testDeviceAdd saves a node whose lvm tools were found unable to
+// report in json, then requests a device on it.
+func testDeviceAdd(t *testing.T, app *App) (*NodeEntry, *httptest.ResponseRecorder) {
+	node := NewNodeEntryFromRequest(&api.NodeAddRequest{
+		Zone:      1,
+		Hostnames: api.HostAddresses{Manage: []string{"m1"}, Storage: []string{"s1"}},
+		ClusterId: "c1",
+	})
+	node.Capabilities = &api.NodeCapabilities{LvmVersion: "2.02.100"}
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		return node.Save(tx)
+	})
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+
+	body, err := json.Marshal(api.DeviceAddRequest{
+		Device: api.Device{Name: "/dev/sdb"},
+		NodeId: node.Info.Id,
+	})
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/devices", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	app.DeviceAdd(w, r)
+	return node, w
+}
+
+func TestDeviceAddReprobesNode(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	m, err := mockexec.NewMockExecutor()
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	app.executor = &probingExecutor{
+		MockExecutor: m,
+		caps: &executors.NodeCapabilities{
+			LvmVersion: "2.02.180",
+			LvmJson:    true,
+		},
+	}
+
+	node, w := testDeviceAdd(t, app)
+	tests.Assert(t, w.Code == http.StatusAccepted,
+		"expected", http.StatusAccepted, "got", w.Code, w.Body.String())
+
+	// the device gets added in the background
+	var entry *NodeEntry
+	for i := 0; i < 100; i++ {
+		err = app.db.View(func(tx *bolt.Tx) error {
+			var err error
+			entry, err = NewNodeEntryFromId(tx, node.Info.Id)
+			return err
+		})
+		tests.Assert(t, err == nil, "expected err == nil, got", err)
+		if len(entry.Devices) > 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	tests.Assert(t, len(entry.Devices) == 1,
+		"expected 1 device, got", len(entry.Devices))
+
+	// the new capabilities are kept
+	tests.Assert(t, entry.Capabilities.LvmJson, "expected lvm json")
+	tests.Assert(t, entry.Capabilities.LvmVersion == "2.02.180",
+		"unexpected lvm version", entry.Capabilities.LvmVersion)
+}
+
+func TestDeviceAddRefusesWithoutLvmJson(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	m, err := mockexec.NewMockExecutor()
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	pe := &probingExecutor{MockExecutor: m, err: errors.New("unreachable")}
+	app.executor = pe
+
+	// the node can not be probed
+	_, w := testDeviceAdd(t, app)
+	tests.Assert(t, w.Code == http.StatusBadRequest,
+		"expected", http.StatusBadRequest, "got", w.Code)
+
+	// the lvm tools still can not report in json
+	pe.caps = &executors.NodeCapabilities{LvmVersion: "2.02.100"}
+	pe.err = nil
+	_, w = testDeviceAdd(t, app)
+	tests.Assert(t, w.Code == http.StatusBadRequest,
+		"expected", http.StatusBadRequest, "got", w.Code)
+}
@@ -0,0 +1,134 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+var (
+	// time between the comments sent to keep idle streams open
+	eventKeepAlive = 30 * time.Second
+)
+
+// Events streams the events of the server as Server-Sent Events.
+// A client that sends the Last-Event-ID header, or the
+// last_event_id query parameter, is first sent the journaled events
+// after that event.
+func (a *App) Events(w http.ResponseWriter, r *http.Request) {
+	if a.events == nil {
+		utils.HttpError(w, "Events are not available with a read-only db",
+			http.StatusServiceUnavailable)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.HttpError(w, "Streaming is not supported",
+			http.StatusInternalServerError)
+		return
+	}
+
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("last_event_id")
+	}
+	var since uint64
+	if last != "" {
+		var err error
+		since, err = strconv.ParseUint(last, 10, 64)
+		if err != nil {
+			utils.HttpError(w, "invalid last event id: "+last,
+				http.StatusBadRequest)
+			return
+		}
+	}
+
+	// subscribe before reading the journal so no event is missed.
+	// Events in both are sent once.
+	ch := a.events.Subscribe()
+	defer a.events.Unsubscribe(ch)
+
+	var (
+		events []api.Event
+		lost   bool
+	)
+	if last != "" {
+		var err error
+		events, lost, err = a.events.Since(since)
+		if err != nil {
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	sent := since
+	if lost {
+		writeEvent(w, api.Event{Type: api.EventsLost})
+		// the id may be from another db with more events, and
+		// every event of the journal is newer than the ones sent
+		sent = 0
+	}
+	for _, e := range events {
+		writeEvent(w, e)
+		sent = e.Seq
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				// the client was too slow and can resume
+				// from the last event it got
+				return
+			}
+			if e.Seq <= sent {
+				continue
+			}
+			writeEvent(w, e)
+			sent = e.Seq
+			flusher.Flush()
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes an event in the Server-Sent Events format.
+// Events without a sequence number are written without an id.
+func writeEvent(w io.Writer, e api.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		logger.LogError("Unable to encode %v event: %v", e.Type, err)
+		return
+	}
+	if e.Seq != 0 {
+		fmt.Fprintf(w, "id: %v\n", e.Seq)
+	}
+	fmt.Fprintf(w, "event: %v\ndata: %s\n\n", e.Type, data)
+}
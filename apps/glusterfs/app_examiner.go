@@ -12,6 +12,8 @@ package glusterfs
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/heketi/heketi/pkg/utils"
 )
 
 // ExamineGluster ... Compares the state of heketi db with the state of Gluster
@@ -20,7 +22,7 @@ func (a *App) ExamineGluster(w http.ResponseWriter, r *http.Request) {
 
 	response, err := a.OnDemandExaminer().ExamineGluster()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
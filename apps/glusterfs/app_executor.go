@@ -0,0 +1,128 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/injectexec"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+// ExecutorConnections reports the state of the connections the
+// executor holds to the nodes. It exists to aid debugging.
+func (a *App) ExecutorConnections(w http.ResponseWriter, r *http.Request) {
+	stats := rex.PoolStats{}
+	if p, ok := a.executor.(executors.ConnectionPoolReporter); ok {
+		stats = p.ConnectionPoolStats()
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(stats); err != nil {
+		panic(err)
+	}
+}
+
+// ExecutorCircuits reports the nodes the executor failed to connect
+// to and whether it is still trying to connect to them.
+func (a *App) ExecutorCircuits(w http.ResponseWriter, r *http.Request) {
+	stats := rex.BreakerStats{}
+	if b, ok := a.executor.(executors.CircuitBreakerReporter); ok {
+		stats = b.CircuitBreakerStats()
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(stats); err != nil {
+		panic(err)
+	}
+}
+
+// CommandTrail reports the most recent commands run on the nodes.
+// The optional host and op query parameters limit the commands to
+// those run on a host or for an operation.
+func (a *App) CommandTrail(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	records := a.commandTrail.List(q.Get("host"), q.Get("op"))
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(records); err != nil {
+		panic(err)
+	}
+}
+
+// InjectScenarioRequest activates a fault scenario of the inject
+// executor, optionally starting the counters of its hooks over.
+type InjectScenarioRequest struct {
+	injectexec.ScenarioState
+	ResetCounters bool `json:"reset_counters"`
+}
+
+// injector returns the injector of the inject executor, or writes
+// an error if the server does not run one.
+func (a *App) injector(w http.ResponseWriter) *injectexec.Injector {
+	ie, ok := a.executor.(*injectexec.InjectExecutor)
+	if !ok {
+		utils.HttpError(w, "Executor does not inject faults",
+			http.StatusNotFound)
+		return nil
+	}
+	return ie.Injector()
+}
+
+// InjectState reports the active fault scenario of the inject
+// executor and how often each of its hooks matched and fired.
+func (a *App) InjectState(w http.ResponseWriter, r *http.Request) {
+	inj := a.injector(w)
+	if inj == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(inj.State()); err != nil {
+		panic(err)
+	}
+}
+
+// InjectSetScenario switches the fault scenario of the inject
+// executor. An empty scenario disables the hooks limited to one.
+func (a *App) InjectSetScenario(w http.ResponseWriter, r *http.Request) {
+	inj := a.injector(w)
+	if inj == nil {
+		return
+	}
+
+	var msg InjectScenarioRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		utils.HttpError(w,
+			fmt.Sprintf("request unable to be parsed: %s", err.Error()),
+			http.StatusBadRequest)
+		return
+	}
+	inj.SetScenario(msg.ScenarioState, msg.ResetCounters)
+
+	a.InjectState(w, r)
+}
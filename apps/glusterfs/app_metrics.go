@@ -0,0 +1,275 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/metrics"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+type opStatsKey struct {
+	label  string
+	result string
+}
+
+type opStatsValue struct {
+	count    uint64
+	duration time.Duration
+}
+
+// operationStats sums up the operations run by the server by the
+// kind of operation and its outcome. A nil operationStats is valid
+// and counts nothing.
+type operationStats struct {
+	lock  sync.Mutex
+	stats map[opStatsKey]*opStatsValue
+}
+
+func newOperationStats() *operationStats {
+	return &operationStats{
+		stats: map[opStatsKey]*opStatsValue{},
+	}
+}
+
+// Observe records that an operation with the given label ended with
+// err after running for d.
+func (s *operationStats) Observe(label string, err error, d time.Duration) {
+	if s == nil {
+		return
+	}
+	k := opStatsKey{label: label, result: "success"}
+	if err != nil {
+		k.result = "failure"
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, ok := s.stats[k]
+	if !ok {
+		v = &opStatsValue{}
+		s.stats[k] = v
+	}
+	v.count++
+	v.duration += d
+}
+
+func (s *operationStats) addTo(f *metrics.Family) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for k, v := range s.stats {
+		f.AddSummary(v.duration.Seconds(), v.count,
+			metrics.Label{Name: "type", Value: k.label},
+			metrics.Label{Name: "result", Value: k.result})
+	}
+}
+
+// MetricsReadableBy returns true if the metrics may be read with a
+// token of the given issuer. An empty issuer stands for no token.
+func (a *App) MetricsReadableBy(issuer string) bool {
+	switch a.conf.Metrics.Access {
+	case MetricsAccessPublic:
+		return true
+	case MetricsAccessUser:
+		return issuer != ""
+	}
+	return issuer == "admin"
+}
+
+// Metrics reports the state of the clusters and of the server in
+// the prometheus text format.
+func (a *App) Metrics(w http.ResponseWriter, r *http.Request) {
+	ms := metrics.NewSet()
+	ms.Gauge("heketi_up", "Is heketi running?").Add(1)
+
+	if err := a.topologyMetrics(ms); err != nil {
+		logger.LogError("Unable to collect topology metrics: %v", err)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := a.operationMetrics(ms); err != nil {
+		logger.LogError("Unable to collect operation metrics: %v", err)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.commandMetrics(ms)
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+	ms.WriteTo(w)
+}
+
+func (a *App) topologyMetrics(ms *metrics.Set) error {
+	topo, err := a.TopologyInfo()
+	if err != nil {
+		return err
+	}
+	var nodeUp map[string]bool
+	if a.nhealth != nil {
+		nodeUp = a.nhealth.Status()
+	}
+
+	clusterCount := ms.Gauge("heketi_cluster_count",
+		"Number of clusters")
+	nodeCount := ms.Gauge("heketi_nodes_count",
+		"Number of nodes on cluster")
+	nodeStates := ms.Gauge("heketi_nodes_state_count",
+		"Number of nodes on cluster by state")
+	nodeHealth := ms.Gauge("heketi_node_up",
+		"Node found healthy by the last health check")
+	deviceCount := ms.Gauge("heketi_device_count",
+		"Number of devices on host")
+	deviceStates := ms.Gauge("heketi_devices_state_count",
+		"Number of devices on cluster by state")
+	deviceSize := ms.Gauge("heketi_device_size",
+		"Total size of the device in bytes")
+	deviceFree := ms.Gauge("heketi_device_free",
+		"Amount of Free space available on the device in bytes")
+	deviceUsed := ms.Gauge("heketi_device_used",
+		"Amount of space used on the device in bytes")
+	brickCount := ms.Gauge("heketi_device_brick_count",
+		"Number of bricks on device")
+	volumeCount := ms.Gauge("heketi_volumes_count",
+		"Number of volumes on cluster")
+	volumeSizes := ms.Gauge("heketi_volumes_size_bytes",
+		"Total size of the volumes on cluster")
+	volumeSize := ms.Gauge("heketi_volume_size_bytes",
+		"Size of the volume")
+	blockCount := ms.Gauge("heketi_block_volumes_count",
+		"Number of block volumes on cluster")
+	blockSizes := ms.Gauge("heketi_block_volumes_size_bytes",
+		"Total size of the block volumes on cluster")
+	blockSize := ms.Gauge("heketi_block_volume_size_bytes",
+		"Size of the block volume")
+
+	clusterCount.Add(float64(len(topo.ClusterList)))
+	for _, c := range topo.ClusterList {
+		cluster := metrics.Label{Name: "cluster", Value: c.Id}
+
+		nodeCount.Add(float64(len(c.Nodes)), cluster)
+		nodesByState := map[api.EntryState]int{}
+		devicesByState := map[api.EntryState]int{}
+		for _, n := range c.Nodes {
+			nodesByState[n.State]++
+			hostname := metrics.Label{
+				Name: "hostname", Value: n.Hostnames.Manage[0]}
+			if up, ok := nodeUp[n.Id]; ok {
+				nodeHealth.Add(boolValue(up), cluster, hostname)
+			}
+
+			deviceCount.Add(float64(len(n.DevicesInfo)), cluster, hostname)
+			for _, d := range n.DevicesInfo {
+				devicesByState[d.State]++
+				device := metrics.Label{Name: "device", Value: d.Name}
+				deviceSize.Add(kbToBytes(d.Storage.Total), cluster, device, hostname)
+				deviceFree.Add(kbToBytes(d.Storage.Free), cluster, device, hostname)
+				deviceUsed.Add(kbToBytes(d.Storage.Used), cluster, device, hostname)
+				brickCount.Add(float64(len(d.Bricks)), cluster, device, hostname)
+			}
+		}
+		for state, count := range nodesByState {
+			nodeStates.Add(float64(count), cluster,
+				metrics.Label{Name: "state", Value: string(state)})
+		}
+		for state, count := range devicesByState {
+			deviceStates.Add(float64(count), cluster,
+				metrics.Label{Name: "state", Value: string(state)})
+		}
+
+		var total float64
+		for _, v := range c.Volumes {
+			size := gbToBytes(v.Size)
+			total += size
+			volumeSize.Add(size, cluster,
+				metrics.Label{Name: "name", Value: v.Name},
+				metrics.Label{Name: "volume", Value: v.Id})
+		}
+		volumeCount.Add(float64(len(c.Volumes)), cluster)
+		volumeSizes.Add(total, cluster)
+
+		total = 0
+		for _, bv := range c.BlockVolumes {
+			size := gbToBytes(bv.Size)
+			total += size
+			blockSize.Add(size, cluster,
+				metrics.Label{Name: "block_volume", Value: bv.Id},
+				metrics.Label{Name: "name", Value: bv.Name},
+				metrics.Label{Name: "volume", Value: bv.BlockHostingVolume})
+		}
+		blockCount.Add(float64(len(c.BlockVolumes)), cluster)
+		blockSizes.Add(total, cluster)
+	}
+	return nil
+}
+
+func (a *App) operationMetrics(ms *metrics.Set) error {
+	var counts map[OperationStatus]int
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		counts, err = PendingOperationStateCount(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	pending := ms.Gauge("heketi_pending_operations",
+		"Number of pending operations in the db by status")
+	pending.Add(float64(counts[NewOperation]),
+		metrics.Label{Name: "status", Value: "new"})
+	pending.Add(float64(counts[StaleOperation]),
+		metrics.Label{Name: "status", Value: "stale"})
+	pending.Add(float64(counts[FailedOperation]),
+		metrics.Label{Name: "status", Value: "failed"})
+
+	ms.Gauge("heketi_operations_in_flight",
+		"Number of operations being processed by the server").
+		Add(float64(a.optracker.Get()))
+	ms.Gauge("heketi_operations_stuck",
+		"Number of in-flight operations past their deadline").
+		Add(float64(len(a.optracker.Stuck())))
+	a.opstats.addTo(ms.Summary("heketi_operation_duration_seconds",
+		"Time taken by the operations by type and result"))
+	return nil
+}
+
+func (a *App) commandMetrics(ms *metrics.Set) {
+	duration := ms.Summary("heketi_remote_command_duration_seconds",
+		"Time taken by the commands run on the host")
+	errors := ms.Counter("heketi_remote_command_errors_total",
+		"Number of commands run on the host that failed")
+	for _, hs := range a.commandTrail.HostStats() {
+		host := metrics.Label{Name: "host", Value: hs.Host}
+		duration.AddSummary(hs.Duration.Seconds(), hs.Commands, host)
+		errors.Add(float64(hs.Errors), host)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func kbToBytes(kb uint64) float64 {
+	return float64(kb) * 1024
+}
+
+func gbToBytes(gb int) float64 {
+	return float64(gb) * 1024 * 1024 * 1024
+}
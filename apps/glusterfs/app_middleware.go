@@ -18,6 +18,7 @@ import (
 	"github.com/urfave/negroni"
 
 	"github.com/heketi/heketi/middleware"
+	"github.com/heketi/heketi/pkg/utils"
 )
 
 // Authorization function
@@ -31,8 +32,9 @@ func (a *App) Auth(w http.ResponseWriter, r *http.Request, next http.HandlerFunc
 	claims := token.Claims.(*middleware.HeketiJwtClaims)
 
 	// Check access
-	if "user" == claims.Issuer && r.URL.Path != "/volumes" {
-		http.Error(w, "Administrator access required", http.StatusUnauthorized)
+	if "user" == claims.Issuer && r.URL.Path != "/volumes" &&
+		!(r.URL.Path == "/metrics" && a.MetricsReadableBy(claims.Issuer)) {
+		utils.HttpError(w, "Administrator access required", http.StatusUnauthorized)
 		return
 	}
 
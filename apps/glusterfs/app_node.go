@@ -15,6 +15,7 @@ import (
 
 	"github.com/boltdb/bolt"
 	"github.com/gorilla/mux"
+	"github.com/heketi/heketi/executors"
 	wdb "github.com/heketi/heketi/pkg/db"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/heketi/pkg/utils"
@@ -25,24 +26,24 @@ func (a *App) NodeAdd(w http.ResponseWriter, r *http.Request) {
 
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
 
 	// Check information in JSON request
 	if len(msg.Hostnames.Manage) == 0 {
-		http.Error(w, "Manage hostname missing", http.StatusBadRequest)
+		utils.HttpError(w, "Manage hostname missing", http.StatusBadRequest)
 		return
 	}
 	if len(msg.Hostnames.Storage) == 0 {
-		http.Error(w, "Storage hostname missing", http.StatusBadRequest)
+		utils.HttpError(w, "Storage hostname missing", http.StatusBadRequest)
 		return
 	}
 
@@ -50,14 +51,14 @@ func (a *App) NodeAdd(w http.ResponseWriter, r *http.Request) {
 	// if it is because it was set to zero, or it is the default
 	// value used for missing 'zone' in JSON
 	if msg.Zone == 0 {
-		http.Error(w, "Zone cannot be zero or value is missing", http.StatusBadRequest)
+		utils.HttpError(w, "Zone cannot be zero or value is missing", http.StatusBadRequest)
 		return
 	}
 
 	// Check for correct values
 	for _, name := range append(msg.Hostnames.Manage, msg.Hostnames.Storage...) {
 		if name == "" {
-			http.Error(w, "Hostname cannot be an empty string", http.StatusBadRequest)
+			utils.HttpError(w, "Hostname cannot be an empty string", http.StatusBadRequest)
 			return
 		}
 	}
@@ -65,24 +66,23 @@ func (a *App) NodeAdd(w http.ResponseWriter, r *http.Request) {
 	// Create a node entry
 	node := NewNodeEntryFromRequest(&msg)
 
-	// Get cluster and peer node hostname
+	// Get cluster and register the node
 	var cluster *ClusterEntry
-	var peer_node_hostname string
 	err = a.db.Update(func(tx *bolt.Tx) error {
 		var err error
 		cluster, err = NewClusterEntryFromId(tx, msg.ClusterId)
 		if err == ErrNotFound {
-			http.Error(w, "Cluster id does not exist", http.StatusNotFound)
+			utils.HttpError(w, "Cluster id does not exist", http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
 		// Register node
 		err = node.Register(tx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusConflict)
+			utils.HttpError(w, err.Error(), http.StatusConflict)
 			return err
 		}
 		return nil
@@ -91,87 +91,157 @@ func (a *App) NodeAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Connect to the new node and find a peer for it
+	peer_node_hostname, err := prepareNodeAdd(a.db, a.executor,
+		executors.WithContext(a.executor, r.Context()), node, cluster)
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Add node
+	logger.Info("Adding node %v", node.ManageHostName())
+	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
+		err := finishNodeAdd(a.db, a.executor, a.requestExecutor(r),
+			node, peer_node_hostname)
+		if err != nil {
+			return "", err
+		}
+		return "/nodes/" + node.Info.Id, nil
+	})
+}
+
+// prepareNodeAdd connects to a registered node that is being added,
+// pins its host key before running any command on it and checks
+// that glusterd is running. It returns the manage hostname of the
+// node of the cluster to probe the new node from, which is empty for
+// the first node of a cluster. The connection settings and host keys
+// are kept by hosts. If a step fails the node is deregistered.
+func prepareNodeAdd(db wdb.DB, hosts, executor executors.Executor,
+	node *NodeEntry, cluster *ClusterEntry) (peer string, e error) {
+
+	defer func() {
+		if e != nil {
+			forgetNode(db, hosts, node)
+		}
+	}()
+
+	// Connect to the new node with its own settings, if any
+	if setter, ok := hosts.(executors.HostConnectionSetter); ok {
+		err := setter.SetHostConnection(node.ManageHostName(),
+			node.HostConnection(cluster))
+		if err != nil {
+			return "", logger.Err(err)
+		}
+	}
+
+	// Record the host key of the new node before running anything
+	// on it. Later connections to the node must present the same key.
+	if pinner, ok := hosts.(executors.HostKeyPinner); ok {
+		var err error
+		node.HostKey, err = pinner.ScanHostKey(node.ManageHostName())
+		if err != nil {
+			logger.Err(err)
+			return "", logger.LogError("Unable to get host key of new node: %v", err)
+		}
+		err = pinner.PinHostKey(node.ManageHostName(), node.HostKey)
+		if err != nil {
+			logger.Err(err)
+			return "", logger.LogError("Unable to pin host key of new node: %v", err)
+		}
+	}
+
 	// Get a node's hostname in the cluster to execute the Gluster peer command
 	// only if there is more than one node
 	if len(cluster.Info.Nodes) > 0 {
-		peer_node_hostname, err = GetVerifiedManageHostname(a.db, a.executor, cluster.Info.Id)
+		var err error
+		peer, err = GetVerifiedManageHostname(db, executor, cluster.Info.Id)
 		if err != nil {
 			logger.Err(err)
-			err := logger.LogError("None of the nodes in cluster has glusterd running")
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			return "", logger.LogError("None of the nodes in cluster has glusterd running")
 		}
 	} else {
-		err := a.executor.GlusterdCheck(node.ManageHostName())
+		err := executor.GlusterdCheck(node.ManageHostName())
 		if err != nil {
 			logger.Err(err)
-			err := logger.LogError("New Node doesn't have glusterd running")
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			return "", logger.LogError("New Node doesn't have glusterd running")
 		}
 	}
 
-	// Add node
-	logger.Info("Adding node %v", node.ManageHostName())
-	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (seeother string, e error) {
+	return peer, nil
+}
 
-		// Cleanup in case of failure
-		defer func() {
-			if e != nil {
-				a.db.Update(func(tx *bolt.Tx) error {
-					node.Deregister(tx)
-					return nil
-				})
-			}
-		}()
+// finishNodeAdd probes a node prepared by prepareNodeAdd into the
+// trusted pool and adds it to its cluster. If a step fails the node
+// is deregistered.
+func finishNodeAdd(db wdb.DB, hosts, executor executors.Executor,
+	node *NodeEntry, peer string) (e error) {
 
-		// Peer probe if there is at least one other node
-		// TODO: What happens if the peer_node is not responding.. we need to choose another.
-		// It will only choose the working one now. Hence done.
-		if peer_node_hostname != "" {
-			err := a.executor.PeerProbe(peer_node_hostname, node.StorageHostName())
-			if err != nil {
-				return "", err
-			}
+	// Cleanup in case of failure
+	defer func() {
+		if e != nil {
+			forgetNode(db, hosts, node)
 		}
+	}()
 
-		// Add node entry into the db
-		err = a.db.Update(func(tx *bolt.Tx) error {
-			cluster, err := NewClusterEntryFromId(tx, msg.ClusterId)
-			if err == ErrNotFound {
-				http.Error(w, "Cluster id does not exist", http.StatusNotFound)
-				return err
-			} else if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return err
-			}
-
-			// Add node to cluster
-			cluster.NodeAdd(node.Info.Id)
+	// Peer probe if there is at least one other node
+	// TODO: What happens if the peer_node is not responding.. we need to choose another.
+	// It will only choose the working one now. Hence done.
+	if peer != "" {
+		err := executor.PeerProbe(peer, node.StorageHostName())
+		if err != nil {
+			return err
+		}
+	}
 
-			// Save cluster
-			err = cluster.Save(tx)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return err
-			}
+	// Find out what the node can do
+	if err := node.probeCapabilities(executor); err != nil {
+		logger.LogError("Unable to probe capabilities of node %v: %v",
+			node.Info.Id, err)
+	}
 
-			// Save node
-			err = node.Save(tx)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return err
-			}
+	// Add node entry into the db
+	err := db.Update(func(tx *bolt.Tx) error {
+		cluster, err := NewClusterEntryFromId(tx, node.Info.ClusterId)
+		if err != nil {
+			return err
+		}
 
-			return nil
+		// Add node to cluster
+		cluster.NodeAdd(node.Info.Id)
 
-		})
+		// Save cluster
+		err = cluster.Save(tx)
 		if err != nil {
-			return "", err
+			return err
 		}
-		logger.Info("Added node " + node.Info.Id)
-		return "/nodes/" + node.Info.Id, nil
+
+		// Save node
+		return node.Save(tx)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("Added node " + node.Info.Id)
+	return nil
+}
+
+// forgetNode deregisters a node that could not be added and drops
+// its host key and connection settings.
+func forgetNode(db wdb.DB, hosts executors.Executor, node *NodeEntry) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		return node.Deregister(tx)
 	})
+	if err != nil {
+		logger.LogError("Unable to deregister node %v: %v",
+			node.Info.Id, err)
+	}
+	if pinner, ok := hosts.(executors.HostKeyPinner); ok {
+		pinner.PinHostKey(node.ManageHostName(), "")
+	}
+	if setter, ok := hosts.(executors.HostConnectionSetter); ok {
+		setter.SetHostConnection(node.ManageHostName(), nil)
+	}
 }
 
 func (a *App) NodeInfo(w http.ResponseWriter, r *http.Request) {
@@ -185,16 +255,16 @@ func (a *App) NodeInfo(w http.ResponseWriter, r *http.Request) {
 	err := a.db.View(func(tx *bolt.Tx) error {
 		entry, err := NewNodeEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, "Id not found", http.StatusNotFound)
+			utils.HttpError(w, "Id not found", http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
 		info, err = entry.NewInfoReponse(tx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -213,63 +283,85 @@ func (a *App) NodeInfo(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// NodeResync probes a node again for the versions and features of
+// its software, for example after the node was upgraded.
+func (a *App) NodeResync(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var node *NodeEntry
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		node, err = NewNodeEntryFromId(tx, id)
+		return err
+	})
+	if err == ErrNotFound {
+		utils.HttpError(w, "Id not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
+		logger.Err(err)
+		return
+	}
+
+	if _, ok := a.executor.(executors.CapabilityProber); !ok {
+		utils.HttpError(w, "Executor can not probe nodes", http.StatusBadRequest)
+		return
+	}
+
+	logger.Info("Checking for node %v changes", id)
+
+	// Probe the node in background
+	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
+		if err := node.probeCapabilities(a.requestExecutor(r)); err != nil {
+			return "", err
+		}
+
+		if err := node.saveCapabilities(a.db); err != nil {
+			return "", err
+		}
+
+		logger.Info("Updated node %v", id)
+		return "", nil
+	})
+}
+
 func (a *App) NodeDelete(w http.ResponseWriter, r *http.Request) {
 	// Get the id from the URL
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	// Get node info
-	var (
-		peer_node, node *NodeEntry
-		cluster         *ClusterEntry
-	)
+	var node *NodeEntry
 	err := a.db.View(func(tx *bolt.Tx) error {
 
 		// Access node entry
 		var err error
 		node, err = NewNodeEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return logger.Err(err)
 		}
 
 		// Check the node can be deleted
 		if !node.IsDeleteOk() {
-			http.Error(w, node.ConflictString(), http.StatusConflict)
+			utils.HttpError(w, node.ConflictString(), http.StatusConflict)
 			logger.LogError(node.ConflictString())
 			return ErrConflict
 		}
 
-		// Access cluster information and peer node
-		cluster, err = NewClusterEntryFromId(tx, node.Info.ClusterId)
+		// Check the cluster of the node
+		_, err = NewClusterEntryFromId(tx, node.Info.ClusterId)
 		if err == ErrNotFound {
-			http.Error(w, "Cluster id does not exist", http.StatusNotFound)
+			utils.HttpError(w, "Cluster id does not exist", http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return logger.Err(err)
 		}
-
-		// Get a node in the cluster to execute the Gluster peer command
-		// If it only has one in the list, then there is no need to do a
-		// peer detach.
-		if len(cluster.Info.Nodes) > 1 {
-			for index := range cluster.Info.Nodes {
-				peer_node, err = cluster.NodeEntryFromClusterIndex(tx, index)
-				if err != nil {
-					return logger.Err(err)
-				}
-
-				// Cannot peer detach from the same node, we need to execute
-				// the command from another node
-				if peer_node.Info.Id != node.Info.Id {
-					break
-				}
-			}
-		}
 		return nil
 	})
 	if err != nil {
@@ -279,64 +371,108 @@ func (a *App) NodeDelete(w http.ResponseWriter, r *http.Request) {
 	// Delete node asynchronously
 	logger.Info("Deleting node %v [%v]", node.ManageHostName(), node.Info.Id)
 	a.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
+		return "", deleteNode(a.db, a.executor, a.requestExecutor(r), node)
+	})
+}
 
-		// Remove from trusted pool
-		if peer_node != nil {
-			err := a.executor.PeerDetach(peer_node.ManageHostName(), node.StorageHostName())
+// deleteNode detaches a node without devices from the trusted pool of
+// its cluster and removes it from the db. The connection settings and
+// host key of the node are dropped from hosts.
+func deleteNode(db wdb.DB, hosts, executor executors.Executor,
+	node *NodeEntry) error {
+
+	// Get a node in the cluster to execute the Gluster peer command
+	// If it only has one in the list, then there is no need to do a
+	// peer detach.
+	var peer string
+	err := db.View(func(tx *bolt.Tx) error {
+		cluster, err := NewClusterEntryFromId(tx, node.Info.ClusterId)
+		if err != nil {
+			return logger.Err(err)
+		}
+		for index := range cluster.Info.Nodes {
+			peer_node, err := cluster.NodeEntryFromClusterIndex(tx, index)
 			if err != nil {
-				return "", err
+				return logger.Err(err)
 			}
-		}
 
-		// Remove from db
-		err = a.db.Update(func(tx *bolt.Tx) error {
-			// Get Cluster
-			cluster, err := NewClusterEntryFromId(tx, node.Info.ClusterId)
-			if err == ErrNotFound {
-				logger.Critical("Cluster id %v is expected be in db. Pointed to by node %v",
-					node.Info.ClusterId,
-					node.Info.Id)
-				return err
-			} else if err != nil {
-				logger.Err(err)
-				return err
+			// Cannot peer detach from the same node, we need to execute
+			// the command from another node
+			if peer_node.Info.Id != node.Info.Id {
+				peer = peer_node.ManageHostName()
+				break
 			}
-			cluster.NodeDelete(node.Info.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-			// Save cluster
-			err = cluster.Save(tx)
-			if err != nil {
-				logger.Err(err)
-				return err
-			}
+	// Remove from trusted pool
+	if peer != "" {
+		err := executor.PeerDetach(peer, node.StorageHostName())
+		if err != nil {
+			return err
+		}
+	}
 
-			// Remove hostnames
-			node.Deregister(tx)
+	// Remove from db
+	err = db.Update(func(tx *bolt.Tx) error {
+		// Get Cluster
+		cluster, err := NewClusterEntryFromId(tx, node.Info.ClusterId)
+		if err == ErrNotFound {
+			logger.Critical("Cluster id %v is expected be in db. Pointed to by node %v",
+				node.Info.ClusterId,
+				node.Info.Id)
+			return err
+		} else if err != nil {
+			logger.Err(err)
+			return err
+		}
+		cluster.NodeDelete(node.Info.Id)
 
-			// Delete node from db
-			err = node.Delete(tx)
-			if err != nil {
-				logger.Err(err)
-				return err
-			}
+		// Save cluster
+		err = cluster.Save(tx)
+		if err != nil {
+			logger.Err(err)
+			return err
+		}
 
-			err = refreshVolumeNodes(tx, node)
-			if err != nil {
-				logger.Err(err)
-				return err
-			}
-			return nil
+		// Remove hostnames
+		err = node.Deregister(tx)
+		if err != nil {
+			logger.Err(err)
+			return err
+		}
 
-		})
+		// Delete node from db
+		err = node.Delete(tx)
 		if err != nil {
-			return "", err
+			logger.Err(err)
+			return err
 		}
-		// Show that the key has been deleted
-		logger.Info("Deleted node [%s]", id)
 
-		return "", nil
+		err = refreshVolumeNodes(tx, node)
+		if err != nil {
+			logger.Err(err)
+			return err
+		}
+		return nil
 
 	})
+	if err != nil {
+		return err
+	}
+	if pinner, ok := hosts.(executors.HostKeyPinner); ok {
+		pinner.PinHostKey(node.ManageHostName(), "")
+	}
+	if setter, ok := hosts.(executors.HostConnectionSetter); ok {
+		setter.SetHostConnection(node.ManageHostName(), nil)
+	}
+	// Show that the key has been deleted
+	logger.Info("Deleted node [%s]", node.Info.Id)
+	return nil
 }
 
 func refreshVolumeNodes(tx *bolt.Tx, node *NodeEntry) error {
@@ -400,12 +536,12 @@ func (a *App) NodeSetState(w http.ResponseWriter, r *http.Request) {
 	var msg api.StateRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
@@ -414,10 +550,10 @@ func (a *App) NodeSetState(w http.ResponseWriter, r *http.Request) {
 	err = a.db.View(func(tx *bolt.Tx) error {
 		node, err = NewNodeEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, "Id not found", http.StatusNotFound)
+			utils.HttpError(w, "Id not found", http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -448,7 +584,7 @@ func (a *App) NodeSetState(w http.ResponseWriter, r *http.Request) {
 				a.optracker.Remove(token)
 			}
 		}()
-		err = node.SetState(a.db, a.executor, msg.State)
+		err = node.SetState(a.db, a.runner(), msg.State)
 		if err != nil {
 			return "", err
 		}
@@ -468,13 +604,13 @@ func (a *App) NodeSetTags(w http.ResponseWriter, r *http.Request) {
 	var msg api.TagsChangeRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
@@ -482,15 +618,15 @@ func (a *App) NodeSetTags(w http.ResponseWriter, r *http.Request) {
 	err = a.db.Update(func(tx *bolt.Tx) error {
 		node, err = NewNodeEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, "Id not found", http.StatusNotFound)
+			utils.HttpError(w, "Id not found", http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 		ApplyTags(node, msg)
 		if err := node.Save(tx); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 		return nil
@@ -505,3 +641,84 @@ func (a *App) NodeSetTags(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 }
+
+// NodeSetHostKey re-pins the ssh host key of a node, for example
+// after the node has been reinstalled.
+func (a *App) NodeSetHostKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var msg api.NodeHostKeyRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		utils.HttpError(w, "request unable to be parsed", 422)
+		return
+	}
+
+	pinner, ok := a.executor.(executors.HostKeyPinner)
+	if !ok {
+		utils.HttpError(w, "Executor does not support host keys",
+			http.StatusBadRequest)
+		return
+	}
+
+	var node *NodeEntry
+	err = a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		node, err = NewNodeEntryFromId(tx, id)
+		return err
+	})
+	if err == ErrNotFound {
+		utils.HttpError(w, "Id not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	host := node.ManageHostName()
+	key := msg.Key
+	if key == "" {
+		// trust the key the node presents now. The old key stays
+		// pinned until the new key is known
+		key, err = pinner.ScanHostKey(host)
+		if err != nil {
+			err := logger.LogError("Unable to get host key of node %v: %v",
+				id, err)
+			utils.HttpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	err = pinner.PinHostKey(host, key)
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var info *api.NodeInfoResponse
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		node, err := NewNodeEntryFromId(tx, id)
+		if err != nil {
+			return err
+		}
+		node.HostKey = key
+		if err := node.Save(tx); err != nil {
+			return err
+		}
+		info, err = node.NewInfoReponse(tx)
+		return err
+	})
+	if err != nil {
+		pinner.PinHostKey(host, node.HostKey)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Pinned host key %v for node %v",
+		info.HostKeyFingerprint, id)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,105 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/mockexec"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+// pinningExecutor records the calls made to a node that identifies
+// itself by a host key.
+type pinningExecutor struct {
+	*mockexec.MockExecutor
+	calls []string
+	keys  map[string]string
+}
+
+func newPinningExecutor(t *testing.T) *pinningExecutor {
+	m, err := mockexec.NewMockExecutor()
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	pe := &pinningExecutor{MockExecutor: m, keys: map[string]string{}}
+	m.MockGlusterdCheck = func(host string) error {
+		pe.calls = append(pe.calls, "check "+host)
+		return nil
+	}
+	return pe
+}
+
+func (pe *pinningExecutor) ScanHostKey(host string) (string, error) {
+	pe.calls = append(pe.calls, "scan "+host)
+	return "key-" + host, nil
+}
+
+func (pe *pinningExecutor) PinHostKey(host, key string) error {
+	pe.calls = append(pe.calls, "pin "+host+" "+key)
+	pe.keys[host] = key
+	return nil
+}
+
+func (pe *pinningExecutor) SetHostConnection(
+	host string, hc *executors.HostConnection) error {
+
+	pe.calls = append(pe.calls, "connect "+host)
+	return nil
+}
+
+func TestPrepareNodeAddPinsBeforeCommands(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	pe := newPinningExecutor(t)
+	cluster := NewClusterEntry()
+	node := NewNodeEntryFromRequest(&api.NodeAddRequest{
+		Zone:      1,
+		Hostnames: api.HostAddresses{Manage: []string{"m1"}, Storage: []string{"s1"}},
+		ClusterId: cluster.Info.Id,
+	})
+
+	peer, err := prepareNodeAdd(app.db, pe, pe, node, cluster)
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, peer == "", "expected no peer, got", peer)
+	expected := "connect m1,scan m1,pin m1 key-m1,check m1"
+	tests.Assert(t, strings.Join(pe.calls, ",") == expected,
+		"expected", expected, "got", pe.calls)
+	tests.Assert(t, node.HostKey == "key-m1", "unexpected key", node.HostKey)
+}
+
+func TestPrepareNodeAddFailureUnpins(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	pe := newPinningExecutor(t)
+	pe.MockGlusterdCheck = func(host string) error {
+		return errors.New("glusterd is down")
+	}
+	cluster := NewClusterEntry()
+	node := NewNodeEntryFromRequest(&api.NodeAddRequest{
+		Zone:      1,
+		Hostnames: api.HostAddresses{Manage: []string{"m1"}, Storage: []string{"s1"}},
+		ClusterId: cluster.Info.Id,
+	})
+
+	_, err := prepareNodeAdd(app.db, pe, pe, node, cluster)
+	tests.Assert(t, err != nil, "expected err != nil")
+	key, ok := pe.keys["m1"]
+	tests.Assert(t, ok && key == "", "expected the key to be unpinned, got", key)
+}
@@ -44,6 +44,7 @@ func (a *App) AppOperationsInfo() (*api.OperationsInfo, error) {
 	}
 
 	info.InFlight = a.optracker.Get()
+	info.Stuck = uint64(len(a.optracker.Stuck()))
 
 	return info, nil
 }
@@ -67,11 +68,12 @@ func (a *App) OperationsInfo(w http.ResponseWriter, r *http.Request) {
 		return nil
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	info.InFlight = a.optracker.Get()
+	info.Stuck = uint64(len(a.optracker.Stuck()))
 
 	// Write msg
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -84,6 +86,7 @@ func (a *App) OperationsInfo(w http.ResponseWriter, r *http.Request) {
 func (a *App) PendingOperationList(w http.ResponseWriter, r *http.Request) {
 	p := &api.PendingOperationListResponse{}
 	tracked := a.optracker.Tracked()
+	stuck := a.optracker.Stuck()
 
 	err := a.db.View(func(tx *bolt.Tx) error {
 		ops, err := PendingOperationList(tx)
@@ -97,14 +100,16 @@ func (a *App) PendingOperationList(w http.ResponseWriter, r *http.Request) {
 				return err
 			}
 			p.PendingOperations[i] = pop.ToInfo()
-			if tracked[pop.Id] {
+			if stuck[pop.Id] {
+				p.PendingOperations[i].SubStatus = "stuck"
+			} else if tracked[pop.Id] {
 				p.PendingOperations[i].SubStatus = "in-flight"
 			}
 		}
 		return nil
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -139,10 +144,10 @@ func (a *App) PendingOperationDetails(w http.ResponseWriter, r *http.Request) {
 		return nil
 	})
 	if err == ErrNotFound {
-		http.Error(w, fmt.Sprintf("Id not found: %v", pid), http.StatusNotFound)
+		utils.HttpError(w, fmt.Sprintf("Id not found: %v", pid), http.StatusNotFound)
 		return
 	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -160,12 +165,12 @@ func (a *App) PendingOperationCleanUp(w http.ResponseWriter, r *http.Request) {
 	var msg api.PendingOperationsCleanRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
@@ -0,0 +1,60 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/heketi/heketi/executors/recordexec"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/idgen"
+	"github.com/heketi/tests"
+)
+
+func TestDeterministicIdsRequireEmptyDB(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	recfile := tests.Tempfile()
+	defer os.Remove(recfile)
+	randomness := idgen.Randomness
+	defer func() {
+		idgen.Randomness = randomness
+	}()
+
+	config := &GlusterFSConfig{
+		DBfile:                tmpfile,
+		Executor:              "record/local",
+		MaxInflightOperations: 64,
+		RecordConfig: recordexec.RecordConfig{
+			File:             recfile,
+			DeterministicIds: true,
+		},
+	}
+
+	// an empty db is accepted
+	app, err := NewApp(config)
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, app.recording != nil, "expected a recording")
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		return NewClusterEntryFromRequest(&api.ClusterCreateRequest{}).Save(tx)
+	})
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	app.Close()
+
+	// the ids generated now could clash with the saved cluster
+	idgen.Randomness = randomness
+	app, err = NewApp(config)
+	tests.Assert(t, err != nil, "expected err != nil")
+	tests.Assert(t, idgen.Randomness == randomness,
+		"expected ids to stay random")
+}
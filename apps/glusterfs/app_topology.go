@@ -10,11 +10,26 @@
 package glusterfs
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
 	"github.com/boltdb/bolt"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
 )
 
 func (a *App) TopologyInfo() (*api.TopologyInfoResponse, error) {
+	return a.FilteredTopologyInfo(api.TopologyInfoOptions{})
+}
+
+// FilteredTopologyInfo returns the parts of the topology selected by
+// opts. The topology is read in a single db transaction.
+func (a *App) FilteredTopologyInfo(
+	opts api.TopologyInfoOptions) (*api.TopologyInfoResponse, error) {
+
 	topo := &api.TopologyInfoResponse{
 		ClusterList: make([]api.Cluster, 0),
 	}
@@ -24,6 +39,23 @@ func (a *App) TopologyInfo() (*api.TopologyInfoResponse, error) {
 		if err != nil {
 			return err
 		}
+		if opts.Cluster != "" {
+			if _, err := NewClusterEntryFromId(tx, opts.Cluster); err != nil {
+				return err
+			}
+			clusters = []string{opts.Cluster}
+		}
+		if opts.Node != "" {
+			node, err := NewNodeEntryFromId(tx, opts.Node)
+			if err != nil {
+				return err
+			}
+			if opts.Cluster != "" && opts.Cluster != node.Info.ClusterId {
+				// the node is not part of the cluster
+				return nil
+			}
+			clusters = []string{node.Info.ClusterId}
+		}
 
 		for _, cluster := range clusters {
 			clusterInfo, err := clusterInfo(tx, cluster)
@@ -42,25 +74,52 @@ func (a *App) TopologyInfo() (*api.TopologyInfoResponse, error) {
 			}
 			cluster.Id = clusterInfo.Id
 
+			// with a node filter only the volumes, and their block
+			// volumes, with bricks on the node are reported
+			volumes := map[string]bool{}
 			for _, volume := range clusterInfo.Volumes {
+				if opts.NoVolumes {
+					break
+				}
 				volumeInfo, err := volumeInfo(tx, volume)
 				if err != nil {
 					return err
 				}
+				if opts.Node != "" && !hasBrickOnNode(volumeInfo, opts.Node) {
+					continue
+				}
+				if opts.NoBricks {
+					volumeInfo.Bricks = []api.BrickInfo{}
+				}
+				volumes[volumeInfo.Id] = true
 				cluster.Volumes = append(cluster.Volumes, *volumeInfo)
 			}
 			for _, blockVol := range clusterInfo.BlockVolumes {
+				if opts.NoVolumes {
+					break
+				}
 				blkVolumeInfo, err := blockVolumeInfo(tx, blockVol)
 				if err != nil {
 					return err
 				}
+				if opts.Node != "" && !volumes[blkVolumeInfo.BlockHostingVolume] {
+					continue
+				}
 				cluster.BlockVolumes = append(cluster.BlockVolumes, *blkVolumeInfo)
 			}
 			for _, node := range clusterInfo.Nodes {
+				if opts.Node != "" && node != opts.Node {
+					continue
+				}
 				nodei, err := nodeInfo(tx, string(node))
 				if err != nil {
 					return err
 				}
+				if opts.NoBricks {
+					for i := range nodei.DevicesInfo {
+						nodei.DevicesInfo[i].Bricks = []api.BrickInfo{}
+					}
+				}
 				cluster.Nodes = append(cluster.Nodes, *nodei)
 			}
 			topo.ClusterList = append(topo.ClusterList, cluster)
@@ -71,6 +130,202 @@ func (a *App) TopologyInfo() (*api.TopologyInfoResponse, error) {
 	return topo, err
 }
 
+func hasBrickOnNode(v *api.VolumeInfoResponse, nodeId string) bool {
+	for _, b := range v.Bricks {
+		if b.NodeId == nodeId {
+			return true
+		}
+	}
+	return false
+}
+
+// Topology reports the topology of the clusters. The optional
+// cluster and node query parameters limit the topology to a cluster
+// or a node and the volumes and bricks parameters set to false omit
+// the volumes or the lists of bricks.
+func (a *App) Topology(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := api.TopologyInfoOptions{
+		Cluster: q.Get("cluster"),
+		Node:    q.Get("node"),
+	}
+	volumes, err := queryBool(q, "volumes", true)
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bricks, err := queryBool(q, "bricks", true)
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.NoVolumes = !volumes
+	opts.NoBricks = !bricks
+
+	topo, err := a.FilteredTopologyInfo(opts)
+	if err == ErrNotFound {
+		utils.HttpError(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(topo); err != nil {
+		panic(err)
+	}
+}
+
+// TopologyApply makes the topology of the server match the requested
+// topology. With the dry_run query parameter the changes that would
+// be made are returned instead.
+func (a *App) TopologyApply(w http.ResponseWriter, r *http.Request) {
+	var msg api.TopologyApplyRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		utils.HttpError(w, "request unable to be parsed", 422)
+		return
+	}
+	err = msg.Validate()
+	if err != nil {
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		logger.LogError("validation failed: " + err.Error())
+		return
+	}
+
+	if isDryRun(r) {
+		var diff *api.TopologyDiff
+		err := a.db.View(func(tx *bolt.Tx) error {
+			var err error
+			diff, err = topologyDiff(tx, &msg)
+			return err
+		})
+		if err != nil {
+			utils.HttpError(w, err.Error(), http.StatusBadRequest)
+			logger.LogError(err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(diff); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	op := NewTopologyApplyOperation(&msg, a.db, a.executor)
+	op.retries = a.retries
+	if err := AsyncHttpOperation(a, w, r, op); err != nil {
+		OperationHttpErrorf(w, err, "Failed to apply topology: %v", err)
+		return
+	}
+}
+
+// TopologyExport reports the topology in the format used by
+// heketi-cli topology load. The flags query parameter adds the
+// file and block flags of the clusters.
+func (a *App) TopologyExport(w http.ResponseWriter, r *http.Request) {
+	flags, err := queryBool(r.URL.Query(), "flags", false)
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var topo *api.TopologyFile
+	err = a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		topo, err = topologyExport(tx, flags)
+		return err
+	})
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(topo); err != nil {
+		panic(err)
+	}
+}
+
+// topologyExport returns the clusters, nodes and devices in the db
+// as a topology file. Failed nodes and devices are left out as they
+// are on their way out of the topology.
+func topologyExport(tx *bolt.Tx, flags bool) (*api.TopologyFile, error) {
+	topo := &api.TopologyFile{Clusters: []api.TopologyFileCluster{}}
+
+	clusters, err := ClusterList(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range clusters {
+		cluster, err := NewClusterEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		c := api.TopologyFileCluster{Nodes: []api.TopologyFileNode{}}
+		if flags {
+			block, file := cluster.Info.Block, cluster.Info.File
+			c.Block = &block
+			c.File = &file
+		}
+
+		for _, nodeId := range cluster.Info.Nodes {
+			node, err := NewNodeEntryFromId(tx, nodeId)
+			if err != nil {
+				return nil, err
+			}
+			if node.State == api.EntryStateFailed {
+				continue
+			}
+			n := api.TopologyFileNode{
+				Node: api.NodeAddRequest{
+					Zone:       node.Info.Zone,
+					Hostnames:  node.Info.Hostnames,
+					Tags:       node.Info.Tags,
+					Connection: node.Info.Connection,
+				},
+				Devices: []*api.TopologyFileDevice{},
+			}
+			for _, deviceId := range node.Devices {
+				device, err := NewDeviceEntryFromId(tx, deviceId)
+				if err != nil {
+					return nil, err
+				}
+				if device.State == api.EntryStateFailed {
+					continue
+				}
+				n.Devices = append(n.Devices, &api.TopologyFileDevice{
+					Device: api.Device{
+						Name: device.Info.Name,
+						Tags: device.Info.Tags,
+					},
+				})
+			}
+			c.Nodes = append(c.Nodes, n)
+		}
+		topo.Clusters = append(topo.Clusters, c)
+	}
+	return topo, nil
+}
+
+// queryBool returns the boolean value of the query parameter name,
+// or def if the parameter is not set.
+func queryBool(q url.Values, name string, def bool) (bool, error) {
+	v := q.Get(name)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid value for %v: %v", name, v)
+	}
+	return b, nil
+}
+
 func clusterInfo(tx *bolt.Tx, id string) (*api.ClusterInfoResponse, error) {
 	var info *api.ClusterInfoResponse
 	entry, err := NewClusterEntryFromId(tx, id)
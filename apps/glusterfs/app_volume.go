@@ -32,76 +32,22 @@ func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 	var msg api.VolumeCreateRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
 
-	switch {
-	case msg.Gid < 0:
-		http.Error(w, "Bad group id less than zero", http.StatusBadRequest)
-		logger.LogError("Bad group id less than zero")
-		return
-	case msg.Gid >= math.MaxInt32:
-		http.Error(w, "Bad group id equal or greater than 2**32", http.StatusBadRequest)
-		logger.LogError("Bad group id equal or greater than 2**32")
-		return
-	}
-
-	switch msg.Durability.Type {
-	case api.DurabilityEC:
-	case api.DurabilityReplicate:
-	case api.DurabilityDistributeOnly:
-	case "":
-		msg.Durability.Type = api.DurabilityDistributeOnly
-	default:
-		http.Error(w, "Unknown durability type", http.StatusBadRequest)
-		logger.LogError("Unknown durability type")
-		return
-	}
-
-	if msg.Size < 1 {
-		http.Error(w, "Invalid volume size", http.StatusBadRequest)
-		logger.LogError("Invalid volume size")
+	err = checkVolumeCreateRequest(&msg)
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		logger.LogError(err.Error())
 		return
 	}
-	if msg.Snapshot.Enable {
-		if msg.Snapshot.Factor < 1 || msg.Snapshot.Factor > VOLUME_CREATE_MAX_SNAPSHOT_FACTOR {
-			http.Error(w, "Invalid snapshot factor", http.StatusBadRequest)
-			logger.LogError("Invalid snapshot factor")
-			return
-		}
-	}
-
-	if msg.Durability.Type == api.DurabilityReplicate {
-		if msg.Durability.Replicate.Replica > 5 {
-			http.Error(w, "Invalid replica value", http.StatusBadRequest)
-			logger.LogError("Invalid replica value")
-			return
-		}
-	}
-
-	if msg.Durability.Type == api.DurabilityEC {
-		d := msg.Durability.Disperse
-		// Place here correct combinations
-		switch {
-		case d.Data == 2 && d.Redundancy == 1:
-		case d.Data == 4 && d.Redundancy == 2:
-		case d.Data == 8 && d.Redundancy == 3:
-		case d.Data == 8 && d.Redundancy == 4:
-		default:
-			http.Error(w,
-				fmt.Sprintf("Invalid dispersion combination: %v+%v", d.Data, d.Redundancy),
-				http.StatusBadRequest)
-			logger.LogError(fmt.Sprintf("Invalid dispersion combination: %v+%v", d.Data, d.Redundancy))
-			return
-		}
-	}
 
 	// Check that the clusters requested are available
 	err = a.db.View(func(tx *bolt.Tx) error {
@@ -109,11 +55,11 @@ func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 		// :TODO: All we need to do is check for one instead of gathering all keys
 		clusters, err := ClusterList(tx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 		if len(clusters) == 0 {
-			http.Error(w, fmt.Sprintf("No clusters configured"), http.StatusBadRequest)
+			utils.HttpError(w, fmt.Sprintf("No clusters configured"), http.StatusBadRequest)
 			logger.LogError("No clusters configured")
 			return ErrNotFound
 		}
@@ -122,7 +68,7 @@ func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 		for _, clusterid := range msg.Clusters {
 			_, err := NewClusterEntryFromId(tx, clusterid)
 			if err != nil {
-				http.Error(w, fmt.Sprintf("Cluster id %v not found", clusterid), http.StatusBadRequest)
+				utils.HttpError(w, fmt.Sprintf("Cluster id %v not found", clusterid), http.StatusBadRequest)
 				logger.LogError(fmt.Sprintf("Cluster id %v not found", clusterid))
 				return err
 			}
@@ -138,7 +84,7 @@ func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("co&m New volume:", vol.Info)
 
 	if uint64(msg.Size)*GB < vol.Durability.MinVolumeSize() {
-		http.Error(w, fmt.Sprintf("Requested volume size (%v GB) is "+
+		utils.HttpError(w, fmt.Sprintf("Requested volume size (%v GB) is "+
 			"smaller than the minimum supported volume size (%v)",
 			msg.Size, vol.Durability.MinVolumeSize()),
 			http.StatusBadRequest)
@@ -148,25 +94,92 @@ func (a *App) VolumeCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vc := NewVolumeCreateOperation(vol, a.db)
-	if a.conf.RetryLimits.VolumeCreate > 0 {
-		vc.maxRetries = a.conf.RetryLimits.VolumeCreate
+	if isDryRun(r) {
+		a.PlanHttpResponse(w, "Create Volume",
+			planOperation(func(opdb db.DB) Operation {
+				return NewVolumeCreateOperation(vol, opdb)
+			}))
+		return
 	}
+
+	vc := NewVolumeCreateOperation(vol, a.db)
 	if err := AsyncHttpOperation(a, w, r, vc); err != nil {
 		OperationHttpErrorf(w, err, "Failed to allocate new volume: %v", err)
 		return
 	}
 }
 
+// checkVolumeCreateRequest verifies the values of a volume create
+// request that can be checked without the db. The durability type of
+// the request is set to the default if it was not provided.
+func checkVolumeCreateRequest(msg *api.VolumeCreateRequest) error {
+	switch {
+	case msg.Gid < 0:
+		return fmt.Errorf("Bad group id less than zero")
+	case msg.Gid >= math.MaxInt32:
+		return fmt.Errorf("Bad group id equal or greater than 2**32")
+	}
+
+	switch msg.Durability.Type {
+	case api.DurabilityEC:
+	case api.DurabilityReplicate:
+	case api.DurabilityDistributeOnly:
+	case "":
+		msg.Durability.Type = api.DurabilityDistributeOnly
+	default:
+		return fmt.Errorf("Unknown durability type")
+	}
+
+	if msg.Size < 1 {
+		return fmt.Errorf("Invalid volume size")
+	}
+	if msg.Snapshot.Enable {
+		if msg.Snapshot.Factor < 1 || msg.Snapshot.Factor > VOLUME_CREATE_MAX_SNAPSHOT_FACTOR {
+			return fmt.Errorf("Invalid snapshot factor")
+		}
+	}
+
+	if msg.Durability.Type == api.DurabilityReplicate {
+		if msg.Durability.Replicate.Replica > 5 {
+			return fmt.Errorf("Invalid replica value")
+		}
+	}
+
+	if msg.Durability.Type == api.DurabilityEC {
+		d := msg.Durability.Disperse
+		// Place here correct combinations
+		switch {
+		case d.Data == 2 && d.Redundancy == 1:
+		case d.Data == 4 && d.Redundancy == 2:
+		case d.Data == 8 && d.Redundancy == 3:
+		case d.Data == 8 && d.Redundancy == 4:
+		default:
+			return fmt.Errorf("Invalid dispersion combination: %v+%v", d.Data, d.Redundancy)
+		}
+	}
+	return nil
+}
+
+// VolumeList lists the volumes. The query parameters select, order
+// and page the volumes, as described by api.ListOptions.
 func (a *App) VolumeList(w http.ResponseWriter, r *http.Request) {
 
-	var list api.VolumeListResponse
+	opts, err := listOptions(r.URL.Query())
+	if err == nil {
+		err = volumeListFilters.check(opts)
+	}
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var list *api.VolumeListResponse
 
-	// Get all the cluster ids from the DB
-	err := a.db.View(func(tx *bolt.Tx) error {
+	// Get the selected volumes from the DB
+	err = a.db.View(func(tx *bolt.Tx) error {
 		var err error
 
-		list.Volumes, err = ListCompleteVolumes(tx)
+		list, err = listVolumes(tx, opts)
 		if err != nil {
 			return err
 		}
@@ -176,7 +189,7 @@ func (a *App) VolumeList(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		logger.Err(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -197,21 +210,21 @@ func (a *App) VolumeInfo(w http.ResponseWriter, r *http.Request) {
 		entry, err := NewVolumeEntryFromId(tx, id)
 		if err == ErrNotFound || !entry.Visible() {
 			// treat an invisible entry like it doesn't exist
-			http.Error(w, "Id not found", http.StatusNotFound)
+			utils.HttpError(w, "Id not found", http.StatusNotFound)
 			return ErrNotFound
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
 		info, err = entry.NewInfoResponse(tx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 		err = UpdateVolumeInfoComplete(tx, info)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -241,16 +254,16 @@ func (a *App) VolumeDelete(w http.ResponseWriter, r *http.Request) {
 		var err error
 		volume, err = NewVolumeEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
 		if volume.Info.Name == db.HeketiStorageVolumeName {
 			err := fmt.Errorf("Cannot delete volume containing the Heketi database")
-			http.Error(w, err.Error(), http.StatusConflict)
+			utils.HttpError(w, err.Error(), http.StatusConflict)
 			return err
 		}
 
@@ -263,13 +276,13 @@ func (a *App) VolumeDelete(w http.ResponseWriter, r *http.Request) {
 			_, err = NewBlockVolumeEntryFromId(tx, bvId)
 			if err == nil {
 				err = logger.LogError("Cannot delete a block hosting volume containing block volumes")
-				http.Error(w, err.Error(), http.StatusConflict)
+				utils.HttpError(w, err.Error(), http.StatusConflict)
 				return err
 			}
 			if err != ErrNotFound {
 				err = logger.LogError("Refusing to delete block-hosting volume: "+
 					"Error loading block-volume [%v]: %v", bvId, err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 				return err
 			}
 		}
@@ -280,6 +293,14 @@ func (a *App) VolumeDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		a.PlanHttpResponse(w, "Delete Volume",
+			planOperation(func(opdb db.DB) Operation {
+				return NewVolumeDeleteOperation(volume, opdb)
+			}))
+		return
+	}
+
 	vdel := NewVolumeDeleteOperation(volume, a.db)
 	if err := AsyncHttpOperation(a, w, r, vdel); err != nil {
 		OperationHttpErrorf(w, err, "Failed to set up volume delete: %v", err)
@@ -298,19 +319,19 @@ func (a *App) VolumeExpand(w http.ResponseWriter, r *http.Request) {
 	var msg api.VolumeExpandRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 	logger.Debug("Msg: %v", msg)
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
 
 	if msg.Size < 1 {
-		http.Error(w, "Invalid volume size", http.StatusBadRequest)
+		utils.HttpError(w, "Invalid volume size", http.StatusBadRequest)
 		return
 	}
 	logger.Debug("Size: %v", msg.Size)
@@ -321,10 +342,10 @@ func (a *App) VolumeExpand(w http.ResponseWriter, r *http.Request) {
 		var err error
 		volume, err = NewVolumeEntryFromId(tx, id)
 		if err == ErrNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -335,6 +356,14 @@ func (a *App) VolumeExpand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		a.PlanHttpResponse(w, "Expand Volume",
+			planOperation(func(opdb db.DB) Operation {
+				return NewVolumeExpandOperation(volume, opdb, msg.Size)
+			}))
+		return
+	}
+
 	ve := NewVolumeExpandOperation(volume, a.db, msg.Size)
 	if err := AsyncHttpOperation(a, w, r, ve); err != nil {
 		OperationHttpErrorf(w, err, "Failed to allocate volume expansion: %v", err)
@@ -349,12 +378,12 @@ func (a *App) VolumeClone(w http.ResponseWriter, r *http.Request) {
 	var msg api.VolumeCloneRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", http.StatusUnprocessableEntity)
+		utils.HttpError(w, "request unable to be parsed", http.StatusUnprocessableEntity)
 		return
 	}
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(),
+		utils.HttpError(w, "validation failed: "+err.Error(),
 			http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
@@ -366,10 +395,10 @@ func (a *App) VolumeClone(w http.ResponseWriter, r *http.Request) {
 		volume, err = NewVolumeEntryFromId(tx, vol_id)
 		if err == ErrNotFound || !volume.Visible() {
 			// treat an invisible volume like it doesn't exist
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
@@ -396,12 +425,12 @@ func (a *App) VolumeSetBlockRestriction(w http.ResponseWriter, r *http.Request)
 	var msg api.VolumeBlockRestrictionRequest
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w, "request unable to be parsed", 422)
+		utils.HttpError(w, "request unable to be parsed", 422)
 		return
 	}
 	err = msg.Validate()
 	if err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		logger.LogError("validation failed: " + err.Error())
 		return
 	}
@@ -411,10 +440,10 @@ func (a *App) VolumeSetBlockRestriction(w http.ResponseWriter, r *http.Request)
 		volume, err = NewVolumeEntryFromId(tx, id)
 		if err == ErrNotFound || !volume.Visible() {
 			// treat an invisible volume like it doesn't exist
-			http.Error(w, err.Error(), http.StatusNotFound)
+			utils.HttpError(w, err.Error(), http.StatusNotFound)
 			return err
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			utils.HttpError(w, err.Error(), http.StatusInternalServerError)
 			return err
 		}
 
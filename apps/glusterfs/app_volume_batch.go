@@ -0,0 +1,126 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+const (
+	// number of completed batch results kept for clients
+	VOLUME_BATCH_RESULTS_LIMIT = 64
+)
+
+func (a *App) VolumeBatchCreate(w http.ResponseWriter, r *http.Request) {
+	var msg api.VolumeBatchCreateRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		utils.HttpError(w, "request unable to be parsed", 422)
+		return
+	}
+	err = msg.Validate()
+	if err != nil {
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		logger.LogError("validation failed: " + err.Error())
+		return
+	}
+
+	// the batch and each of its volumes are in-flight operations
+	if uint64(len(msg.Volumes)) >= a.optracker.Limit {
+		err := fmt.Errorf("batch of %v volumes exceeds the limit of %v "+
+			"in-flight operations", len(msg.Volumes), a.optracker.Limit)
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		logger.LogError(err.Error())
+		return
+	}
+
+	for i := range msg.Volumes {
+		if err := checkVolumeCreateRequest(&msg.Volumes[i]); err != nil {
+			utils.HttpError(w, fmt.Sprintf("volumes[%v]: %v", i, err),
+				http.StatusBadRequest)
+			logger.LogError("volumes[%v]: %v", i, err)
+			return
+		}
+	}
+
+	// Check that the clusters requested are available
+	err = a.db.View(func(tx *bolt.Tx) error {
+		clusters, err := ClusterList(tx)
+		if err != nil {
+			return err
+		}
+		if len(clusters) == 0 {
+			return fmt.Errorf("No clusters configured")
+		}
+		for i, v := range msg.Volumes {
+			for _, clusterid := range v.Clusters {
+				if _, err := NewClusterEntryFromId(tx, clusterid); err != nil {
+					return fmt.Errorf("volumes[%v]: Cluster id %v not found",
+						i, clusterid)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		utils.HttpError(w, err.Error(), http.StatusBadRequest)
+		logger.LogError(err.Error())
+		return
+	}
+
+	vols := make([]*VolumeEntry, len(msg.Volumes))
+	for i := range msg.Volumes {
+		vol := NewVolumeEntryFromRequest(&msg.Volumes[i])
+		if uint64(msg.Volumes[i].Size)*GB < vol.Durability.MinVolumeSize() {
+			err := fmt.Errorf("volumes[%v]: Requested volume size (%v GB) is "+
+				"smaller than the minimum supported volume size (%v)",
+				i, msg.Volumes[i].Size, vol.Durability.MinVolumeSize())
+			utils.HttpError(w, err.Error(), http.StatusBadRequest)
+			logger.LogError(err.Error())
+			return
+		}
+		vols[i] = vol
+	}
+
+	op := NewVolumeBatchCreateOperation(
+		vols, a.db, msg.AllOrNothing, a.volumeBatches)
+	op.optracker = a.optracker
+	op.deadlines = a.deadlines
+	op.retries = a.retries
+	if err := AsyncHttpOperation(a, w, r, op); err != nil {
+		OperationHttpErrorf(w, err, "Failed to allocate new volumes: %v", err)
+		return
+	}
+}
+
+func (a *App) VolumeBatchInfo(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	resp, found := a.volumeBatches.Get(id)
+	if !found {
+		utils.HttpError(w, "Id not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		panic(err)
+	}
+}
@@ -294,7 +294,7 @@ func (v *BlockVolumeEntry) deleteBlockVolumeExec(db wdb.RODB,
 	hvname string,
 	executor executors.Executor) error {
 
-	executorhost, err := GetVerifiedManageHostname(db, executor, v.Info.Cluster)
+	executorhost, err := GetVerifiedBlockManageHostname(db, executor, v.Info.Cluster)
 	if err != nil {
 		return err
 	}
@@ -63,16 +63,30 @@ func (v *BlockVolumeEntry) createBlockVolumeRequest(db wdb.RODB,
 			return err
 		}
 
-		if v.Info.Hacount > 0 && v.Info.Hacount <= len(bhvol.Info.Mount.GlusterFS.Hosts) {
+		// only nodes with gluster-block can export the block volume
+		cluster, err := NewClusterEntryFromId(tx, bhvol.Info.Cluster)
+		if err != nil {
+			return err
+		}
+		bhosts, err := cluster.blockStorageHosts(tx, bhvol.Info.Mount.GlusterFS.Hosts)
+		if err != nil {
+			return err
+		}
+		if len(bhosts) == 0 {
+			return fmt.Errorf("none of the nodes of block hosting volume %v have gluster-block",
+				bhvol.Info.Id)
+		}
+
+		if v.Info.Hacount > 0 && v.Info.Hacount <= len(bhosts) {
 			v.Info.BlockVolume.Hosts = nil
-			for _, i := range rand.Perm(len(bhvol.Info.Mount.GlusterFS.Hosts)) {
-				managehostname, e := GetManageHostnameFromStorageHostname(tx, bhvol.Info.Mount.GlusterFS.Hosts[i])
+			for _, i := range rand.Perm(len(bhosts)) {
+				managehostname, e := GetManageHostnameFromStorageHostname(tx, bhosts[i])
 				if e != nil {
-					return fmt.Errorf("Could not find managehostname for %v", bhvol.Info.Mount.GlusterFS.Hosts[i])
+					return fmt.Errorf("Could not find managehostname for %v", bhosts[i])
 				}
 				e = executor.GlusterdCheck(managehostname)
 				if e == nil {
-					v.Info.BlockVolume.Hosts = append(v.Info.BlockVolume.Hosts, bhvol.Info.Mount.GlusterFS.Hosts[i])
+					v.Info.BlockVolume.Hosts = append(v.Info.BlockVolume.Hosts, bhosts[i])
 					if len(v.Info.BlockVolume.Hosts) == v.Info.Hacount {
 						break
 					}
@@ -82,7 +96,7 @@ func (v *BlockVolumeEntry) createBlockVolumeRequest(db wdb.RODB,
 				return fmt.Errorf("insufficient block hosts online")
 			}
 		} else {
-			v.Info.BlockVolume.Hosts = bhvol.Info.Mount.GlusterFS.Hosts
+			v.Info.BlockVolume.Hosts = bhosts
 			v.Info.Hacount = len(v.Info.BlockVolume.Hosts)
 		}
 
@@ -97,7 +111,7 @@ func (v *BlockVolumeEntry) createBlockVolumeRequest(db wdb.RODB,
 	}
 
 	// Select the host on which glusterd is running. To avoid request failing on host down senario.
-	executorhost, err := GetVerifiedManageHostname(db, executor, v.Info.Cluster)
+	executorhost, err := GetVerifiedBlockManageHostname(db, executor, v.Info.Cluster)
 	if err != nil {
 		return nil, "", err
 	}
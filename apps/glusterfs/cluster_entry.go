@@ -54,6 +54,7 @@ func NewClusterEntryFromRequest(req *api.ClusterCreateRequest) *ClusterEntry {
 	entry.Info.Id = idgen.GenUUID()
 	entry.Info.Block = req.Block
 	entry.Info.File = req.File
+	entry.Info.Connection = req.Connection
 
 	return entry
 }
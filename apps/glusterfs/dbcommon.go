@@ -14,6 +14,7 @@ import (
 
 	"github.com/boltdb/bolt"
 
+	wdb "github.com/heketi/heketi/pkg/db"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/heketi/pkg/idgen"
 )
@@ -117,6 +118,12 @@ func initializeBuckets(tx *bolt.Tx) error {
 		return err
 	}
 
+	_, err = tx.CreateBucketIfNotExists([]byte(BOLTDB_BUCKET_EVENTS))
+	if err != nil {
+		logger.LogError("Unable to create events bucket in DB")
+		return err
+	}
+
 	return nil
 }
 
@@ -209,6 +216,37 @@ func recordNewDBGenerationID(tx *bolt.Tx) error {
 	return entry.Save(tx)
 }
 
+// dbIsEmpty returns true if the db has no clusters, nodes, devices,
+// volumes, bricks, block volumes or pending operations.
+func dbIsEmpty(db wdb.RODB) bool {
+	empty := true
+	err := db.View(func(tx *bolt.Tx) error {
+		for _, name := range []string{
+			BOLTDB_BUCKET_CLUSTER,
+			BOLTDB_BUCKET_NODE,
+			BOLTDB_BUCKET_DEVICE,
+			BOLTDB_BUCKET_VOLUME,
+			BOLTDB_BUCKET_BRICK,
+			BOLTDB_BUCKET_BLOCKVOLUME,
+			BOLTDB_BUCKET_PENDING_OPS,
+		} {
+			b := tx.Bucket([]byte(name))
+			if b == nil {
+				continue
+			}
+			if k, _ := b.Cursor().First(); k != nil {
+				empty = false
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return empty
+}
+
 // OpenDB is a wrapper over bolt.Open. It takes a bool to decide whether it should be a read-only open.
 // Other bolt DB config options remain local to this function.
 func OpenDB(dbfilename string, ReadOnly bool) (dbhandle *bolt.DB, err error) {
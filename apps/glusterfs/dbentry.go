@@ -89,6 +89,8 @@ func EntrySave(tx *bolt.Tx, entry DbEntry, key string) error {
 		return err
 	}
 
+	event := entryEvent(entry, key, b.Get([]byte(key)), false)
+
 	// Save data using the id as the key
 	err = b.Put([]byte(key), buffer)
 	if err != nil {
@@ -96,7 +98,7 @@ func EntrySave(tx *bolt.Tx, entry DbEntry, key string) error {
 		return err
 	}
 
-	return nil
+	return journalEntryEvent(tx, event)
 }
 
 func EntryDelete(tx *bolt.Tx, entry DbEntry, key string) error {
@@ -111,6 +113,8 @@ func EntryDelete(tx *bolt.Tx, entry DbEntry, key string) error {
 		return err
 	}
 
+	event := entryEvent(entry, key, b.Get([]byte(key)), true)
+
 	// Delete key
 	err := b.Delete([]byte(key))
 	if err != nil {
@@ -118,7 +122,7 @@ func EntryDelete(tx *bolt.Tx, entry DbEntry, key string) error {
 		return err
 	}
 
-	return nil
+	return journalEntryEvent(tx, event)
 }
 
 func EntryLoad(tx *bolt.Tx, entry DbEntry, key string) error {
@@ -154,8 +154,10 @@ func (d *DeviceEntry) modifyState(db wdb.DB, s api.EntryState) error {
 	})
 }
 
+// SetState changes the state of the device. Moving the device to the
+// failed state removes its bricks with an operation run by run.
 func (d *DeviceEntry) SetState(db wdb.DB,
-	e executors.Executor,
+	run operationRunner,
 	s api.EntryState) error {
 
 	if e := d.stateCheck(s); e != nil {
@@ -172,7 +174,7 @@ func (d *DeviceEntry) SetState(db wdb.DB,
 			return err
 		}
 	case api.EntryStateFailed:
-		if err := d.Remove(db, e); err != nil {
+		if err := d.Remove(db, run); err != nil {
 			if err == ErrNoReplacement {
 				return logger.LogError("Unable to delete device [%v] as no device was found to replace it", d.Id())
 			}
@@ -393,12 +395,9 @@ func (d *DeviceEntry) poolMetadataSize(tpsize uint64) uint64 {
 }
 
 // Moves all the bricks from the device to one or more other devices
-func (d *DeviceEntry) Remove(db wdb.DB,
-	executor executors.Executor) (e error) {
+func (d *DeviceEntry) Remove(db wdb.DB, run operationRunner) (e error) {
 
-	if e = RunOperation(
-		NewDeviceRemoveOperation(d.Info.Id, db),
-		executor); e != nil {
+	if e = run.run(NewDeviceRemoveOperation(d.Info.Id, db)); e != nil {
 		return e
 	}
 	// tests currently expect d to be updated to match db state
@@ -11,6 +11,9 @@ package glusterfs
 
 import (
 	"errors"
+	"sort"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
 )
 
 var (
@@ -33,3 +36,90 @@ var (
 	// returned by code related to operations load
 	ErrTooManyOperations = errors.New("Server handling too many operations")
 )
+
+// apiError returns the error in the error format of the api. The
+// code is taken from the well known errors and the errors of each
+// cluster or host of aggregated errors are returned as details.
+func apiError(err error) *api.Error {
+	if oerr, ok := err.(OperationRetryError); ok {
+		err = oerr.OriginalError
+	}
+
+	switch e := err.(type) {
+	case *api.Error:
+		return e
+	case *MultiClusterError:
+		ae := api.NewError(commonErrorCode(e.errors), e.Error())
+		for _, c := range sortedErrorKeys(e.errors) {
+			ae.Details = append(ae.Details, api.ErrorDetail{
+				Cluster: c,
+				Message: e.errors[c].Error(),
+			})
+		}
+		ae.Retryable = isTransientError(e)
+		return ae
+	case *MultiHostError:
+		ae := api.NewError(commonErrorCode(e.errors), e.Error())
+		for _, h := range sortedErrorKeys(e.errors) {
+			ae.Details = append(ae.Details, api.ErrorDetail{
+				Host:    h,
+				Message: e.errors[h].Error(),
+			})
+		}
+		ae.Retryable = isTransientError(e)
+		return ae
+	}
+	return api.NewError(errorCode(err), err.Error())
+}
+
+// errorCode returns the api error code of a single error.
+// Wrapped errors get the code of the error they wrap.
+func errorCode(err error) api.ErrorCode {
+	codes := []struct {
+		code   api.ErrorCode
+		errors []error
+	}{
+		{api.ErrorCodeNoSpace, []error{ErrNoSpace, ErrMaxBricks,
+			ErrMinimumBrickSize, ErrEmptyCluster, ErrNoStorage}},
+		{api.ErrorCodeNotFound, []error{ErrNotFound}},
+		{api.ErrorCodeConflict, []error{ErrConflict, ErrFound, ErrKeyExists}},
+		{api.ErrorCodeTooManyRequests, []error{ErrTooManyOperations}},
+	}
+	for _, c := range codes {
+		for _, e := range c.errors {
+			if isError(err, e) {
+				return c.code
+			}
+		}
+	}
+	if isTransientError(err) {
+		return api.ErrorCodeUnavailable
+	}
+	return api.ErrorCodeInternal
+}
+
+// commonErrorCode returns the code shared by all the errors, or the
+// internal error code if the errors differ.
+func commonErrorCode(m map[string]error) api.ErrorCode {
+	var code api.ErrorCode
+	for _, err := range m {
+		c := apiError(err).Code
+		if code != "" && c != code {
+			return api.ErrorCodeInternal
+		}
+		code = c
+	}
+	if code == "" {
+		return api.ErrorCodeInternal
+	}
+	return code
+}
+
+func sortedErrorKeys(m map[string]error) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,269 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+const (
+	BOLTDB_BUCKET_EVENTS       = "EVENTS"
+	DEFAULT_EVENT_JOURNAL_SIZE = 10000
+
+	// events held for a subscriber that is not keeping up before
+	// the subscription is dropped
+	eventSubscriberBuffer = 256
+)
+
+var (
+	// global var to track the event journal of the active app.
+	// Changes to db entries are journaled from the generic entry
+	// functions, which have no access to the app. Same caveats as
+	// for currentNodeHealthCache.
+	currentEventJournal *eventJournal
+
+	eventsNow func() time.Time = time.Now
+)
+
+// eventJournal keeps the most recent events in the db and passes new
+// events on to its subscribers. Events are journaled within the db
+// transaction of the change they report, so only committed changes
+// are reported. A nil journal records nothing.
+type eventJournal struct {
+	db    *bolt.DB
+	limit uint64
+
+	lock sync.Mutex
+	subs map[chan api.Event]bool
+}
+
+func newEventJournal(db *bolt.DB, limit uint64) *eventJournal {
+	if limit == 0 {
+		limit = DEFAULT_EVENT_JOURNAL_SIZE
+	}
+	return &eventJournal{
+		db:    db,
+		limit: limit,
+		subs:  map[chan api.Event]bool{},
+	}
+}
+
+func eventKey(seq uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, seq)
+	return k
+}
+
+// add journals the event within tx. The subscribers are sent the
+// event once tx is committed.
+func (j *eventJournal) add(tx *bolt.Tx, e api.Event) error {
+	if j == nil {
+		return nil
+	}
+	b := tx.Bucket([]byte(BOLTDB_BUCKET_EVENTS))
+	if b == nil {
+		// db has not been initialized for events
+		return nil
+	}
+
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	e.Seq = seq
+	e.Time = eventsNow().Unix()
+	buffer, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(eventKey(seq), buffer); err != nil {
+		return err
+	}
+
+	// drop the oldest events
+	if seq > j.limit {
+		old := [][]byte{}
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil &&
+			binary.BigEndian.Uint64(k) <= seq-j.limit; k, _ = c.Next() {
+
+			old = append(old, append([]byte{}, k...))
+		}
+		for _, k := range old {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+
+	tx.OnCommit(func() {
+		j.publish(e)
+	})
+	return nil
+}
+
+// Record journals an event that is not part of a db change.
+func (j *eventJournal) Record(e api.Event) {
+	if j == nil {
+		return
+	}
+	err := j.db.Update(func(tx *bolt.Tx) error {
+		return j.add(tx, e)
+	})
+	if err != nil {
+		logger.LogError("Unable to record %v event: %v", e.Type, err)
+	}
+}
+
+// Since returns the journaled events after seq. If some of these
+// events are no longer in the journal lost is true.
+func (j *eventJournal) Since(seq uint64) (events []api.Event, lost bool, e error) {
+	events = []api.Event{}
+	e = j.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BOLTDB_BUCKET_EVENTS))
+		if b == nil {
+			return nil
+		}
+		if seq > b.Sequence() {
+			// the events are from another db
+			lost = true
+			seq = 0
+		}
+		c := b.Cursor()
+		k, v := c.Seek(eventKey(seq + 1))
+		if k != nil && binary.BigEndian.Uint64(k) > seq+1 {
+			lost = true
+		} else if k == nil && seq < b.Sequence() {
+			lost = true
+		}
+		for ; k != nil; k, v = c.Next() {
+			var event api.Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return
+}
+
+// Subscribe returns a channel that is sent the new events. The
+// channel is closed if the subscriber does not keep up.
+func (j *eventJournal) Subscribe() chan api.Event {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	ch := make(chan api.Event, eventSubscriberBuffer)
+	j.subs[ch] = true
+	return ch
+}
+
+func (j *eventJournal) Unsubscribe(ch chan api.Event) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.subs[ch] {
+		delete(j.subs, ch)
+		close(ch)
+	}
+}
+
+func (j *eventJournal) publish(e api.Event) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	for ch := range j.subs {
+		select {
+		case ch <- e:
+		default:
+			logger.Warning("Dropping event subscriber that is not keeping up")
+			delete(j.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// entryEvent returns the event reporting the change of a db entry
+// from old, or nil if changes to the entry are not reported. Old is
+// nil for a new entry.
+func entryEvent(entry DbEntry, key string,
+	old []byte, deleted bool) *api.Event {
+
+	kind := func(created, updated, removed api.EventType) api.EventType {
+		switch {
+		case deleted:
+			return removed
+		case old == nil:
+			return created
+		default:
+			return updated
+		}
+	}
+
+	switch v := entry.(type) {
+	case *VolumeEntry:
+		return &api.Event{
+			Type: kind(api.EventVolumeCreated,
+				api.EventVolumeUpdated, api.EventVolumeDeleted),
+			Id:      key,
+			Pending: v.Pending.Id != "",
+		}
+	case *BlockVolumeEntry:
+		return &api.Event{
+			Type: kind(api.EventBlockVolumeCreated,
+				api.EventBlockVolumeUpdated, api.EventBlockVolumeDeleted),
+			Id:      key,
+			Pending: v.Pending.Id != "",
+		}
+	case *DeviceEntry:
+		e := &api.Event{
+			Type: kind(api.EventDeviceCreated,
+				api.EventDeviceUpdated, api.EventDeviceDeleted),
+			Id:    key,
+			State: v.State,
+		}
+		if !deleted && old != nil {
+			prev := NewDeviceEntry()
+			if err := prev.Unmarshal(old); err == nil && prev.State != v.State {
+				e.OldState = prev.State
+			}
+		}
+		return e
+	case *NodeEntry:
+		if deleted || old == nil {
+			return nil
+		}
+		prev := NewNodeEntry()
+		if err := prev.Unmarshal(old); err != nil || prev.State == v.State {
+			return nil
+		}
+		return &api.Event{
+			Type:     api.EventNodeStateChanged,
+			Id:       key,
+			State:    v.State,
+			OldState: prev.State,
+		}
+	}
+	return nil
+}
+
+// journalEntryEvent journals the event of an entry change, if any,
+// within the transaction of the change.
+func journalEntryEvent(tx *bolt.Tx, e *api.Event) error {
+	if e == nil {
+		return nil
+	}
+	return currentEventJournal.add(tx, *e)
+}
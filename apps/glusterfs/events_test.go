@@ -0,0 +1,163 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+func testEventSeqs(events []api.Event) []uint64 {
+	seqs := []uint64{}
+	for _, e := range events {
+		seqs = append(seqs, e.Seq)
+	}
+	return seqs
+}
+
+func TestEventJournalSince(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	j := newEventJournal(app.db, 3)
+	events, lost, err := j.Since(0)
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, len(events) == 0 && !lost,
+		"expected no events, got", events, lost)
+
+	// only the last 3 of 5 events are kept
+	for i := 0; i < 5; i++ {
+		j.Record(api.Event{Type: api.EventOperationStarted})
+	}
+	for _, c := range []struct {
+		since    uint64
+		expected []uint64
+		lost     bool
+	}{
+		{0, []uint64{3, 4, 5}, true},
+		{1, []uint64{3, 4, 5}, true},
+		{2, []uint64{3, 4, 5}, false},
+		{4, []uint64{5}, false},
+		{5, []uint64{}, false},
+		// an id beyond the journal is from another db
+		{6, []uint64{3, 4, 5}, true},
+		{100, []uint64{3, 4, 5}, true},
+	} {
+		events, lost, err := j.Since(c.since)
+		tests.Assert(t, err == nil, "expected err == nil, got", err)
+		seqs := testEventSeqs(events)
+		tests.Assert(t, len(seqs) == len(c.expected),
+			"since", c.since, "expected", c.expected, "got", seqs)
+		for i := range seqs {
+			tests.Assert(t, seqs[i] == c.expected[i],
+				"since", c.since, "expected", c.expected, "got", seqs)
+		}
+		tests.Assert(t, lost == c.lost,
+			"since", c.since, "expected lost", c.lost, "got", lost)
+	}
+}
+
+func TestEventJournalTrimmed(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// events dropped before any remain still count as lost
+	j := newEventJournal(app.db, 1)
+	for i := 0; i < 3; i++ {
+		j.Record(api.Event{Type: api.EventOperationStarted})
+	}
+	events, lost, err := j.Since(1)
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, lost, "expected events to be lost")
+	seqs := testEventSeqs(events)
+	tests.Assert(t, len(seqs) == 1 && seqs[0] == 3,
+		"expected event 3, got", seqs)
+}
+
+// streamRecorder is a response writer that can be read while the
+// handler is still writing to it.
+type streamRecorder struct {
+	lock   sync.Mutex
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (sr *streamRecorder) Header() http.Header {
+	return sr.header
+}
+
+func (sr *streamRecorder) Write(b []byte) (int, error) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	return sr.body.Write(b)
+}
+
+func (sr *streamRecorder) WriteHeader(int) {}
+
+func (sr *streamRecorder) Flush() {}
+
+func (sr *streamRecorder) String() string {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	return sr.body.String()
+}
+
+func TestEventsResumeFromOtherDB(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	// the client resumes from an id of a db with more events
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	r.Header.Set("Last-Event-ID", "100")
+	w := &streamRecorder{header: http.Header{}}
+	done := make(chan struct{})
+	go func() {
+		app.Events(w, r)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// the handler has subscribed once it reports the lost events
+	for i := 0; i < 100 && !strings.Contains(w.String(), "event: "); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a new event is sent even though its id is lower
+	app.events.Record(api.Event{Type: api.EventOperationStarted})
+	found := false
+	for i := 0; i < 100 && !found; i++ {
+		time.Sleep(10 * time.Millisecond)
+		found = strings.Contains(w.String(),
+			"event: "+string(api.EventOperationStarted))
+	}
+	tests.Assert(t, found, "expected new events to be sent, got", w.String())
+	tests.Assert(t, strings.Contains(w.String(), "event: "+string(api.EventsLost)),
+		"expected the client to be told events were lost")
+}
@@ -72,17 +72,21 @@ func (examiner Examiner) fetchClusterData(cluster ClusterEntry, heketidb Db) (cl
 		}
 
 		// Device Info
-		nodedata.LVMPVInfo, err = examiner.executor.PVS(host)
-		if err != nil {
-			errorstrings = append(errorstrings, fmt.Sprintf("could not fetch LVM pvs data from node %v : %v", host, err))
-		}
-		nodedata.LVMVGInfo, err = examiner.executor.VGS(host)
-		if err != nil {
-			errorstrings = append(errorstrings, fmt.Sprintf("could not fetch LVM vgs data from node %v : %v", host, err))
-		}
-		nodedata.LVMLVInfo, err = examiner.executor.LVS(host)
-		if err != nil {
-			errorstrings = append(errorstrings, fmt.Sprintf("could not fetch LVM lvs data from node %v : %v", host, err))
+		if !nodeEntry.hasLvmJson() {
+			errorstrings = append(errorstrings, fmt.Sprintf("LVM on node %v does not support json reports", host))
+		} else {
+			nodedata.LVMPVInfo, err = examiner.executor.PVS(host)
+			if err != nil {
+				errorstrings = append(errorstrings, fmt.Sprintf("could not fetch LVM pvs data from node %v : %v", host, err))
+			}
+			nodedata.LVMVGInfo, err = examiner.executor.VGS(host)
+			if err != nil {
+				errorstrings = append(errorstrings, fmt.Sprintf("could not fetch LVM vgs data from node %v : %v", host, err))
+			}
+			nodedata.LVMLVInfo, err = examiner.executor.LVS(host)
+			if err != nil {
+				errorstrings = append(errorstrings, fmt.Sprintf("could not fetch LVM lvs data from node %v : %v", host, err))
+			}
 		}
 
 		// Brick Info
@@ -93,7 +97,7 @@ func (examiner Examiner) fetchClusterData(cluster ClusterEntry, heketidb Db) (cl
 
 		// Block Volume Info
 		for _, blockHostingVolume := range heketidb.Volumes {
-			if blockHostingVolume.Info.Block {
+			if blockHostingVolume.Info.Block && nodeEntry.hasGlusterBlock() {
 				names, err := examiner.executor.ListBlockVolumes(host, blockHostingVolume.Info.Name)
 				if err != nil {
 					errorstrings = append(errorstrings, fmt.Sprintf("could not fetch block volume list for block hosting volume %v : %v", blockHostingVolume.Info.Id, err))
@@ -18,6 +18,8 @@ import (
 
 	"github.com/heketi/heketi/executors"
 	wdb "github.com/heketi/heketi/pkg/db"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
 )
 
 var (
@@ -42,6 +44,9 @@ type NodeHealthCache struct {
 	nodes map[string]*NodeHealthStatus
 	lock  sync.RWMutex
 
+	// journal the health changes are reported to
+	events *eventJournal
+
 	// to stop the monitor
 	stop chan<- interface{}
 }
@@ -57,12 +62,20 @@ func NewNodeHealthCache(reftime, starttime uint32, db wdb.RODB, e executors.Exec
 	}
 }
 
+// Status returns the known health of the nodes. A node found up by
+// the last check is reported down if the executor has since stopped
+// connecting to it.
 func (hc *NodeHealthCache) Status() map[string]bool {
 	hc.lock.RLock()
 	defer hc.lock.RUnlock()
+	circuits, _ := hc.exec.(executors.CircuitBreakerReporter)
 	healthy := map[string]bool{}
 	for k, v := range hc.nodes {
 		healthy[k] = v.Up
+		if v.Up && circuits != nil &&
+			circuits.HostCircuit(v.Host) == rex.CircuitOpen {
+			healthy[k] = false
+		}
 	}
 	return healthy
 }
@@ -81,14 +94,31 @@ func (hc *NodeHealthCache) Refresh() error {
 }
 
 func (hc *NodeHealthCache) updateNode(s *NodeHealthStatus) {
+	// the change is recorded after the cache is unlocked as the
+	// cache is read from within db transactions
+	if changed, up := hc.probeNode(s); changed {
+		hc.events.Record(api.Event{
+			Type: api.EventNodeHealthChanged,
+			Id:   s.NodeId,
+			Up:   &up,
+		})
+	}
+}
+
+// probeNode updates the health of a node and returns true if the
+// health has changed. A node is assumed up until found otherwise.
+func (hc *NodeHealthCache) probeNode(s *NodeHealthStatus) (changed, up bool) {
 	hc.lock.Lock()
 	defer hc.lock.Unlock()
-	if prev, found := hc.nodes[s.NodeId]; found {
+	prev, found := hc.nodes[s.NodeId]
+	if found {
 		s = prev
 	} else {
 		hc.nodes[s.NodeId] = s
 	}
+	wasUp := !found || s.Up
 	s.update(hc.exec)
+	return wasUp != s.Up, s.Up
 }
 
 func (hc *NodeHealthCache) cleanOld() {
@@ -0,0 +1,539 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// listItem is an item of a list with the values the list can be
+// ordered by.
+type listItem struct {
+	id   string
+	name string
+	size int
+}
+
+// listCursor is the last item of a page. Continuation tokens are
+// encoded cursors, so a list resumes after that item even if items
+// were added or removed in between.
+type listCursor struct {
+	Sort string `json:"sort,omitempty"`
+	Id   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Size int    `json:"size,omitempty"`
+}
+
+func (c listCursor) token() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		// a cursor of strings and ints always encodes
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseListCursor(token string) (listCursor, error) {
+	var c listCursor
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || c.Id == "" {
+		return c, fmt.Errorf("invalid continue token: %v", token)
+	}
+	return c, nil
+}
+
+// listOptions reads the options of a list from the query parameters
+// of the request.
+func listOptions(q url.Values) (api.ListOptions, error) {
+	opts := api.ListOptions{
+		Continue:   q.Get("continue"),
+		Cluster:    q.Get("cluster"),
+		NamePrefix: q.Get("name_prefix"),
+		Tag:        q.Get("tag"),
+		Sort:       q.Get("sort"),
+	}
+
+	ints := []struct {
+		name string
+		v    *int
+	}{
+		{"limit", &opts.Limit},
+		{"min_size", &opts.MinSize},
+		{"max_size", &opts.MaxSize},
+	}
+	for _, i := range ints {
+		if s := q.Get(i.name); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 0 {
+				return opts, fmt.Errorf("invalid value for %v: %v", i.name, s)
+			}
+			*i.v = v
+		}
+	}
+	if opts.MaxSize > 0 && opts.MinSize > opts.MaxSize {
+		return opts, fmt.Errorf("min_size is larger than max_size")
+	}
+
+	if q.Get("block") != "" {
+		block, err := queryBool(q, "block", false)
+		if err != nil {
+			return opts, err
+		}
+		opts.Block = &block
+	}
+	detail, err := queryBool(q, "detail", false)
+	if err != nil {
+		return opts, err
+	}
+	opts.Detail = detail
+
+	switch strings.TrimPrefix(opts.Sort, "-") {
+	case "", api.ListSortId, api.ListSortName, api.ListSortSize:
+	default:
+		return opts, fmt.Errorf("invalid value for sort: %v", opts.Sort)
+	}
+	if opts.Tag != "" && strings.HasPrefix(opts.Tag, "=") {
+		return opts, fmt.Errorf("invalid value for tag: %v", opts.Tag)
+	}
+	if opts.Continue != "" {
+		c, err := parseListCursor(opts.Continue)
+		if err != nil {
+			return opts, err
+		}
+		if c.Sort != opts.Sort {
+			return opts, fmt.Errorf(
+				"continue token is for a list sorted by %q", c.Sort)
+		}
+	}
+	return opts, nil
+}
+
+// listFilters are the filters, and orders, a list supports.
+type listFilters struct {
+	kind                       string
+	cluster, name, block, size bool
+}
+
+var (
+	volumeListFilters      = listFilters{"volumes", true, true, true, true}
+	blockVolumeListFilters = listFilters{"block volumes", true, true, false, true}
+	clusterListFilters     = listFilters{"clusters", false, false, true, false}
+)
+
+// check returns an error if the options use a filter or an order
+// the list does not support.
+func (lf listFilters) check(opts api.ListOptions) error {
+	sortBy := strings.TrimPrefix(opts.Sort, "-")
+	switch {
+	case !lf.cluster && opts.Cluster != "":
+		return fmt.Errorf("%v can not be filtered by cluster", lf.kind)
+	case !lf.name && opts.NamePrefix != "":
+		return fmt.Errorf("%v can not be filtered by name", lf.kind)
+	case !lf.name && sortBy == api.ListSortName:
+		return fmt.Errorf("%v can not be sorted by name", lf.kind)
+	case !lf.block && opts.Block != nil:
+		return fmt.Errorf("%v can not be filtered by block", lf.kind)
+	case !lf.size && (opts.MinSize > 0 || opts.MaxSize > 0):
+		return fmt.Errorf("%v can not be filtered by size", lf.kind)
+	case !lf.size && sortBy == api.ListSortSize:
+		return fmt.Errorf("%v can not be sorted by size", lf.kind)
+	}
+	return nil
+}
+
+// needsEntries returns true if the db entries of the items are
+// needed to filter, order or describe them.
+func needsEntries(opts api.ListOptions) bool {
+	sortBy := strings.TrimPrefix(opts.Sort, "-")
+	return opts.Cluster != "" || opts.NamePrefix != "" ||
+		opts.Block != nil || opts.MinSize > 0 || opts.MaxSize > 0 ||
+		opts.Tag != "" || opts.Detail ||
+		(sortBy != "" && sortBy != api.ListSortId)
+}
+
+func sizeInRange(size int, opts api.ListOptions) bool {
+	return size >= opts.MinSize && (opts.MaxSize == 0 || size <= opts.MaxSize)
+}
+
+// pageList orders the items and returns the page of the items
+// selected by the options, with the token of the next page if the
+// list does not end with the page.
+func pageList(items []listItem, opts api.ListOptions) ([]listItem, string) {
+	desc := strings.HasPrefix(opts.Sort, "-")
+	sortBy := strings.TrimPrefix(opts.Sort, "-")
+	less := func(a, b listItem) bool {
+		switch {
+		case sortBy == api.ListSortName && a.name != b.name:
+			return a.name < b.name
+		case sortBy == api.ListSortSize && a.size != b.size:
+			return a.size < b.size
+		}
+		return a.id < b.id
+	}
+	before := func(a, b listItem) bool {
+		if desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return before(items[i], items[j])
+	})
+
+	start := 0
+	if opts.Continue != "" {
+		// checked when the options were read
+		c, _ := parseListCursor(opts.Continue)
+		last := listItem{id: c.Id, name: c.Name, size: c.Size}
+		start = sort.Search(len(items), func(i int) bool {
+			return before(last, items[i])
+		})
+	}
+	end := len(items)
+	if opts.Limit == 0 || start+opts.Limit >= end {
+		return items[start:end], ""
+	}
+	end = start + opts.Limit
+	last := items[end-1]
+	c := listCursor{Sort: opts.Sort, Id: last.id}
+	switch sortBy {
+	case api.ListSortName:
+		c.Name = last.name
+	case api.ListSortSize:
+		c.Size = last.size
+	}
+	return items[start:end], c.token()
+}
+
+func listItemIds(items []listItem) []string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.id
+	}
+	return ids
+}
+
+// tagFilter selects the items on devices, or nodes, with a tag.
+// A device has the tags of its node. The tags of the devices and
+// volumes are cached as each is checked.
+type tagFilter struct {
+	name     string
+	value    string
+	hasValue bool
+
+	devices map[string]bool
+	volumes map[string]bool
+}
+
+// newTagFilter returns the filter of a tag, given as name or
+// name=value, or nil for no tag.
+func newTagFilter(tag string) *tagFilter {
+	if tag == "" {
+		return nil
+	}
+	f := &tagFilter{
+		devices: map[string]bool{},
+		volumes: map[string]bool{},
+	}
+	parts := strings.SplitN(tag, "=", 2)
+	f.name = parts[0]
+	if len(parts) == 2 {
+		f.value = parts[1]
+		f.hasValue = true
+	}
+	return f
+}
+
+func (f *tagFilter) matches(tags map[string]string) bool {
+	v, ok := tags[f.name]
+	return ok && (!f.hasValue || v == f.value)
+}
+
+func (f *tagFilter) device(tx *bolt.Tx, id string) (bool, error) {
+	if m, ok := f.devices[id]; ok {
+		return m, nil
+	}
+	d, err := NewDeviceEntryFromId(tx, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := NewNodeEntryFromId(tx, d.NodeId)
+	if err != nil {
+		return false, err
+	}
+	m := f.matches(MergeTags(n, d))
+	f.devices[id] = m
+	return m, nil
+}
+
+// volume returns true if a brick of the volume is on a device with
+// the tag.
+func (f *tagFilter) volume(tx *bolt.Tx, v *VolumeEntry) (bool, error) {
+	if m, ok := f.volumes[v.Info.Id]; ok {
+		return m, nil
+	}
+	m := false
+	for _, id := range v.Bricks {
+		b, err := NewBrickEntryFromId(tx, id)
+		if err != nil {
+			return false, err
+		}
+		m, err = f.device(tx, b.Info.DeviceId)
+		if err != nil {
+			return false, err
+		}
+		if m {
+			break
+		}
+	}
+	f.volumes[v.Info.Id] = m
+	return m, nil
+}
+
+// blockVolume returns true if the block hosting volume of the block
+// volume matches.
+func (f *tagFilter) blockVolume(tx *bolt.Tx, bv *BlockVolumeEntry) (bool, error) {
+	if m, ok := f.volumes[bv.Info.BlockHostingVolume]; ok {
+		return m, nil
+	}
+	v, err := NewVolumeEntryFromId(tx, bv.Info.BlockHostingVolume)
+	if err != nil {
+		return false, err
+	}
+	return f.volume(tx, v)
+}
+
+// cluster returns true if a node, or device, of the cluster has the
+// tag.
+func (f *tagFilter) cluster(tx *bolt.Tx, c *ClusterEntry) (bool, error) {
+	for _, id := range c.Info.Nodes {
+		n, err := NewNodeEntryFromId(tx, id)
+		if err != nil {
+			return false, err
+		}
+		if f.matches(n.AllTags()) {
+			return true, nil
+		}
+		for _, d := range n.Devices {
+			m, err := f.device(tx, d)
+			if err != nil {
+				return false, err
+			}
+			if m {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// listVolumes returns the page of the complete volumes selected by
+// the options, and the info of these volumes if requested.
+func listVolumes(tx *bolt.Tx, opts api.ListOptions) (
+	*api.VolumeListResponse, error) {
+
+	ids, err := ListCompleteVolumes(tx)
+	if err != nil {
+		return nil, err
+	}
+	tags := newTagFilter(opts.Tag)
+	entries := map[string]*VolumeEntry{}
+	items := []listItem{}
+	for _, id := range ids {
+		if !needsEntries(opts) {
+			items = append(items, listItem{id: id})
+			continue
+		}
+		v, err := NewVolumeEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if (opts.Cluster != "" && v.Info.Cluster != opts.Cluster) ||
+			!strings.HasPrefix(v.Info.Name, opts.NamePrefix) ||
+			(opts.Block != nil && v.Info.Block != *opts.Block) ||
+			!sizeInRange(v.Info.Size, opts) {
+			continue
+		}
+		if tags != nil {
+			m, err := tags.volume(tx, v)
+			if err != nil {
+				return nil, err
+			}
+			if !m {
+				continue
+			}
+		}
+		items = append(items, listItem{id: id, name: v.Info.Name, size: v.Info.Size})
+		if opts.Detail {
+			entries[id] = v
+		}
+	}
+
+	page, cont := pageList(items, opts)
+	list := &api.VolumeListResponse{
+		Volumes:  listItemIds(page),
+		Continue: cont,
+	}
+	if !opts.Detail {
+		return list, nil
+	}
+	pblk, err := MapPendingBlockVolumes(tx)
+	if err != nil {
+		return nil, err
+	}
+	list.Details = []api.VolumeInfoResponse{}
+	for _, item := range page {
+		info, err := entries[item.id].NewInfoResponse(tx)
+		if err != nil {
+			return nil, err
+		}
+		if len(pblk) > 0 {
+			info.BlockInfo.BlockVolumes = removeKeysFromList(
+				info.BlockInfo.BlockVolumes, pblk)
+		}
+		list.Details = append(list.Details, *info)
+	}
+	return list, nil
+}
+
+// listBlockVolumes returns the page of the complete block volumes
+// selected by the options, and the info of these block volumes if
+// requested.
+func listBlockVolumes(tx *bolt.Tx, opts api.ListOptions) (
+	*api.BlockVolumeListResponse, error) {
+
+	ids, err := ListCompleteBlockVolumes(tx)
+	if err != nil {
+		return nil, err
+	}
+	tags := newTagFilter(opts.Tag)
+	entries := map[string]*BlockVolumeEntry{}
+	items := []listItem{}
+	for _, id := range ids {
+		if !needsEntries(opts) {
+			items = append(items, listItem{id: id})
+			continue
+		}
+		bv, err := NewBlockVolumeEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if (opts.Cluster != "" && bv.Info.Cluster != opts.Cluster) ||
+			!strings.HasPrefix(bv.Info.Name, opts.NamePrefix) ||
+			!sizeInRange(bv.Info.Size, opts) {
+			continue
+		}
+		if tags != nil {
+			m, err := tags.blockVolume(tx, bv)
+			if err != nil {
+				return nil, err
+			}
+			if !m {
+				continue
+			}
+		}
+		items = append(items, listItem{id: id, name: bv.Info.Name, size: bv.Info.Size})
+		if opts.Detail {
+			entries[id] = bv
+		}
+	}
+
+	page, cont := pageList(items, opts)
+	list := &api.BlockVolumeListResponse{
+		BlockVolumes: listItemIds(page),
+		Continue:     cont,
+	}
+	if !opts.Detail {
+		return list, nil
+	}
+	list.Details = []api.BlockVolumeInfoResponse{}
+	for _, item := range page {
+		info, err := entries[item.id].NewInfoResponse(tx)
+		if err != nil {
+			return nil, err
+		}
+		list.Details = append(list.Details, *info)
+	}
+	return list, nil
+}
+
+// listClusters returns the page of the clusters selected by the
+// options, and the info of these clusters if requested.
+func listClusters(tx *bolt.Tx, opts api.ListOptions) (
+	*api.ClusterListResponse, error) {
+
+	ids, err := ClusterList(tx)
+	if err != nil {
+		return nil, err
+	}
+	tags := newTagFilter(opts.Tag)
+	entries := map[string]*ClusterEntry{}
+	items := []listItem{}
+	for _, id := range ids {
+		if !needsEntries(opts) {
+			items = append(items, listItem{id: id})
+			continue
+		}
+		c, err := NewClusterEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Block != nil && c.Info.Block != *opts.Block {
+			continue
+		}
+		if tags != nil {
+			m, err := tags.cluster(tx, c)
+			if err != nil {
+				return nil, err
+			}
+			if !m {
+				continue
+			}
+		}
+		items = append(items, listItem{id: id})
+		if opts.Detail {
+			entries[id] = c
+		}
+	}
+
+	page, cont := pageList(items, opts)
+	list := &api.ClusterListResponse{
+		Clusters: listItemIds(page),
+		Continue: cont,
+	}
+	if !opts.Detail {
+		return list, nil
+	}
+	list.Details = []api.ClusterInfoResponse{}
+	for _, item := range page {
+		info, err := entries[item.id].NewClusterInfoResponse(tx)
+		if err != nil {
+			return nil, err
+		}
+		if err := UpdateClusterInfoComplete(tx, info); err != nil {
+			return nil, err
+		}
+		list.Details = append(list.Details, *info)
+	}
+	return list, nil
+}
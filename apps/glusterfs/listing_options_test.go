@@ -0,0 +1,152 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/heketi/tests"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+func pageListItems() []listItem {
+	return []listItem{
+		{id: "d", name: "vol_b", size: 10},
+		{id: "a", name: "vol_e", size: 30},
+		{id: "e", name: "vol_a", size: 10},
+		{id: "c", name: "vol_d", size: 20},
+		{id: "b", name: "vol_c", size: 20},
+	}
+}
+
+// pageAll walks the pages of the items and returns the ids of each
+// page.
+func pageAll(t *testing.T, items []listItem, opts api.ListOptions) [][]string {
+	var pages [][]string
+	for i := 0; i < len(items)+1; i++ {
+		page, cont := pageList(items, opts)
+		pages = append(pages, listItemIds(page))
+		if cont == "" {
+			return pages
+		}
+		// the tokens are read back like those of a request
+		opts.Continue = cont
+		_, err := listOptions(url.Values{
+			"continue": []string{cont},
+			"sort":     []string{opts.Sort},
+		})
+		tests.Assert(t, err == nil, "expected err == nil, got", err)
+	}
+	t.Fatalf("list does not end: %v", pages)
+	return nil
+}
+
+func sameIds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPageListNoLimit(t *testing.T) {
+	page, cont := pageList(pageListItems(), api.ListOptions{})
+	tests.Assert(t, cont == "", "expected no token, got", cont)
+	ids := listItemIds(page)
+	tests.Assert(t, sameIds(ids, []string{"a", "b", "c", "d", "e"}), "got", ids)
+}
+
+func TestPageListTokens(t *testing.T) {
+	for _, c := range []struct {
+		sort  string
+		limit int
+		pages [][]string
+	}{
+		{"", 2, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{"", 5, [][]string{{"a", "b", "c", "d", "e"}}},
+		{"", 4, [][]string{{"a", "b", "c", "d"}, {"e"}}},
+		{"-id", 3, [][]string{{"e", "d", "c"}, {"b", "a"}}},
+		{"name", 2, [][]string{{"e", "d"}, {"b", "c"}, {"a"}}},
+		// equal sizes are ordered by id, also across pages
+		{"size", 1, [][]string{{"d"}, {"e"}, {"b"}, {"c"}, {"a"}}},
+		{"-size", 2, [][]string{{"a", "c"}, {"b", "e"}, {"d"}}},
+	} {
+		pages := pageAll(t, pageListItems(),
+			api.ListOptions{Sort: c.sort, Limit: c.limit})
+		tests.Assert(t, len(pages) == len(c.pages),
+			"sort", c.sort, "expected", c.pages, "got", pages)
+		for i := range pages {
+			tests.Assert(t, sameIds(pages[i], c.pages[i]),
+				"sort", c.sort, "expected", c.pages, "got", pages)
+		}
+	}
+}
+
+func TestPageListResumesAfterChanges(t *testing.T) {
+	opts := api.ListOptions{Sort: "size", Limit: 2}
+	page, cont := pageList(pageListItems(), opts)
+	tests.Assert(t, len(page) == 2 && page[1].id == "e", "got", page)
+
+	// the last item of the page, e of size 10, is removed and items
+	// are added before and after it
+	items := []listItem{
+		{id: "d", size: 10},
+		{id: "a", size: 30},
+		{id: "c", size: 20},
+		{id: "b", size: 20},
+		{id: "f", size: 5},
+		{id: "g", size: 10},
+		{id: "h", size: 20},
+	}
+	opts.Continue = cont
+	opts.Limit = 0
+	page, cont = pageList(items, opts)
+	tests.Assert(t, cont == "", "expected no token, got", cont)
+	ids := listItemIds(page)
+	tests.Assert(t, sameIds(ids, []string{"g", "b", "c", "h", "a"}), "got", ids)
+}
+
+func TestPageListEmpty(t *testing.T) {
+	page, cont := pageList([]listItem{}, api.ListOptions{Limit: 2})
+	tests.Assert(t, len(page) == 0, "got", page)
+	tests.Assert(t, cont == "", "expected no token, got", cont)
+}
+
+func TestListOptionsContinue(t *testing.T) {
+	_, cont := pageList(pageListItems(), api.ListOptions{Sort: "name", Limit: 1})
+	tests.Assert(t, cont != "")
+
+	for _, c := range []struct {
+		token, sort string
+		valid       bool
+	}{
+		{cont, "name", true},
+		// a token only continues the order it was made for
+		{cont, "", false},
+		{cont, "-name", false},
+		{"not-base64!", "name", false},
+		{"bm90IGpzb24", "name", false},
+		// a cursor without an id
+		{listCursor{Sort: "name"}.token(), "name", false},
+	} {
+		_, err := listOptions(url.Values{
+			"continue": []string{c.token},
+			"sort":     []string{c.sort},
+		})
+		tests.Assert(t, (err == nil) == c.valid,
+			"token", c.token, "sort", c.sort, "got", err)
+	}
+}
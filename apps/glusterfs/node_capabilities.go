@@ -0,0 +1,146 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/heketi/heketi/executors"
+	wdb "github.com/heketi/heketi/pkg/db"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+const (
+	// arbiter volumes first appeared in gluster 3.7
+	arbiterOpVersion = 30700
+)
+
+// probeCapabilities sets the capabilities of the node to those the
+// executor finds on it. The capabilities are left unchanged if the
+// executor can not probe nodes.
+func (n *NodeEntry) probeCapabilities(e executors.Executor) error {
+	p, ok := e.(executors.CapabilityProber)
+	if !ok {
+		return nil
+	}
+	nc, err := p.NodeCapabilities(n.ManageHostName())
+	if err == executors.NotSupportedError {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	n.Capabilities = &api.NodeCapabilities{
+		GlusterVersion:      nc.GlusterVersion,
+		OpVersion:           nc.OpVersion,
+		GlusterBlock:        nc.GlusterBlock,
+		GlusterBlockVersion: nc.GlusterBlockVersion,
+		LvmVersion:          nc.LvmVersion,
+		LvmJson:             nc.LvmJson,
+		KernelVersion:       nc.KernelVersion,
+		Xfs:                 nc.Xfs,
+		XfsVersion:          nc.XfsVersion,
+		Probed:              time.Now().Unix(),
+	}
+	logger.Info("Node %v runs gluster %v (op-version %v), gluster-block: %v",
+		n.Info.Id, nc.GlusterVersion, nc.OpVersion, nc.GlusterBlock)
+	return nil
+}
+
+// saveCapabilities stores the capabilities of the node in the db,
+// leaving the rest of the stored node unchanged.
+func (n *NodeEntry) saveCapabilities(db wdb.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		// Reload node in current transaction
+		entry, err := NewNodeEntryFromId(tx, n.Info.Id)
+		if err != nil {
+			return err
+		}
+		entry.Capabilities = n.Capabilities
+		return entry.Save(tx)
+	})
+}
+
+// The checks below give nodes that have never been probed, such as
+// nodes added by older versions of heketi, the benefit of the doubt.
+
+// hasGlusterBlock returns false if the node is known to lack
+// gluster-block.
+func (n *NodeEntry) hasGlusterBlock() bool {
+	return n.Capabilities == nil || n.Capabilities.GlusterBlock
+}
+
+// hasLvmJson returns false if the lvm tools of the node are known
+// to be unable to report in json.
+func (n *NodeEntry) hasLvmJson() bool {
+	return n.Capabilities == nil || n.Capabilities.LvmJson
+}
+
+// supportsOpVersion returns false if the gluster of the node is known
+// to be older than op-version v.
+func (n *NodeEntry) supportsOpVersion(v int) bool {
+	return n.Capabilities == nil ||
+		n.Capabilities.OpVersion == 0 ||
+		n.Capabilities.OpVersion >= v
+}
+
+// nodeBelowOpVersion returns the first node of the cluster known
+// to run a gluster older than op-version v, or nil if there is none.
+func (c *ClusterEntry) nodeBelowOpVersion(tx *bolt.Tx, v int) (*NodeEntry, error) {
+	for _, id := range c.Info.Nodes {
+		node, err := NewNodeEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !node.supportsOpVersion(v) {
+			return node, nil
+		}
+	}
+	return nil, nil
+}
+
+// hasGlusterBlockNode returns true if any node of the cluster may
+// have gluster-block.
+func (c *ClusterEntry) hasGlusterBlockNode(tx *bolt.Tx) (bool, error) {
+	for _, id := range c.Info.Nodes {
+		node, err := NewNodeEntryFromId(tx, id)
+		if err != nil {
+			return false, err
+		}
+		if node.hasGlusterBlock() {
+			return true, nil
+		}
+	}
+	// an empty cluster has no nodes to rule out
+	return len(c.Info.Nodes) == 0, nil
+}
+
+// blockStorageHosts returns the storage hostnames among hosts whose
+// nodes in the cluster may have gluster-block.
+func (c *ClusterEntry) blockStorageHosts(tx *bolt.Tx, hosts []string) ([]string, error) {
+	lacking := map[string]bool{}
+	for _, id := range c.Info.Nodes {
+		node, err := NewNodeEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !node.hasGlusterBlock() {
+			lacking[node.StorageHostName()] = true
+		}
+	}
+	usable := []string{}
+	for _, h := range hosts {
+		if !lacking[h] {
+			usable = append(usable, h)
+		}
+	}
+	return usable, nil
+}
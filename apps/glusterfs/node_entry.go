@@ -20,6 +20,7 @@ import (
 	wdb "github.com/heketi/heketi/pkg/db"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/heketi/pkg/idgen"
+	rexssh "github.com/heketi/heketi/pkg/remoteexec/ssh"
 	"github.com/heketi/heketi/pkg/sortedstrings"
 	"github.com/lpabon/godbc"
 )
@@ -29,6 +30,14 @@ type NodeEntry struct {
 
 	Info    api.NodeInfo
 	Devices sort.StringSlice
+
+	// HostKey is the ssh host key, in authorized keys format,
+	// pinned for the node when it was added
+	HostKey string
+
+	// Capabilities describes the software found on the node when
+	// it was last probed. It is nil if the node was never probed.
+	Capabilities *api.NodeCapabilities
 }
 
 func NewNodeEntry() *NodeEntry {
@@ -48,6 +57,7 @@ func NewNodeEntryFromRequest(req *api.NodeAddRequest) *NodeEntry {
 	node.Info.Hostnames = req.Hostnames
 	node.Info.Zone = req.Zone
 	node.Info.Tags = copyTags(req.Tags)
+	node.Info.Connection = req.Connection
 
 	return node
 }
@@ -64,6 +74,36 @@ func NewNodeEntryFromId(tx *bolt.Tx, id string) (*NodeEntry, error) {
 	return entry, nil
 }
 
+// HostConnection returns the connection settings for the node,
+// combining those of the node's cluster and the node itself.
+// It returns nil if neither has any settings.
+func (n *NodeEntry) HostConnection(c *ClusterEntry) *executors.HostConnection {
+	var hc *executors.HostConnection
+	for _, cs := range []*api.ConnectionSettings{
+		c.Info.Connection, n.Info.Connection} {
+
+		if cs == nil {
+			continue
+		}
+		if hc == nil {
+			hc = &executors.HostConnection{}
+		}
+		if cs.User != "" {
+			hc.User = cs.User
+		}
+		if cs.Port != "" {
+			hc.Port = cs.Port
+		}
+		if cs.Key != "" {
+			hc.Key = cs.Key
+		}
+		if cs.Sudo != nil {
+			hc.Sudo = cs.Sudo
+		}
+	}
+	return hc
+}
+
 func (n *NodeEntry) registerManageKey(host string) string {
 	return "MANAGE" + host
 }
@@ -74,6 +114,19 @@ func (n *NodeEntry) registerStorageKey(host string) string {
 
 // Verify gluster process in the node and return the manage hostname of a node in the cluster
 func GetVerifiedManageHostname(db wdb.RODB, e executors.Executor, clusterId string) (string, error) {
+	return getVerifiedManageHostname(db, e, clusterId, nil)
+}
+
+// GetVerifiedBlockManageHostname is like GetVerifiedManageHostname
+// but skips nodes known to lack gluster-block.
+func GetVerifiedBlockManageHostname(db wdb.RODB, e executors.Executor, clusterId string) (string, error) {
+	return getVerifiedManageHostname(db, e, clusterId,
+		(*NodeEntry).hasGlusterBlock)
+}
+
+func getVerifiedManageHostname(db wdb.RODB, e executors.Executor,
+	clusterId string, usable func(*NodeEntry) bool) (string, error) {
+
 	godbc.Require(clusterId != "")
 	var cluster *ClusterEntry
 	var node *NodeEntry
@@ -105,6 +158,9 @@ func GetVerifiedManageHostname(db wdb.RODB, e executors.Executor, clusterId stri
 		if !newNode.isOnline() {
 			continue
 		}
+		if usable != nil && !usable(newNode) {
+			continue
+		}
 		err = e.GlusterdCheck(newNode.ManageHostName())
 		if err != nil {
 			logger.Info("Glusterd not running in %v", newNode.ManageHostName())
@@ -281,7 +337,10 @@ func (n *NodeEntry) Delete(tx *bolt.Tx) error {
 	return EntryDelete(tx, n, n.Info.Id)
 }
 
-func (n *NodeEntry) SetState(db wdb.DB, e executors.Executor,
+// SetState changes the state of the node. Moving the node to the
+// failed state removes the bricks of its devices with operations run
+// by run.
+func (n *NodeEntry) SetState(db wdb.DB, run operationRunner,
 	s api.EntryState) error {
 
 	// Check current state
@@ -353,7 +412,7 @@ func (n *NodeEntry) SetState(db wdb.DB, e executors.Executor,
 					}
 					return nil
 				})
-				err = d.Remove(db, e)
+				err = d.Remove(db, run)
 				if err != nil {
 					if err == ErrNoReplacement {
 						return logger.LogError("Unable to remove node [%v] as no device was found to replace device [%v]", n.Info.Id, d.Id())
@@ -394,6 +453,12 @@ func (n *NodeEntry) NewInfoReponse(tx *bolt.Tx) (*api.NodeInfoResponse, error) {
 	info.State = n.State
 	info.DevicesInfo = make([]api.DeviceInfoResponse, 0)
 	info.Tags = copyTags(n.Info.Tags)
+	info.Connection = n.Info.Connection
+	info.HostKeyFingerprint = rexssh.HostKeyFingerprint(n.HostKey)
+	if n.Capabilities != nil {
+		nc := *n.Capabilities
+		info.Capabilities = &nc
+	}
 
 	// Add each drive information
 	for _, deviceid := range n.Devices {
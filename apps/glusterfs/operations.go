@@ -135,6 +135,13 @@ func (om *OperationManager) Id() string {
 	return om.op.Id
 }
 
+// OperationType returns the type of this operation's pending
+// operation entry. The type is only known after the operation
+// has been built.
+func (om *OperationManager) OperationType() PendingOperationType {
+	return om.op.Type
+}
+
 // MarkFailed marks the pending operation entry associated with
 // the operation as failed.
 func (om *OperationManager) MarkFailed() error {
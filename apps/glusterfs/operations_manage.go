@@ -10,14 +10,18 @@
 package glusterfs
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/lpabon/godbc"
 
 	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/heketi/pkg/idgen"
+	"github.com/heketi/heketi/pkg/utils"
 )
 
 type OpClass int
@@ -38,6 +42,23 @@ type OpTracker struct {
 	lock      sync.RWMutex
 	normalOps map[string]bool
 	bgOps     map[string]bool
+	details   map[string]*TrackedOperation
+}
+
+// TrackedOperation holds the details the OpTracker keeps about
+// an in-flight operation.
+type TrackedOperation struct {
+	Id       string
+	Label    string
+	Started  time.Time
+	Deadline time.Time
+	Stuck    bool
+}
+
+// overdue returns true if the operation has a deadline and the
+// deadline has passed.
+func (t *TrackedOperation) overdue(now time.Time) bool {
+	return !t.Deadline.IsZero() && now.After(t.Deadline)
 }
 
 func newOpTracker(limit uint64) *OpTracker {
@@ -45,6 +66,7 @@ func newOpTracker(limit uint64) *OpTracker {
 		Limit:     limit,
 		normalOps: make(map[string]bool),
 		bgOps:     make(map[string]bool),
+		details:   make(map[string]*TrackedOperation),
 	}
 }
 
@@ -60,6 +82,10 @@ func (ot *OpTracker) insert(id string, c OpClass) error {
 	default:
 		ot.normalOps[id] = true
 	}
+	ot.details[id] = &TrackedOperation{
+		Id:      id,
+		Started: opTrackerNow(),
+	}
 	return nil
 }
 
@@ -76,8 +102,61 @@ func (ot *OpTracker) Remove(id string) {
 	defer ot.lock.Unlock()
 	godbc.Require(id != "", "id must not be empty")
 	godbc.Require(ot.normalOps[id] || ot.bgOps[id], "id not tracked", id)
+	ot.remove(id)
+}
+
+func (ot *OpTracker) remove(id string) {
 	delete(ot.normalOps, id)
 	delete(ot.bgOps, id)
+	delete(ot.details, id)
+}
+
+// Describe sets the label and the deadline of a tracked operation.
+// A timeout of zero means the operation has no deadline.
+func (ot *OpTracker) Describe(id, label string, timeout time.Duration) {
+	ot.lock.Lock()
+	defer ot.lock.Unlock()
+	t, ok := ot.details[id]
+	if !ok {
+		logger.Debug("id [%v] not tracked", id)
+		return
+	}
+	t.Label = label
+	if timeout > 0 {
+		t.Deadline = t.Started.Add(timeout)
+	}
+}
+
+// MarkOverdue marks all tracked operations that have passed their
+// deadline as stuck. It returns copies of the operations that became
+// stuck since the last call.
+func (ot *OpTracker) MarkOverdue() []TrackedOperation {
+	ot.lock.Lock()
+	defer ot.lock.Unlock()
+	now := opTrackerNow()
+	out := []TrackedOperation{}
+	for _, t := range ot.details {
+		if t.Stuck || !t.overdue(now) {
+			continue
+		}
+		t.Stuck = true
+		out = append(out, *t)
+	}
+	return out
+}
+
+// Stuck returns a mapping of the IDs of tracked operations that
+// have passed their deadlines to booleans. Booleans are always true.
+func (ot *OpTracker) Stuck() map[string]bool {
+	ot.lock.RLock()
+	defer ot.lock.RUnlock()
+	out := map[string]bool{}
+	for k, t := range ot.details {
+		if t.Stuck {
+			out[k] = true
+		}
+	}
+	return out
 }
 
 // Get returns the number of operations currently tracked.
@@ -136,6 +215,35 @@ func (ot *OpTracker) ThrottleOrAdd(id string, c OpClass) bool {
 	return false
 }
 
+// ThrottleOrAddAll is ThrottleOrAdd for a group of normal
+// operations. It returns true, and adds none of the operations,
+// if adding all of them would put the number of operations over
+// the limit.
+func (ot *OpTracker) ThrottleOrAddAll(ids []string) bool {
+	ot.lock.Lock()
+	defer ot.lock.Unlock()
+	n := len(ot.normalOps)
+	if uint64(n+len(ids)) > ot.Limit {
+		logger.Warning(
+			"operations in-flight (%v) plus %v new exceeds limit (%v)",
+			n, len(ids), ot.Limit)
+		return true
+	}
+	for i, id := range ids {
+		err := ot.insert(id, TrackNormal)
+		if err == ErrConflict {
+			logger.Warning("operation [%v] already tracked, throttling", id)
+			for _, added := range ids[:i] {
+				ot.remove(added)
+			}
+			return true
+		} else if err != nil {
+			panic(err)
+		}
+	}
+	return false
+}
+
 // ThrottleOrToken exists for use cases where throttling is required
 // but a pre-existing unique identifier does not. It will return
 // true and an empty-string if the number of operations is over the limit,
@@ -150,10 +258,13 @@ func (ot *OpTracker) ThrottleOrToken() (bool, string) {
 }
 
 func runOperationAfterBuild(o Operation,
-	executor executors.Executor) (err error) {
+	executor executors.Executor, retries retryPolicy) (err error) {
 
 	label := o.Label()
-	max_tries := o.MaxRetries() + 1
+	max_tries := retries.maxRetries(o) + 1
+
+	// tag the commands run on the nodes with the operation
+	executor = executors.ForOperation(executor, o.Id())
 
 	for attempt := 1; ; attempt++ {
 		logger.Info("Trying %v (attempt #%v/%v)", label, attempt, max_tries)
@@ -166,10 +277,8 @@ func runOperationAfterBuild(o Operation,
 
 		logger.LogError("%v Failed: %v", label, err)
 
-		oerr, isRetryError := err.(OperationRetryError)
-		if isRetryError {
-			err = oerr.OriginalError
-		}
+		var isRetryError bool
+		err, isRetryError = retryableError(err)
 
 		if rerr := o.Rollback(executor); rerr != nil {
 			logger.LogError("%v Rollback error: %v", label, rerr)
@@ -187,6 +296,10 @@ func runOperationAfterBuild(o Operation,
 			return err
 		}
 
+		if delay := retries.backoff.Delay(attempt + 1); delay > 0 {
+			logger.Info("Waiting %v before retrying %v", delay, label)
+			retrySleep(delay)
+		}
 		logger.Info("Retrying %v", label)
 
 		if err := o.Build(); err != nil {
@@ -199,6 +312,15 @@ func runOperationAfterBuild(o Operation,
 	return o.Finalize()
 }
 
+// asyncIdOperation is implemented by operations that keep their
+// results under the operation id. The async request of such an
+// operation uses the same id so that clients can find the results
+// even if the operation fails.
+type asyncIdOperation interface {
+	Operation
+	keepsResults()
+}
+
 // AsyncHttpOperation runs all the steps of an operation with the long-running
 // parts wrapped in an async http function. If AsyncHttpOperation returns nil
 // then it has started the async function and the caller should respond to the
@@ -224,27 +346,118 @@ func AsyncHttpOperation(app *App,
 		return err
 	}
 
-	app.asyncManager.AsyncHttpRedirectFunc(w, r, func() (string, error) {
+	app.optracker.Describe(op.Id(), label, app.deadlines.timeout(op))
+	app.events.Record(api.Event{
+		Type:  api.EventOperationStarted,
+		Id:    op.Id(),
+		Label: label,
+	})
+
+	redirect := app.asyncManager.AsyncHttpRedirectFunc
+	if _, ok := op.(asyncIdOperation); ok {
+		redirect = func(w http.ResponseWriter, r *http.Request,
+			f func() (string, error)) {
+			app.asyncManager.AsyncHttpRedirectUsing(w, r, op.Id(), f)
+		}
+	}
+	redirect(w, r, func() (string, error) {
 		// decrement the op counter once the operation is done
 		// either success or failure
 		defer app.optracker.Remove(op.Id())
 		logger.Info("Started async operation: %v", label)
-		if err := runOperationAfterBuild(op, app.executor); err != nil {
+		ctx, cancel := app.operationContext(r, op)
+		defer cancel()
+		started := time.Now()
+		err := runOperationAfterBuild(op,
+			executors.WithContext(app.executor, ctx), app.retries)
+		app.opstats.Observe(label, err, time.Since(started))
+		if err != nil {
+			app.events.Record(api.Event{
+				Type:  api.EventOperationFailed,
+				Id:    op.Id(),
+				Label: label,
+				Error: err.Error(),
+			})
 			return "", err
 		}
+		app.events.Record(api.Event{
+			Type:  api.EventOperationFinished,
+			Id:    op.Id(),
+			Label: label,
+		})
 
 		return op.ResourceUrl(), nil
 	})
 	return nil
 }
 
+// detachedContext keeps the values of a context but is never done.
+// It lets work started by a request outlive the request.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (dc detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (dc detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (dc detachedContext) Err() error {
+	return nil
+}
+
+func (dc detachedContext) Value(key interface{}) interface{} {
+	return dc.parent.Value(key)
+}
+
+// operationContext returns the context the commands of an operation
+// started by the request r run within. The context keeps the values
+// of the request context but is not cancelled when the request ends.
+// If stuck operations are to be cancelled it ends at the deadline
+// of the operation.
+func (a *App) operationContext(r *http.Request, o Operation) (
+	context.Context, context.CancelFunc) {
+
+	ctx := context.Context(detachedContext{r.Context()})
+	if d := a.deadlines.timeout(o); d > 0 && a.conf.OperationTimeouts.CancelStuck {
+		return context.WithDeadline(ctx, time.Now().Add(d))
+	}
+	return context.WithCancel(ctx)
+}
+
+// requestExecutor returns the executor for the commands run in the
+// background for the request r. The commands run within a context
+// that keeps the values of the request context but is not cancelled
+// when the request ends.
+func (a *App) requestExecutor(r *http.Request) executors.Executor {
+	return executors.WithContext(a.executor, detachedContext{r.Context()})
+}
+
 // RunOperation performs all steps of an Operation and returns
 // an error if any of those steps fail. This function is meant to
 // make it easy to run an operation outside of the rest endpoints
-// and should only be used in test code.
+// and should only be used in test code. The operation is retried
+// as many times as it allows, without a delay.
 func RunOperation(o Operation,
 	executor executors.Executor) (err error) {
 
+	return operationRunner{executor: executor}.run(o)
+}
+
+// operationRunner runs operations that are part of a larger change,
+// such as the device removals of a node state change, with the same
+// executor and retry policy as the operations of the rest endpoints.
+type operationRunner struct {
+	executor executors.Executor
+	retries  retryPolicy
+}
+
+// run performs all steps of the operation and returns an error if
+// any of those steps fail.
+func (or operationRunner) run(o Operation) (err error) {
 	label := o.Label()
 	defer func() {
 		if err != nil {
@@ -258,7 +471,7 @@ func RunOperation(o Operation,
 		return err
 	}
 
-	return runOperationAfterBuild(o, executor)
+	return runOperationAfterBuild(o, or.executor, or.retries)
 }
 
 // rollbackViaClean runs a CleanableOperation's clean methods as
@@ -303,7 +516,8 @@ func markFailedIfSupported(o Operation) error {
 
 // OperationHttpErrorf writes the appropriate http error responses for
 // errors returned from AsyncHttpOperation, as well as formatting the
-// given error response string.
+// given error response string. The code and details of the response
+// are the ones of the error.
 func OperationHttpErrorf(
 	w http.ResponseWriter, e error, f string, v ...interface{}) {
 
@@ -317,5 +531,7 @@ func OperationHttpErrorf(
 		msg = fmt.Sprintf(f, v...)
 	}
 
-	http.Error(w, msg, status)
+	ae := *apiError(e)
+	ae.Message = msg
+	utils.WriteError(w, &ae, status)
 }
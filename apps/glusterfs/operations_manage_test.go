@@ -0,0 +1,79 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/heketi/tests"
+)
+
+type testContextKey struct{}
+
+func TestOperationContextOutlivesRequest(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	rctx, rcancel := context.WithCancel(
+		context.WithValue(context.Background(), testContextKey{}, "v"))
+	r := httptest.NewRequest("POST", "/volumes", nil).WithContext(rctx)
+
+	op := NewVolumeCreateOperation(NewVolumeEntry(), app.db)
+	op.op.Type = OperationCreateVolume
+	ctx, cancel := app.operationContext(r, op)
+	defer cancel()
+
+	// the request ends once the operation is accepted
+	rcancel()
+	tests.Assert(t, ctx.Err() == nil, "expected ctx to be live, got", ctx.Err())
+	tests.Assert(t, ctx.Value(testContextKey{}) == "v",
+		"expected the values of the request")
+	_, ok := ctx.Deadline()
+	tests.Assert(t, !ok, "expected no deadline")
+
+	cancel()
+	tests.Assert(t, ctx.Err() == context.Canceled,
+		"expected ctx to be cancelled, got", ctx.Err())
+}
+
+func TestOperationContextDeadline(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	app.deadlines = newOperationDeadlines(OperationTimeoutConfig{
+		VolumeCreate: 60,
+	})
+	r := httptest.NewRequest("POST", "/volumes", nil)
+	op := NewVolumeCreateOperation(NewVolumeEntry(), app.db)
+	op.op.Type = OperationCreateVolume
+
+	// deadlines only end the context if stuck operations get cancelled
+	ctx, cancel := app.operationContext(r, op)
+	_, ok := ctx.Deadline()
+	tests.Assert(t, !ok, "expected no deadline")
+	cancel()
+
+	app.conf.OperationTimeouts.CancelStuck = true
+	ctx, cancel = app.operationContext(r, op)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	tests.Assert(t, ok, "expected a deadline")
+	left := time.Until(deadline)
+	tests.Assert(t, left > 50*time.Second && left <= 60*time.Second,
+		"expected a deadline in about 60s, got", left)
+}
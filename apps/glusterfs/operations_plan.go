@@ -0,0 +1,331 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/cmdexec"
+	"github.com/heketi/heketi/executors/planexec"
+	wdb "github.com/heketi/heketi/pkg/db"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// planChange is a function that makes a change using the given db
+// and executor.
+type planChange func(db wdb.DB, executor executors.Executor) error
+
+// planSnapshot holds the parts of the db that are compared before and
+// after a planned change.
+type planSnapshot struct {
+	bricks       map[string]api.BrickInfo
+	devices      map[string]api.DeviceInfo
+	deviceNodes  map[string]string
+	nodeClusters map[string]string
+	volumes      map[string]string
+	blockVolumes map[string]string
+}
+
+// isDryRun returns true if the request asks for the plan of a change
+// rather than the change itself.
+func isDryRun(r *http.Request) bool {
+	dryRun, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return err == nil && dryRun
+}
+
+// planOperation runs the steps of an operation without the retries
+// and rollback of a normal run. Any failure simply ends the plan.
+func planOperation(newOp func(db wdb.DB) Operation) planChange {
+	return func(db wdb.DB, executor executors.Executor) error {
+		op := newOp(db)
+		if err := op.Build(); err != nil {
+			return err
+		}
+		if err := op.Exec(executor); err != nil {
+			return err
+		}
+		return op.Finalize()
+	}
+}
+
+// planCmdConfig returns the configuration used to render the
+// commands of a plan.
+func (a *App) planCmdConfig() *cmdexec.CmdConfig {
+	switch a.conf.Executor {
+	case "local", "inject/local", "record/local", "gd2/local":
+		return &a.conf.LocalConfig.CmdConfig
+	case "sim":
+		return &cmdexec.CmdConfig{
+			RebalanceOnExpansion: a.conf.SimConfig.RebalanceOnExpansion,
+			SnapShotLimit:        a.conf.SimConfig.SnapShotLimit,
+		}
+	case "mock", "inject/mock":
+		return &cmdexec.CmdConfig{}
+	default:
+		return &a.conf.SshConfig.CmdConfig
+	}
+}
+
+// PlanHttpResponse makes the change within a db transaction that is
+// thrown away and a plan executor that only records the commands it
+// would run. The resulting plan is written to the response.
+// The transaction is on a copy of the db so that other writers are
+// not blocked while the plan queries the nodes.
+func (a *App) PlanHttpResponse(w http.ResponseWriter,
+	label string, change planChange) {
+
+	plan, err := a.plan(label, change)
+	if err != nil {
+		OperationHttpErrorf(w, err, "Failed to plan %v: %v", label, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) plan(label string, change planChange) (*api.OperationPlan, error) {
+	db, err := a.planDB()
+	if err != nil {
+		return nil, err
+	}
+	defer removePlanDB(db)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	// nothing done while planning is ever committed
+	defer tx.Rollback()
+
+	before, err := newPlanSnapshot(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	pe := planexec.NewPlanExecutor(a.executor, a.planCmdConfig())
+	logger.Info("Planning %v", label)
+	if err := change(wdb.WrapTx(tx), pe); err != nil {
+		logger.LogError("Planning %v failed: %v", label, err)
+		return nil, err
+	}
+
+	after, err := newPlanSnapshot(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := before.diff(after)
+	plan.Operation = label
+	for _, c := range pe.Commands() {
+		plan.Commands = append(plan.Commands, api.PlannedCommand{
+			Host:    c.Host,
+			Command: c.Command,
+		})
+	}
+	return plan, nil
+}
+
+// planDB returns a copy of the db for a plan to change. The copy
+// must be removed with removePlanDB.
+func (a *App) planDB() (*bolt.DB, error) {
+	f, err := ioutil.TempFile("", "heketi-plan-")
+	if err != nil {
+		return nil, err
+	}
+	err = a.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(f)
+		return err
+	})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	db, err := OpenDB(f.Name(), false)
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return db, nil
+}
+
+func removePlanDB(db *bolt.DB) {
+	path := db.Path()
+	db.Close()
+	if err := os.Remove(path); err != nil {
+		logger.LogError("Unable to remove plan db %v: %v", path, err)
+	}
+}
+
+func newPlanSnapshot(tx *bolt.Tx) (*planSnapshot, error) {
+	s := &planSnapshot{
+		bricks:       map[string]api.BrickInfo{},
+		devices:      map[string]api.DeviceInfo{},
+		deviceNodes:  map[string]string{},
+		nodeClusters: map[string]string{},
+		volumes:      map[string]string{},
+		blockVolumes: map[string]string{},
+	}
+
+	ids, err := BrickList(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		b, err := NewBrickEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		s.bricks[id] = b.Info
+	}
+
+	ids, err = DeviceList(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		d, err := NewDeviceEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		s.devices[id] = d.Info
+		s.deviceNodes[id] = d.NodeId
+	}
+
+	ids, err = NodeList(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if strings.HasPrefix(id, "MANAGE") ||
+			strings.HasPrefix(id, "STORAGE") {
+			continue
+		}
+		n, err := NewNodeEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		s.nodeClusters[id] = n.Info.ClusterId
+	}
+
+	ids, err = VolumeList(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		v, err := NewVolumeEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		s.volumes[id] = v.Info.Cluster
+	}
+
+	ids, err = BlockVolumeList(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		bv, err := NewBlockVolumeEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		s.blockVolumes[id] = bv.Info.Cluster
+	}
+	return s, nil
+}
+
+// diff returns a plan describing the bricks and storage that differ
+// between the two snapshots.
+func (s *planSnapshot) diff(after *planSnapshot) *api.OperationPlan {
+	plan := &api.OperationPlan{
+		Bricks:        []api.BrickInfo{},
+		RemovedBricks: []api.BrickInfo{},
+		Devices:       []api.PlannedDeviceUsage{},
+		Commands:      []api.PlannedCommand{},
+	}
+	clusters := map[string]bool{}
+
+	for id, b := range after.bricks {
+		if _, found := s.bricks[id]; !found {
+			plan.Bricks = append(plan.Bricks, b)
+			clusters[after.nodeClusters[b.NodeId]] = true
+		}
+	}
+	for id, b := range s.bricks {
+		if _, found := after.bricks[id]; !found {
+			plan.RemovedBricks = append(plan.RemovedBricks, b)
+			clusters[s.nodeClusters[b.NodeId]] = true
+		}
+	}
+	sort.Slice(plan.Bricks, func(i, j int) bool {
+		return plan.Bricks[i].Path < plan.Bricks[j].Path
+	})
+	sort.Slice(plan.RemovedBricks, func(i, j int) bool {
+		return plan.RemovedBricks[i].Path < plan.RemovedBricks[j].Path
+	})
+
+	for id, d := range s.devices {
+		da, found := after.devices[id]
+		if !found || da.Storage == d.Storage {
+			continue
+		}
+		used := int64(da.Storage.Used) - int64(d.Storage.Used)
+		plan.Devices = append(plan.Devices, api.PlannedDeviceUsage{
+			Id:         id,
+			NodeId:     s.deviceNodes[id],
+			Name:       d.Name,
+			Used:       used,
+			FreeBefore: d.Storage.Free,
+			FreeAfter:  da.Storage.Free,
+		})
+		plan.SpaceUsed += used
+	}
+	sort.Slice(plan.Devices, func(i, j int) bool {
+		return plan.Devices[i].Id < plan.Devices[j].Id
+	})
+
+	for _, m := range []struct{ before, after map[string]string }{
+		{s.volumes, after.volumes},
+		{s.blockVolumes, after.blockVolumes},
+	} {
+		for id, c := range m.after {
+			if _, found := m.before[id]; !found {
+				clusters[c] = true
+			}
+		}
+		for id, c := range m.before {
+			if _, found := m.after[id]; !found {
+				clusters[c] = true
+			}
+		}
+	}
+	delete(clusters, "")
+	if len(clusters) == 1 {
+		for c := range clusters {
+			plan.Cluster = c
+		}
+	}
+	return plan
+}
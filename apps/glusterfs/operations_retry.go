@@ -0,0 +1,195 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"time"
+
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+var (
+	// errors that retrying an operation will not resolve
+	permanentErrors = []error{
+		ErrNoSpace, ErrMaxBricks, ErrMinimumBrickSize,
+		ErrEmptyCluster, ErrNoStorage, ErrNotFound, ErrConflict,
+	}
+
+	// retrySleep can be overridden in test code to avoid waiting
+	retrySleep = time.Sleep
+)
+
+// retryPolicy holds the number of times operations may be retried
+// after the first attempt fails with a retryable error, and the
+// delay between the attempts. The zero value retries operations as
+// many times as the operations allow, without a delay.
+type retryPolicy struct {
+	// limits maps the type of an operation to its number of
+	// retries. Types not in the map use the limit of the operation.
+	limits  map[PendingOperationType]int
+	backoff RetryBackoff
+}
+
+// newRetryPolicy returns the retry policy of the operation retry
+// configuration.
+func newRetryPolicy(rc RetryLimitConfig) retryPolicy {
+	rp := retryPolicy{
+		limits: map[PendingOperationType]int{},
+	}
+	rp.setLimit(OperationCreateVolume, rc.VolumeCreate)
+	rp.setLimit(OperationDeleteVolume, rc.VolumeDelete)
+	rp.setLimit(OperationExpandVolume, rc.VolumeExpand)
+	rp.setLimit(OperationCloneVolume, rc.VolumeClone)
+	rp.setLimit(OperationCreateBlockVolume, rc.BlockVolumeCreate)
+	rp.setLimit(OperationDeleteBlockVolume, rc.BlockVolumeDelete)
+	rp.setLimit(OperationRemoveDevice, rc.DeviceRemove)
+
+	if rc.Backoff.InitialMs > 0 {
+		rp.backoff = RetryBackoff{
+			Initial:    time.Duration(rc.Backoff.InitialMs) * time.Millisecond,
+			Max:        time.Duration(rc.Backoff.MaxMs) * time.Millisecond,
+			Multiplier: rc.Backoff.Multiplier,
+		}
+	}
+	return rp
+}
+
+// setLimit sets the number of retries for operations of the given
+// type. A value of zero keeps the limit of the operations and a
+// negative value disables retries.
+func (rp retryPolicy) setLimit(t PendingOperationType, limit int) {
+	switch {
+	case limit < 0:
+		rp.limits[t] = 0
+	case limit > 0:
+		rp.limits[t] = limit
+	}
+}
+
+// maxRetries returns the number of times the operation may be
+// retried. The operation must have been built.
+func (rp retryPolicy) maxRetries(o Operation) int {
+	if to, ok := o.(typedOperation); ok {
+		if limit, found := rp.limits[to.OperationType()]; found {
+			return limit
+		}
+	}
+	return o.MaxRetries()
+}
+
+// RetryBackoff describes an exponential backoff between attempts
+// of an operation.
+type RetryBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// Delay returns the amount of time to wait before starting the
+// given attempt. Attempts are numbered starting at one and the
+// first attempt never waits.
+func (rb RetryBackoff) Delay(attempt int) time.Duration {
+	if attempt <= 1 || rb.Initial <= 0 {
+		return 0
+	}
+	mult := rb.Multiplier
+	if mult < 1 {
+		mult = 2
+	}
+	d := float64(rb.Initial)
+	for i := 2; i < attempt; i++ {
+		d *= mult
+		if rb.Max > 0 && d >= float64(rb.Max) {
+			return rb.Max
+		}
+	}
+	return time.Duration(d)
+}
+
+// retryableError examines an error returned by the Exec phase of an
+// operation. It returns the error that should be reported to the
+// caller and true if the operation may succeed if it is attempted
+// again.
+func retryableError(err error) (error, bool) {
+	if oerr, ok := err.(OperationRetryError); ok {
+		// the operation asked for a retry but some errors will
+		// never go away by trying again
+		return oerr.OriginalError, !isPermanentError(oerr.OriginalError)
+	}
+	return err, isTransientError(err)
+}
+
+// isPermanentError returns true if the error indicates a condition
+// that will not be resolved by running the operation again, such
+// as failing to allocate space.
+func isPermanentError(err error) bool {
+	for _, perr := range permanentErrors {
+		if isError(err, perr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTransientError returns true if the error indicates a condition
+// that is likely to go away on its own, such as a failure to reach
+// a node. Errors wrapping a transient error are transient.
+// Aggregated errors are transient only if every error they contain
+// is transient.
+func isTransientError(err error) bool {
+	for _, e := range errorChain(err) {
+		switch e := e.(type) {
+		case *rex.ConnectionError:
+			return true
+		case *MultiHostError:
+			return allTransient(e.errors)
+		case *MultiClusterError:
+			return allTransient(e.errors)
+		}
+	}
+	return false
+}
+
+func allTransient(m map[string]error) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for _, err := range m {
+		if !isTransientError(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// errorChain returns the error followed by the errors it wraps.
+// An error wraps another error if it has an Unwrap method.
+func errorChain(err error) []error {
+	chain := []error{}
+	for err != nil {
+		chain = append(chain, err)
+		w, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = w.Unwrap()
+	}
+	return chain
+}
+
+// isError returns true if the error, or an error it wraps, is target.
+func isError(err, target error) bool {
+	for _, e := range errorChain(err) {
+		if e == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+	"github.com/heketi/tests"
+)
+
+// testWrapError wraps an error the way the executors do.
+type testWrapError struct {
+	err error
+}
+
+func (e *testWrapError) Error() string {
+	return fmt.Sprintf("wrapped: %v", e.err)
+}
+
+func (e *testWrapError) Unwrap() error {
+	return e.err
+}
+
+func TestRetryBackoffDelay(t *testing.T) {
+	rb := RetryBackoff{
+		Initial:    time.Second,
+		Max:        5 * time.Second,
+		Multiplier: 2,
+	}
+	tests.Assert(t, rb.Delay(1) == 0, "expected no delay, got", rb.Delay(1))
+	tests.Assert(t, rb.Delay(2) == time.Second,
+		"expected 1s, got", rb.Delay(2))
+	tests.Assert(t, rb.Delay(3) == 2*time.Second,
+		"expected 2s, got", rb.Delay(3))
+	tests.Assert(t, rb.Delay(4) == 4*time.Second,
+		"expected 4s, got", rb.Delay(4))
+	tests.Assert(t, rb.Delay(5) == 5*time.Second,
+		"expected delay capped at 5s, got", rb.Delay(5))
+	tests.Assert(t, rb.Delay(50) == 5*time.Second,
+		"expected delay capped at 5s, got", rb.Delay(50))
+
+	// a zero backoff never waits
+	tests.Assert(t, RetryBackoff{}.Delay(3) == 0,
+		"expected no delay, got", RetryBackoff{}.Delay(3))
+}
+
+func TestRetryPolicyLimits(t *testing.T) {
+	rp := newRetryPolicy(RetryLimitConfig{
+		VolumeCreate: 7,
+		VolumeDelete: -1,
+	})
+
+	vc := NewVolumeCreateOperation(NewVolumeEntry(), nil)
+	vc.op.Type = OperationCreateVolume
+	tests.Assert(t, rp.maxRetries(vc) == 7,
+		"expected 7, got", rp.maxRetries(vc))
+	vd := NewVolumeDeleteOperation(NewVolumeEntry(), nil)
+	vd.op.Type = OperationDeleteVolume
+	tests.Assert(t, rp.maxRetries(vd) == 0,
+		"expected 0, got", rp.maxRetries(vd))
+	// an unconfigured type keeps the limit of the operation
+	ve := NewVolumeExpandOperation(NewVolumeEntry(), nil, 10)
+	ve.op.Type = OperationExpandVolume
+	tests.Assert(t, rp.maxRetries(ve) == ve.MaxRetries(),
+		"expected", ve.MaxRetries(), "got", rp.maxRetries(ve))
+
+	// the zero policy keeps the limits of the operations
+	tests.Assert(t, retryPolicy{}.maxRetries(vc) == vc.MaxRetries(),
+		"expected", vc.MaxRetries(), "got", retryPolicy{}.maxRetries(vc))
+}
+
+func TestIsTransientError(t *testing.T) {
+	cerr := &rex.ConnectionError{Host: "h1", Err: errors.New("refused")}
+	tests.Assert(t, isTransientError(cerr), "expected transient")
+	tests.Assert(t, isTransientError(&testWrapError{cerr}),
+		"expected wrapped connection error to be transient")
+	tests.Assert(t, !isTransientError(errors.New("foo")),
+		"expected plain error not to be transient")
+	tests.Assert(t, !isTransientError(ErrNoSpace),
+		"expected ErrNoSpace not to be transient")
+
+	m := &MultiHostError{errors: map[string]error{
+		"h1": cerr,
+		"h2": &testWrapError{cerr},
+	}}
+	tests.Assert(t, isTransientError(m), "expected transient")
+	m.errors["h3"] = ErrNoSpace
+	tests.Assert(t, !isTransientError(m), "expected not transient")
+}
+
+func TestRetryableError(t *testing.T) {
+	cerr := &rex.ConnectionError{Host: "h1", Err: errors.New("refused")}
+
+	err, retry := retryableError(cerr)
+	tests.Assert(t, err == cerr, "expected the error unchanged, got", err)
+	tests.Assert(t, retry, "expected connection error to be retried")
+
+	err, retry = retryableError(OperationRetryError{
+		OriginalError: &testWrapError{ErrNoSpace},
+	})
+	tests.Assert(t, !retry, "expected wrapped ErrNoSpace not to be retried")
+	tests.Assert(t, isError(err, ErrNoSpace),
+		"expected original error, got", err)
+
+	_, retry = retryableError(OperationRetryError{
+		OriginalError: errors.New("brick allocation conflict"),
+	})
+	tests.Assert(t, retry, "expected retry requested by operation")
+}
+
+func TestErrorCodeWrapped(t *testing.T) {
+	tests.Assert(t, errorCode(ErrNoSpace) == api.ErrorCodeNoSpace,
+		"expected", api.ErrorCodeNoSpace, "got", errorCode(ErrNoSpace))
+	code := errorCode(&testWrapError{ErrNoSpace})
+	tests.Assert(t, code == api.ErrorCodeNoSpace,
+		"expected", api.ErrorCodeNoSpace, "got", code)
+	code = errorCode(&testWrapError{&testWrapError{ErrNotFound}})
+	tests.Assert(t, code == api.ErrorCodeNotFound,
+		"expected", api.ErrorCodeNotFound, "got", code)
+	code = errorCode(&testWrapError{
+		&rex.ConnectionError{Host: "h1", Err: errors.New("refused")}})
+	tests.Assert(t, code == api.ErrorCodeUnavailable,
+		"expected", api.ErrorCodeUnavailable, "got", code)
+	code = errorCode(errors.New("foo"))
+	tests.Assert(t, code == api.ErrorCodeInternal,
+		"expected", api.ErrorCodeInternal, "got", code)
+}
+
+func TestAppRunnerUsesRetryLimits(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	app, err := NewApp(&GlusterFSConfig{
+		DBfile:                tmpfile,
+		Executor:              "mock",
+		MaxInflightOperations: 64,
+		RetryLimits: RetryLimitConfig{
+			DeviceRemove: 3,
+			Backoff: RetryBackoffConfig{
+				InitialMs: 100,
+				MaxMs:     1000,
+			},
+		},
+	})
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	defer app.Close()
+
+	run := app.runner()
+	tests.Assert(t, run.executor == app.executor,
+		"expected the executor of the app")
+	op := NewDeviceRemoveOperation("abc", app.db)
+	op.op.Type = OperationRemoveDevice
+	tests.Assert(t, run.retries.maxRetries(op) == 3,
+		"expected 3, got", run.retries.maxRetries(op))
+	tests.Assert(t, run.retries.backoff.Delay(2) == 100*time.Millisecond,
+		"expected 100ms, got", run.retries.backoff.Delay(2))
+}
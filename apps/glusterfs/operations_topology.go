@@ -0,0 +1,592 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/heketi/heketi/executors"
+	wdb "github.com/heketi/heketi/pkg/db"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/idgen"
+)
+
+// topologyDiff returns the changes needed to make the topology in
+// the db match the requested topology. Nodes are matched by their
+// first manage hostname and devices by name. Missing nodes are only
+// disabled or removed in the clusters the topology resolves to, the
+// clusters of the known nodes it names.
+func topologyDiff(tx *bolt.Tx,
+	req *api.TopologyApplyRequest) (*api.TopologyDiff, error) {
+
+	diff := &api.TopologyDiff{Changes: []api.TopologyChange{}}
+	add := func(c api.TopologyChange) {
+		diff.Changes = append(diff.Changes, c)
+	}
+
+	ids, err := NodeList(tx)
+	if err != nil {
+		return nil, err
+	}
+	nodeIds := []string{}
+	nodes := map[string]*NodeEntry{}
+	nodesById := map[string]*NodeEntry{}
+	for _, id := range ids {
+		if strings.HasPrefix(id, "MANAGE") ||
+			strings.HasPrefix(id, "STORAGE") {
+			continue
+		}
+		nodeIds = append(nodeIds, id)
+		node, err := NewNodeEntryFromId(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		nodes[node.ManageHostName()] = node
+		nodesById[id] = node
+	}
+
+	// the nodes of the file, by hostname and by id, and the
+	// clusters of the db the clusters of the file resolve to
+	wanted := map[string]bool{}
+	found := map[string]bool{}
+	clusters := map[string]bool{}
+	for i, c := range req.Topology.Clusters {
+		// a cluster of the file is the cluster its known nodes are in
+		clusterId := ""
+		for _, n := range c.Nodes {
+			host := n.Node.Hostnames.Manage[0]
+			if wanted[host] {
+				return nil, fmt.Errorf("Node %v is in the topology more than once", host)
+			}
+			wanted[host] = true
+			node, ok := nodes[host]
+			if !ok {
+				continue
+			}
+			if clusterId != "" && clusterId != node.Info.ClusterId {
+				return nil, fmt.Errorf(
+					"Nodes of cluster %v of the topology are in clusters %v and %v",
+					i, clusterId, node.Info.ClusterId)
+			}
+			clusterId = node.Info.ClusterId
+		}
+		if clusterId != "" {
+			clusters[clusterId] = true
+		} else if len(c.Nodes) > 0 {
+			flags := api.ClusterFlags{
+				Block: c.Block == nil || *c.Block,
+				File:  c.File == nil || *c.File,
+			}
+			add(api.TopologyChange{
+				Change: api.TopologyAddCluster,
+				Flags:  &flags,
+			})
+		}
+
+		for _, n := range c.Nodes {
+			host := n.Node.Hostnames.Manage[0]
+			node, ok := nodes[host]
+			if !ok {
+				add(api.TopologyChange{
+					Change:    api.TopologyAddNode,
+					ClusterId: clusterId,
+					Hostname:  host,
+					Zone:      n.Node.Zone,
+					Tags:      n.Node.Tags,
+				})
+				for _, d := range n.Devices {
+					add(api.TopologyChange{
+						Change:    api.TopologyAddDevice,
+						ClusterId: clusterId,
+						Hostname:  host,
+						Device:    d.Name,
+						Tags:      d.Tags,
+					})
+				}
+				continue
+			}
+			found[node.Info.Id] = true
+
+			if node.Info.Zone != n.Node.Zone {
+				add(api.TopologyChange{
+					Change:    api.TopologySetNodeZone,
+					ClusterId: clusterId,
+					NodeId:    node.Info.Id,
+					Hostname:  host,
+					OldZone:   node.Info.Zone,
+					Zone:      n.Node.Zone,
+				})
+			}
+			if !tagsEqual(node.Info.Tags, n.Node.Tags) {
+				add(api.TopologyChange{
+					Change:    api.TopologySetNodeTags,
+					ClusterId: clusterId,
+					NodeId:    node.Info.Id,
+					Hostname:  host,
+					OldTags:   node.Info.Tags,
+					Tags:      n.Node.Tags,
+				})
+			}
+			err := topologyDeviceDiff(tx, node, n.Devices, req.Missing, add)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if req.Missing == api.TopologyMissingDisable ||
+		req.Missing == api.TopologyMissingRemove {
+
+		for _, id := range nodeIds {
+			node := nodesById[id]
+			if found[id] || !clusters[node.Info.ClusterId] {
+				continue
+			}
+			c := api.TopologyChange{
+				ClusterId: node.Info.ClusterId,
+				NodeId:    node.Info.Id,
+				Hostname:  node.ManageHostName(),
+			}
+			if req.Missing == api.TopologyMissingRemove {
+				c.Change = api.TopologyRemoveNode
+				add(c)
+			} else if node.State == api.EntryStateOnline {
+				c.Change = api.TopologyDisableNode
+				add(c)
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// topologyDeviceDiff adds the changes needed to make the devices of
+// a known node match the devices of the node in the topology.
+func topologyDeviceDiff(tx *bolt.Tx,
+	node *NodeEntry,
+	devices []*api.TopologyFileDevice,
+	missing api.TopologyMissing,
+	add func(api.TopologyChange)) error {
+
+	entries := []*DeviceEntry{}
+	known := map[string]*DeviceEntry{}
+	for _, id := range node.Devices {
+		d, err := NewDeviceEntryFromId(tx, id)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, d)
+		known[d.Info.Name] = d
+	}
+
+	wanted := map[string]bool{}
+	for _, d := range devices {
+		wanted[d.Name] = true
+		c := api.TopologyChange{
+			ClusterId: node.Info.ClusterId,
+			NodeId:    node.Info.Id,
+			Hostname:  node.ManageHostName(),
+			Device:    d.Name,
+		}
+		device, ok := known[d.Name]
+		if !ok {
+			c.Change = api.TopologyAddDevice
+			c.Tags = d.Tags
+			add(c)
+			continue
+		}
+		if !tagsEqual(device.Info.Tags, d.Tags) {
+			c.Change = api.TopologySetDeviceTags
+			c.DeviceId = device.Info.Id
+			c.OldTags = device.Info.Tags
+			c.Tags = d.Tags
+			add(c)
+		}
+	}
+
+	for _, device := range entries {
+		if wanted[device.Info.Name] {
+			continue
+		}
+		c := api.TopologyChange{
+			ClusterId: node.Info.ClusterId,
+			NodeId:    node.Info.Id,
+			DeviceId:  device.Info.Id,
+			Hostname:  node.ManageHostName(),
+			Device:    device.Info.Name,
+		}
+		switch {
+		case missing == api.TopologyMissingRemove:
+			c.Change = api.TopologyRemoveDevice
+			add(c)
+		case missing == api.TopologyMissingDisable &&
+			device.State == api.EntryStateOnline:
+			c.Change = api.TopologyDisableDevice
+			add(c)
+		}
+	}
+	return nil
+}
+
+// tagsEqual returns true if both sets of tags are the same. A nil
+// set of tags is the same as an empty set.
+func tagsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// TopologyApplyOperation implements the operation functions used to
+// make the topology match a topology file. The changes of the diff
+// are made in order and the first failure stops the apply.
+//
+// Changes that were made before a failure are kept. Since the apply
+// is declarative, running it again continues where it stopped.
+type TopologyApplyOperation struct {
+	noRetriesOperation
+	db wdb.DB
+	// the server's executor, which holds the connection settings
+	// and host keys of the nodes
+	hosts executors.Executor
+	id    string
+	req   *api.TopologyApplyRequest
+	diff  *api.TopologyDiff
+	// the retry policy of the device removals
+	retries retryPolicy
+
+	// the clusters and nodes added by the apply
+	newCluster string
+	newNodes   map[string]string
+}
+
+// NewTopologyApplyOperation returns a new TopologyApplyOperation for
+// the requested topology.
+func NewTopologyApplyOperation(
+	req *api.TopologyApplyRequest,
+	db wdb.DB, hosts executors.Executor) *TopologyApplyOperation {
+
+	return &TopologyApplyOperation{
+		db:       db,
+		hosts:    hosts,
+		id:       idgen.GenUUID(),
+		req:      req,
+		newNodes: map[string]string{},
+	}
+}
+
+func (tao *TopologyApplyOperation) Id() string {
+	return tao.id
+}
+
+func (tao *TopologyApplyOperation) Label() string {
+	return "Apply Topology"
+}
+
+func (tao *TopologyApplyOperation) ResourceUrl() string {
+	return "/topology"
+}
+
+// Build works out the changes to make. It does not change the db.
+func (tao *TopologyApplyOperation) Build() error {
+	return tao.db.View(func(tx *bolt.Tx) error {
+		var err error
+		tao.diff, err = topologyDiff(tx, tao.req)
+		return err
+	})
+}
+
+// Exec makes the changes of the diff.
+func (tao *TopologyApplyOperation) Exec(executor executors.Executor) error {
+	for i, c := range tao.diff.Changes {
+		logger.Info("Applying topology change %v/%v: %v %v %v",
+			i+1, len(tao.diff.Changes), c.Change, c.Hostname, c.Device)
+		if err := tao.apply(executor, c); err != nil {
+			return logger.LogError("Failed to %v %v %v: %v",
+				c.Change, c.Hostname, c.Device, err)
+		}
+	}
+	return nil
+}
+
+// Rollback keeps the changes already made.
+func (tao *TopologyApplyOperation) Rollback(executor executors.Executor) error {
+	return nil
+}
+
+func (tao *TopologyApplyOperation) Finalize() error {
+	return nil
+}
+
+func (tao *TopologyApplyOperation) apply(
+	executor executors.Executor, c api.TopologyChange) error {
+
+	switch c.Change {
+	case api.TopologyAddCluster:
+		return tao.addCluster(c)
+	case api.TopologyAddNode:
+		return tao.addNode(executor, c)
+	case api.TopologyAddDevice:
+		return tao.addDevice(executor, c)
+	case api.TopologySetNodeZone, api.TopologySetNodeTags:
+		return tao.db.Update(func(tx *bolt.Tx) error {
+			node, err := NewNodeEntryFromId(tx, c.NodeId)
+			if err != nil {
+				return err
+			}
+			if c.Change == api.TopologySetNodeZone {
+				node.Info.Zone = c.Zone
+			} else {
+				node.SetTags(copyTags(c.Tags))
+			}
+			return node.Save(tx)
+		})
+	case api.TopologySetDeviceTags:
+		return tao.db.Update(func(tx *bolt.Tx) error {
+			device, err := NewDeviceEntryFromId(tx, c.DeviceId)
+			if err != nil {
+				return err
+			}
+			device.SetTags(copyTags(c.Tags))
+			return device.Save(tx)
+		})
+	case api.TopologyDisableNode:
+		node, err := tao.node(c.NodeId)
+		if err != nil {
+			return err
+		}
+		return node.SetState(tao.db, tao.runner(executor),
+			api.EntryStateOffline)
+	case api.TopologyDisableDevice:
+		device, err := tao.device(c.DeviceId)
+		if err != nil {
+			return err
+		}
+		return device.SetState(tao.db, tao.runner(executor),
+			api.EntryStateOffline)
+	case api.TopologyRemoveDevice:
+		return tao.removeDevice(executor, c.DeviceId)
+	case api.TopologyRemoveNode:
+		return tao.removeNode(executor, c.NodeId)
+	default:
+		return fmt.Errorf("Unknown topology change: %v", c.Change)
+	}
+}
+
+// runner returns a runner for the operations of the state changes
+// made by the apply.
+func (tao *TopologyApplyOperation) runner(
+	executor executors.Executor) operationRunner {
+
+	return operationRunner{executor: executor, retries: tao.retries}
+}
+
+func (tao *TopologyApplyOperation) node(id string) (*NodeEntry, error) {
+	var node *NodeEntry
+	err := tao.db.View(func(tx *bolt.Tx) error {
+		var err error
+		node, err = NewNodeEntryFromId(tx, id)
+		return err
+	})
+	return node, err
+}
+
+func (tao *TopologyApplyOperation) device(id string) (*DeviceEntry, error) {
+	var device *DeviceEntry
+	err := tao.db.View(func(tx *bolt.Tx) error {
+		var err error
+		device, err = NewDeviceEntryFromId(tx, id)
+		return err
+	})
+	return device, err
+}
+
+// fileNode returns the node of the topology file with the given
+// manage hostname.
+func (tao *TopologyApplyOperation) fileNode(host string) (*api.TopologyFileNode, error) {
+	for _, c := range tao.req.Topology.Clusters {
+		for i := range c.Nodes {
+			if c.Nodes[i].Node.Hostnames.Manage[0] == host {
+				return &c.Nodes[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("Node %v is not in the topology", host)
+}
+
+func (tao *TopologyApplyOperation) addCluster(c api.TopologyChange) error {
+	cluster := NewClusterEntryFromRequest(&api.ClusterCreateRequest{
+		ClusterFlags: *c.Flags,
+	})
+	err := tao.db.Update(func(tx *bolt.Tx) error {
+		return cluster.Save(tx)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("Added cluster %v", cluster.Info.Id)
+	tao.newCluster = cluster.Info.Id
+	return nil
+}
+
+// addNode adds a node the same way as a node add request does.
+func (tao *TopologyApplyOperation) addNode(
+	executor executors.Executor, c api.TopologyChange) error {
+
+	fn, err := tao.fileNode(c.Hostname)
+	if err != nil {
+		return err
+	}
+	req := fn.Node
+	req.ClusterId = c.ClusterId
+	if req.ClusterId == "" {
+		req.ClusterId = tao.newCluster
+	}
+	node := NewNodeEntryFromRequest(&req)
+
+	var cluster *ClusterEntry
+	err = tao.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		cluster, err = NewClusterEntryFromId(tx, req.ClusterId)
+		if err != nil {
+			return err
+		}
+		return node.Register(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	peer, err := prepareNodeAdd(tao.db, tao.hosts, executor, node, cluster)
+	if err != nil {
+		return err
+	}
+	err = finishNodeAdd(tao.db, tao.hosts, executor, node, peer)
+	if err != nil {
+		return err
+	}
+	tao.newNodes[c.Hostname] = node.Info.Id
+	return nil
+}
+
+// addDevice adds a device the same way as a device add request does.
+func (tao *TopologyApplyOperation) addDevice(
+	executor executors.Executor, c api.TopologyChange) error {
+
+	nodeId := c.NodeId
+	if nodeId == "" {
+		nodeId = tao.newNodes[c.Hostname]
+	}
+	node, err := tao.node(nodeId)
+	if err != nil {
+		return err
+	}
+	if !node.hasLvmJson() {
+		return fmt.Errorf(
+			"LVM (version %q) on node %v does not support json reports",
+			node.Capabilities.LvmVersion, nodeId)
+	}
+
+	fn, err := tao.fileNode(c.Hostname)
+	if err != nil {
+		return err
+	}
+	destroy := false
+	for _, d := range fn.Devices {
+		if d.Name == c.Device {
+			destroy = d.DestroyData
+		}
+	}
+
+	device := NewDeviceEntryFromRequest(&api.DeviceAddRequest{
+		Device: api.Device{Name: c.Device, Tags: c.Tags},
+		NodeId: nodeId,
+	})
+	return setupDevice(tao.db, executor, node, device, destroy)
+}
+
+// removeDevice moves the bricks off a device and then deletes it.
+func (tao *TopologyApplyOperation) removeDevice(
+	executor executors.Executor, id string) error {
+
+	device, err := tao.device(id)
+	if err != nil {
+		return err
+	}
+	// a failed device has no bricks left to move
+	if device.State != api.EntryStateFailed {
+		for _, s := range []api.EntryState{
+			api.EntryStateOffline, api.EntryStateFailed} {
+
+			if err := device.SetState(tao.db, tao.runner(executor), s); err != nil {
+				return err
+			}
+		}
+	}
+	return tao.deleteDevice(executor, id)
+}
+
+// deleteDevice tears down an empty device and removes it from the db.
+func (tao *TopologyApplyOperation) deleteDevice(
+	executor executors.Executor, id string) error {
+
+	device, err := tao.device(id)
+	if err != nil {
+		return err
+	}
+	if err := device.CheckDelete(); err != nil {
+		return err
+	}
+	node, err := tao.node(device.NodeId)
+	if err != nil {
+		return err
+	}
+	return deleteDevice(tao.db, executor, node, device, false)
+}
+
+// removeNode moves the bricks off all the devices of a node, deletes
+// the devices and then removes the node from its cluster.
+func (tao *TopologyApplyOperation) removeNode(
+	executor executors.Executor, id string) error {
+
+	node, err := tao.node(id)
+	if err != nil {
+		return err
+	}
+	if node.State != api.EntryStateFailed {
+		for _, s := range []api.EntryState{
+			api.EntryStateOffline, api.EntryStateFailed} {
+
+			if err := node.SetState(tao.db, tao.runner(executor), s); err != nil {
+				return err
+			}
+		}
+	}
+	for _, deviceId := range node.Devices {
+		if err := tao.deleteDevice(executor, deviceId); err != nil {
+			return err
+		}
+	}
+
+	// reload the node now that it has no devices
+	node, err = tao.node(id)
+	if err != nil {
+		return err
+	}
+	return deleteNode(tao.db, tao.hosts, executor, node)
+}
@@ -0,0 +1,163 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/tests"
+)
+
+// testTopologyCluster saves a cluster with online nodes of the given
+// manage hostnames and returns the ids of the nodes by hostname.
+func testTopologyCluster(t *testing.T, tx *bolt.Tx,
+	hosts ...string) (*ClusterEntry, map[string]string) {
+
+	cluster := NewClusterEntryFromRequest(&api.ClusterCreateRequest{})
+	ids := map[string]string{}
+	for _, h := range hosts {
+		node := NewNodeEntryFromRequest(&api.NodeAddRequest{
+			Zone:      1,
+			Hostnames: api.HostAddresses{Manage: []string{h}, Storage: []string{h}},
+			ClusterId: cluster.Info.Id,
+		})
+		err := node.Save(tx)
+		tests.Assert(t, err == nil, "expected err == nil, got", err)
+		cluster.NodeAdd(node.Info.Id)
+		ids[h] = node.Info.Id
+	}
+	err := cluster.Save(tx)
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	return cluster, ids
+}
+
+func testTopologyFileNode(host string) api.TopologyFileNode {
+	return api.TopologyFileNode{
+		Node: api.NodeAddRequest{
+			Zone:      1,
+			Hostnames: api.HostAddresses{Manage: []string{host}, Storage: []string{host}},
+		},
+		Devices: []*api.TopologyFileDevice{},
+	}
+}
+
+func TestTopologyDiffMissingNodes(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	var a, b *ClusterEntry
+	var aIds, bIds map[string]string
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		a, aIds = testTopologyCluster(t, tx, "a1", "a2")
+		b, bIds = testTopologyCluster(t, tx, "b1")
+		return nil
+	})
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+
+	// the file only describes cluster a, with a new node
+	req := &api.TopologyApplyRequest{
+		Topology: api.TopologyFile{Clusters: []api.TopologyFileCluster{{
+			Nodes: []api.TopologyFileNode{
+				testTopologyFileNode("a1"),
+				testTopologyFileNode("n1"),
+			},
+		}}},
+	}
+	for _, missing := range []api.TopologyMissing{
+		api.TopologyMissingKeep,
+		api.TopologyMissingDisable,
+		api.TopologyMissingRemove,
+	} {
+		req.Missing = missing
+		var diff *api.TopologyDiff
+		err = app.db.View(func(tx *bolt.Tx) error {
+			var err error
+			diff, err = topologyDiff(tx, req)
+			return err
+		})
+		tests.Assert(t, err == nil, "expected err == nil, got", err)
+
+		changes := diff.Changes
+		expected := 1
+		if missing != api.TopologyMissingKeep {
+			expected = 2
+		}
+		tests.Assert(t, len(changes) == expected,
+			missing, "expected", expected, "changes, got", changes)
+		tests.Assert(t, changes[0].Change == api.TopologyAddNode,
+			"expected a node add, got", changes[0])
+		tests.Assert(t, changes[0].ClusterId == a.Info.Id,
+			"expected the node added to", a.Info.Id, "got", changes[0].ClusterId)
+		tests.Assert(t, changes[0].Hostname == "n1",
+			"expected n1, got", changes[0].Hostname)
+		if missing == api.TopologyMissingKeep {
+			continue
+		}
+
+		// only the missing node of cluster a is touched
+		c := changes[1]
+		if missing == api.TopologyMissingRemove {
+			tests.Assert(t, c.Change == api.TopologyRemoveNode,
+				"expected a node removal, got", c)
+		} else {
+			tests.Assert(t, c.Change == api.TopologyDisableNode,
+				"expected a node disable, got", c)
+		}
+		tests.Assert(t, c.NodeId == aIds["a2"],
+			"expected node", aIds["a2"], "got", c.NodeId)
+		for _, c := range changes {
+			tests.Assert(t, c.ClusterId != b.Info.Id,
+				"expected cluster b to be left alone, got", c)
+			tests.Assert(t, c.NodeId != bIds["b1"],
+				"expected node b1 to be left alone, got", c)
+		}
+	}
+}
+
+func TestTopologyDiffNewCluster(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		testTopologyCluster(t, tx, "a1")
+		return nil
+	})
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+
+	// a file of new nodes resolves to no known cluster, so no
+	// node is missing from it
+	req := &api.TopologyApplyRequest{
+		Topology: api.TopologyFile{Clusters: []api.TopologyFileCluster{{
+			Nodes: []api.TopologyFileNode{testTopologyFileNode("n1")},
+		}}},
+		Missing: api.TopologyMissingRemove,
+	}
+	var diff *api.TopologyDiff
+	err = app.db.View(func(tx *bolt.Tx) error {
+		var err error
+		diff, err = topologyDiff(tx, req)
+		return err
+	})
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, len(diff.Changes) == 2,
+		"expected 2 changes, got", diff.Changes)
+	tests.Assert(t, diff.Changes[0].Change == api.TopologyAddCluster,
+		"expected a cluster add, got", diff.Changes[0])
+	tests.Assert(t, diff.Changes[1].Change == api.TopologyAddNode,
+		"expected a node add, got", diff.Changes[1])
+}
@@ -83,27 +83,31 @@ func (vc *VolumeCreateOperation) MaxRetries() int {
 // Build allocates and saves new volume and brick entries (tagged as pending)
 // in the db.
 func (vc *VolumeCreateOperation) Build() error {
-	return vc.db.Update(func(tx *bolt.Tx) error {
-		txdb := wdb.WrapTx(tx)
-		brick_entries, err := vc.vol.createVolumeComponents(txdb)
-		if err != nil {
-			return err
-		}
-		for _, brick := range brick_entries {
-			vc.op.RecordAddBrick(brick)
-			if e := brick.Save(tx); e != nil {
-				return e
-			}
-		}
-		vc.op.RecordAddVolume(vc.vol)
-		if e := vc.vol.Save(tx); e != nil {
-			return e
-		}
-		if e := vc.op.Save(tx); e != nil {
+	return vc.db.Update(vc.build)
+}
+
+// build performs the db changes of the Build phase within the
+// given transaction.
+func (vc *VolumeCreateOperation) build(tx *bolt.Tx) error {
+	txdb := wdb.WrapTx(tx)
+	brick_entries, err := vc.vol.createVolumeComponents(txdb)
+	if err != nil {
+		return err
+	}
+	for _, brick := range brick_entries {
+		vc.op.RecordAddBrick(brick)
+		if e := brick.Save(tx); e != nil {
 			return e
 		}
-		return nil
-	})
+	}
+	vc.op.RecordAddVolume(vc.vol)
+	if e := vc.vol.Save(tx); e != nil {
+		return e
+	}
+	if e := vc.op.Save(tx); e != nil {
+		return e
+	}
+	return nil
 }
 
 // Exec creates new bricks and volume on the underlying glusterfs storage system.
@@ -123,26 +127,30 @@ func (vc *VolumeCreateOperation) Exec(executor executors.Executor) error {
 
 // Finalize marks our new volume and brick db entries as no longer pending.
 func (vc *VolumeCreateOperation) Finalize() error {
-	return vc.db.Update(func(tx *bolt.Tx) error {
-		brick_entries, err := bricksFromOp(wdb.WrapTx(tx), vc.op, vc.vol.Info.Gid)
-		if err != nil {
-			logger.LogError("Failed to get bricks from op: %v", err)
-			return err
-		}
-		for _, brick := range brick_entries {
-			vc.op.FinalizeBrick(brick)
-			if e := brick.Save(tx); e != nil {
-				return e
-			}
-		}
-		vc.op.FinalizeVolume(vc.vol)
-		if e := vc.vol.Save(tx); e != nil {
+	return vc.db.Update(vc.finalize)
+}
+
+// finalize performs the db changes of the Finalize phase within
+// the given transaction.
+func (vc *VolumeCreateOperation) finalize(tx *bolt.Tx) error {
+	brick_entries, err := bricksFromOp(wdb.WrapTx(tx), vc.op, vc.vol.Info.Gid)
+	if err != nil {
+		logger.LogError("Failed to get bricks from op: %v", err)
+		return err
+	}
+	for _, brick := range brick_entries {
+		vc.op.FinalizeBrick(brick)
+		if e := brick.Save(tx); e != nil {
 			return e
 		}
+	}
+	vc.op.FinalizeVolume(vc.vol)
+	if e := vc.vol.Save(tx); e != nil {
+		return e
+	}
 
-		vc.op.Delete(tx)
-		return nil
-	})
+	vc.op.Delete(tx)
+	return nil
 }
 
 // Rollback removes any dangling volume and bricks from the underlying storage
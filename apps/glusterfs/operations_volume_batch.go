@@ -0,0 +1,366 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/heketi/heketi/executors"
+	wdb "github.com/heketi/heketi/pkg/db"
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/idgen"
+)
+
+var (
+	// ErrBatchRolledBack is reported for volumes that were removed
+	// because another volume in an all-or-nothing batch failed.
+	ErrBatchRolledBack = errors.New("Volume rolled back due to failure of another volume in the batch")
+)
+
+// VolumeBatchCreateOperation implements the operation functions used
+// to create many volumes from a single request. Each volume is created
+// by its own VolumeCreateOperation (and pending operation entry) but
+// the volumes are placed and executed together.
+//
+// If AllOrNothing is set the volumes are placed in a single db
+// transaction and a failure of any volume rolls back all volumes.
+// Otherwise each volume succeeds or fails on its own.
+type VolumeBatchCreateOperation struct {
+	noRetriesOperation
+	db           wdb.DB
+	id           string
+	allOrNothing bool
+	results      *volumeBatchResults
+
+	// each volume create takes a slot of the operation tracker,
+	// if set, with the deadline of a volume create. Volumes that
+	// succeed or fail on their own are retried like a lone create.
+	optracker *OpTracker
+	deadlines operationDeadlines
+	retries   retryPolicy
+
+	// per-volume state, in request order
+	ops    []*VolumeCreateOperation
+	names  []string
+	errors []error
+	// done is set once a volume needs no further rollback or finalize
+	done []bool
+	// tracked is set while a volume is in the operation tracker
+	tracked []bool
+}
+
+// NewVolumeBatchCreateOperation returns a new VolumeBatchCreateOperation
+// for the given volume entries. The outcome of the batch is saved to
+// the results store when the operation finishes.
+func NewVolumeBatchCreateOperation(
+	vols []*VolumeEntry, db wdb.DB,
+	allOrNothing bool, results *volumeBatchResults) *VolumeBatchCreateOperation {
+
+	vbc := &VolumeBatchCreateOperation{
+		db:           db,
+		id:           idgen.GenUUID(),
+		allOrNothing: allOrNothing,
+		results:      results,
+		ops:          make([]*VolumeCreateOperation, len(vols)),
+		names:        make([]string, len(vols)),
+		errors:       make([]error, len(vols)),
+		done:         make([]bool, len(vols)),
+		tracked:      make([]bool, len(vols)),
+	}
+	for i, v := range vols {
+		vbc.ops[i] = NewVolumeCreateOperation(v, db)
+		vbc.names[i] = v.Info.Name
+	}
+	return vbc
+}
+
+func (vbc *VolumeBatchCreateOperation) Id() string {
+	return vbc.id
+}
+
+func (vbc *VolumeBatchCreateOperation) Label() string {
+	return "Create Volume Batch"
+}
+
+func (vbc *VolumeBatchCreateOperation) ResourceUrl() string {
+	return fmt.Sprintf("/volumes/batch/%v", vbc.id)
+}
+
+// keepsResults marks the batch as an operation whose results are
+// kept under its id.
+func (vbc *VolumeBatchCreateOperation) keepsResults() {}
+
+// track adds the volume creates of the batch to the operation
+// tracker. It returns ErrTooManyOperations if that would exceed
+// the limit of in-flight operations.
+func (vbc *VolumeBatchCreateOperation) track() error {
+	if vbc.optracker == nil {
+		return nil
+	}
+	ids := make([]string, len(vbc.ops))
+	for i, vc := range vbc.ops {
+		ids[i] = vc.Id()
+	}
+	if vbc.optracker.ThrottleOrAddAll(ids) {
+		return ErrTooManyOperations
+	}
+	for i := range vbc.tracked {
+		vbc.tracked[i] = true
+	}
+	return nil
+}
+
+// describe sets the label and deadline of a placed volume create.
+func (vbc *VolumeBatchCreateOperation) describe(i int) {
+	if vbc.tracked[i] {
+		vc := vbc.ops[i]
+		vbc.optracker.Describe(vc.Id(), vc.Label(), vbc.deadlines.timeout(vc))
+	}
+}
+
+// untrack removes a volume create from the operation tracker once
+// the volume needs no further work.
+func (vbc *VolumeBatchCreateOperation) untrack(i int) {
+	if vbc.tracked[i] {
+		vbc.optracker.Remove(vbc.ops[i].Id())
+		vbc.tracked[i] = false
+	}
+}
+
+// remaining returns the number of volumes that have not yet been
+// rolled back or finalized.
+func (vbc *VolumeBatchCreateOperation) remaining() int {
+	count := 0
+	for _, done := range vbc.done {
+		if !done {
+			count++
+		}
+	}
+	return count
+}
+
+// failed returns an error summarizing the failed volumes or nil if
+// no volume has failed.
+func (vbc *VolumeBatchCreateOperation) failed() error {
+	m := HostErrorMap{}
+	for i, err := range vbc.errors {
+		if err != nil {
+			m.Add(vbc.names[i], err)
+		}
+	}
+	return m.ToError("Failed to create volumes:")
+}
+
+// Build allocates the bricks of all the volumes in the batch. In
+// all-or-nothing mode the allocation happens in a single transaction.
+func (vbc *VolumeBatchCreateOperation) Build() error {
+	if err := vbc.track(); err != nil {
+		return err
+	}
+
+	if vbc.allOrNothing {
+		err := vbc.db.Update(func(tx *bolt.Tx) error {
+			for i, vc := range vbc.ops {
+				if err := vc.build(tx); err != nil {
+					logger.LogError("Failed to place volume %v: %v",
+						vbc.names[i], err)
+					return err
+				}
+			}
+			return nil
+		})
+		for i := range vbc.ops {
+			if err != nil {
+				vbc.untrack(i)
+			} else {
+				vbc.describe(i)
+			}
+		}
+		return err
+	}
+
+	for i, vc := range vbc.ops {
+		if err := vc.Build(); err != nil {
+			logger.LogError("Failed to place volume %v: %v",
+				vbc.names[i], err)
+			vbc.errors[i] = err
+			vbc.done[i] = true
+			vbc.untrack(i)
+			continue
+		}
+		vbc.describe(i)
+	}
+	if vbc.remaining() == 0 {
+		return vbc.failed()
+	}
+	return nil
+}
+
+// Exec creates the bricks and volumes of the batch. The volumes are
+// created concurrently so brick creation is spread over all the
+// hosts in use. Unless in all-or-nothing mode, each volume is run
+// through its own exec, rollback, and finalize steps.
+func (vbc *VolumeBatchCreateOperation) Exec(executor executors.Executor) error {
+	var wg sync.WaitGroup
+	for i, vc := range vbc.ops {
+		if vbc.done[i] {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, vc *VolumeCreateOperation) {
+			defer wg.Done()
+			if vbc.allOrNothing {
+				vbc.errors[i] = vc.Exec(executor)
+				return
+			}
+			// the volume is either finalized or rolled back
+			vbc.errors[i] = runOperationAfterBuild(vc, executor, vbc.retries)
+			vbc.done[i] = true
+			vbc.untrack(i)
+		}(i, vc)
+	}
+	wg.Wait()
+
+	err := vbc.failed()
+	if vbc.allOrNothing && err != nil {
+		for i := range vbc.errors {
+			if vbc.errors[i] == nil {
+				vbc.errors[i] = ErrBatchRolledBack
+			}
+		}
+		vbc.saveResults()
+		return err
+	}
+	if vbc.allFailed() {
+		vbc.saveResults()
+		return err
+	}
+	return nil
+}
+
+// allFailed returns true if no volume in the batch was created.
+func (vbc *VolumeBatchCreateOperation) allFailed() bool {
+	for _, err := range vbc.errors {
+		if err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Rollback removes the bricks and volumes of all volumes in the batch
+// that have not already been rolled back.
+func (vbc *VolumeBatchCreateOperation) Rollback(executor executors.Executor) error {
+	m := HostErrorMap{}
+	for i, vc := range vbc.ops {
+		if vbc.done[i] {
+			continue
+		}
+		if err := vc.Rollback(executor); err != nil {
+			markFailedIfSupported(vc)
+			m.Add(vbc.names[i], err)
+		}
+		vbc.done[i] = true
+		vbc.untrack(i)
+	}
+	return m.ToError("Failed to roll back volumes:")
+}
+
+// Finalize marks the new volumes as no longer pending and records the
+// outcome of the batch.
+func (vbc *VolumeBatchCreateOperation) Finalize() error {
+	if vbc.allOrNothing {
+		err := vbc.db.Update(func(tx *bolt.Tx) error {
+			for _, vc := range vbc.ops {
+				if err := vc.finalize(tx); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		for i := range vbc.ops {
+			vbc.untrack(i)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// in the other mode volumes were finalized as they completed
+	vbc.saveResults()
+	return nil
+}
+
+// Response returns the per-volume results of the batch.
+func (vbc *VolumeBatchCreateOperation) Response() *api.VolumeBatchCreateResponse {
+	resp := &api.VolumeBatchCreateResponse{
+		Id:      vbc.id,
+		Volumes: make([]api.VolumeBatchCreateResult, len(vbc.ops)),
+	}
+	for i, vc := range vbc.ops {
+		r := &resp.Volumes[i]
+		r.Name = vbc.names[i]
+		if vbc.errors[i] != nil {
+			r.Error = vbc.errors[i].Error()
+		} else {
+			r.Id = vc.vol.Info.Id
+			r.Name = vc.vol.Info.Name
+		}
+	}
+	return resp
+}
+
+func (vbc *VolumeBatchCreateOperation) saveResults() {
+	if vbc.results != nil {
+		vbc.results.Add(vbc.Response())
+	}
+}
+
+// volumeBatchResults holds the responses of recently completed
+// volume batches until a client fetches them.
+type volumeBatchResults struct {
+	lock    sync.Mutex
+	limit   int
+	order   []string
+	results map[string]*api.VolumeBatchCreateResponse
+}
+
+func newVolumeBatchResults(limit int) *volumeBatchResults {
+	return &volumeBatchResults{
+		limit:   limit,
+		results: map[string]*api.VolumeBatchCreateResponse{},
+	}
+}
+
+// Add saves a batch response, dropping the oldest saved response
+// if the store is full.
+func (vbr *volumeBatchResults) Add(resp *api.VolumeBatchCreateResponse) {
+	vbr.lock.Lock()
+	defer vbr.lock.Unlock()
+	if _, found := vbr.results[resp.Id]; !found {
+		vbr.order = append(vbr.order, resp.Id)
+	}
+	vbr.results[resp.Id] = resp
+	for len(vbr.order) > vbr.limit {
+		delete(vbr.results, vbr.order[0])
+		vbr.order = vbr.order[1:]
+	}
+}
+
+// Get returns the saved response for the given batch id.
+func (vbr *volumeBatchResults) Get(id string) (*api.VolumeBatchCreateResponse, bool) {
+	vbr.lock.Lock()
+	defer vbr.lock.Unlock()
+	resp, found := vbr.results[id]
+	return resp, found
+}
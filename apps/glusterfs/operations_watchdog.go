@@ -0,0 +1,157 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"time"
+
+	"github.com/heketi/heketi/executors"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+var (
+	opTrackerNow func() time.Time = time.Now
+)
+
+// typedOperation is implemented by operations that know the type
+// of their pending operation entry.
+type typedOperation interface {
+	OperationType() PendingOperationType
+}
+
+// operationDeadlines holds the time operations may be in-flight
+// before they are considered stuck.
+type operationDeadlines struct {
+	// types maps the type of an operation to its deadline
+	types map[PendingOperationType]time.Duration
+	// def applies to operations without a type specific deadline.
+	// Zero means no deadline.
+	def time.Duration
+}
+
+// newOperationDeadlines returns the deadlines of the operation
+// timeout configuration.
+func newOperationDeadlines(tc OperationTimeoutConfig) operationDeadlines {
+	od := operationDeadlines{
+		types: map[PendingOperationType]time.Duration{},
+		def:   time.Second * time.Duration(tc.Default),
+	}
+	od.set(OperationCreateVolume, tc.VolumeCreate)
+	od.set(OperationDeleteVolume, tc.VolumeDelete)
+	od.set(OperationExpandVolume, tc.VolumeExpand)
+	od.set(OperationCloneVolume, tc.VolumeClone)
+	od.set(OperationCreateBlockVolume, tc.BlockVolumeCreate)
+	od.set(OperationDeleteBlockVolume, tc.BlockVolumeDelete)
+	od.set(OperationRemoveDevice, tc.DeviceRemove)
+	return od
+}
+
+func (od operationDeadlines) set(t PendingOperationType, seconds uint32) {
+	if seconds > 0 {
+		od.types[t] = time.Second * time.Duration(seconds)
+	}
+}
+
+// enabled returns true if any deadline is configured.
+func (od operationDeadlines) enabled() bool {
+	return od.def > 0 || len(od.types) > 0
+}
+
+// timeout returns the amount of time the given operation may run
+// before it is considered stuck. Zero means the operation has no
+// deadline.
+func (od operationDeadlines) timeout(o Operation) time.Duration {
+	if to, ok := o.(typedOperation); ok {
+		if d, found := od.types[to.OperationType()]; found {
+			return d
+		}
+	}
+	return od.def
+}
+
+// operationWatchdog periodically checks the in-flight operations
+// for operations that have exceeded their deadlines.
+type operationWatchdog struct {
+	optracker *OpTracker
+	executor  executors.Executor
+
+	CheckInterval time.Duration
+	// Cancel enables cancelling the commands of stuck operations
+	Cancel bool
+
+	// to stop the watchdog
+	stop chan<- interface{}
+}
+
+// Start creates a background goroutine to periodically check
+// for stuck operations.
+func (ow *operationWatchdog) Start() {
+	ticker := time.NewTicker(ow.CheckInterval)
+	stop := make(chan interface{})
+	ow.stop = stop
+
+	go func() {
+		logger.Info("Started operation watchdog")
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				logger.Info("Stopping operation watchdog")
+				return
+			case <-ticker.C:
+				ow.Check()
+			}
+		}
+	}()
+}
+
+// Stop the operation watchdog.
+func (ow *operationWatchdog) Stop() {
+	ow.stop <- true
+}
+
+// Check looks for operations that have newly passed their deadlines,
+// marks them stuck, logs what they appear to be blocked on and,
+// if configured, cancels the commands they are blocked on.
+func (ow *operationWatchdog) Check() {
+	stuck := ow.optracker.MarkOverdue()
+	if len(stuck) == 0 {
+		return
+	}
+	active := ow.activeCommands()
+	for _, t := range stuck {
+		logger.Warning(
+			"Operation %v [%v] is stuck: running for %v, deadline was %v",
+			t.Label, t.Id,
+			opTrackerNow().Sub(t.Started), t.Deadline.Sub(t.Started))
+		for _, ac := range active.List() {
+			if ac.Operation != t.Id {
+				continue
+			}
+			logger.Warning(
+				"Operation [%v] may be blocked on command [%v] on [%v] (running %v)",
+				t.Id, ac.Command, ac.Host, opTrackerNow().Sub(ac.Started))
+		}
+		if ow.Cancel {
+			n := active.CancelOperation(t.Id)
+			logger.Warning("Cancelled %v command(s) for stuck operation [%v]",
+				n, t.Id)
+		}
+	}
+}
+
+// activeCommands returns the active commands of the executor or
+// nil if the executor does not track them.
+func (ow *operationWatchdog) activeCommands() *rex.ActiveCommands {
+	if r, ok := ow.executor.(executors.ActiveCommandsReporter); ok {
+		return r.ActiveCommands()
+	}
+	return nil
+}
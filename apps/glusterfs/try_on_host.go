@@ -11,6 +11,9 @@ package glusterfs
 
 import (
 	"fmt"
+	"sort"
+
+	rex "github.com/heketi/heketi/pkg/remoteexec"
 )
 
 // nodeHosts is a mapping from the node ID to the hosts's
@@ -23,6 +26,9 @@ type tryOnHosts struct {
 	// nodesUp allows ht user of try on hosts to override the default
 	// function for fetching
 	nodesUp func() map[string]bool
+	// hostCircuit allows the user of try on hosts to override the
+	// default function for fetching the executor's circuit state
+	hostCircuit func(host string) rex.CircuitState
 }
 
 func newTryOnHosts(hosts nodeHosts) *tryOnHosts {
@@ -40,12 +46,59 @@ func (c *tryOnHosts) nodeStatus() map[string]bool {
 	return c.nodesUp()
 }
 
+// circuitState returns the state of the executor's circuit to host.
+// The default behavior of this function can be controlled by
+// setting the 'hostCircuit' function in the struct.
+func (c *tryOnHosts) circuitState(host string) rex.CircuitState {
+	if c.hostCircuit == nil {
+		return currentHostCircuit(host)
+	}
+	return c.hostCircuit(host)
+}
+
+// candidates returns the ids of the nodes worth trying, the nodes
+// most likely to be reachable first: nodes known to be up, then
+// nodes of unknown health and last the nodes the executor is only
+// probing again after failing to connect to them.
+func (c *tryOnHosts) candidates() []string {
+	nodeUp := c.nodeStatus()
+	rank := map[string]int{}
+	ids := []string{}
+	for nodeId, host := range c.Hosts {
+		if up, found := nodeUp[nodeId]; found && !up {
+			// if the node is in the cache and we know it was not
+			// recently healthy, skip it
+			logger.Debug("skipping node. %v (%v) is presumed unhealthy",
+				nodeId, host)
+			continue
+		}
+		switch c.circuitState(host) {
+		case rex.CircuitOpen:
+			logger.Debug("skipping node. %v (%v) is unreachable",
+				nodeId, host)
+			continue
+		case rex.CircuitHalfOpen:
+			rank[nodeId] = 2
+		default:
+			if !nodeUp[nodeId] {
+				rank[nodeId] = 1
+			}
+		}
+		ids = append(ids, nodeId)
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		return rank[ids[i]] < rank[ids[j]]
+	})
+	return ids
+}
+
 // once returns a tryOnHosts that only tries one host known
 // to be up.
 func (c *tryOnHosts) once() *tryOnHosts {
 	return &tryOnHosts{
-		Hosts:   c.Hosts,
-		nodesUp: c.nodesUp,
+		Hosts:       c.Hosts,
+		nodesUp:     c.nodesUp,
+		hostCircuit: c.hostCircuit,
 		done: func(err error) bool {
 			return true
 		},
@@ -64,15 +117,8 @@ func (c *tryOnHosts) run(f func(host string) error) error {
 
 	mherr := HostErrorMap{}
 	tries := 0
-	nodeUp := c.nodeStatus()
-	for nodeId, host := range c.Hosts {
-		if up, found := nodeUp[nodeId]; found && !up {
-			// if the node is in the cache and we know it was not
-			// recently healthy, skip it
-			logger.Debug("skipping node. %v (%v) is presumed unhealthy",
-				nodeId, host)
-			continue
-		}
+	for _, nodeId := range c.candidates() {
+		host := c.Hosts[nodeId]
 		logger.Debug("running function on node %v (%v)", nodeId, host)
 		tries++
 		err := f(host)
@@ -604,6 +604,9 @@ func (v *VolumeEntry) createVolumeComponents(
 		allowName:   v.Info.Name,
 		allowCreate: true,
 	}
+	if v.HasArbiterOption() {
+		cr.minOpVersion = arbiterOpVersion
+	}
 	possibleClusters, err := eligibleClusters(db, cr, possibleClusters)
 	if err != nil {
 		return nil, err
@@ -877,6 +880,8 @@ type clusterReq struct {
 	allowBlock  bool
 	allowName   string
 	allowCreate bool
+	// lowest op-version all nodes must support, if any
+	minOpVersion int
 }
 
 func eligibleClusters(db wdb.RODB, req clusterReq,
@@ -916,6 +921,32 @@ func eligibleClusters(db wdb.RODB, req clusterReq,
 					fmt.Errorf("Cluster does not support requested volume type"))
 				continue
 			}
+			if req.allowBlock {
+				found, err := c.hasGlusterBlockNode(tx)
+				if err != nil {
+					return err
+				}
+				if !found {
+					cerr.Add(
+						c.Info.Id,
+						fmt.Errorf("Cluster has no nodes with gluster-block"))
+					continue
+				}
+			}
+			if req.minOpVersion > 0 {
+				node, err := c.nodeBelowOpVersion(tx, req.minOpVersion)
+				if err != nil {
+					return err
+				}
+				if node != nil {
+					cerr.Add(
+						c.Info.Id,
+						fmt.Errorf("Node %v has op-version %v and %v is required",
+							node.Info.Id, node.Capabilities.OpVersion,
+							req.minOpVersion))
+					continue
+				}
+			}
 			if req.allowName != "" {
 				found, err := volumeNameExistsInCluster(tx, c, req.allowName)
 				if err != nil {
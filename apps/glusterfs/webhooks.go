@@ -0,0 +1,356 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package glusterfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+const (
+	// events waiting to be sent to a webhook before new events are
+	// sent to the dead-letter log instead
+	webhookQueueSize = 1024
+
+	defaultWebhookRetries = 5
+	defaultWebhookTimeout = 10
+)
+
+var (
+	// events sent to webhooks without an events filter
+	defaultWebhookEvents = []string{
+		string(api.EventOperationFinished),
+		string(api.EventOperationFailed),
+		string(api.EventNodeHealthChanged),
+		string(api.EventDeviceUpdated) + ":" + string(api.EntryStateFailed),
+	}
+
+	defaultWebhookBackoff = RetryBackoff{
+		Initial:    time.Second,
+		Max:        time.Minute,
+		Multiplier: 2,
+	}
+)
+
+// webhookNotifier sends the events of the journal to the configured
+// webhooks.
+type webhookNotifier struct {
+	events *eventJournal
+	hooks  []*webhook
+
+	stop chan<- interface{}
+	wg   sync.WaitGroup
+}
+
+// webhook delivers events to one url. Events that can not be
+// delivered are written to the dead-letter log.
+type webhook struct {
+	url     string
+	filter  []string
+	secret  []byte
+	retries int
+	backoff RetryBackoff
+	client  *http.Client
+
+	deadLetterFile string
+	deadLetterLock sync.Mutex
+
+	queue chan api.Event
+}
+
+func newWebhook(c WebhookConfig) *webhook {
+	w := &webhook{
+		url:            c.URL,
+		filter:         c.Events,
+		secret:         []byte(c.Secret),
+		retries:        c.Retries,
+		backoff:        defaultWebhookBackoff,
+		deadLetterFile: c.DeadLetterFile,
+		queue:          make(chan api.Event, webhookQueueSize),
+	}
+	if len(w.filter) == 0 {
+		w.filter = defaultWebhookEvents
+	}
+	if w.retries == 0 {
+		w.retries = defaultWebhookRetries
+	}
+	if c.Backoff.InitialMs > 0 {
+		w.backoff = RetryBackoff{
+			Initial:    time.Duration(c.Backoff.InitialMs) * time.Millisecond,
+			Max:        time.Duration(c.Backoff.MaxMs) * time.Millisecond,
+			Multiplier: c.Backoff.Multiplier,
+		}
+	}
+	timeout := c.TimeoutSeconds
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+	w.client = &http.Client{
+		Timeout: time.Duration(timeout) * time.Second,
+	}
+	return w
+}
+
+func newWebhookNotifier(events *eventJournal,
+	conf []WebhookConfig) *webhookNotifier {
+
+	n := &webhookNotifier{events: events}
+	for _, c := range conf {
+		n.hooks = append(n.hooks, newWebhook(c))
+	}
+	return n
+}
+
+// Start sends the new events of the journal to the webhooks until
+// Stop is called.
+func (n *webhookNotifier) Start() {
+	stop := make(chan interface{})
+	n.stop = stop
+
+	for _, w := range n.hooks {
+		n.wg.Add(1)
+		go func(w *webhook) {
+			defer n.wg.Done()
+			w.run(stop)
+		}(w)
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		logger.Info("Started webhook notifier for %v webhooks", len(n.hooks))
+		ch := n.events.Subscribe()
+		// the last event sent to the webhooks
+		var last uint64
+		for {
+			select {
+			case <-stop:
+				n.events.Unsubscribe(ch)
+				logger.Info("Stopping webhook notifier")
+				return
+			case e, ok := <-ch:
+				if !ok {
+					// only dropped if this loop falls behind. The
+					// missed events are read back from the journal.
+					logger.Warning("Webhook notifier fell behind, replaying events after %v", last)
+					ch = n.events.Subscribe()
+					last = n.replay(last)
+					continue
+				}
+				if e.Seq <= last {
+					continue
+				}
+				n.enqueue(e)
+				last = e.Seq
+			}
+		}
+	}()
+}
+
+// replay sends the journaled events after seq to the webhooks and
+// returns the sequence number of the last event sent.
+func (n *webhookNotifier) replay(seq uint64) uint64 {
+	events, lost, err := n.events.Since(seq)
+	if err != nil {
+		logger.LogError("Unable to read events after %v for webhooks: %v",
+			seq, err)
+		return seq
+	}
+	if lost {
+		logger.LogError("Events after %v are no longer in the journal "+
+			"and were not sent to the webhooks", seq)
+	}
+	for _, e := range events {
+		n.enqueue(e)
+		seq = e.Seq
+	}
+	return seq
+}
+
+func (n *webhookNotifier) enqueue(e api.Event) {
+	for _, w := range n.hooks {
+		w.enqueue(e)
+	}
+}
+
+// Stop stops the notifier. Events waiting to be delivered are dropped.
+func (n *webhookNotifier) Stop() {
+	close(n.stop)
+	n.wg.Wait()
+}
+
+// wants returns true if the event passes the filter of the webhook.
+// A filter entry of the form type:state only passes events of the
+// type that changed the state of an existing device or node to state.
+func (w *webhook) wants(e api.Event) bool {
+	for _, f := range w.filter {
+		parts := strings.SplitN(f, ":", 2)
+		if parts[0] != string(e.Type) {
+			continue
+		}
+		if len(parts) == 1 {
+			return true
+		}
+		if e.OldState != "" && e.OldState != e.State &&
+			parts[1] == string(e.State) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *webhook) enqueue(e api.Event) {
+	if !w.wants(e) {
+		return
+	}
+	select {
+	case w.queue <- e:
+	default:
+		w.deadLetter(e, fmt.Errorf("webhook queue is full"))
+	}
+}
+
+func (w *webhook) run(stop <-chan interface{}) {
+	// a delivery in progress is cancelled when the server stops
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-w.queue:
+			if err := w.deliver(ctx, e); err != nil {
+				w.deadLetter(e, err)
+			}
+		}
+	}
+}
+
+// deliver posts the event to the webhook, retrying failures with a
+// backoff between the attempts. It gives up when ctx is done.
+func (w *webhook) deliver(ctx context.Context, e api.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, e, body)
+		if err == nil {
+			return nil
+		}
+		if attempt > w.retries {
+			return err
+		}
+		logger.Warning("Webhook %v failed for event %v (attempt #%v/%v): %v",
+			w.url, e.Seq, attempt, w.retries+1, err)
+		timer := time.NewTimer(w.backoff.Delay(attempt + 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("server stopping: %v", err)
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *webhook) post(ctx context.Context, e api.Event, body []byte) error {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Heketi-Event", string(e.Type))
+	req.Header.Set("X-Heketi-Delivery", strconv.FormatUint(e.Seq, 10))
+	if len(w.secret) > 0 {
+		req.Header.Set("X-Heketi-Signature", "sha256="+w.sign(body))
+	}
+
+	r, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %v", r.Status)
+	}
+	return nil
+}
+
+// sign returns the hex encoded HMAC-SHA256 of body with the secret
+// of the webhook.
+func (w *webhook) sign(body []byte) string {
+	mac := hmac.New(sha256.New, w.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deadLetter records an event that could not be delivered. The event
+// is appended, as a line of json, to the dead-letter file of the
+// webhook, or logged if the webhook has none.
+func (w *webhook) deadLetter(e api.Event, reason error) {
+	logger.LogError("Unable to deliver event %v to webhook %v: %v",
+		e.Seq, w.url, reason)
+	if w.deadLetterFile == "" {
+		return
+	}
+
+	line, err := json.Marshal(struct {
+		Time  int64     `json:"time"`
+		URL   string    `json:"url"`
+		Error string    `json:"error"`
+		Event api.Event `json:"event"`
+	}{
+		Time:  time.Now().Unix(),
+		URL:   w.url,
+		Error: reason.Error(),
+		Event: e,
+	})
+	if err != nil {
+		logger.LogError("Unable to encode dead letter: %v", err)
+		return
+	}
+
+	w.deadLetterLock.Lock()
+	defer w.deadLetterLock.Unlock()
+	f, err := os.OpenFile(w.deadLetterFile,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		logger.LogError("Unable to open dead-letter file %v: %v",
+			w.deadLetterFile, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logger.LogError("Unable to write dead-letter file %v: %v",
+			w.deadLetterFile, err)
+	}
+}
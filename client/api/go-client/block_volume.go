@@ -70,7 +70,15 @@ func (c *Client) BlockVolumeCreate(request *api.BlockVolumeCreateRequest) (
 }
 
 func (c *Client) BlockVolumeList() (*api.BlockVolumeListResponse, error) {
-	req, err := http.NewRequest("GET", c.host+"/blockvolumes", nil)
+	return c.BlockVolumeListWithOptions(api.ListOptions{})
+}
+
+// BlockVolumeListWithOptions returns the page of the block volumes
+// selected by opts.
+func (c *Client) BlockVolumeListWithOptions(
+	opts api.ListOptions) (*api.BlockVolumeListResponse, error) {
+
+	req, err := http.NewRequest("GET", c.host+"/blockvolumes"+listQuery(opts), nil)
 	if err != nil {
 		return nil, err
 	}
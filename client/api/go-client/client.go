@@ -189,6 +189,9 @@ func (c *Client) doBasic(req *http.Request) (*http.Response, error) {
 		<-c.throttle
 	}()
 
+	// errors are read in the json error format
+	req.Header.Set(utils.ErrorVersionHeader, strconv.Itoa(utils.ErrorVersion))
+
 	httpClient := &http.Client{}
 	if c.tlsClientConfig != nil {
 		httpClient.Transport = &http.Transport{
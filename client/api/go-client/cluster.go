@@ -131,9 +131,16 @@ func (c *Client) ClusterInfo(id string) (*api.ClusterInfoResponse, error) {
 }
 
 func (c *Client) ClusterList() (*api.ClusterListResponse, error) {
+	return c.ClusterListWithOptions(api.ListOptions{})
+}
+
+// ClusterListWithOptions returns the page of the clusters selected by
+// opts.
+func (c *Client) ClusterListWithOptions(
+	opts api.ListOptions) (*api.ClusterListResponse, error) {
 
 	// Create request
-	req, err := http.NewRequest("GET", c.host+"/clusters", nil)
+	req, err := http.NewRequest("GET", c.host+"/clusters"+listQuery(opts), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,99 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+// EventStream reads the events sent by the server.
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// Events opens the event stream of the server. If lastEventId is not
+// zero the stream starts with the events after that event.
+func (c *Client) Events(lastEventId uint64) (*EventStream, error) {
+	req, err := http.NewRequest("GET", c.host+"/events", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventId != 0 {
+		req.Header.Set("Last-Event-ID",
+			strconv.FormatUint(lastEventId, 10))
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open the stream
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		defer r.Body.Close()
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	return &EventStream{
+		body:    r.Body,
+		scanner: bufio.NewScanner(r.Body),
+	}, nil
+}
+
+// Next returns the next event of the stream. It returns io.EOF once
+// the server has closed the stream.
+func (s *EventStream) Next() (*api.Event, error) {
+	var data string
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		switch {
+		case line == "":
+			if data == "" {
+				continue
+			}
+			var e api.Event
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				return nil, err
+			}
+			return &e, nil
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+		// ids, event types and comments are not needed as the
+		// data holds the whole event
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
@@ -216,3 +216,82 @@ func (c *Client) NodeSetTags(id string, request *api.TagsChangeRequest) error {
 	}
 	return nil
 }
+
+func (c *Client) NodeSetHostKey(id string, request *api.NodeHostKeyRequest) (
+	*api.NodeInfoResponse, error) {
+
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST",
+		c.host+"/nodes/"+id+"/hostkey",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var node api.NodeInfoResponse
+	err = utils.GetJsonFromResponse(r, &node)
+	if err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// NodeResync probes a node again for the versions and features
+// of its software.
+func (c *Client) NodeResync(id string) error {
+
+	// Create a request
+	req, err := http.NewRequest("GET", c.host+"/nodes/"+id+"/resync", nil)
+	if err != nil {
+		return err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusAccepted {
+		return utils.GetErrorFromResponse(r)
+	}
+
+	// Wait for response
+	r, err = c.pollResponse(r)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != http.StatusNoContent {
+		return utils.GetErrorFromResponse(r)
+	}
+
+	return nil
+}
@@ -13,10 +13,90 @@
 package client
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
 	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
 )
 
+var errNoTopologyEndpoint = errors.New("server has no topology endpoint")
+
+// TopologyInfo returns the whole topology of the clusters. Servers
+// without the topology endpoint are asked for each of the clusters,
+// nodes and volumes in turn.
 func (c *Client) TopologyInfo() (*api.TopologyInfoResponse, error) {
+	topo, err := c.TopologyInfoWithOptions(api.TopologyInfoOptions{})
+	if err == errNoTopologyEndpoint {
+		return c.topologyInfoByResource()
+	}
+	return topo, err
+}
+
+// TopologyInfoWithOptions returns the parts of the topology of the
+// clusters selected by opts, in a single request.
+func (c *Client) TopologyInfoWithOptions(
+	opts api.TopologyInfoOptions) (*api.TopologyInfoResponse, error) {
+
+	q := url.Values{}
+	if opts.Cluster != "" {
+		q.Set("cluster", opts.Cluster)
+	}
+	if opts.Node != "" {
+		q.Set("node", opts.Node)
+	}
+	if opts.NoVolumes {
+		q.Set("volumes", "false")
+	}
+	if opts.NoBricks {
+		q.Set("bricks", "false")
+	}
+	u := c.host + "/topology"
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	// Create request
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNotFound && len(q) == 0 {
+		// the whole topology is always found, unless the server
+		// is too old to have the endpoint
+		return nil, errNoTopologyEndpoint
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var topo api.TopologyInfoResponse
+	err = utils.GetJsonFromResponse(r, &topo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &topo, nil
+}
+
+func (c *Client) topologyInfoByResource() (*api.TopologyInfoResponse, error) {
 	topo := &api.TopologyInfoResponse{
 		ClusterList: make([]api.Cluster, 0),
 	}
@@ -64,3 +144,145 @@ func (c *Client) TopologyInfo() (*api.TopologyInfoResponse, error) {
 	return topo, nil
 
 }
+
+// TopologyDiff returns the changes the server would make to match
+// the requested topology, without making them.
+func (c *Client) TopologyDiff(
+	request *api.TopologyApplyRequest) (*api.TopologyDiff, error) {
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST",
+		c.host+"/topology/apply?dry_run=true",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var diff api.TopologyDiff
+	err = utils.GetJsonFromResponse(r, &diff)
+	if err != nil {
+		return nil, err
+	}
+
+	return &diff, nil
+}
+
+// TopologyApply makes the topology of the server match the requested
+// topology and returns the resulting topology.
+func (c *Client) TopologyApply(
+	request *api.TopologyApplyRequest) (*api.TopologyInfoResponse, error) {
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST",
+		c.host+"/topology/apply",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusAccepted {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Wait for response
+	r, err = c.pollResponse(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var topo api.TopologyInfoResponse
+	err = utils.GetJsonFromResponse(r, &topo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &topo, nil
+}
+
+// TopologyExport returns the topology in the format used to load
+// it. If flags is set the file and block flags of the clusters are
+// included.
+func (c *Client) TopologyExport(flags bool) (*api.TopologyFile, error) {
+	u := c.host + "/topology/export"
+	if flags {
+		u += "?flags=true"
+	}
+
+	// Create request
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var topo api.TopologyFile
+	err = utils.GetJsonFromResponse(r, &topo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &topo, nil
+}
@@ -16,6 +16,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/heketi/pkg/utils"
@@ -75,6 +77,93 @@ func (c *Client) VolumeCreate(request *api.VolumeCreateRequest) (
 
 }
 
+func (c *Client) VolumeBatchCreate(request *api.VolumeBatchCreateRequest) (
+	*api.VolumeBatchCreateResponse, error) {
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST",
+		c.host+"/volumes/batch",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusAccepted {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Wait for response
+	r, err = c.pollResponse(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var batch api.VolumeBatchCreateResponse
+	err = utils.GetJsonFromResponse(r, &batch)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
+func (c *Client) VolumeBatchInfo(id string) (*api.VolumeBatchCreateResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/volumes/batch/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, utils.GetErrorFromResponse(r)
+	}
+
+	// Read JSON response
+	var batch api.VolumeBatchCreateResponse
+	err = utils.GetJsonFromResponse(r, &batch)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
 func (c *Client) VolumeSetBlockRestriction(id string, request *api.VolumeBlockRestrictionRequest) (
 	*api.VolumeInfoResponse, error) {
 
@@ -184,9 +273,17 @@ func (c *Client) VolumeExpand(id string, request *api.VolumeExpandRequest) (
 }
 
 func (c *Client) VolumeList() (*api.VolumeListResponse, error) {
+	return c.VolumeListWithOptions(api.ListOptions{})
+}
+
+// VolumeListWithOptions returns the page of the volumes selected by
+// opts. The Continue field of the response, if set, is the Continue
+// option of the next page.
+func (c *Client) VolumeListWithOptions(
+	opts api.ListOptions) (*api.VolumeListResponse, error) {
 
 	// Create request
-	req, err := http.NewRequest("GET", c.host+"/volumes", nil)
+	req, err := http.NewRequest("GET", c.host+"/volumes"+listQuery(opts), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -335,3 +432,43 @@ func (c *Client) VolumeClone(id string, request *api.VolumeCloneRequest) (*api.V
 
 	return &volume, nil
 }
+
+// listQuery returns the query string of the list options, starting
+// with ?, or an empty string for the default options.
+func listQuery(opts api.ListOptions) string {
+	q := url.Values{}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Continue != "" {
+		q.Set("continue", opts.Continue)
+	}
+	if opts.Cluster != "" {
+		q.Set("cluster", opts.Cluster)
+	}
+	if opts.NamePrefix != "" {
+		q.Set("name_prefix", opts.NamePrefix)
+	}
+	if opts.Block != nil {
+		q.Set("block", strconv.FormatBool(*opts.Block))
+	}
+	if opts.MinSize > 0 {
+		q.Set("min_size", strconv.Itoa(opts.MinSize))
+	}
+	if opts.MaxSize > 0 {
+		q.Set("max_size", strconv.Itoa(opts.MaxSize))
+	}
+	if opts.Tag != "" {
+		q.Set("tag", opts.Tag)
+	}
+	if opts.Sort != "" {
+		q.Set("sort", opts.Sort)
+	}
+	if opts.Detail {
+		q.Set("detail", "true")
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
@@ -210,10 +210,34 @@ var blockVolumeListCommand = &cobra.Command{
 			return err
 		}
 
-		// List volumes
-		list, err := heketi.BlockVolumeList()
-		if err != nil {
-			return err
+		// List volumes, a page at a time
+		opts := api.ListOptions{
+			Limit:  listPageSize,
+			Detail: !options.Json,
+		}
+		list := &api.BlockVolumeListResponse{BlockVolumes: []string{}}
+		volumes := []api.BlockVolumeInfoResponse{}
+		for {
+			page, err := heketi.BlockVolumeListWithOptions(opts)
+			if err != nil {
+				return err
+			}
+			list.BlockVolumes = append(list.BlockVolumes, page.BlockVolumes...)
+			if opts.Detail && len(page.Details) == 0 {
+				// servers without detailed lists
+				for _, id := range page.BlockVolumes {
+					volume, err := heketi.BlockVolumeInfo(id)
+					if err != nil {
+						return err
+					}
+					page.Details = append(page.Details, *volume)
+				}
+			}
+			volumes = append(volumes, page.Details...)
+			if page.Continue == "" {
+				break
+			}
+			opts.Continue = page.Continue
 		}
 
 		if options.Json {
@@ -223,14 +247,9 @@ var blockVolumeListCommand = &cobra.Command{
 			}
 			fmt.Fprintf(stdout, string(data))
 		} else {
-			for _, id := range list.BlockVolumes {
-				volume, err := heketi.BlockVolumeInfo(id)
-				if err != nil {
-					return err
-				}
-
+			for _, volume := range volumes {
 				fmt.Fprintf(stdout, "Id:%-35v Cluster:%-35v Name:%v\n",
-					id,
+					volume.Id,
 					volume.Cluster,
 					volume.Name)
 			}
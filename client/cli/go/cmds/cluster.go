@@ -45,6 +45,7 @@ func init() {
 			"\n\tregular file volumes on the cluster to be created."+
 			"\n\tThis is enabled by default. Use '--file=false' to"+
 			"\n\tdisable creation of file volumes on this cluster.")
+	addConnectionFlags(clusterCreateCommand)
 
 	clusterSetFlagsCommand.Flags().StringVar(&cl_block_str, "block", "",
 		"\n\tOptional: Allow the user to control the possibility of creating"+
@@ -87,6 +88,11 @@ var clusterCreateCommand = &cobra.Command{
 		req := &api.ClusterCreateRequest{}
 		req.File = cl_file
 		req.Block = cl_block
+		conn, err := connectionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		req.Connection = conn
 
 		// Create a client to talk to Heketi
 		heketi, err := newHeketiClient()
@@ -14,6 +14,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/spf13/cobra"
@@ -37,10 +38,12 @@ func init() {
 	nodeCommand.AddCommand(nodeRemoveCommand)
 	nodeCommand.AddCommand(nodeSetTagsCommand)
 	nodeCommand.AddCommand(nodeRmTagsCommand)
+	nodeCommand.AddCommand(nodeResyncCommand)
 	nodeAddCommand.Flags().IntVar(&zone, "zone", 0, "The zone in which the node should reside")
 	nodeAddCommand.Flags().StringVar(&clusterId, "cluster", "", "The cluster in which the node should reside")
 	nodeAddCommand.Flags().StringVar(&managmentHostNames, "management-host-name", "", "Management host name")
 	nodeAddCommand.Flags().StringVar(&storageHostNames, "storage-host-name", "", "Storage host name")
+	addConnectionFlags(nodeAddCommand)
 	nodeSetTagsCommand.Flags().BoolP("exact", "e", false,
 		"Set the object to this exact set of tags. Overwrites existing tags.")
 	nodeRmTagsCommand.Flags().Bool("all", false,
@@ -51,6 +54,7 @@ func init() {
 	nodeListCommand.SilenceUsage = true
 	nodeRemoveCommand.SilenceUsage = true
 	nodeSetTagsCommand.SilenceUsage = true
+	nodeResyncCommand.SilenceUsage = true
 }
 
 var nodeCommand = &cobra.Command{
@@ -90,6 +94,11 @@ var nodeAddCommand = &cobra.Command{
 		req.Hostnames.Manage = []string{managmentHostNames}
 		req.Hostnames.Storage = []string{storageHostNames}
 		req.Zone = zone
+		conn, err := connectionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		req.Connection = conn
 
 		// Create a client
 		heketi, err := newHeketiClient()
@@ -309,6 +318,35 @@ var nodeInfoCommand = &cobra.Command{
 	},
 }
 
+var nodeResyncCommand = &cobra.Command{
+	Use:     "resync [node_id]",
+	Short:   "Probes the node again for the versions of its software",
+	Long:    "Probes the node again for the versions of its software",
+	Example: "  $ heketi-cli node resync 886a86a868711bef83001",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := cmd.Flags().Args()
+
+		//ensure proper number of args
+		if len(s) < 1 {
+			return errors.New("Node id missing")
+		}
+
+		nodeId := cmd.Flags().Arg(0)
+
+		// Create a client
+		heketi, err := newHeketiClient()
+		if err != nil {
+			return err
+		}
+
+		err = heketi.NodeResync(nodeId)
+		if err == nil {
+			fmt.Fprintf(stdout, "Node %v updated\n", nodeId)
+		}
+		return err
+	},
+}
+
 var nodeRemoveCommand = &cobra.Command{
 	Use:     "remove [node_id]",
 	Short:   "Removes a node and all its associated devices from Heketi",
@@ -394,4 +432,51 @@ func printNodeInfo(w io.Writer, info *api.NodeInfoResponse) {
 			fmt.Fprintf(stdout, "  %v: %v\n", k, v)
 		}
 	}
+	if c := info.Capabilities; c != nil {
+		fmt.Fprintf(stdout, "Capabilities:\n"+
+			"  Gluster: %v (op-version %v)\n"+
+			"  Gluster Block: %v %v\n"+
+			"  LVM: %v (json reports: %v)\n"+
+			"  Kernel: %v (xfs: %v)\n"+
+			"  XFS Tools: %v\n",
+			c.GlusterVersion, c.OpVersion,
+			c.GlusterBlock, c.GlusterBlockVersion,
+			c.LvmVersion, c.LvmJson,
+			c.KernelVersion, c.Xfs,
+			c.XfsVersion)
+	}
+}
+
+// addConnectionFlags adds the flags that override how the server
+// connects to nodes.
+func addConnectionFlags(cmd *cobra.Command) {
+	cmd.Flags().String("ssh-user", "",
+		"Optional: User to connect to the node(s) as")
+	cmd.Flags().String("ssh-port", "",
+		"Optional: Port to connect to the node(s) on")
+	cmd.Flags().String("ssh-key", "",
+		"Optional: Name of a key in the server's credentials file")
+	cmd.Flags().String("ssh-sudo", "",
+		"Optional: Run commands on the node(s) with sudo (true/false)")
+}
+
+// connectionFromFlags returns the connection settings given on the
+// command line or nil if none were given.
+func connectionFromFlags(cmd *cobra.Command) (*api.ConnectionSettings, error) {
+	cs := &api.ConnectionSettings{}
+	cs.User, _ = cmd.Flags().GetString("ssh-user")
+	cs.Port, _ = cmd.Flags().GetString("ssh-port")
+	cs.Key, _ = cmd.Flags().GetString("ssh-key")
+	sudo, _ := cmd.Flags().GetString("ssh-sudo")
+	if sudo != "" {
+		b, err := strconv.ParseBool(sudo)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for --ssh-sudo: %v", sudo)
+		}
+		cs.Sudo = &b
+	}
+	if *cs == (api.ConnectionSettings{}) {
+		return nil, nil
+	}
+	return cs, nil
 }
@@ -37,6 +37,7 @@ var operationsCommand = &cobra.Command{
 var opInfoTemplate = `Operation Counts:
   Total: {{.Total}}
   In-Flight: {{.InFlight}}
+  Stuck: {{.Stuck}}
   New: {{.New}}
   Failed: {{.Failed}}
   Stale: {{.Stale}}
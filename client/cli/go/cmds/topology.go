@@ -28,60 +28,13 @@ const (
 )
 
 var (
-	jsonConfigFile string
-	customTemplate string
+	jsonConfigFile  string
+	customTemplate  string
+	topologyFilter  api.TopologyInfoOptions
+	topologyMissing string
+	exportFlags     bool
 )
 
-// Config file
-type ConfigFileDeviceOptions struct {
-	api.Device
-	DestroyData bool `json:"destroydata,omitempty"`
-}
-
-type ConfigFileDevice struct {
-	ConfigFileDeviceOptions
-}
-type ConfigFileNode struct {
-	Devices []*ConfigFileDevice `json:"devices"`
-	Node    api.NodeAddRequest  `json:"node"`
-}
-type ConfigFileCluster struct {
-	Nodes []ConfigFileNode `json:"nodes"`
-	Block *bool            `json:"block,omitempty"`
-	File  *bool            `json:"file,omitempty"`
-}
-type ConfigFile struct {
-	Clusters []ConfigFileCluster `json:"clusters"`
-}
-
-// UnmarshalJSON is implemented on the ConfigFileDevice so that older
-// topology files that use strings in the device list can be used
-// with newer versions of heketi. If the json object is a string,
-// it is assigned to the device name and all other values ignored.
-// Otherwise we assume that the object matches the device and
-// that is decoded into our local wrapper type.
-func (device *ConfigFileDevice) UnmarshalJSON(b []byte) error {
-	var s string
-	err := json.Unmarshal(b, &s)
-	if err == nil {
-		device.Name = s
-		return nil
-	}
-
-	// ConfigFileDevice embeds the ConfigFileDeviceOptions struct which has
-	// additional members compared to the standard api.Device. Structuring
-	// it this way, prevents a recursive call to UnmarshalJSON().
-	var d ConfigFileDeviceOptions
-	err = json.Unmarshal(b, &d)
-	if err != nil {
-		return err
-	}
-	device.Name = d.Name
-	device.Tags = d.Tags
-	device.DestroyData = d.DestroyData
-	return nil
-}
-
 func init() {
 	RootCmd.AddCommand(topologyCommand)
 	topologyCommand.AddCommand(topologyLoadCommand)
@@ -91,6 +44,32 @@ func init() {
 	topologyCommand.AddCommand(topologyInfoCommand)
 	topologyInfoCommand.Flags().StringVarP(&customTemplate, "template", "T", "",
 		"\n\tCustom Go-template for formatting topology info output.")
+	topologyInfoCommand.Flags().StringVar(&topologyFilter.Cluster, "cluster", "",
+		"\n\tOnly show the cluster with this id.")
+	topologyInfoCommand.Flags().StringVar(&topologyFilter.Node, "node", "",
+		"\n\tOnly show the node with this id and the volumes with"+
+			"\n\tbricks on the node.")
+	topologyInfoCommand.Flags().BoolVar(&topologyFilter.NoVolumes, "no-volumes", false,
+		"\n\tDo not show the volumes and block volumes.")
+	topologyInfoCommand.Flags().BoolVar(&topologyFilter.NoBricks, "no-bricks", false,
+		"\n\tDo not show the bricks of the devices and volumes.")
+	topologyCommand.AddCommand(topologyDiffCommand)
+	topologyCommand.AddCommand(topologyApplyCommand)
+	for _, cmd := range []*cobra.Command{
+		topologyDiffCommand, topologyApplyCommand} {
+
+		cmd.Flags().StringVarP(&jsonConfigFile, "json", "j", "",
+			"\n\tConfiguration containing devices, nodes, and clusters, in"+
+				"\n\tJSON format.")
+		cmd.Flags().StringVar(&topologyMissing, "missing", "keep",
+			"\n\tWhat to do with nodes and devices that are not in the"+
+				"\n\tconfiguration: keep, disable or remove them.")
+		cmd.SilenceUsage = true
+	}
+	topologyCommand.AddCommand(topologyExportCommand)
+	topologyExportCommand.Flags().BoolVar(&exportFlags, "cluster-flags", false,
+		"\n\tInclude the file and block flags of the clusters.")
+	topologyExportCommand.SilenceUsage = true
 	topologyLoadCommand.SilenceUsage = true
 	topologyInfoCommand.SilenceUsage = true
 }
@@ -147,15 +126,9 @@ var topologyLoadCommand = &cobra.Command{
 		}
 
 		// Load config file
-		fp, err := os.Open(jsonConfigFile)
+		topology, err := loadTopologyFile(jsonConfigFile)
 		if err != nil {
-			return errors.New("Unable to open config file")
-		}
-		defer fp.Close()
-		configParser := json.NewDecoder(fp)
-		var topology ConfigFile
-		if err = configParser.Decode(&topology); err != nil {
-			return errors.New("Unable to parse config file")
+			return err
 		}
 
 		// Create client
@@ -277,11 +250,180 @@ var topologyLoadCommand = &cobra.Command{
 	},
 }
 
+// loadTopologyFile reads a topology configuration file.
+func loadTopologyFile(name string) (*api.TopologyFile, error) {
+	fp, err := os.Open(name)
+	if err != nil {
+		return nil, errors.New("Unable to open config file")
+	}
+	defer fp.Close()
+	var topology api.TopologyFile
+	if err := json.NewDecoder(fp).Decode(&topology); err != nil {
+		return nil, errors.New("Unable to parse config file")
+	}
+	return &topology, nil
+}
+
+// topologyApplyRequest builds the apply request from the command
+// line options.
+func topologyApplyRequest() (*api.TopologyApplyRequest, error) {
+	if jsonConfigFile == "" {
+		return nil, errors.New("Missing configuration file")
+	}
+	topology, err := loadTopologyFile(jsonConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	return &api.TopologyApplyRequest{
+		Topology: *topology,
+		Missing:  api.TopologyMissing(topologyMissing),
+	}, nil
+}
+
+func printTopologyDiff(diff *api.TopologyDiff) {
+	if len(diff.Changes) == 0 {
+		fmt.Fprintf(stdout, "Topology is up to date\n")
+		return
+	}
+	for _, c := range diff.Changes {
+		switch c.Change {
+		case api.TopologyAddCluster:
+			fmt.Fprintf(stdout, "%v: block=%v file=%v\n",
+				c.Change, c.Flags.Block, c.Flags.File)
+		case api.TopologySetNodeZone:
+			fmt.Fprintf(stdout, "%v: %v %v -> %v\n",
+				c.Change, c.Hostname, c.OldZone, c.Zone)
+		case api.TopologySetNodeTags, api.TopologySetDeviceTags:
+			fmt.Fprintf(stdout, "%v: %v %v %v -> %v\n",
+				c.Change, c.Hostname, c.Device, c.OldTags, c.Tags)
+		default:
+			fmt.Fprintf(stdout, "%v: %v %v\n",
+				c.Change, c.Hostname, c.Device)
+		}
+	}
+}
+
+var topologyDiffCommand = &cobra.Command{
+	Use:   "diff",
+	Short: "Show the changes needed to match a configuration file",
+	Long:  "Show the changes needed to make the topology match a configuration file",
+	Example: `  * Show the nodes and devices that would be added
+      $ heketi-cli topology diff --json=topo.json
+
+  * Also show the nodes and devices that would be removed
+      $ heketi-cli topology diff --json=topo.json --missing=remove`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req, err := topologyApplyRequest()
+		if err != nil {
+			return err
+		}
+
+		heketi, err := newHeketiClient()
+		if err != nil {
+			return err
+		}
+
+		diff, err := heketi.TopologyDiff(req)
+		if err != nil {
+			return err
+		}
+
+		if options.Json {
+			data, err := json.Marshal(diff)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, string(data))
+		} else {
+			printTopologyDiff(diff)
+		}
+		return nil
+	},
+}
+
+var topologyApplyCommand = &cobra.Command{
+	Use:   "apply",
+	Short: "Make the topology match a configuration file",
+	Long: "Make the topology match a configuration file. Nodes and devices" +
+		" are added and their zones and tags are updated. Nodes and" +
+		" devices not in the file are kept, disabled or removed as" +
+		" set by --missing.",
+	Example: " $ heketi-cli topology apply --json=topo.json --missing=disable",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req, err := topologyApplyRequest()
+		if err != nil {
+			return err
+		}
+
+		heketi, err := newHeketiClient()
+		if err != nil {
+			return err
+		}
+
+		diff, err := heketi.TopologyDiff(req)
+		if err != nil {
+			return err
+		}
+		if !options.Json {
+			printTopologyDiff(diff)
+		}
+		if len(diff.Changes) == 0 {
+			return nil
+		}
+
+		topoinfo, err := heketi.TopologyApply(req)
+		if err != nil {
+			return err
+		}
+
+		if options.Json {
+			data, err := json.Marshal(topoinfo)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, string(data))
+		} else {
+			fmt.Fprintf(stdout, "Applied %v changes\n", len(diff.Changes))
+		}
+		return nil
+	},
+}
+
+var topologyExportCommand = &cobra.Command{
+	Use:   "export",
+	Short: "Write the current Topology as a configuration file",
+	Long: "Write the current Topology in the JSON format used by" +
+		" topology load",
+	Example: " $ heketi-cli topology export --cluster-flags > topo.json",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		heketi, err := newHeketiClient()
+		if err != nil {
+			return err
+		}
+
+		topo, err := heketi.TopologyExport(exportFlags)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(topo, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s\n", data)
+		return nil
+	},
+}
+
 var topologyInfoCommand = &cobra.Command{
-	Use:     "info",
-	Short:   "Retrieves information about the current Topology",
-	Long:    "Retrieves information about the current Topology",
-	Example: " $ heketi-cli topology info",
+	Use:   "info",
+	Short: "Retrieves information about the current Topology",
+	Long:  "Retrieves information about the current Topology",
+	Example: `  * Show the whole topology
+      $ heketi-cli topology info
+
+  * Show a node without the bricks
+      $ heketi-cli topology info --node=3e098cb4407d7109806bb196d9e8f095 --no-bricks`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		// Create a client to talk to Heketi
@@ -291,7 +433,12 @@ var topologyInfoCommand = &cobra.Command{
 		}
 
 		// Create Topology
-		topoinfo, err := heketi.TopologyInfo()
+		var topoinfo *api.TopologyInfoResponse
+		if topologyFilter == (api.TopologyInfoOptions{}) {
+			topoinfo, err = heketi.TopologyInfo()
+		} else {
+			topoinfo, err = heketi.TopologyInfoWithOptions(topologyFilter)
+		}
 		if err != nil {
 			return err
 		}
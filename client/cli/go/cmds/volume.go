@@ -14,6 +14,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -35,8 +36,20 @@ var (
 	id                   string
 	glusterVolumeOptions string
 	block                bool
+
+	// volume list filters
+	listCluster    string
+	listNamePrefix string
+	listBlock      string
+	listMinSize    int
+	listMaxSize    int
+	listTag        string
+	listSort       string
 )
 
+// volumes requested at once when listing
+const listPageSize = 500
+
 func init() {
 	RootCmd.AddCommand(volumeCommand)
 	volumeCommand.AddCommand(volumeCreateCommand)
@@ -88,6 +101,23 @@ func init() {
 	volumeCreateCommand.Flags().BoolVar(&block, "block", false,
 		"\n\tOptional: Create a block-hosting volume. Intended to host"+
 			"\n\tloopback files to be exported as block devices.")
+	volumeListCommand.Flags().StringVar(&listCluster, "cluster", "",
+		"\n\tOptional: List only the volumes of this cluster")
+	volumeListCommand.Flags().StringVar(&listNamePrefix, "name-prefix", "",
+		"\n\tOptional: List only the volumes with a name starting with this prefix")
+	volumeListCommand.Flags().StringVar(&listBlock, "block", "",
+		"\n\tOptional: true lists only the block hosting volumes, false"+
+			"\n\tonly the other volumes")
+	volumeListCommand.Flags().IntVar(&listMinSize, "min-size", 0,
+		"\n\tOptional: List only the volumes of at least this size in GiB")
+	volumeListCommand.Flags().IntVar(&listMaxSize, "max-size", 0,
+		"\n\tOptional: List only the volumes of at most this size in GiB")
+	volumeListCommand.Flags().StringVar(&listTag, "tag", "",
+		"\n\tOptional: List only the volumes with bricks on devices, or nodes,"+
+			"\n\twith this tag, given as name or name=value")
+	volumeListCommand.Flags().StringVar(&listSort, "sort", "",
+		"\n\tOptional: Order of the volumes: id (default), name or size."+
+			"\n\tPrefix with - to reverse the order")
 	volumeCreateCommand.SilenceUsage = true
 	volumeDeleteCommand.SilenceUsage = true
 	volumeExpandCommand.SilenceUsage = true
@@ -463,21 +493,64 @@ func printVolumeInfo(volume *api.VolumeInfoResponse) {
 }
 
 var volumeListCommand = &cobra.Command{
-	Use:     "list",
-	Short:   "Lists the volumes managed by Heketi",
-	Long:    "Lists the volumes managed by Heketi",
-	Example: "  $ heketi-cli volume list",
+	Use:   "list",
+	Short: "Lists the volumes managed by Heketi",
+	Long:  "Lists the volumes managed by Heketi",
+	Example: `  * List all the volumes:
+      $ heketi-cli volume list
+
+  * List the block hosting volumes of a cluster, largest first:
+      $ heketi-cli volume list --cluster=0995098e1284ddccb46c7752d142c832 \
+        --block=true --sort=-size`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := api.ListOptions{
+			Limit:      listPageSize,
+			Cluster:    listCluster,
+			NamePrefix: listNamePrefix,
+			MinSize:    listMinSize,
+			MaxSize:    listMaxSize,
+			Tag:        listTag,
+			Sort:       listSort,
+			Detail:     !options.Json,
+		}
+		if listBlock != "" {
+			b, err := strconv.ParseBool(listBlock)
+			if err != nil {
+				return fmt.Errorf("Invalid value for --block: %v", listBlock)
+			}
+			opts.Block = &b
+		}
+
 		// Create a client
 		heketi, err := newHeketiClient()
 		if err != nil {
 			return err
 		}
 
-		// List volumes
-		list, err := heketi.VolumeList()
-		if err != nil {
-			return err
+		// List volumes, a page at a time
+		list := &api.VolumeListResponse{Volumes: []string{}}
+		volumes := []api.VolumeInfoResponse{}
+		for {
+			page, err := heketi.VolumeListWithOptions(opts)
+			if err != nil {
+				return err
+			}
+			list.Volumes = append(list.Volumes, page.Volumes...)
+			if opts.Detail && len(page.Details) == 0 {
+				// servers without detailed lists
+				for _, id := range page.Volumes {
+					volume, err := heketi.VolumeInfo(id)
+					if err != nil {
+						return err
+					}
+					page.Details = append(page.Details, *volume)
+				}
+			}
+			volumes = append(volumes, page.Details...)
+			if page.Continue == "" {
+				break
+			}
+			opts.Continue = page.Continue
 		}
 
 		if options.Json {
@@ -487,18 +560,13 @@ var volumeListCommand = &cobra.Command{
 			}
 			fmt.Fprintf(stdout, string(data))
 		} else {
-			for _, id := range list.Volumes {
-				volume, err := heketi.VolumeInfo(id)
-				if err != nil {
-					return err
-				}
-
+			for _, volume := range volumes {
 				blockstr := ""
 				if volume.Block {
 					blockstr = " [block]"
 				}
 				fmt.Fprintf(stdout, "Id:%-35v Cluster:%-35v Name:%v%v\n",
-					id,
+					volume.Id,
 					volume.Cluster,
 					volume.Name,
 					blockstr)
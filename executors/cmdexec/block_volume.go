@@ -13,6 +13,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lpabon/godbc"
 
@@ -44,21 +45,20 @@ func (s *CmdExecutor) BlockVolumeCreate(host string,
 		auth_set = "disable"
 	}
 
-	cmd := fmt.Sprintf(
-		"gluster-block create %v/%v ha %v auth %v prealloc %v %v %vGiB --json",
-		volume.GlusterVolumeName,
-		volume.Name,
-		volume.Hacount,
-		auth_set,
-		s.BlockVolumeDefaultPrealloc(),
+	cmd := rex.Argv("gluster-block", "create",
+		fmt.Sprintf("%v/%v", volume.GlusterVolumeName, volume.Name),
+		"ha", fmt.Sprintf("%v", volume.Hacount),
+		"auth", auth_set,
+		"prealloc", s.BlockVolumeDefaultPrealloc(),
 		strings.Join(volume.BlockHosts, ","),
-		volume.Size)
+		fmt.Sprintf("%vGiB", volume.Size),
+		"--json")
 
 	// Initialize the commands with the create command
-	commands := []string{cmd}
+	commands := rex.Cmds{cmd}
 
 	// Execute command
-	results, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 10)
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 10*time.Minute)
 	if err != nil {
 		return nil, err
 	}
@@ -113,11 +113,12 @@ func (s *CmdExecutor) BlockVolumeDestroy(host string, blockHostingVolumeName str
 	godbc.Require(blockHostingVolumeName != "")
 	godbc.Require(blockVolumeName != "")
 
-	commands := []string{
-		fmt.Sprintf("gluster-block delete %v/%v --json",
-			blockHostingVolumeName, blockVolumeName),
+	commands := rex.Cmds{
+		rex.Argv("gluster-block", "delete",
+			fmt.Sprintf("%v/%v", blockHostingVolumeName, blockVolumeName),
+			"--json"),
 	}
-	res, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 10)
+	res, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 10*time.Minute)
 	if err != nil {
 		// non-command error conditions
 		return err
@@ -169,12 +170,14 @@ func (c *CmdExecutor) ListBlockVolumes(host string, blockhostingvolume string) (
 	godbc.Require(host != "")
 	godbc.Require(blockhostingvolume != "")
 
-	commands := []string{fmt.Sprintf("gluster-block list %v --json", blockhostingvolume)}
+	commands := rex.Cmds{
+		rex.Argv("gluster-block", "list", blockhostingvolume, "--json"),
+	}
 
-	results, err := c.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 10)
+	results, err := c.RemoteExecutor.ExecCommands(c.Context(), host, commands, 10*time.Minute)
 	if err := rex.AnyError(results, err); err != nil {
 		logger.Err(err)
-		return nil, fmt.Errorf("unable to list blockvolumes on block hosting volume %v : %v", blockhostingvolume, err)
+		return nil, wrapErrorf(err, "unable to list blockvolumes on block hosting volume %v : %v", blockhostingvolume, err)
 	}
 
 	type BlockVolumeListOutput struct {
@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/heketi/heketi/executors"
 	conv "github.com/heketi/heketi/pkg/conversions"
@@ -42,7 +43,7 @@ func (s *CmdExecutor) BrickCreate(host string,
 	var lvChunkSize string
 	var xfsSw int
 	var xfsSu int
-	var mkfsXfs string
+	var mkfsXfs rex.ArgvCmd
 	if brick.Format == executors.ArbiterFormat {
 		xfsInodeOptions = "maxpct=100"
 		lvChunkSize = "256K"
@@ -57,71 +58,72 @@ func (s *CmdExecutor) BrickCreate(host string,
 	devnode := paths.BrickDevNode(brick.VgId, brick.Name)
 	// Create mkfs.xfs command
 	if xfsSw == 0 || xfsSu == 0 {
-		mkfsXfs = fmt.Sprintf("mkfs.xfs -i %v -n size=8192 %v", xfsInodeOptions, devnode)
+		mkfsXfs = rex.Argv("mkfs.xfs", "-i", xfsInodeOptions, "-n", "size=8192", devnode)
 	} else {
-		mkfsXfs = fmt.Sprintf("mkfs.xfs -i %v -d su=%v,sw=%v -n size=8192 %v", xfsInodeOptions, xfsSu, xfsSw, devnode)
+		mkfsXfs = rex.Argv("mkfs.xfs", "-i", xfsInodeOptions,
+			"-d", fmt.Sprintf("su=%v,sw=%v", xfsSu, xfsSw),
+			"-n", "size=8192", devnode)
 	}
-	commands := []string{
+	commands := rex.Cmds{
 
 		// Create a directory
-		fmt.Sprintf("mkdir -p %v", mountPath),
+		rex.Argv("mkdir", "-p", mountPath),
 
 		// Setup the LV
-		fmt.Sprintf("lvcreate -qq --autobackup=%v --poolmetadatasize %vK --chunksize %v --size %vK --thin %v/%v --virtualsize %vK --name %v",
+		rex.Argv("lvcreate", "-qq",
 			// backup LVM metadata
-			conv.BoolToYN(s.BackupLVM),
+			"--autobackup="+conv.BoolToYN(s.BackupLVM),
 
 			// MetadataSize
-			brick.PoolMetadataSize,
+			"--poolmetadatasize", fmt.Sprintf("%vK", brick.PoolMetadataSize),
 
 			// ChunkSize
-			lvChunkSize,
+			"--chunksize", lvChunkSize,
 
 			//Thin Pool Size
-			brick.TpSize,
+			"--size", fmt.Sprintf("%vK", brick.TpSize),
 
-			// volume group
-			paths.VgIdToName(brick.VgId),
-
-			// ThinP name
-			brick.TpName,
+			// volume group and ThinP name
+			"--thin", fmt.Sprintf("%v/%v", paths.VgIdToName(brick.VgId), brick.TpName),
 
 			// Allocation size
-			brick.Size,
+			"--virtualsize", fmt.Sprintf("%vK", brick.Size),
 
 			// Logical Vol name
-			brick.LvName),
+			"--name", brick.LvName),
 
 		// Format
 		mkfsXfs,
 
-		// Fstab
-		fmt.Sprintf("awk \"BEGIN {print \\\"%v %v xfs rw,inode64,noatime,nouuid 0 0\\\" >> \\\"%v\\\"}\"",
-			devnode,
-			mountPath,
-			s.Fstab),
+		// Fstab, the entry and file are passed as awk variables so
+		// that they are never parsed as part of the awk program
+		rex.Argv("awk",
+			"-v", fmt.Sprintf("entry=%v %v xfs rw,inode64,noatime,nouuid 0 0",
+				devnode, mountPath),
+			"-v", "fstab="+s.Fstab,
+			"BEGIN {print entry >> fstab}"),
 
 		// Mount
-		fmt.Sprintf("mount -o rw,inode64,noatime,nouuid %v %v", devnode, mountPath),
+		rex.Argv("mount", "-o", "rw,inode64,noatime,nouuid", devnode, mountPath),
 
 		// Create a directory inside the formated volume for GlusterFS
-		fmt.Sprintf("mkdir %v", brickPath),
+		rex.Argv("mkdir", brickPath),
 	}
 
 	// Only set the GID if the value is other than root(gid 0).
 	// When no gid is set, root is the only one that can write to the volume
 	if 0 != brick.Gid {
-		commands = append(commands, []string{
+		commands = append(commands, rex.Cmds{
 			// Set GID on brick
-			fmt.Sprintf("chown :%v %v", brick.Gid, brickPath),
+			rex.Argv("chown", fmt.Sprintf(":%v", brick.Gid), brickPath),
 
 			// Set writable by GID and UID
-			fmt.Sprintf("chmod 2775 %v", brickPath),
+			rex.Argv("chmod", "2775", brickPath),
 		}...)
 	}
 
 	// Execute commands
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 10))
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 10*time.Minute))
 	if err != nil {
 		// Cleanup
 		s.BrickDestroy(host, brick)
@@ -137,20 +139,19 @@ func (s *CmdExecutor) BrickCreate(host string,
 
 func (s *CmdExecutor) deleteBrickLV(host, lv string) error {
 	// Remove the LV (by device name)
-	commands := []string{
-		fmt.Sprintf("lvremove --autobackup=%v -f %v",
-			conv.BoolToYN(s.BackupLVM), lv),
+	commands := rex.Cmds{
+		rex.Argv("lvremove", "--autobackup="+conv.BoolToYN(s.BackupLVM), "-f", lv),
 	}
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5))
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute))
 	return err
 }
 
 func (s *CmdExecutor) countThinLVsInPool(host, tp string) (int, error) {
 	// Detect the number of bricks using the thin-pool
-	commands := []string{
-		fmt.Sprintf("lvs --noheadings --options=thin_count %v", tp),
+	commands := rex.Cmds{
+		rex.Argv("lvs", "--noheadings", "--options=thin_count", tp),
 	}
-	results, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5)
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute)
 	if err := rex.AnyError(results, err); err != nil {
 		return 0, err
 	}
@@ -178,15 +179,15 @@ func (s *CmdExecutor) BrickDestroy(host string,
 	)
 
 	// Try to unmount first
-	commands := []string{
-		fmt.Sprintf("umount %v", brick.Path),
+	commands := rex.Cmds{
+		rex.Argv("umount", brick.Path),
 	}
-	umountErr = rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5))
+	umountErr = rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute))
 	if umountErr != nil {
 		logger.Err(umountErr)
 		// check if the brick was previously unmounted
 		res, e := s.RemoteExecutor.ExecCommands(
-			host, rex.OneCmd("mount"), 5)
+			s.Context(), host, rex.Cmds{rex.Argv("mount")}, 5*time.Minute)
 
 		if e == nil && res.Ok() && !strings.Contains(res[0].Output, brick.Path) {
 			logger.Warning("brick path [%v] not mounted, assuming deleted",
@@ -195,10 +196,10 @@ func (s *CmdExecutor) BrickDestroy(host string,
 		} else {
 			if s.DebugUmountFailures() {
 				// in case unmounting failed, grab the output of 'lsof /path/to/brick'
-				commands = []string{
-					fmt.Sprintf("lsof %s", brick.Path),
+				commands = rex.Cmds{
+					rex.Argv("lsof", brick.Path),
 				}
-				res, _ = s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5)
+				res, _ = s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute)
 				logger.Warning("brick path [%s] kept open by:\n%s", brick.Path, res[0].Output)
 			}
 		}
@@ -252,10 +253,10 @@ func (s *CmdExecutor) BrickDestroy(host string,
 
 	// If there is no brick left in the thin-pool, it can be removed
 	if thin_count == 0 {
-		commands = []string{
-			fmt.Sprintf("lvremove --autobackup=%v -f %v", conv.BoolToYN(s.BackupLVM), tp),
+		commands = rex.Cmds{
+			rex.Argv("lvremove", "--autobackup="+conv.BoolToYN(s.BackupLVM), "-f", tp),
 		}
-		err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5))
+		err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute))
 		if errIsLvNotFound(err) {
 			logger.Warning("did not delete missing thin pool: %v", tp)
 			// if the thin pool is gone then the bricks in the db associated
@@ -269,10 +270,10 @@ func (s *CmdExecutor) BrickDestroy(host string,
 	}
 
 	// Now cleanup the mount point
-	commands = []string{
-		fmt.Sprintf("rmdir %v", brick.Path),
+	commands = rex.Cmds{
+		rex.Argv("rmdir", brick.Path),
 	}
-	err = rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5))
+	err = rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute))
 	if err != nil {
 		logger.Err(err)
 	}
@@ -287,12 +288,12 @@ func (s *CmdExecutor) removeBrickFromFstab(
 	if strings.HasPrefix(brick.Path, "/run/gluster/") || strings.HasPrefix(brick.Path, "/var/run/gluster/") {
 		return nil
 	}
-	commands := []string{
-		fmt.Sprintf("sed -i.save \"/%v/d\" %v",
-			paths.BrickIdToName(brick.Name),
+	commands := rex.Cmds{
+		rex.Argv("sed", "-i.save",
+			fmt.Sprintf("/%v/d", paths.BrickIdToName(brick.Name)),
 			s.Fstab),
 	}
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5))
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute))
 	if err != nil {
 		logger.Err(err)
 	}
@@ -311,16 +312,15 @@ func errIsLvNotFound(err error) bool {
 func (s *CmdExecutor) GetBrickMountStatus(host string) (*executors.BricksMountStatus, error) {
 	godbc.Require(host != "")
 
-	commands := []string{
-		"mount",
-		fmt.Sprintf("cat %v", s.Fstab),
+	commands := rex.Cmds{
+		rex.Argv("mount"),
+		rex.Argv("cat", s.Fstab),
 	}
 
-	res, err := s.RemoteExecutor.ExecCommands(
-		host, rex.ToCmds(commands), 5)
+	res, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute)
 	if err := rex.AnyError(res, err); err != nil {
 		logger.Err(err)
-		return nil, fmt.Errorf("Unable to get mount status for bricks : %v", err)
+		return nil, wrapErrorf(err, "Unable to get mount status for bricks : %v", err)
 	}
 
 	var brickMounts executors.BricksMountStatus
@@ -0,0 +1,186 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package cmdexec
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+const probeTimeout = time.Minute
+
+// NodeCapabilities probes host for the versions of gluster,
+// gluster-block, lvm and xfs it has installed. A tool that is
+// missing or fails leaves its fields empty; only errors reaching
+// the node are returned.
+func (s *CmdExecutor) NodeCapabilities(host string) (*executors.NodeCapabilities, error) {
+	godbc.Require(host != "")
+
+	logger.Info("Probing capabilities of node %v", host)
+	nc := &executors.NodeCapabilities{}
+
+	out, ok, err := s.probe(host, rex.Argv("gluster", "--version"), probeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		nc.GlusterVersion = parseGlusterVersion(out)
+	}
+
+	out, ok, err = s.probe(host,
+		s.glusterCmd("volume", "get", "all", "cluster.op-version"),
+		s.GlusterCliExecTimeout())
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		nc.OpVersion = parseOpVersion(out)
+	}
+
+	out, ok, err = s.probe(host, rex.Argv("gluster-block", "version"), probeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		nc.GlusterBlock = true
+		nc.GlusterBlockVersion = firstLine(out)
+	}
+
+	out, ok, err = s.probe(host, rex.Argv("lvm", "version"), probeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		nc.LvmVersion = parseLvmVersion(out)
+	}
+
+	// the device functions rely on json reports
+	_, nc.LvmJson, err = s.probe(host,
+		rex.Argv("pvs", "--reportformat", "json", "-o", "pv_name"),
+		probeTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	out, ok, err = s.probe(host, rex.Argv("uname", "-r"), probeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		nc.KernelVersion = firstLine(out)
+	}
+
+	out, ok, err = s.probe(host, rex.Argv("cat", "/proc/filesystems"), probeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		nc.Xfs = hasFilesystem(out, "xfs")
+	}
+
+	out, ok, err = s.probe(host, rex.Argv("mkfs.xfs", "-V"), probeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		nc.XfsVersion = lastField(out)
+	}
+
+	return nc, nil
+}
+
+// probe runs a single command on host and returns its output and
+// whether it succeeded. The error is only set if the command could
+// not be run at all.
+func (s *CmdExecutor) probe(host string, cmd rex.ArgvCmd,
+	timeout time.Duration) (string, bool, error) {
+
+	cmd.Options.Quiet = true
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host,
+		rex.Cmds{cmd}, timeout)
+	if err != nil {
+		return "", false, err
+	}
+	if len(results) == 0 || !results[0].Ok() {
+		logger.Debug("Probe [%v] failed on node %v", cmd, host)
+		return "", false, nil
+	}
+	return results[0].Output, true, nil
+}
+
+func firstLine(s string) string {
+	return strings.TrimSpace(strings.SplitN(s, "\n", 2)[0])
+}
+
+func lastField(s string) string {
+	f := strings.Fields(firstLine(s))
+	if len(f) == 0 {
+		return ""
+	}
+	return f[len(f)-1]
+}
+
+// parseGlusterVersion returns the version in the output of
+// gluster --version, e.g. "6.0" from "glusterfs 6.0".
+func parseGlusterVersion(s string) string {
+	f := strings.Fields(firstLine(s))
+	if len(f) < 2 {
+		return ""
+	}
+	return f[1]
+}
+
+// parseOpVersion returns the op-version in the option listing
+// of gluster volume get.
+func parseOpVersion(s string) int {
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		if len(f) == 2 && f[0] == "cluster.op-version" {
+			v, err := strconv.Atoi(f[1])
+			if err == nil {
+				return v
+			}
+		}
+	}
+	return 0
+}
+
+// parseLvmVersion returns the version in the output of lvm version,
+// e.g. "2.02.180(2)-RHEL7" from "LVM version: 2.02.180(2)-RHEL7 (2018-07-20)".
+func parseLvmVersion(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "LVM version:") {
+			f := strings.Fields(strings.TrimPrefix(line, "LVM version:"))
+			if len(f) > 0 {
+				return f[0]
+			}
+		}
+	}
+	return ""
+}
+
+// hasFilesystem returns true if fs is listed in the contents
+// of /proc/filesystems.
+func hasFilesystem(s, fs string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		if len(f) > 0 && f[len(f)-1] == fs {
+			return true
+		}
+	}
+	return false
+}
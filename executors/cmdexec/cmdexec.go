@@ -10,12 +10,15 @@
 package cmdexec
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"regexp"
 	"strconv"
 	"sync"
+	"time"
 
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/pkg/logging"
 	rex "github.com/heketi/heketi/pkg/remoteexec"
 )
@@ -26,7 +29,11 @@ var (
 )
 
 type RemoteCommandTransport interface {
-	ExecCommands(host string, commands rex.Cmds, timeoutMinutes int) (rex.Results, error)
+	// ExecCommands runs the commands on host. The commands stop
+	// when ctx is done or, if timeout is not zero, once they have
+	// run for longer than timeout.
+	ExecCommands(ctx context.Context, host string, commands rex.Cmds,
+		timeout time.Duration) (rex.Results, error)
 	RebalanceOnExpansion() bool
 	SnapShotLimit() int
 	GlusterCliTimeout() uint32
@@ -45,10 +52,18 @@ type CmdExecutor struct {
 	RemoteExecutor RemoteCommandTransport
 	Fstab          string
 	BackupLVM      bool
+
+	// context the commands run within, if not the background
+	ctx context.Context
 }
 
-func (c *CmdExecutor) glusterCommand() string {
-	return fmt.Sprintf("gluster --mode=script --timeout=%v", c.GlusterCliTimeout())
+// glusterCmd returns a gluster cli command with the given arguments.
+func (c *CmdExecutor) glusterCmd(args ...string) rex.ArgvCmd {
+	return rex.Argv(append([]string{
+		"gluster",
+		"--mode=script",
+		fmt.Sprintf("--timeout=%v", c.GlusterCliTimeout()),
+	}, args...)...)
 }
 
 func setWithEnvVariables(config *CmdConfig) {
@@ -87,20 +102,85 @@ func (c *CmdExecutor) Init(config *CmdConfig) {
 	setWithEnvVariables(config)
 }
 
+// derive returns a new executor with the same configuration that
+// runs commands through the same transport.
+func (c *CmdExecutor) derive() *CmdExecutor {
+	return &CmdExecutor{
+		config:         c.config,
+		Throttlemap:    make(map[string]chan bool),
+		RemoteExecutor: c.RemoteExecutor,
+		Fstab:          c.Fstab,
+		BackupLVM:      c.BackupLVM,
+		ctx:            c.ctx,
+	}
+}
+
+// ForOperation returns an executor that runs the same commands
+// through the same transport, marked with the given operation id.
+func (c *CmdExecutor) ForOperation(id string) executors.Executor {
+	o := c.derive()
+	o.RemoteExecutor = &operationTransport{
+		RemoteCommandTransport: c.RemoteExecutor,
+		id:                     id,
+	}
+	return o
+}
+
+// WithContext returns an executor that runs the same commands
+// through the same transport within ctx.
+func (c *CmdExecutor) WithContext(ctx context.Context) executors.Executor {
+	o := c.derive()
+	o.ctx = ctx
+	return o
+}
+
+// Context returns the context the commands of the executor run within.
+func (c *CmdExecutor) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// operationTransport marks all commands passing through it with
+// the id of an operation.
+type operationTransport struct {
+	RemoteCommandTransport
+	id string
+}
+
+func (t *operationTransport) ExecCommands(ctx context.Context,
+	host string, commands rex.Cmds, timeout time.Duration) (rex.Results, error) {
+
+	return t.RemoteCommandTransport.ExecCommands(
+		ctx, host, rex.WithOperation(commands, t.id), timeout)
+}
+
 func (s *CmdExecutor) AccessConnection(host string) {
-	var (
-		c  chan bool
-		ok bool
-	)
+	s.throttle(host) <- true
+}
 
+// AccessConnectionContext waits for access to host like
+// AccessConnection but gives up, returning the error of ctx,
+// once ctx is done.
+func (s *CmdExecutor) AccessConnectionContext(ctx context.Context, host string) error {
+	select {
+	case s.throttle(host) <- true:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *CmdExecutor) throttle(host string) chan bool {
 	s.Lock.Lock()
-	if c, ok = s.Throttlemap[host]; !ok {
+	defer s.Lock.Unlock()
+	c, ok := s.Throttlemap[host]
+	if !ok {
 		c = make(chan bool, 1)
 		s.Throttlemap[host] = c
 	}
-	s.Lock.Unlock()
-
-	c <- true
+	return c
 }
 
 func (s *CmdExecutor) FreeConnection(host string) {
@@ -143,15 +223,15 @@ func (c *CmdExecutor) GlusterCliTimeout() uint32 {
 	return c.config.GlusterCliTimeout
 }
 
-// The timeout, in minutes, for the command execution.
+// The timeout for the command execution.
 // It used to be 10 minutes (or sometimes 5, for some simple commands),
 // but now it needs to be longer than the gluster cli timeout at
 // least where calling the gluster cli.
-func (c *CmdExecutor) GlusterCliExecTimeout() int {
-	timeout := 1 + (int(c.GlusterCliTimeout())+1)/60
+func (c *CmdExecutor) GlusterCliExecTimeout() time.Duration {
+	timeout := time.Second * time.Duration(c.GlusterCliTimeout()+60)
 
-	if timeout < 10 {
-		timeout = 10
+	if timeout < 10*time.Minute {
+		timeout = 10 * time.Minute
 	}
 
 	return timeout
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/heketi/heketi/executors"
 	conv "github.com/heketi/heketi/pkg/conversions"
@@ -40,27 +41,28 @@ const (
 func (s *CmdExecutor) DeviceSetup(host, device, vgid string, destroy bool) (d *executors.DeviceInfo, e error) {
 
 	// Setup commands
-	commands := []string{}
+	commands := rex.Cmds{}
 
 	if destroy {
 		logger.Info("Data on device %v (host %v) will be destroyed", device, host)
-		commands = append(commands, fmt.Sprintf("wipefs --all %v", device))
+		commands = append(commands, rex.Argv("wipefs", "--all", device))
 	}
-	commands = append(commands, fmt.Sprintf("pvcreate -qq --metadatasize=128M --dataalignment=%v '%v'", s.PVDataAlignment(), device))
-	commands = append(commands, fmt.Sprintf("vgcreate -qq --physicalextentsize=%v --autobackup=%v %v %v",
+	commands = append(commands, rex.Argv("pvcreate", "-qq", "--metadatasize=128M",
+		"--dataalignment="+s.PVDataAlignment(), device))
+	commands = append(commands, rex.Argv("vgcreate", "-qq",
 
 		// Physical extent size
-		s.VGPhysicalExtentSize(),
+		"--physicalextentsize="+s.VGPhysicalExtentSize(),
 
 		// Autobackup
-		conv.BoolToYN(s.BackupLVM),
+		"--autobackup="+conv.BoolToYN(s.BackupLVM),
 
 		// Device
 		paths.VgIdToName(vgid), device),
 	)
 
 	// Execute command
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5))
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute))
 	if err != nil {
 		err = s.deviceSetupError(err, host, device)
 		return nil, err
@@ -89,11 +91,11 @@ func (s *CmdExecutor) DeviceSetup(host, device, vgid string, destroy bool) (d *e
 func (s *CmdExecutor) PVS(host string) (d *executors.PVSCommandOutput, e error) {
 
 	// Setup commands
-	commands := []string{}
-
-	commands = append(commands, fmt.Sprintf("pvs --reportformat json --units k"))
+	commands := rex.Cmds{
+		rex.Argv("pvs", "--reportformat", "json", "--units", "k"),
+	}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
 		return nil, fmt.Errorf("Unable to get data for LVM PVs")
@@ -109,11 +111,11 @@ func (s *CmdExecutor) PVS(host string) (d *executors.PVSCommandOutput, e error)
 func (s *CmdExecutor) VGS(host string) (d *executors.VGSCommandOutput, e error) {
 
 	// Setup commands
-	commands := []string{}
-
-	commands = append(commands, fmt.Sprintf("vgs --reportformat json --units k"))
+	commands := rex.Cmds{
+		rex.Argv("vgs", "--reportformat", "json", "--units", "k"),
+	}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
 		return nil, fmt.Errorf("Unable to get data for LVM VGs")
@@ -129,11 +131,11 @@ func (s *CmdExecutor) VGS(host string) (d *executors.VGSCommandOutput, e error)
 func (s *CmdExecutor) LVS(host string) (d *executors.LVSCommandOutput, e error) {
 
 	// Setup commands
-	commands := []string{}
-
-	commands = append(commands, fmt.Sprintf("lvs --reportformat json --units k"))
+	commands := rex.Cmds{
+		rex.Argv("lvs", "--reportformat", "json", "--units", "k"),
+	}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
 		return nil, fmt.Errorf("Unable to get data for LVM LVs")
@@ -190,13 +192,13 @@ func (s *CmdExecutor) DeviceForget(host string, dh *executors.DeviceVgHandle) er
 }
 
 func (s *CmdExecutor) removeDevice(host, device, vgid string) error {
-	commands := []string{
-		fmt.Sprintf("vgremove -qq %v", paths.VgIdToName(vgid)),
-		fmt.Sprintf("pvremove -qq '%v'", device),
+	commands := rex.Cmds{
+		rex.Argv("vgremove", "-qq", paths.VgIdToName(vgid)),
+		rex.Argv("pvremove", "-qq", device),
 	}
 
 	// Execute command
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5))
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute))
 	if err != nil {
 		return logger.LogError(
 			"Failed to delete device %v with id %v on host %v: %v",
@@ -207,11 +209,11 @@ func (s *CmdExecutor) removeDevice(host, device, vgid string) error {
 
 func (s *CmdExecutor) removeDeviceMountPoint(host, vgid string) error {
 	pdir := paths.BrickMountPointParent(vgid)
-	commands := []string{
-		fmt.Sprintf("rmdir %v", pdir),
+	commands := rex.Cmds{
+		rex.Argv("rmdir", pdir),
 	}
 
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5))
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute))
 	if err != nil && !strings.Contains(err.Error(), "No such file or directory") {
 		logger.LogError("Error while removing the VG directory: %v", err)
 	}
@@ -223,12 +225,12 @@ func (s *CmdExecutor) getVgSizeFromNode(
 	host, device, vgid string) error {
 
 	// Setup command
-	commands := []string{
-		fmt.Sprintf("vgdisplay -c %v", paths.VgIdToName(vgid)),
+	commands := rex.Cmds{
+		rex.Argv("vgdisplay", "-c", paths.VgIdToName(vgid)),
 	}
 
 	// Execute command
-	results, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5)
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute)
 	if err := rex.AnyError(results, err); err != nil {
 		return err
 	}
@@ -277,13 +279,12 @@ func (s *CmdExecutor) getVgSizeFromNode(
 func (s *CmdExecutor) getDeviceHandle(host, device string) (
 	*executors.DeviceHandle, error) {
 
-	commands := []string{}
-	commands = append(commands,
-		fmt.Sprintf("pvs -o pv_name,pv_uuid,vg_name --reportformat=json %v", device))
-	commands = append(commands,
-		fmt.Sprintf("udevadm info --query=symlink --name=%v", device))
+	commands := rex.Cmds{
+		rex.Argv("pvs", "-o", "pv_name,pv_uuid,vg_name", "--reportformat=json", device),
+		rex.Argv("udevadm", "info", "--query=symlink", "--name="+device),
+	}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5)
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute)
 	if err != nil {
 		return nil, connErr("failed to get device handle", err)
 	}
@@ -319,11 +320,11 @@ func (s *CmdExecutor) upgradeHandle(host string, dh *executors.DeviceVgHandle) e
 	}
 	// this handle lacks a uuid, our preferred way to get a persistent
 	// path in /dev. Try to get one based on the vg id
-	commands := []string{}
-	commands = append(commands,
-		fmt.Sprintf("vgs -o pv_name,pv_uuid,vg_name --reportformat=json %v",
-			paths.VgIdToName(dh.VgId)))
-	results, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands), 5)
+	commands := rex.Cmds{
+		rex.Argv("vgs", "-o", "pv_name,pv_uuid,vg_name", "--reportformat=json",
+			paths.VgIdToName(dh.VgId)),
+	}
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands, 5*time.Minute)
 	if e := rex.AnyError(results, err); e != nil {
 		logger.Warning("failed to get vgs info for handle: %v", err)
 		return nil
@@ -444,6 +445,31 @@ func (e connectionErr) Error() string {
 	return fmt.Sprintf("%s: %v", e.msg, e.err)
 }
 
+func (e connectionErr) Unwrap() error {
+	return e.err
+}
+
 func connErr(m string, e error) connectionErr {
 	return connectionErr{m, e}
 }
+
+// wrappedError is an error with a message of its own that keeps the
+// error it was caused by, so the cause can still be examined.
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string {
+	return e.msg
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+// wrapErrorf returns an error with the formatted message that wraps
+// err. The format is expected to include err in the message.
+func wrapErrorf(err error, format string, a ...interface{}) error {
+	return &wrappedError{fmt.Sprintf(format, a...), err}
+}
@@ -0,0 +1,28 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package cmdexec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/heketi/tests"
+)
+
+func TestWrapErrorf(t *testing.T) {
+	cause := errors.New("connection reset")
+	err := wrapErrorf(cause, "Unable to delete volume %v: %v", "vol1", cause)
+	tests.Assert(t, err.Error() == "Unable to delete volume vol1: connection reset",
+		"unexpected message:", err.Error())
+
+	w, ok := err.(interface{ Unwrap() error })
+	tests.Assert(t, ok, "expected error to wrap its cause")
+	tests.Assert(t, w.Unwrap() == cause, "expected", cause, "got", w.Unwrap())
+}
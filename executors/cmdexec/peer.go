@@ -11,6 +11,7 @@ package cmdexec
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/lpabon/godbc"
 
@@ -25,10 +26,10 @@ func (s *CmdExecutor) PeerProbe(host, newnode string) error {
 
 	logger.Info("Probing: %v -> %v", host, newnode)
 	// create the commands
-	commands := []string{
-		fmt.Sprintf("%v peer probe %v", s.glusterCommand(), newnode),
+	commands := rex.Cmds{
+		s.glusterCmd("peer", "probe", newnode),
 	}
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout()))
 	if err != nil {
 		return err
@@ -37,11 +38,11 @@ func (s *CmdExecutor) PeerProbe(host, newnode string) error {
 	// Determine if there is a snapshot limit configuration setting
 	if s.RemoteExecutor.SnapShotLimit() > 0 {
 		logger.Info("Setting snapshot limit")
-		commands = []string{
-			fmt.Sprintf("%v snapshot config snap-max-hard-limit %v",
-				s.glusterCommand(), s.RemoteExecutor.SnapShotLimit()),
+		commands = rex.Cmds{
+			s.glusterCmd("snapshot", "config", "snap-max-hard-limit",
+				fmt.Sprintf("%v", s.RemoteExecutor.SnapShotLimit())),
 		}
-		err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+		err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 			s.GlusterCliExecTimeout()))
 		if err != nil {
 			return err
@@ -57,10 +58,10 @@ func (s *CmdExecutor) PeerDetach(host, detachnode string) error {
 
 	// create the commands
 	logger.Info("Detaching node %v", detachnode)
-	commands := []string{
-		fmt.Sprintf("%v peer detach %v", s.glusterCommand(), detachnode),
+	commands := rex.Cmds{
+		s.glusterCmd("peer", "detach", detachnode),
 	}
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout()))
 	if err != nil {
 		logger.Err(err)
@@ -73,9 +74,9 @@ func (s *CmdExecutor) GlusterdCheck(host string) error {
 	godbc.Require(host != "")
 
 	logger.Info("Check Glusterd service status in node %v", host)
-	cmd := rex.ToCmd("systemctl status glusterd")
+	cmd := rex.Argv("systemctl", "status", "glusterd")
 	cmd.Options.Quiet = true
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.Cmds{cmd}, 10))
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, rex.Cmds{cmd}, 10*time.Minute))
 	if err != nil {
 		logger.Err(err)
 		return err
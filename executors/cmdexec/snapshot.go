@@ -30,12 +30,12 @@ func (s *CmdExecutor) snapshotActivate(host string, snapshot string) error {
 		SnapActivate executors.SnapActivate `xml:"snapActivate"`
 	}
 
-	command := rex.OneCmd(fmt.Sprintf("%v --xml snapshot activate %v", s.glusterCommand(), snapshot))
+	command := rex.Cmds{s.glusterCmd("--xml", "snapshot", "activate", snapshot)}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, command,
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
-		return fmt.Errorf("Unable to activate snapshot %v: %v", snapshot, err)
+		return wrapErrorf(err, "Unable to activate snapshot %v: %v", snapshot, err)
 	}
 
 	var snapActivate CliOutput
@@ -62,12 +62,12 @@ func (s *CmdExecutor) snapshotDeactivate(host string, snapshot string) error {
 		SnapDeactivate executors.SnapDeactivate `xml:"snapDeactivate"`
 	}
 
-	command := rex.OneCmd(fmt.Sprintf("%v --xml snapshot deactivate %v", s.glusterCommand(), snapshot))
+	command := rex.Cmds{s.glusterCmd("--xml", "snapshot", "deactivate", snapshot)}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, command,
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
-		return fmt.Errorf("Unable to deactivate snapshot %v: %v", snapshot, err)
+		return wrapErrorf(err, "Unable to deactivate snapshot %v: %v", snapshot, err)
 	}
 
 	var snapDeactivate CliOutput
@@ -103,14 +103,14 @@ func (s *CmdExecutor) SnapshotCloneVolume(host string, vcr *executors.SnapshotCl
 		SnapClone executors.SnapClone `xml:"CloneCreate"`
 	}
 
-	command := rex.OneCmd(
-		fmt.Sprintf("%v --xml snapshot clone %v %v", s.glusterCommand(), vcr.Volume, vcr.Snapshot),
-	)
+	command := rex.Cmds{
+		s.glusterCmd("--xml", "snapshot", "clone", vcr.Volume, vcr.Snapshot),
+	}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, command,
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
-		return nil, fmt.Errorf("Unable to clone snapshot %v: %v", vcr.Snapshot, err)
+		return nil, wrapErrorf(err, "Unable to clone snapshot %v: %v", vcr.Snapshot, err)
 	}
 
 	var cliOutput CliOutput
@@ -124,15 +124,15 @@ func (s *CmdExecutor) SnapshotCloneVolume(host string, vcr *executors.SnapshotCl
 	}
 
 	// start the newly cloned volume
-	command = rex.OneCmd(
-		fmt.Sprintf("%v --xml volume start %v", s.glusterCommand(), vcr.Volume),
-	)
+	command = rex.Cmds{
+		s.glusterCmd("--xml", "volume", "start", vcr.Volume),
+	}
 
-	err = rex.AnyError(s.RemoteExecutor.ExecCommands(host, command,
+	err = rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout()))
 	if err != nil {
 		s.VolumeDestroy(host, vcr.Volume)
-		return nil, fmt.Errorf("Unable to start volume %v, clone of snapshot %v: %v", vcr.Volume, vcr.Snapshot, err)
+		return nil, wrapErrorf(err, "Unable to start volume %v, clone of snapshot %v: %v", vcr.Volume, vcr.Snapshot, err)
 	}
 
 	return s.VolumeInfo(host, vcr.Volume)
@@ -154,14 +154,14 @@ func (s *CmdExecutor) SnapshotDestroy(host string, snapshot string) error {
 		SnapDelete executors.SnapDelete `xml:"snapDelete"`
 	}
 
-	command := rex.OneCmd(
-		fmt.Sprintf("%v --xml snapshot delete %v", s.glusterCommand(), snapshot),
-	)
+	command := rex.Cmds{
+		s.glusterCmd("--xml", "snapshot", "delete", snapshot),
+	}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, command,
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
-		return fmt.Errorf("Unable to delete snapshot %v: %v", snapshot, err)
+		return wrapErrorf(err, "Unable to delete snapshot %v: %v", snapshot, err)
 	}
 
 	var snapDelete CliOutput
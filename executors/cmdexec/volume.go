@@ -29,7 +29,7 @@ func (s *CmdExecutor) VolumeCreate(host string,
 	godbc.Require(len(volume.Bricks) > 0)
 	godbc.Require(volume.Name != "")
 
-	cmd := fmt.Sprintf("%v volume create %v ", s.glusterCommand(), volume.Name)
+	args := []string{"volume", "create", volume.Name}
 
 	var (
 		inSet     int
@@ -42,16 +42,18 @@ func (s *CmdExecutor) VolumeCreate(host string,
 		maxPerSet = 15
 	case executors.DurabilityReplica:
 		logger.Info("Creating volume %v replica %v", volume.Name, volume.Replica)
-		cmd += fmt.Sprintf("replica %v ", volume.Replica)
+		args = append(args, "replica", fmt.Sprintf("%v", volume.Replica))
 		if volume.Arbiter {
-			cmd += "arbiter 1 "
+			args = append(args, "arbiter", "1")
 		}
 		inSet = volume.Replica
 		maxPerSet = 5
 	case executors.DurabilityDispersion:
 		logger.Info("Creating volume %v dispersion %v+%v",
 			volume.Name, volume.Data, volume.Redundancy)
-		cmd += fmt.Sprintf("disperse-data %v redundancy %v ", volume.Data, volume.Redundancy)
+		args = append(args,
+			"disperse-data", fmt.Sprintf("%v", volume.Data),
+			"redundancy", fmt.Sprintf("%v", volume.Redundancy))
 		inSet = volume.Data + volume.Redundancy
 		maxPerSet = 1
 	}
@@ -62,18 +64,18 @@ func (s *CmdExecutor) VolumeCreate(host string,
 	// only, and then add each brick set in one subsequent command.
 
 	for _, brick := range volume.Bricks[:inSet] {
-		cmd += fmt.Sprintf("%v:%v ", brick.Host, brick.Path)
+		args = append(args, brickArg(brick))
 	}
 
-	commands := []string{cmd}
+	commands := rex.Cmds{s.glusterCmd(args...)}
 
 	commands = append(commands, s.createAddBrickCommands(volume, inSet, inSet, maxPerSet)...)
 
 	commands = append(commands, s.createVolumeOptionsCommand(volume)...)
 
-	commands = append(commands, fmt.Sprintf("%v volume start %v", s.glusterCommand(), volume.Name))
+	commands = append(commands, s.glusterCmd("volume", "start", volume.Name))
 
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout()))
 	if err != nil {
 		return nil, err
@@ -110,15 +112,15 @@ func (s *CmdExecutor) VolumeExpand(host string,
 		0, // start at the beginning of the brick list
 		inSet,
 		maxPerSet)
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout()))
 	if err != nil {
 		return nil, err
 	}
 
 	if s.RemoteExecutor.RebalanceOnExpansion() {
-		commands = []string{fmt.Sprintf("%v volume rebalance %v start", s.glusterCommand(), volume.Name)}
-		err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+		commands = rex.Cmds{s.glusterCmd("volume", "rebalance", volume.Name, "start")}
+		err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 			s.GlusterCliExecTimeout()))
 		if err != nil {
 			// This is a hack. We fake success if rebalance fails.
@@ -140,24 +142,24 @@ func (s *CmdExecutor) VolumeDestroy(host string, volume string) error {
 
 	// First stop the volume, then delete it
 
-	commands := []string{
-		fmt.Sprintf("%v volume stop %v force", s.glusterCommand(), volume),
+	commands := rex.Cmds{
+		s.glusterCmd("volume", "stop", volume, "force"),
 	}
 
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout()))
 	if err != nil {
 		logger.LogError("Unable to stop volume %v: %v", volume, err)
 	}
 
-	commands = []string{
-		fmt.Sprintf("%v volume delete %v", s.glusterCommand(), volume),
+	commands = rex.Cmds{
+		s.glusterCmd("volume", "delete", volume),
 	}
 
-	err = rex.AnyError(s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	err = rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout()))
 	if err != nil {
-		return logger.Err(fmt.Errorf("Unable to delete volume %v: %v", volume, err))
+		return logger.Err(wrapErrorf(err, "Unable to delete volume %v: %v", volume, err))
 	}
 
 	return nil
@@ -171,15 +173,34 @@ func (s *CmdExecutor) VolumeDestroyCheck(host, volume string) error {
 	return s.checkForSnapshots(host, volume)
 }
 
-func (s *CmdExecutor) createVolumeOptionsCommand(volume *executors.VolumeRequest) []string {
-	commands := []string{}
-	var cmd string
+// brickArg returns the host:path form of a brick used by the gluster cli.
+func brickArg(brick executors.BrickInfo) string {
+	return fmt.Sprintf("%v:%v", brick.Host, brick.Path)
+}
+
+// volumeSetCmd returns the command that sets a volume option given
+// as the option name followed by its value. Everything after the
+// name is passed to gluster as the value.
+func (s *CmdExecutor) volumeSetCmd(volume, option string) rex.Cmd {
+	args := []string{"volume", "set", volume}
+	parts := strings.Fields(option)
+	if len(parts) > 0 {
+		args = append(args, parts[0])
+	}
+	if len(parts) > 1 {
+		args = append(args, strings.Join(parts[1:], " "))
+	}
+	return s.glusterCmd(args...)
+}
+
+func (s *CmdExecutor) createVolumeOptionsCommand(volume *executors.VolumeRequest) rex.Cmds {
+	commands := rex.Cmds{}
 
 	// Go through all the Options and create volume set command
 	for _, volOption := range volume.GlusterVolumeOptions {
 		if volOption != "" {
-			cmd = fmt.Sprintf("%v volume set %v %v", s.glusterCommand(), volume.Name, volOption)
-			commands = append(commands, cmd)
+			commands = append(commands,
+				s.volumeSetCmd(volume.Name, volOption))
 		}
 
 	}
@@ -187,30 +208,30 @@ func (s *CmdExecutor) createVolumeOptionsCommand(volume *executors.VolumeRequest
 }
 
 func (s *CmdExecutor) createAddBrickCommands(volume *executors.VolumeRequest,
-	start, inSet, maxPerSet int) []string {
+	start, inSet, maxPerSet int) rex.Cmds {
 
-	commands := []string{}
-	var cmd string
+	commands := rex.Cmds{}
+	var args []string
 
 	// Go through all the bricks and create add-brick commands
 	for index, brick := range volume.Bricks[start:] {
 		if index%(inSet*maxPerSet) == 0 {
-			if cmd != "" {
+			if args != nil {
 				// Add add-brick command to the command list
-				commands = append(commands, cmd)
+				commands = append(commands, s.glusterCmd(args...))
 			}
 
 			// Create a new add-brick command
-			cmd = fmt.Sprintf("%v volume add-brick %v ", s.glusterCommand(), volume.Name)
+			args = []string{"volume", "add-brick", volume.Name}
 		}
 
 		// Add this brick to the add-brick command
-		cmd += fmt.Sprintf("%v:%v ", brick.Host, brick.Path)
+		args = append(args, brickArg(brick))
 	}
 
 	// Add the last add-brick command to the command list
-	if cmd != "" {
-		commands = append(commands, cmd)
+	if args != nil {
+		commands = append(commands, s.glusterCmd(args...))
 	}
 
 	return commands
@@ -228,14 +249,14 @@ func (s *CmdExecutor) checkForSnapshots(host, volume string) error {
 		} `xml:"snapList"`
 	}
 
-	commands := []string{
-		fmt.Sprintf("%v snapshot list %v --xml", s.glusterCommand(), volume),
+	commands := rex.Cmds{
+		s.glusterCmd("snapshot", "list", volume, "--xml"),
 	}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, rex.ToCmds(commands),
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, commands,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
-		return fmt.Errorf("Unable to get snapshot information from volume %v: %v", volume, err)
+		return wrapErrorf(err, "Unable to get snapshot information from volume %v: %v", volume, err)
 	}
 
 	var snapInfo CliOutput
@@ -269,12 +290,12 @@ func (s *CmdExecutor) VolumeInfo(host string, volume string) (*executors.Volume,
 		VolInfo  executors.VolInfo `xml:"volInfo"`
 	}
 
-	command := rex.OneCmd(
-		fmt.Sprintf("%v volume info %v --xml", s.glusterCommand(), volume),
-	)
+	command := rex.Cmds{
+		s.glusterCmd("volume", "info", volume, "--xml"),
+	}
 
 	//Get the xml output of volume info
-	results, err := s.RemoteExecutor.ExecCommands(host, command,
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
 		return nil, fmt.Errorf("Unable to get volume info of volume name: %v", volume)
@@ -299,12 +320,12 @@ func (s *CmdExecutor) VolumesInfo(host string) (*executors.VolInfo, error) {
 		VolInfo  executors.VolInfo `xml:"volInfo"`
 	}
 
-	command := rex.OneCmd(
-		fmt.Sprintf("%v volume info --xml", s.glusterCommand()),
-	)
+	command := rex.Cmds{
+		s.glusterCmd("volume", "info", "--xml"),
+	}
 
 	//Get the xml output of volume info
-	results, err := s.RemoteExecutor.ExecCommands(host, command,
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
 		return nil, fmt.Errorf("Unable to get volume info")
@@ -324,10 +345,11 @@ func (s *CmdExecutor) VolumeReplaceBrick(host string, volume string, oldBrick *e
 	godbc.Require(newBrick != nil)
 
 	// Replace the brick
-	command := rex.OneCmd(
-		fmt.Sprintf("%v volume replace-brick %v %v:%v %v:%v commit force", s.glusterCommand(), volume, oldBrick.Host, oldBrick.Path, newBrick.Host, newBrick.Path),
-	)
-	err := rex.AnyError(s.RemoteExecutor.ExecCommands(host, command,
+	command := rex.Cmds{
+		s.glusterCmd("volume", "replace-brick", volume,
+			brickArg(*oldBrick), brickArg(*newBrick), "commit", "force"),
+	}
+	err := rex.AnyError(s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout()))
 	if err != nil {
 		return logger.Err(fmt.Errorf("Unable to replace brick %v:%v with %v:%v for volume %v", oldBrick.Host, oldBrick.Path, newBrick.Host, newBrick.Path, volume))
@@ -378,15 +400,15 @@ func (s *CmdExecutor) VolumeSnapshot(host string, vsr *executors.VolumeSnapshotR
 		SnapCreate executors.SnapCreate `xml:"snapCreate"`
 	}
 
-	command := rex.OneCmd(
-		fmt.Sprintf("%v --xml snapshot create %v %v no-timestamp", s.glusterCommand(), vsr.Snapshot, vsr.Volume),
+	command := rex.Cmds{
+		s.glusterCmd("--xml", "snapshot", "create", vsr.Snapshot, vsr.Volume, "no-timestamp"),
 		// TODO: set the snapshot description if vsr.Description is non-empty
-	)
+	}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, command,
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
-		return nil, fmt.Errorf("Unable to create snapshot of volume %v: %v", vsr.Volume, err)
+		return nil, wrapErrorf(err, "Unable to create snapshot of volume %v: %v", vsr.Volume, err)
 	}
 
 	var snapCreate CliOutput
@@ -418,11 +440,11 @@ func (s *CmdExecutor) HealInfo(host string, volume string) (*executors.HealInfo,
 		HealInfo executors.HealInfo `xml:"healInfo"`
 	}
 
-	command := rex.OneCmd(
-		fmt.Sprintf("%v volume heal %v info --xml", s.glusterCommand(), volume),
-	)
+	command := rex.Cmds{
+		s.glusterCmd("volume", "heal", volume, "info", "--xml"),
+	}
 
-	results, err := s.RemoteExecutor.ExecCommands(host, command,
+	results, err := s.RemoteExecutor.ExecCommands(s.Context(), host, command,
 		s.GlusterCliExecTimeout())
 	if err := rex.AnyError(results, err); err != nil {
 		return nil, fmt.Errorf("Unable to get heal info of volume : %v", volume)
@@ -441,22 +463,19 @@ func (s *CmdExecutor) VolumeModify(host string, mod *executors.VolumeModifyReque
 
 	commands := rex.Cmds{}
 	if mod.Stopped {
-		c := fmt.Sprintf("%v volume stop %v", s.glusterCommand(), mod.Name)
-		commands = append(commands, rex.ToCmd(c))
+		commands = append(commands, s.glusterCmd("volume", "stop", mod.Name))
 	}
 	for _, volOption := range mod.GlusterVolumeOptions {
 		if volOption == "" {
 			continue
 		}
-		c := fmt.Sprintf("%v volume set %v %v", s.glusterCommand(), mod.Name, volOption)
-		commands = append(commands, rex.ToCmd(c))
+		commands = append(commands, s.volumeSetCmd(mod.Name, volOption))
 	}
 	if mod.Stopped {
-		c := fmt.Sprintf("%v volume start %v", s.glusterCommand(), mod.Name)
-		commands = append(commands, rex.ToCmd(c))
+		commands = append(commands, s.glusterCmd("volume", "start", mod.Name))
 	}
 
 	err := rex.AnyError(s.RemoteExecutor.ExecCommands(
-		host, commands, s.GlusterCliExecTimeout()))
+		s.Context(), host, commands, s.GlusterCliExecTimeout()))
 	return err
 }
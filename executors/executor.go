@@ -10,8 +10,11 @@
 package executors
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
+
+	rex "github.com/heketi/heketi/pkg/remoteexec"
 )
 
 type Executor interface {
@@ -48,6 +51,124 @@ type Executor interface {
 	ListBlockVolumes(host string, blockhostingvolume string) ([]string, error)
 }
 
+// ActiveCommandsReporter is implemented by executors that can report
+// the commands they are currently running on the storage nodes.
+type ActiveCommandsReporter interface {
+	ActiveCommands() *rex.ActiveCommands
+}
+
+// CommandTrailSetter is implemented by executors that can record
+// the commands they run on the storage nodes in a command trail.
+type CommandTrailSetter interface {
+	SetCommandTrail(t *rex.CommandTrail)
+}
+
+// OperationTagger is implemented by executors that can mark the
+// commands they run with the id of the operation they run them for.
+type OperationTagger interface {
+	// ForOperation returns an executor that runs commands for
+	// the operation with the given id
+	ForOperation(id string) Executor
+}
+
+// ForOperation returns an executor that marks its commands with the
+// id of the operation if e supports it, and e itself otherwise.
+func ForOperation(e Executor, id string) Executor {
+	if t, ok := e.(OperationTagger); ok {
+		return t.ForOperation(id)
+	}
+	return e
+}
+
+// ContextExecutor is implemented by executors whose functions can
+// stop their work on the storage nodes when a context is done.
+type ContextExecutor interface {
+	// WithContext returns an executor whose functions run within ctx
+	WithContext(ctx context.Context) Executor
+}
+
+// WithContext returns an executor whose functions run within ctx
+// if e supports contexts, and e itself otherwise.
+func WithContext(e Executor, ctx context.Context) Executor {
+	if ce, ok := e.(ContextExecutor); ok {
+		return ce.WithContext(ctx)
+	}
+	return e
+}
+
+// ConnectionPoolReporter is implemented by executors that keep
+// connections to the storage nodes open between commands.
+type ConnectionPoolReporter interface {
+	ConnectionPoolStats() rex.PoolStats
+}
+
+// Closer is implemented by executors that hold resources, such as
+// open connections to the storage nodes, that must be released once
+// the executor is no longer used.
+type Closer interface {
+	Close() error
+}
+
+// CircuitBreakerReporter is implemented by executors that stop
+// connecting to nodes after repeated connection failures.
+type CircuitBreakerReporter interface {
+	CircuitBreakerStats() rex.BreakerStats
+	// HostCircuit returns the state of the circuit to host
+	HostCircuit(host string) rex.CircuitState
+}
+
+// HostKeyPinner is implemented by executors that identify the nodes
+// by their host keys. Keys are in the ssh authorized keys format.
+type HostKeyPinner interface {
+	// ScanHostKey returns the key currently presented by host,
+	// whether or not it matches the key pinned for host
+	ScanHostKey(host string) (string, error)
+	// PinHostKey sets the key host must present. An empty key
+	// accepts any key.
+	PinHostKey(host, key string) error
+}
+
+// HostConnection overrides how an executor connects to a single
+// node. Empty fields keep the executor's defaults.
+type HostConnection struct {
+	User string `json:"user,omitempty"`
+	Port string `json:"port,omitempty"`
+	// Key names a key known to the executor
+	Key  string `json:"key,omitempty"`
+	Sudo *bool  `json:"sudo,omitempty"`
+}
+
+// HostConnectionSetter is implemented by executors whose connection
+// settings can be changed per node.
+type HostConnectionSetter interface {
+	// SetHostConnection sets the connection overrides for host.
+	// A nil value removes the overrides.
+	SetHostConnection(host string, hc *HostConnection) error
+}
+
+// NodeCapabilities describes the software found on a node.
+// Versions are empty, and the op-version zero, if they could
+// not be determined.
+type NodeCapabilities struct {
+	GlusterVersion      string
+	OpVersion           int
+	GlusterBlock        bool
+	GlusterBlockVersion string
+	LvmVersion          string
+	// LvmJson is true if the lvm tools can report in json
+	LvmJson       bool
+	KernelVersion string
+	// Xfs is true if the kernel of the node supports xfs
+	Xfs        bool
+	XfsVersion string
+}
+
+// CapabilityProber is implemented by executors that can discover
+// the software versions and features of the nodes.
+type CapabilityProber interface {
+	NodeCapabilities(host string) (*NodeCapabilities, error)
+}
+
 // Enumerate durability types
 type DurabilityType int
 
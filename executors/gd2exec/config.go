@@ -0,0 +1,29 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package gd2exec
+
+type Gd2Config struct {
+	// Port of the glusterd2 REST API on the nodes
+	Port  string `json:"port"`
+	Https bool   `json:"https"`
+	// CACertFile verifies the nodes when using https
+	CACertFile string `json:"ca_cert_file"`
+	Insecure   bool   `json:"insecure"`
+
+	// User and Secret sign the requests when glusterd2 requires
+	// authentication
+	User   string `json:"user"`
+	Secret string `json:"secret"`
+
+	// RequestTimeout, in seconds, of a single request
+	RequestTimeout uint32 `json:"request_timeout"`
+
+	RebalanceOnExpansion bool `json:"rebalance_on_expansion"`
+}
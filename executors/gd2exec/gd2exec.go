@@ -0,0 +1,359 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package gd2exec
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/logging"
+)
+
+const (
+	apiPrefix             = "/v1"
+	defaultPort           = "24007"
+	defaultRequestTimeout = 300
+)
+
+var (
+	logger            = logging.NewLogger("[gd2exec]", logging.LEVEL_DEBUG)
+	NotSupportedError = executors.NotSupportedError
+)
+
+// Gd2Executor manages the gluster peers, volumes and snapshots
+// through the glusterd2 REST API of the nodes. It has no access to
+// the storage of the nodes and returns NotSupportedError for device,
+// brick and block volume functions so that it can be stacked over an
+// executor that does.
+type Gd2Executor struct {
+	config *Gd2Config
+	scheme string
+	port   string
+	client *http.Client
+
+	// context the requests are made within, if not the background
+	ctx context.Context
+}
+
+// RequestError is returned when glusterd2 rejects a request.
+type RequestError struct {
+	Host       string
+	StatusCode int
+	Message    string
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("glusterd2 on %v failed request (%v): %v",
+		e.Host, e.StatusCode, e.Message)
+}
+
+func isNotFound(err error) bool {
+	re, ok := err.(*RequestError)
+	return ok && re.StatusCode == http.StatusNotFound
+}
+
+func setWithEnvVariables(config *Gd2Config) {
+	var env string
+
+	env = os.Getenv("HEKETI_GD2_PORT")
+	if "" != env {
+		config.Port = env
+	}
+
+	env = os.Getenv("HEKETI_GD2_USER")
+	if "" != env {
+		config.User = env
+	}
+
+	env = os.Getenv("HEKETI_GD2_SECRET")
+	if "" != env {
+		config.Secret = env
+	}
+
+	env = os.Getenv("HEKETI_GD2_HTTPS")
+	if "" != env {
+		b, err := strconv.ParseBool(env)
+		if err == nil {
+			config.Https = b
+		}
+	}
+}
+
+func NewGd2Executor(config *Gd2Config) (*Gd2Executor, error) {
+	// Override configuration
+	setWithEnvVariables(config)
+
+	g := &Gd2Executor{
+		config: config,
+		scheme: "http",
+		port:   config.Port,
+	}
+	if g.port == "" {
+		g.port = defaultPort
+	}
+
+	timeout := config.RequestTimeout
+	if timeout == 0 {
+		timeout = defaultRequestTimeout
+	}
+	g.client = &http.Client{
+		Timeout: time.Second * time.Duration(timeout),
+	}
+
+	if config.Https {
+		g.scheme = "https"
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: config.Insecure,
+		}
+		if config.CACertFile != "" {
+			pem, err := ioutil.ReadFile(config.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"Unable to read glusterd2 CA certificate %v: %v",
+					config.CACertFile, err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf(
+					"No certificates found in %v", config.CACertFile)
+			}
+		}
+		g.client.Transport = &http.Transport{
+			TLSClientConfig: tlsConfig,
+		}
+	}
+
+	godbc.Ensure(g.port != "")
+	return g, nil
+}
+
+// SetHttpClient replaces the client used to reach glusterd2.
+func (g *Gd2Executor) SetHttpClient(c *http.Client) {
+	g.client = c
+}
+
+// WithContext returns an executor that makes its requests to
+// glusterd2 within ctx.
+func (g *Gd2Executor) WithContext(ctx context.Context) executors.Executor {
+	return g.withContext(ctx)
+}
+
+func (g *Gd2Executor) withContext(ctx context.Context) *Gd2Executor {
+	c := *g
+	c.ctx = ctx
+	return &c
+}
+
+// url returns the address of an API path on the glusterd2 of host.
+// A host that already includes a port is used as is.
+func (g *Gd2Executor) url(host, path string) string {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, g.port)
+	}
+	return g.scheme + "://" + host + path
+}
+
+// do sends a request with in, if any, as the json body to the
+// glusterd2 of host and decodes the response into out, if any.
+func (g *Gd2Executor) do(host, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, g.url(host, path), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if g.ctx != nil {
+		req = req.WithContext(g.ctx)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := g.setToken(req); err != nil {
+		return err
+	}
+
+	logger.Debug("%v %v", method, req.URL)
+	r, err := g.client.Do(req)
+	if err != nil {
+		return logger.LogError("Request %v %v failed: %v", method, req.URL, err)
+	}
+	defer r.Body.Close()
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return &RequestError{
+			Host:       host,
+			StatusCode: r.StatusCode,
+			Message:    errorMessage(data),
+		}
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("Unable to parse response to %v %v: %v",
+				method, req.URL, err)
+		}
+	}
+	return nil
+}
+
+// errorMessage returns the messages of a glusterd2 error response.
+func errorMessage(data []byte) string {
+	var resp struct {
+		Errors []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || len(resp.Errors) == 0 {
+		return strings.TrimSpace(string(data))
+	}
+	msgs := make([]string, len(resp.Errors))
+	for i, e := range resp.Errors {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// setToken signs the request in the way glusterd2 expects when it
+// requires authentication.
+func (g *Gd2Executor) setToken(r *http.Request) error {
+	if g.config.Secret == "" {
+		return nil
+	}
+
+	// Create qsh hash
+	qshstring := r.Method + "&" + r.URL.Path
+	hash := sha256.New()
+	hash.Write([]byte(qshstring))
+
+	user := g.config.User
+	if user == "" {
+		user = "glustercli"
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": user,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Minute * 5).Unix(),
+		"qsh": hex.EncodeToString(hash.Sum(nil)),
+	})
+	signedtoken, err := token.SignedString([]byte(g.config.Secret))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", "bearer "+signedtoken)
+	return nil
+}
+
+func (g *Gd2Executor) RebalanceOnExpansion() bool {
+	return g.config.RebalanceOnExpansion
+}
+
+func (g *Gd2Executor) SetLogLevel(level string) {
+	switch level {
+	case "none":
+		logger.SetLevel(logging.LEVEL_NOLOG)
+	case "critical":
+		logger.SetLevel(logging.LEVEL_CRITICAL)
+	case "error":
+		logger.SetLevel(logging.LEVEL_ERROR)
+	case "warning":
+		logger.SetLevel(logging.LEVEL_WARNING)
+	case "info":
+		logger.SetLevel(logging.LEVEL_INFO)
+	case "debug":
+		logger.SetLevel(logging.LEVEL_DEBUG)
+	}
+}
+
+// The functions below need access to the storage of the nodes,
+// which glusterd2 does not provide.
+
+func (g *Gd2Executor) DeviceSetup(host, device, vgid string, destroy bool) (*executors.DeviceInfo, error) {
+	return nil, NotSupportedError
+}
+
+func (g *Gd2Executor) GetDeviceInfo(host string, dh *executors.DeviceVgHandle) (*executors.DeviceInfo, error) {
+	return nil, NotSupportedError
+}
+
+func (g *Gd2Executor) DeviceTeardown(host string, dh *executors.DeviceVgHandle) error {
+	return NotSupportedError
+}
+
+func (g *Gd2Executor) DeviceForget(host string, dh *executors.DeviceVgHandle) error {
+	return NotSupportedError
+}
+
+func (g *Gd2Executor) BrickCreate(host string, brick *executors.BrickRequest) (*executors.BrickInfo, error) {
+	return nil, NotSupportedError
+}
+
+func (g *Gd2Executor) BrickDestroy(host string, brick *executors.BrickRequest) (bool, error) {
+	return false, NotSupportedError
+}
+
+func (g *Gd2Executor) BlockVolumeCreate(host string, blockVolume *executors.BlockVolumeRequest) (*executors.BlockVolumeInfo, error) {
+	return nil, NotSupportedError
+}
+
+func (g *Gd2Executor) BlockVolumeDestroy(host string, blockHostingVolumeName string, blockVolumeName string) error {
+	return NotSupportedError
+}
+
+func (g *Gd2Executor) ListBlockVolumes(host string, blockhostingvolume string) ([]string, error) {
+	return nil, NotSupportedError
+}
+
+func (g *Gd2Executor) SnapshotCloneBlockVolume(host string, scr *executors.SnapshotCloneRequest) (*executors.BlockVolumeInfo, error) {
+	return nil, NotSupportedError
+}
+
+func (g *Gd2Executor) PVS(host string) (*executors.PVSCommandOutput, error) {
+	return nil, NotSupportedError
+}
+
+func (g *Gd2Executor) VGS(host string) (*executors.VGSCommandOutput, error) {
+	return nil, NotSupportedError
+}
+
+func (g *Gd2Executor) LVS(host string) (*executors.LVSCommandOutput, error) {
+	return nil, NotSupportedError
+}
+
+func (g *Gd2Executor) GetBrickMountStatus(host string) (*executors.BricksMountStatus, error) {
+	return nil, NotSupportedError
+}
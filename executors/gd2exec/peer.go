@@ -0,0 +1,123 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package gd2exec
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/lpabon/godbc"
+)
+
+// peer is a member of the glusterd2 cluster
+type peer struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	PeerAddresses   []string `json:"peer-addresses"`
+	ClientAddresses []string `json:"client-addresses"`
+	Online          bool     `json:"online"`
+}
+
+// matches returns true if the peer is known by the given name or
+// address.
+func (p *peer) matches(name string) bool {
+	if p.Name == name {
+		return true
+	}
+	for _, addrs := range [][]string{p.PeerAddresses, p.ClientAddresses} {
+		for _, a := range addrs {
+			h, _, err := net.SplitHostPort(a)
+			if err != nil {
+				h = a
+			}
+			if h == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (g *Gd2Executor) peers(host string) ([]peer, error) {
+	var peers []peer
+	err := g.do(host, http.MethodGet, apiPrefix+"/peers", nil, &peers)
+	return peers, err
+}
+
+// peerIds returns the ids of the peers with the given names, as
+// seen by host.
+func (g *Gd2Executor) peerIds(host string, names ...string) (map[string]string, error) {
+	peers, err := g.peers(host)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]string{}
+	for _, name := range names {
+		if _, done := ids[name]; done {
+			continue
+		}
+		for i := range peers {
+			if peers[i].matches(name) {
+				ids[name] = peers[i].ID
+				break
+			}
+		}
+		if _, found := ids[name]; !found {
+			return nil, fmt.Errorf("Node %v is not a peer of %v", name, host)
+		}
+	}
+	return ids, nil
+}
+
+func (g *Gd2Executor) PeerProbe(host, newnode string) error {
+	godbc.Require(host != "")
+	godbc.Require(newnode != "")
+
+	logger.Info("Probing: %v -> %v", host, newnode)
+	req := struct {
+		Addresses []string `json:"addresses"`
+	}{
+		Addresses: []string{newnode},
+	}
+	return g.do(host, http.MethodPost, apiPrefix+"/peers", req, nil)
+}
+
+func (g *Gd2Executor) PeerDetach(host, detachnode string) error {
+	godbc.Require(host != "")
+	godbc.Require(detachnode != "")
+
+	logger.Info("Detaching node %v", detachnode)
+	ids, err := g.peerIds(host, detachnode)
+	if err != nil {
+		logger.Err(err)
+		return nil
+	}
+	err = g.do(host, http.MethodDelete,
+		apiPrefix+"/peers/"+ids[detachnode], nil, nil)
+	if err != nil {
+		logger.Err(err)
+	}
+
+	return nil
+}
+
+func (g *Gd2Executor) GlusterdCheck(host string) error {
+	godbc.Require(host != "")
+
+	logger.Info("Check glusterd2 status in node %v", host)
+	err := g.do(host, http.MethodGet, "/version", nil, nil)
+	if err != nil {
+		logger.Err(err)
+		return err
+	}
+
+	return nil
+}
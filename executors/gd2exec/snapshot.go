@@ -0,0 +1,112 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package gd2exec
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+)
+
+type snapCreateReq struct {
+	VolName     string `json:"volname"`
+	SnapName    string `json:"snapname"`
+	Description string `json:"description,omitempty"`
+}
+
+type snapCloneReq struct {
+	CloneName string `json:"clonename"`
+}
+
+type snapInfo struct {
+	VolInfo    volumeInfo `json:"snapinfo"`
+	ParentName string     `json:"parentname"`
+}
+
+func snapshotPath(snapshot string, parts ...string) string {
+	return strings.Join(append(
+		[]string{apiPrefix, "snapshots", url.PathEscape(snapshot)},
+		parts...), "/")
+}
+
+func (g *Gd2Executor) VolumeSnapshot(host string, vsr *executors.VolumeSnapshotRequest) (*executors.Snapshot, error) {
+	godbc.Require(host != "")
+	godbc.Require(vsr != nil)
+
+	req := snapCreateReq{
+		VolName:     vsr.Volume,
+		SnapName:    vsr.Snapshot,
+		Description: vsr.Description,
+	}
+	var info snapInfo
+	err := g.do(host, http.MethodPost, apiPrefix+"/snapshots", req, &info)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create snapshot of volume %v: %v", vsr.Volume, err)
+	}
+
+	snap := &executors.Snapshot{
+		Name: vsr.Snapshot,
+		UUID: info.VolInfo.ID,
+	}
+	logger.Debug("snapshot: %+v\n", snap)
+	return snap, nil
+}
+
+func (g *Gd2Executor) SnapshotCloneVolume(host string, vcr *executors.SnapshotCloneRequest) (*executors.Volume, error) {
+	godbc.Require(host != "")
+	godbc.Require(vcr != nil)
+
+	// cloning can only be done when a snapshot is activated
+	err := g.do(host, http.MethodPost,
+		snapshotPath(vcr.Snapshot, "activate"), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to activate snapshot %v: %v", vcr.Snapshot, err)
+	}
+
+	// we do not want activated snapshots sticking around
+	defer func() {
+		err := g.do(host, http.MethodPost,
+			snapshotPath(vcr.Snapshot, "deactivate"), nil, nil)
+		if err != nil {
+			logger.LogError("Unable to deactivate snapshot %v: %v", vcr.Snapshot, err)
+		}
+	}()
+
+	err = g.do(host, http.MethodPost, snapshotPath(vcr.Snapshot, "clone"),
+		snapCloneReq{CloneName: vcr.Volume}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to clone snapshot %v to volume %v: %v", vcr.Snapshot, vcr.Volume, err)
+	}
+
+	// start the newly cloned volume
+	err = g.do(host, http.MethodPost, volumePath(vcr.Volume, "start"), nil, nil)
+	if err != nil {
+		g.VolumeDestroy(host, vcr.Volume)
+		return nil, fmt.Errorf("Unable to start volume %v, clone of snapshot %v: %v", vcr.Volume, vcr.Snapshot, err)
+	}
+
+	return g.VolumeInfo(host, vcr.Volume)
+}
+
+func (g *Gd2Executor) SnapshotDestroy(host string, snapshot string) error {
+	godbc.Require(host != "")
+	godbc.Require(snapshot != "")
+
+	err := g.do(host, http.MethodDelete, snapshotPath(snapshot), nil, nil)
+	if err != nil {
+		return fmt.Errorf("Unable to delete snapshot %v: %v", snapshot, err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package gd2exec
+
+import (
+	"context"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/stack"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+// Gd2Stack manages gluster through glusterd2 and the storage of the
+// nodes through another executor, such as the ssh executor.
+type Gd2Stack struct {
+	stack.ExecutorStack
+
+	gd2   *Gd2Executor
+	nodes executors.Executor
+}
+
+func NewGd2Stack(gd2 *Gd2Executor, nodes executors.Executor) *Gd2Stack {
+	gs := &Gd2Stack{
+		gd2:   gd2,
+		nodes: nodes,
+	}
+	// glusterd2 answers the glusterd checks; the node executor
+	// would look for the glusterd service
+	gs.SetExec([]executors.Executor{gd2, nodes})
+	return gs
+}
+
+// WithContext returns a stack whose glusterd2 requests and node
+// executor run within ctx.
+func (gs *Gd2Stack) WithContext(ctx context.Context) executors.Executor {
+	return NewGd2Stack(gs.gd2.withContext(ctx),
+		executors.WithContext(gs.nodes, ctx))
+}
+
+// ForOperation returns a stack whose node executor marks its
+// commands with the operation id, if it supports it.
+func (gs *Gd2Stack) ForOperation(id string) executors.Executor {
+	return NewGd2Stack(gs.gd2, executors.ForOperation(gs.nodes, id))
+}
+
+// ActiveCommands returns the active commands tracker of the node
+// executor, if it has one.
+func (gs *Gd2Stack) ActiveCommands() *rex.ActiveCommands {
+	if r, ok := gs.nodes.(executors.ActiveCommandsReporter); ok {
+		return r.ActiveCommands()
+	}
+	return nil
+}
+
+// SetCommandTrail sets the command trail of the node executor,
+// if it records commands.
+func (gs *Gd2Stack) SetCommandTrail(t *rex.CommandTrail) {
+	if s, ok := gs.nodes.(executors.CommandTrailSetter); ok {
+		s.SetCommandTrail(t)
+	}
+}
+
+// ConnectionPoolStats returns the connection pool statistics of the
+// node executor, if it pools connections.
+func (gs *Gd2Stack) ConnectionPoolStats() rex.PoolStats {
+	if r, ok := gs.nodes.(executors.ConnectionPoolReporter); ok {
+		return r.ConnectionPoolStats()
+	}
+	return rex.PoolStats{}
+}
+
+// Close closes the node executor, if it holds resources.
+func (gs *Gd2Stack) Close() error {
+	if c, ok := gs.nodes.(executors.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// CircuitBreakerStats returns the circuit breaker statistics of
+// the node executor, if it breaks circuits.
+func (gs *Gd2Stack) CircuitBreakerStats() rex.BreakerStats {
+	if r, ok := gs.nodes.(executors.CircuitBreakerReporter); ok {
+		return r.CircuitBreakerStats()
+	}
+	return rex.BreakerStats{}
+}
+
+// HostCircuit returns the state of the circuit to a node in the
+// node executor, if it breaks circuits.
+func (gs *Gd2Stack) HostCircuit(host string) rex.CircuitState {
+	if r, ok := gs.nodes.(executors.CircuitBreakerReporter); ok {
+		return r.HostCircuit(host)
+	}
+	return rex.CircuitClosed
+}
+
+// ScanHostKey returns the host key of a node from the node executor,
+// if it identifies nodes by host key.
+func (gs *Gd2Stack) ScanHostKey(host string) (string, error) {
+	if p, ok := gs.nodes.(executors.HostKeyPinner); ok {
+		return p.ScanHostKey(host)
+	}
+	return "", nil
+}
+
+// PinHostKey pins the host key of a node in the node executor,
+// if it identifies nodes by host key.
+func (gs *Gd2Stack) PinHostKey(host, key string) error {
+	if p, ok := gs.nodes.(executors.HostKeyPinner); ok {
+		return p.PinHostKey(host, key)
+	}
+	return nil
+}
+
+// SetHostConnection sets the connection settings of a node in the
+// node executor, if it supports per node settings.
+func (gs *Gd2Stack) SetHostConnection(
+	host string, hc *executors.HostConnection) error {
+
+	if s, ok := gs.nodes.(executors.HostConnectionSetter); ok {
+		return s.SetHostConnection(host, hc)
+	}
+	return nil
+}
+
+// NodeCapabilities probes a node through the node executor, if it
+// can discover the capabilities of the nodes.
+func (gs *Gd2Stack) NodeCapabilities(
+	host string) (*executors.NodeCapabilities, error) {
+
+	if p, ok := gs.nodes.(executors.CapabilityProber); ok {
+		return p.NodeCapabilities(host)
+	}
+	return nil, NotSupportedError
+}
@@ -0,0 +1,482 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package gd2exec
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/idgen"
+)
+
+type brickReq struct {
+	PeerID string `json:"peerid"`
+	Path   string `json:"path"`
+}
+
+type subvolReq struct {
+	Type               string     `json:"type"`
+	Bricks             []brickReq `json:"bricks"`
+	ReplicaCount       int        `json:"replica,omitempty"`
+	ArbiterCount       int        `json:"arbiter,omitempty"`
+	DisperseCount      int        `json:"disperse-count,omitempty"`
+	DisperseData       int        `json:"disperse-data,omitempty"`
+	DisperseRedundancy int        `json:"disperse-redundancy,omitempty"`
+}
+
+type volCreateReq struct {
+	Name    string            `json:"name"`
+	Subvols []subvolReq       `json:"subvols"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+type volExpandReq struct {
+	ReplicaCount int        `json:"replica,omitempty"`
+	Bricks       []brickReq `json:"bricks"`
+}
+
+type volOptionReq struct {
+	Options map[string]string `json:"options"`
+}
+
+type replaceBrickReq struct {
+	SrcPeerID    string `json:"srcpeerid"`
+	SrcBrickPath string `json:"srcbrickpath"`
+	NewPeerID    string `json:"newpeerid"`
+	NewBrickPath string `json:"newbrickpath"`
+	Force        bool   `json:"force"`
+}
+
+type brickInfo struct {
+	ID       string `json:"id"`
+	Path     string `json:"path"`
+	PeerID   string `json:"peer-id"`
+	Hostname string `json:"host"`
+	Type     int    `json:"type"`
+}
+
+type subvolInfo struct {
+	Name   string      `json:"name"`
+	Bricks []brickInfo `json:"bricks"`
+}
+
+type volumeInfo struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Type            int               `json:"type"`
+	DistCount       int               `json:"distribute-count"`
+	ReplicaCount    int               `json:"replica-count"`
+	ArbiterCount    int               `json:"arbiter-count"`
+	DisperseCount   int               `json:"disperse-count"`
+	RedundancyCount int               `json:"disperse-redundancy-count"`
+	Options         map[string]string `json:"options"`
+	State           string            `json:"state"`
+	Subvols         []subvolInfo      `json:"subvols"`
+}
+
+type brickHealInfo struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	HostID  string `json:"host-id"`
+	Entries *int64 `json:"entries"`
+}
+
+const (
+	arbiterBrickType = 1
+	volumeStarted    = "Started"
+)
+
+// names of the glusterd2 volume types, in the order of their values
+var volumeTypes = []string{
+	"Distribute",
+	"Replicate",
+	"Disperse",
+	"Distributed-Replicate",
+	"Distributed-Disperse",
+}
+
+func volumePath(volume string, parts ...string) string {
+	return strings.Join(append(
+		[]string{apiPrefix, "volumes", url.PathEscape(volume)},
+		parts...), "/")
+}
+
+// toVolume converts the glusterd2 description of a volume to the
+// one the gluster cli returns.
+func (v *volumeInfo) toVolume() *executors.Volume {
+	vol := &executors.Volume{
+		VolumeName:      v.Name,
+		ID:              v.ID,
+		StatusStr:       v.State,
+		DistCount:       v.DistCount,
+		ReplicaCount:    v.ReplicaCount,
+		ArbiterCount:    v.ArbiterCount,
+		DisperseCount:   v.DisperseCount,
+		RedundancyCount: v.RedundancyCount,
+	}
+	if v.State == volumeStarted {
+		vol.Status = 1
+	}
+	if v.Type >= 0 && v.Type < len(volumeTypes) {
+		vol.TypeStr = volumeTypes[v.Type]
+	}
+	for _, sv := range v.Subvols {
+		for _, b := range sv.Bricks {
+			brick := executors.Brick{
+				UUID:     b.ID,
+				Name:     b.Hostname + ":" + b.Path,
+				HostUUID: b.PeerID,
+			}
+			if b.Type == arbiterBrickType {
+				brick.IsArbiter = 1
+			}
+			vol.Bricks.BrickList = append(vol.Bricks.BrickList, brick)
+		}
+	}
+	vol.BrickCount = len(vol.Bricks.BrickList)
+	names := make([]string, 0, len(v.Options))
+	for name := range v.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vol.Options.OptionList = append(vol.Options.OptionList,
+			executors.Option{Name: name, Value: v.Options[name]})
+	}
+	vol.OptCount = len(vol.Options.OptionList)
+	return vol
+}
+
+// volumeOptions converts options given as the option name followed
+// by its value into the map glusterd2 expects.
+func volumeOptions(options []string) map[string]string {
+	m := map[string]string{}
+	for _, option := range options {
+		parts := strings.Fields(option)
+		if len(parts) == 0 {
+			continue
+		}
+		m[parts[0]] = strings.Join(parts[1:], " ")
+	}
+	return m
+}
+
+// brickReqs returns the bricks of the volume request, identified
+// by the peer ids of their hosts.
+func (g *Gd2Executor) brickReqs(host string,
+	bricks []executors.BrickInfo) ([]brickReq, error) {
+
+	names := make([]string, len(bricks))
+	for i, b := range bricks {
+		names[i] = b.Host
+	}
+	ids, err := g.peerIds(host, names...)
+	if err != nil {
+		return nil, err
+	}
+	reqs := make([]brickReq, len(bricks))
+	for i, b := range bricks {
+		reqs[i] = brickReq{PeerID: ids[b.Host], Path: b.Path}
+	}
+	return reqs, nil
+}
+
+func (g *Gd2Executor) VolumeCreate(host string,
+	volume *executors.VolumeRequest) (*executors.Volume, error) {
+
+	godbc.Require(volume != nil)
+	godbc.Require(host != "")
+	godbc.Require(len(volume.Bricks) > 0)
+	godbc.Require(volume.Name != "")
+
+	bricks, err := g.brickReqs(host, volume.Bricks)
+	if err != nil {
+		return nil, err
+	}
+
+	req := volCreateReq{
+		Name:    volume.Name,
+		Options: volumeOptions(volume.GlusterVolumeOptions),
+	}
+	switch volume.Type {
+	case executors.DurabilityNone:
+		logger.Info("Creating volume %v with no durability", volume.Name)
+		req.Subvols = []subvolReq{{Type: "distribute", Bricks: bricks}}
+	case executors.DurabilityReplica:
+		logger.Info("Creating volume %v replica %v", volume.Name, volume.Replica)
+		for i := 0; i+volume.Replica <= len(bricks); i += volume.Replica {
+			sv := subvolReq{
+				Type:         "replicate",
+				Bricks:       bricks[i : i+volume.Replica],
+				ReplicaCount: volume.Replica,
+			}
+			if volume.Arbiter {
+				sv.ArbiterCount = 1
+			}
+			req.Subvols = append(req.Subvols, sv)
+		}
+	case executors.DurabilityDispersion:
+		logger.Info("Creating volume %v dispersion %v+%v",
+			volume.Name, volume.Data, volume.Redundancy)
+		inSet := volume.Data + volume.Redundancy
+		for i := 0; i+inSet <= len(bricks); i += inSet {
+			req.Subvols = append(req.Subvols, subvolReq{
+				Type:               "disperse",
+				Bricks:             bricks[i : i+inSet],
+				DisperseCount:      inSet,
+				DisperseData:       volume.Data,
+				DisperseRedundancy: volume.Redundancy,
+			})
+		}
+	}
+
+	err = g.do(host, http.MethodPost, apiPrefix+"/volumes", req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.do(host, http.MethodPost, volumePath(volume.Name, "start"), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &executors.Volume{}, nil
+}
+
+func (g *Gd2Executor) VolumeExpand(host string,
+	volume *executors.VolumeRequest) (*executors.Volume, error) {
+
+	godbc.Require(volume != nil)
+	godbc.Require(host != "")
+	godbc.Require(len(volume.Bricks) > 0)
+	godbc.Require(volume.Name != "")
+
+	bricks, err := g.brickReqs(host, volume.Bricks)
+	if err != nil {
+		return nil, err
+	}
+	req := volExpandReq{Bricks: bricks}
+	if volume.Type == executors.DurabilityReplica {
+		req.ReplicaCount = volume.Replica
+	}
+
+	err = g.do(host, http.MethodPost, volumePath(volume.Name, "expand"), req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.RebalanceOnExpansion() {
+		err = g.do(host, http.MethodPost,
+			volumePath(volume.Name, "rebalance", "start"), nil, nil)
+		if err != nil {
+			// As with the gluster cli, a failed rebalance does not
+			// undo the expansion. It can be started again manually.
+			logger.LogError("Unable to start rebalance on the volume %v: %v", volume.Name, err)
+			logger.LogError("Action Required: run rebalance manually on the volume %v", volume.Name)
+		}
+	}
+
+	return &executors.Volume{}, nil
+}
+
+func (g *Gd2Executor) VolumeDestroy(host string, volume string) error {
+	godbc.Require(host != "")
+	godbc.Require(volume != "")
+
+	// First stop the volume, then delete it
+	err := g.do(host, http.MethodPost, volumePath(volume, "stop"), nil, nil)
+	if err != nil {
+		logger.LogError("Unable to stop volume %v: %v", volume, err)
+	}
+
+	err = g.do(host, http.MethodDelete, volumePath(volume), nil, nil)
+	if err != nil {
+		return logger.Err(fmt.Errorf("Unable to delete volume %v: %v", volume, err))
+	}
+
+	return nil
+}
+
+func (g *Gd2Executor) VolumeDestroyCheck(host, volume string) error {
+	godbc.Require(host != "")
+	godbc.Require(volume != "")
+
+	_, err := g.volumeInfo(host, volume)
+	if isNotFound(err) {
+		return &executors.VolumeDoesNotExistErr{Name: volume}
+	} else if err != nil {
+		return err
+	}
+
+	var snaps []struct {
+		ParentName string        `json:"parentname"`
+		Snaps      []interface{} `json:"snaps"`
+	}
+	err = g.do(host, http.MethodGet,
+		apiPrefix+"/snapshots?volume="+url.QueryEscape(volume), nil, &snaps)
+	if err != nil {
+		return fmt.Errorf("Unable to get snapshot information from volume %v: %v", volume, err)
+	}
+	count := 0
+	for _, s := range snaps {
+		if s.ParentName == volume {
+			count += len(s.Snaps)
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("Unable to delete volume %v because it contains %v snapshots",
+			volume, count)
+	}
+
+	return nil
+}
+
+func (g *Gd2Executor) volumeInfo(host, volume string) (*volumeInfo, error) {
+	var info volumeInfo
+	err := g.do(host, http.MethodGet, volumePath(volume), nil, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (g *Gd2Executor) VolumeInfo(host string, volume string) (*executors.Volume, error) {
+	godbc.Require(volume != "")
+	godbc.Require(host != "")
+
+	info, err := g.volumeInfo(host, volume)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get volume info of volume name %v: %v", volume, err)
+	}
+	return info.toVolume(), nil
+}
+
+func (g *Gd2Executor) VolumesInfo(host string) (*executors.VolInfo, error) {
+	godbc.Require(host != "")
+
+	var infos []volumeInfo
+	err := g.do(host, http.MethodGet, apiPrefix+"/volumes", nil, &infos)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get volume info: %v", err)
+	}
+	vi := &executors.VolInfo{}
+	for i := range infos {
+		vi.Volumes.VolumeList = append(vi.Volumes.VolumeList, *infos[i].toVolume())
+	}
+	vi.Volumes.Count = len(vi.Volumes.VolumeList)
+	return vi, nil
+}
+
+func (g *Gd2Executor) VolumeReplaceBrick(host string, volume string,
+	oldBrick *executors.BrickInfo, newBrick *executors.BrickInfo) error {
+
+	godbc.Require(volume != "")
+	godbc.Require(host != "")
+	godbc.Require(oldBrick != nil)
+	godbc.Require(newBrick != nil)
+
+	ids, err := g.peerIds(host, oldBrick.Host, newBrick.Host)
+	if err != nil {
+		return err
+	}
+	req := replaceBrickReq{
+		SrcPeerID:    ids[oldBrick.Host],
+		SrcBrickPath: oldBrick.Path,
+		NewPeerID:    ids[newBrick.Host],
+		NewBrickPath: newBrick.Path,
+		Force:        true,
+	}
+	err = g.do(host, http.MethodPost, volumePath(volume, "replacebrick"), req, nil)
+	if err != nil {
+		return logger.Err(fmt.Errorf("Unable to replace brick %v:%v with %v:%v for volume %v: %v", oldBrick.Host, oldBrick.Path, newBrick.Host, newBrick.Path, volume, err))
+	}
+
+	return nil
+}
+
+func (g *Gd2Executor) VolumeClone(host string, vcr *executors.VolumeCloneRequest) (*executors.Volume, error) {
+	godbc.Require(host != "")
+	godbc.Require(vcr != nil)
+
+	vsr := executors.VolumeSnapshotRequest{
+		Volume:   vcr.Volume,
+		Snapshot: "tmpsnap_" + idgen.GenUUID(),
+	}
+
+	snap, err := g.VolumeSnapshot(host, &vsr)
+	if err != nil {
+		return nil, err
+	}
+
+	// we do not want activated snapshots sticking around
+	defer g.SnapshotDestroy(host, snap.Name)
+
+	scr := executors.SnapshotCloneRequest{
+		Snapshot: snap.Name,
+		Volume:   vcr.Clone,
+	}
+
+	return g.SnapshotCloneVolume(host, &scr)
+}
+
+func (g *Gd2Executor) HealInfo(host string, volume string) (*executors.HealInfo, error) {
+	godbc.Require(volume != "")
+	godbc.Require(host != "")
+
+	var bricks []brickHealInfo
+	err := g.do(host, http.MethodGet, volumePath(volume, "heal-info"), nil, &bricks)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get heal info of volume %v: %v", volume, err)
+	}
+
+	hi := &executors.HealInfo{}
+	for _, b := range bricks {
+		entries := "-"
+		if b.Entries != nil {
+			entries = fmt.Sprintf("%v", *b.Entries)
+		}
+		hi.Bricks.BrickList = append(hi.Bricks.BrickList,
+			executors.BrickHealStatus{
+				HostUUID:        b.HostID,
+				Name:            b.Name,
+				Status:          b.Status,
+				NumberOfEntries: entries,
+			})
+	}
+	return hi, nil
+}
+
+// VolumeModify is used to alter the configuration of an existing volume.
+func (g *Gd2Executor) VolumeModify(host string, mod *executors.VolumeModifyRequest) error {
+	if mod.Stopped {
+		err := g.do(host, http.MethodPost, volumePath(mod.Name, "stop"), nil, nil)
+		if err != nil {
+			return err
+		}
+	}
+	options := volumeOptions(mod.GlusterVolumeOptions)
+	if len(options) > 0 {
+		err := g.do(host, http.MethodPost, volumePath(mod.Name, "options"),
+			volOptionReq{Options: options}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	if mod.Stopped {
+		return g.do(host, http.MethodPost, volumePath(mod.Name, "start"), nil, nil)
+	}
+	return nil
+}
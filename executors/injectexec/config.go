@@ -14,4 +14,10 @@ type InjectConfig struct {
 		CmdHooks    CmdHooks    `json:"commands"`
 		ResultHooks ResultHooks `json:"results"`
 	} `json:"command_injection"`
+
+	// Scenario is the scenario active when the server starts
+	Scenario ScenarioState `json:"scenario"`
+	// RandomSeed seeds the chances of hooks with a probability,
+	// making runs repeatable. Zero seeds from the clock.
+	RandomSeed int64 `json:"random_seed"`
 }
@@ -79,6 +79,29 @@ type CmdHook struct {
 	Cmd      string
 	CondFile string
 	Reaction Reaction
+
+	// Host is a regex the host the command runs on must match
+	Host string
+
+	// The following opts control which of the matching calls
+	// the hook fires on. They are counted by the Injector.
+
+	// Nth makes the hook fire from the Nth matching call on.
+	// Unless Count is set it fires on the Nth call only.
+	Nth uint64
+	// Count limits how many times the hook fires
+	Count uint64
+	// Probability, if between 0 and 1, is the chance the hook
+	// fires on a matching call
+	Probability float64
+
+	// Scenario and Step make the hook match only while the named
+	// scenario, and step of it if given, is active
+	Scenario string
+	Step     string
+	// NextStep is the step of the scenario to activate once the
+	// hook has fired
+	NextStep string
 }
 
 // Match returns true if the provided command matches the regex of
@@ -88,6 +111,28 @@ func (c *CmdHook) Match(command string) bool {
 	return (e == nil && m)
 }
 
+// MatchHost returns true if the host matches the regex of the hook
+// or the hook is not limited to any hosts.
+func (c *CmdHook) MatchHost(host string) bool {
+	if c.Host == "" {
+		return true
+	}
+	m, e := regexp.MatchString(c.Host, host)
+	if e != nil {
+		logger.Warning("regexp error: %v", e)
+	}
+	return (e == nil && m)
+}
+
+// MatchScenario returns true if the hook is not limited to a scenario
+// or its scenario, and step, is active.
+func (c *CmdHook) MatchScenario(s ScenarioState) bool {
+	if c.Scenario == "" {
+		return true
+	}
+	return c.Scenario == s.Scenario && (c.Step == "" || c.Step == s.Step)
+}
+
 // CheckConditions returns true if all of the conditions on
 // the hook are true (currenly only the CondFile condition).
 func (c *CmdHook) CheckConditions() bool {
@@ -104,6 +149,9 @@ func (c *CmdHook) CheckConditions() bool {
 
 // String returns a string representation of the hook.
 func (c *CmdHook) String() string {
+	if c.Host != "" {
+		return fmt.Sprintf("CmdHook(%v on %v)", c.Cmd, c.Host)
+	}
 	return fmt.Sprintf("CmdHook(%v)", c.Cmd)
 }
 
@@ -131,6 +179,9 @@ func (r *ResultHook) Match(command, result string) bool {
 
 // String returns a string representation of the hook.
 func (r *ResultHook) String() string {
+	if r.Host != "" {
+		return fmt.Sprintf("ResultHook(%v on %v)", r.Cmd, r.Host)
+	}
 	return fmt.Sprintf("ResultHook(%v)", r.Cmd)
 }
 
@@ -138,49 +189,15 @@ type CmdHooks []CmdHook
 
 type ResultHooks []ResultHook
 
-// HookCommands checks a list of command hooks against a given command
-// string. For the first matching hook the hook's reaction is returned.
-func HookCommands(hooks CmdHooks, c string) rex.Result {
-
-	logger.Info("Checking for hook on %v", c)
-	for _, h := range hooks {
-		if h.Match(c) && h.CheckConditions() {
-			logger.Debug("found hook for %v: %v", c, h)
-			return logHookResult(c, h.Reaction.React())
-		}
-	}
-	return rex.Result{}
-}
-
-// HookResults checks a list of result hooks against a given command
-// and its result or error (as a string). For the first matching hook
-// the hook's reaction is returned.
-func HookResults(hooks ResultHooks, c string, result rex.Result) rex.Result {
-
-	compare := result.Output
-	if !result.Ok() {
-		compare = result.Err.Error()
-	}
-
-	for _, h := range hooks {
-		logger.Info("Checking for hook on %v -> %v", c, compare)
-		if h.Match(c, compare) && h.CheckConditions() {
-			logger.Debug("found hook for %v/%v: %v", c, compare, h)
-			return logHookResult(c, h.Reaction.React())
-		}
-	}
-	return result
-}
-
-func logHookResult(c string, r rex.Result) rex.Result {
+func logHookResult(host, c string, r rex.Result) rex.Result {
 	if r.Ok() {
 		logger.Debug(
-			"Hook command [%v] on [internal]: Stdout [%v]: Stderr [%v]",
-			c, r.Output, r.ErrOutput)
+			"Hook command [%v] on [%v]: Stdout [%v]: Stderr [%v]",
+			c, host, r.Output, r.ErrOutput)
 	} else {
 		logger.LogError(
-			"Hook command [%v] on [internal]: Err[%v]: Stdout [%v]: Stderr [%v]",
-			c, r.Err, r.Output, r.ErrOutput)
+			"Hook command [%v] on [%v]: Err[%v]: Stdout [%v]: Stderr [%v]",
+			c, host, r.Err, r.Output, r.ErrOutput)
 	}
 	return r
 }
@@ -10,8 +10,11 @@
 package injectexec
 
 import (
+	"context"
+
 	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/executors/cmdexec"
+	"github.com/heketi/heketi/executors/localexec"
 	"github.com/heketi/heketi/executors/mockexec"
 	"github.com/heketi/heketi/executors/sshexec"
 	"github.com/heketi/heketi/executors/stack"
@@ -30,8 +33,9 @@ type InjectExecutor struct {
 	realTransport cmdexec.RemoteCommandTransport
 
 	// hooks
-	Pre    *mockexec.MockExecutor
-	config *InjectConfig
+	Pre      *mockexec.MockExecutor
+	config   *InjectConfig
+	injector *Injector
 }
 
 func NewInjectExecutor(
@@ -39,6 +43,7 @@ func NewInjectExecutor(
 
 	ie := &InjectExecutor{}
 	ie.config = config
+	ie.injector = NewInjector(config)
 	ie.Pre = newMockBase()
 	ie.realExecutor = e
 
@@ -53,6 +58,10 @@ func NewInjectExecutor(
 		logger.Info("injecting executor with transport")
 		ie.realTransport = x.RemoteExecutor
 		x.RemoteExecutor = ie.Wrap(x.RemoteExecutor)
+	case *localexec.LocalExecutor:
+		logger.Info("injecting executor with transport")
+		ie.realTransport = x.RemoteExecutor
+		x.RemoteExecutor = ie.Wrap(x.RemoteExecutor)
 	case *cmdexec.CmdExecutor:
 		logger.Info("injecting executor with transport")
 		ie.realTransport = x.RemoteExecutor
@@ -70,13 +79,137 @@ func NewInjectExecutor(
 func (ie *InjectExecutor) Wrap(t cmdexec.RemoteCommandTransport) cmdexec.RemoteCommandTransport {
 	return &WrapCommandTransport{
 		Transport: t,
-		handleBefore: func(c string) rex.Result {
+		handleBefore: func(host, c string) rex.Result {
 			logger.Info("injectexec wrapped command: %v", c)
-			return HookCommands(ie.config.CmdInjection.CmdHooks, c)
+			return ie.injector.HookCommand(host, c)
 		},
-		handleAfter: func(c string, r rex.Result) rex.Result {
+		handleAfter: func(host, c string, r rex.Result) rex.Result {
 			logger.Info("injectexec wrapped command (result): %v", c)
-			return HookResults(ie.config.CmdInjection.ResultHooks, c, r)
+			return ie.injector.HookResult(host, c, r)
 		},
 	}
 }
+
+// WithContext returns an inject executor with the same hooks whose
+// real executor runs within ctx, if it supports contexts.
+func (ie *InjectExecutor) WithContext(ctx context.Context) executors.Executor {
+	return ie.derive(executors.WithContext(ie.realExecutor, ctx))
+}
+
+// ForOperation returns an inject executor with the same hooks whose
+// real executor marks its commands with the operation id, if it
+// supports it.
+func (ie *InjectExecutor) ForOperation(id string) executors.Executor {
+	return ie.derive(executors.ForOperation(ie.realExecutor, id))
+}
+
+// derive returns an inject executor with the same hooks over the
+// given real executor.
+func (ie *InjectExecutor) derive(real executors.Executor) *InjectExecutor {
+	d := &InjectExecutor{
+		realExecutor:  real,
+		realTransport: ie.realTransport,
+		Pre:           ie.Pre,
+		config:        ie.config,
+		injector:      ie.injector,
+	}
+	d.SetExec([]executors.Executor{d.Pre, d.realExecutor})
+	return d
+}
+
+// Injector returns the injector that decides which hooks fire.
+func (ie *InjectExecutor) Injector() *Injector {
+	return ie.injector
+}
+
+// ActiveCommands returns the active commands tracker of the real
+// executor, if it has one.
+func (ie *InjectExecutor) ActiveCommands() *rex.ActiveCommands {
+	if r, ok := ie.realExecutor.(executors.ActiveCommandsReporter); ok {
+		return r.ActiveCommands()
+	}
+	return nil
+}
+
+// SetCommandTrail sets the command trail of the real executor,
+// if it records commands.
+func (ie *InjectExecutor) SetCommandTrail(t *rex.CommandTrail) {
+	if s, ok := ie.realExecutor.(executors.CommandTrailSetter); ok {
+		s.SetCommandTrail(t)
+	}
+}
+
+// ConnectionPoolStats returns the connection pool statistics of the
+// real executor, if it pools connections.
+func (ie *InjectExecutor) ConnectionPoolStats() rex.PoolStats {
+	if r, ok := ie.realExecutor.(executors.ConnectionPoolReporter); ok {
+		return r.ConnectionPoolStats()
+	}
+	return rex.PoolStats{}
+}
+
+// Close closes the real executor, if it holds resources.
+func (ie *InjectExecutor) Close() error {
+	if c, ok := ie.realExecutor.(executors.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// CircuitBreakerStats returns the circuit breaker statistics of
+// the real executor, if it breaks circuits.
+func (ie *InjectExecutor) CircuitBreakerStats() rex.BreakerStats {
+	if r, ok := ie.realExecutor.(executors.CircuitBreakerReporter); ok {
+		return r.CircuitBreakerStats()
+	}
+	return rex.BreakerStats{}
+}
+
+// HostCircuit returns the state of the circuit to a node in the
+// real executor, if it breaks circuits.
+func (ie *InjectExecutor) HostCircuit(host string) rex.CircuitState {
+	if r, ok := ie.realExecutor.(executors.CircuitBreakerReporter); ok {
+		return r.HostCircuit(host)
+	}
+	return rex.CircuitClosed
+}
+
+// ScanHostKey returns the host key of a node from the real executor,
+// if it identifies nodes by host key.
+func (ie *InjectExecutor) ScanHostKey(host string) (string, error) {
+	if p, ok := ie.realExecutor.(executors.HostKeyPinner); ok {
+		return p.ScanHostKey(host)
+	}
+	return "", nil
+}
+
+// PinHostKey pins the host key of a node in the real executor,
+// if it identifies nodes by host key.
+func (ie *InjectExecutor) PinHostKey(host, key string) error {
+	if p, ok := ie.realExecutor.(executors.HostKeyPinner); ok {
+		return p.PinHostKey(host, key)
+	}
+	return nil
+}
+
+// SetHostConnection sets the connection settings of a node in the
+// real executor, if it supports per node settings.
+func (ie *InjectExecutor) SetHostConnection(
+	host string, hc *executors.HostConnection) error {
+
+	if s, ok := ie.realExecutor.(executors.HostConnectionSetter); ok {
+		return s.SetHostConnection(host, hc)
+	}
+	return nil
+}
+
+// NodeCapabilities probes a node through the real executor, if it
+// can discover the capabilities of the nodes.
+func (ie *InjectExecutor) NodeCapabilities(
+	host string) (*executors.NodeCapabilities, error) {
+
+	if p, ok := ie.realExecutor.(executors.CapabilityProber); ok {
+		return p.NodeCapabilities(host)
+	}
+	return nil, executors.NotSupportedError
+}
@@ -0,0 +1,99 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package injectexec
+
+import (
+	"testing"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/mockexec"
+	"github.com/heketi/tests"
+)
+
+// taggingExecutor remembers the operation it was derived for.
+type taggingExecutor struct {
+	*mockexec.MockExecutor
+	operation string
+}
+
+func (te *taggingExecutor) ForOperation(id string) executors.Executor {
+	return &taggingExecutor{te.MockExecutor, id}
+}
+
+func TestInjectExecutorForOperation(t *testing.T) {
+	m, err := mockexec.NewMockExecutor()
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	ie := NewInjectExecutor(&taggingExecutor{MockExecutor: m}, &InjectConfig{})
+
+	e := executors.ForOperation(ie, "op1")
+	oe, ok := e.(*InjectExecutor)
+	tests.Assert(t, ok, "expected an inject executor, got", e)
+	tagged, ok := oe.realExecutor.(*taggingExecutor)
+	tests.Assert(t, ok, "expected the tagging executor, got", oe.realExecutor)
+	tests.Assert(t, tagged.operation == "op1",
+		`expected "op1", got`, tagged.operation)
+	// the hooks are kept
+	tests.Assert(t, oe.Pre == ie.Pre, "expected the same pre-executor")
+	tests.Assert(t, oe.Injector() == ie.Injector(), "expected the same injector")
+	// the original executor is left untouched
+	tests.Assert(t, ie.realExecutor.(*taggingExecutor).operation == "",
+		"expected no operation on the original executor")
+}
+
+// hostsExecutor records the host keys and connection settings set
+// through it.
+type hostsExecutor struct {
+	*mockexec.MockExecutor
+	keys  map[string]string
+	conns map[string]*executors.HostConnection
+}
+
+func (he *hostsExecutor) ScanHostKey(host string) (string, error) {
+	return "key-" + host, nil
+}
+
+func (he *hostsExecutor) PinHostKey(host, key string) error {
+	he.keys[host] = key
+	return nil
+}
+
+func (he *hostsExecutor) SetHostConnection(
+	host string, hc *executors.HostConnection) error {
+
+	he.conns[host] = hc
+	return nil
+}
+
+func TestInjectExecutorForwardsHosts(t *testing.T) {
+	m, err := mockexec.NewMockExecutor()
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	he := &hostsExecutor{
+		MockExecutor: m,
+		keys:         map[string]string{},
+		conns:        map[string]*executors.HostConnection{},
+	}
+	var e executors.Executor = NewInjectExecutor(he, &InjectConfig{})
+
+	pinner, ok := e.(executors.HostKeyPinner)
+	tests.Assert(t, ok, "expected a host key pinner")
+	key, err := pinner.ScanHostKey("h1")
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, key == "key-h1", "unexpected key", key)
+	err = pinner.PinHostKey("h1", key)
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, he.keys["h1"] == "key-h1", "expected the key to be pinned")
+
+	setter, ok := e.(executors.HostConnectionSetter)
+	tests.Assert(t, ok, "expected a host connection setter")
+	hc := &executors.HostConnection{User: "admin"}
+	err = setter.SetHostConnection("h1", hc)
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, he.conns["h1"] == hc, "expected the settings to be set")
+}
@@ -0,0 +1,185 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package injectexec
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+// ScenarioState names the active fault scenario and the active step
+// of it. Hooks can be limited to a scenario or a step.
+type ScenarioState struct {
+	Scenario string `json:"scenario"`
+	Step     string `json:"step"`
+}
+
+// HookCounter counts the calls a hook matched and fired on.
+type HookCounter struct {
+	Hook    string `json:"hook"`
+	Matches uint64 `json:"matches"`
+	Fires   uint64 `json:"fires"`
+}
+
+// InjectorState reports the active scenario and the counters of
+// all the hooks, in the order they are configured.
+type InjectorState struct {
+	ScenarioState
+	Commands []HookCounter `json:"commands"`
+	Results  []HookCounter `json:"results"`
+}
+
+// Injector checks the commands passing through the inject executor
+// against the configured hooks. It keeps the counters and the
+// scenario state that decide which of the matching calls fire.
+type Injector struct {
+	lock     sync.Mutex
+	config   *InjectConfig
+	scenario ScenarioState
+	counters map[*CmdHook]*HookCounter
+	rand     *rand.Rand
+}
+
+func NewInjector(config *InjectConfig) *Injector {
+	seed := config.RandomSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{
+		config:   config,
+		scenario: config.Scenario,
+		counters: map[*CmdHook]*HookCounter{},
+		rand:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// HookCommand checks the command hooks against a command about to
+// run on host. For the first hook that fires the hook's reaction is
+// returned. If no hook fires the result is empty (not completed).
+func (inj *Injector) HookCommand(host, c string) rex.Result {
+	logger.Info("Checking for hook on %v", c)
+	hooks := inj.config.CmdInjection.CmdHooks
+	for i := range hooks {
+		h := &hooks[i]
+		if h.Match(c) && h.MatchHost(host) && h.CheckConditions() &&
+			inj.fire(h, h.String()) {
+
+			logger.Debug("found hook for %v: %v", c, h)
+			return logHookResult(host, c, h.Reaction.React())
+		}
+	}
+	return rex.Result{}
+}
+
+// HookResult checks the result hooks against a command that ran on
+// host and its result or error (as a string). For the first hook that
+// fires the hook's reaction is returned, otherwise the result itself.
+func (inj *Injector) HookResult(host, c string, result rex.Result) rex.Result {
+	compare := result.Output
+	if !result.Ok() {
+		compare = result.Err.Error()
+	}
+
+	hooks := inj.config.CmdInjection.ResultHooks
+	for i := range hooks {
+		h := &hooks[i]
+		logger.Info("Checking for hook on %v -> %v", c, compare)
+		if h.Match(c, compare) && h.MatchHost(host) &&
+			h.CheckConditions() && inj.fire(&h.CmdHook, h.String()) {
+
+			logger.Debug("found hook for %v/%v: %v", c, compare, h)
+			return logHookResult(host, c, h.Reaction.React())
+		}
+	}
+	return result
+}
+
+// fire counts a call matching the hook and returns true if the hook
+// fires on it.
+func (inj *Injector) fire(h *CmdHook, name string) bool {
+	inj.lock.Lock()
+	defer inj.lock.Unlock()
+
+	if !h.MatchScenario(inj.scenario) {
+		return false
+	}
+	hc := inj.counter(h, name)
+	hc.Matches++
+
+	first, limit := h.Nth, h.Count
+	if first == 0 {
+		first = 1
+	} else if limit == 0 {
+		limit = 1
+	}
+	if hc.Matches < first || (limit != 0 && hc.Fires >= limit) {
+		return false
+	}
+	if h.Probability > 0 && h.Probability < 1 &&
+		inj.rand.Float64() >= h.Probability {
+		return false
+	}
+
+	hc.Fires++
+	if h.NextStep != "" {
+		logger.Info("hook %v moves scenario %v to step %v",
+			h, inj.scenario.Scenario, h.NextStep)
+		inj.scenario.Step = h.NextStep
+	}
+	return true
+}
+
+func (inj *Injector) counter(h *CmdHook, name string) *HookCounter {
+	hc, ok := inj.counters[h]
+	if !ok {
+		hc = &HookCounter{Hook: name}
+		inj.counters[h] = hc
+	}
+	return hc
+}
+
+// SetScenario activates a scenario and step. If reset is true the
+// counters of all the hooks start over.
+func (inj *Injector) SetScenario(s ScenarioState, reset bool) {
+	inj.lock.Lock()
+	defer inj.lock.Unlock()
+
+	logger.Info("activating scenario %v step %v", s.Scenario, s.Step)
+	inj.scenario = s
+	if reset {
+		inj.counters = map[*CmdHook]*HookCounter{}
+	}
+}
+
+// State returns the active scenario and the counters of the hooks.
+func (inj *Injector) State() InjectorState {
+	inj.lock.Lock()
+	defer inj.lock.Unlock()
+
+	st := InjectorState{
+		ScenarioState: inj.scenario,
+		Commands:      []HookCounter{},
+		Results:       []HookCounter{},
+	}
+	hooks := inj.config.CmdInjection.CmdHooks
+	for i := range hooks {
+		h := &hooks[i]
+		st.Commands = append(st.Commands, *inj.counter(h, h.String()))
+	}
+	rhooks := inj.config.CmdInjection.ResultHooks
+	for i := range rhooks {
+		h := &rhooks[i]
+		st.Results = append(st.Results, *inj.counter(&h.CmdHook, h.String()))
+	}
+	return st
+}
@@ -0,0 +1,120 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package injectexec
+
+import (
+	"testing"
+
+	"github.com/heketi/tests"
+)
+
+// fires calls fire n times and returns the calls it fired on,
+// counting from 1.
+func fires(inj *Injector, h *CmdHook, n int) []int {
+	fired := []int{}
+	for i := 1; i <= n; i++ {
+		if inj.fire(h, "hook") {
+			fired = append(fired, i)
+		}
+	}
+	return fired
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestInjectorFireNthCount(t *testing.T) {
+	for _, c := range []struct {
+		nth, count uint64
+		expected   []int
+	}{
+		// every call
+		{0, 0, []int{1, 2, 3, 4, 5, 6}},
+		// the first calls
+		{0, 2, []int{1, 2}},
+		// the Nth call only
+		{3, 0, []int{3}},
+		// from the Nth call on, Count times
+		{2, 3, []int{2, 3, 4}},
+		// never reached
+		{7, 0, []int{}},
+	} {
+		inj := NewInjector(&InjectConfig{})
+		h := &CmdHook{Cmd: ".*", Nth: c.nth, Count: c.count}
+		fired := fires(inj, h, 6)
+		tests.Assert(t, equalInts(fired, c.expected),
+			"nth", c.nth, "count", c.count,
+			"expected", c.expected, "got", fired)
+
+		hc := inj.counters[h]
+		tests.Assert(t, hc.Matches == 6, "expected 6 matches, got", hc.Matches)
+		tests.Assert(t, hc.Fires == uint64(len(c.expected)),
+			"expected", len(c.expected), "fires, got", hc.Fires)
+	}
+}
+
+func TestInjectorFireProbability(t *testing.T) {
+	config := &InjectConfig{RandomSeed: 42}
+	h := &CmdHook{Cmd: ".*", Probability: 0.5}
+
+	fired := fires(NewInjector(config), h, 1000)
+	tests.Assert(t, len(fired) > 400 && len(fired) < 600,
+		"expected about half of the calls to fire, got", len(fired))
+	// the same seed fires on the same calls
+	again := fires(NewInjector(config), h, 1000)
+	tests.Assert(t, equalInts(fired, again),
+		"expected the same calls to fire with the same seed")
+
+	// probabilities outside of (0, 1) do not limit the hook
+	for _, p := range []float64{0, 1} {
+		h := &CmdHook{Cmd: ".*", Probability: p}
+		fired := fires(NewInjector(config), h, 10)
+		tests.Assert(t, len(fired) == 10,
+			"probability", p, "expected 10 fires, got", len(fired))
+	}
+
+	// the chance applies to the calls Nth and Count let through
+	h = &CmdHook{Cmd: ".*", Nth: 5, Count: 1000, Probability: 0.5}
+	fired = fires(NewInjector(config), h, 1000)
+	tests.Assert(t, len(fired) > 0 && fired[0] >= 5,
+		"expected no fires before the 5th call, got", fired)
+}
+
+func TestInjectorFireScenario(t *testing.T) {
+	config := &InjectConfig{}
+	config.Scenario = ScenarioState{Scenario: "s1", Step: "a"}
+	inj := NewInjector(config)
+
+	other := &CmdHook{Cmd: ".*", Scenario: "s2"}
+	tests.Assert(t, !inj.fire(other, "other"),
+		"expected hook of another scenario not to fire")
+	tests.Assert(t, inj.counters[other] == nil,
+		"expected no matches counted for another scenario")
+
+	h := &CmdHook{Cmd: ".*", Scenario: "s1", Step: "a", NextStep: "b"}
+	tests.Assert(t, inj.fire(h, "hook"), "expected hook to fire")
+	tests.Assert(t, inj.State().Step == "b",
+		"expected step b, got", inj.State().Step)
+	tests.Assert(t, !inj.fire(h, "hook"),
+		"expected hook of step a not to fire in step b")
+
+	inj.SetScenario(ScenarioState{Scenario: "s1", Step: "a"}, true)
+	tests.Assert(t, inj.counters[h] == nil, "expected counters to be reset")
+	tests.Assert(t, inj.fire(h, "hook"), "expected hook to fire again")
+}
@@ -10,8 +10,10 @@
 package injectexec
 
 import (
+	"context"
 	"github.com/heketi/heketi/executors/cmdexec"
 	rex "github.com/heketi/heketi/pkg/remoteexec"
+	"time"
 )
 
 // WrapCommandTransport can be used to replace a real transport
@@ -19,27 +21,27 @@ import (
 // must be set and the optional handleBefore and handleAfter functions
 // can be used to artificially manipulate the command results.
 type WrapCommandTransport struct {
-	handleBefore func(string) rex.Result
-	handleAfter  func(string, rex.Result) rex.Result
+	handleBefore func(host, command string) rex.Result
+	handleAfter  func(host, command string, r rex.Result) rex.Result
 	Transport    cmdexec.RemoteCommandTransport
 }
 
-func (w *WrapCommandTransport) ExecCommands(
-	host string, commands rex.Cmds, timeoutMinutes int) (rex.Results, error) {
+func (w *WrapCommandTransport) ExecCommands(ctx context.Context,
+	host string, commands rex.Cmds, timeout time.Duration) (rex.Results, error) {
 
 	results := make(rex.Results, len(commands))
 	for i, c := range commands {
-		r := w.Before(c)
+		r := w.Before(host, c)
 		if r.Completed {
 			results[i] = r
 			continue
 		}
 		tres, err := w.Transport.ExecCommands(
-			host, rex.Cmds{c}, timeoutMinutes)
+			ctx, host, rex.Cmds{c}, timeout)
 		if err != nil {
 			return results, err
 		}
-		results[i] = w.After(c, tres)
+		results[i] = w.After(host, c, tres)
 	}
 	return results, nil
 }
@@ -81,10 +83,10 @@ func (w *WrapCommandTransport) XfsSu() int {
 // processing of the command is needed the first return value will
 // be true. The remaining return values are the command's results
 // or error.
-func (w *WrapCommandTransport) Before(command rex.Cmd) rex.Result {
+func (w *WrapCommandTransport) Before(host string, command rex.Cmd) rex.Result {
 
 	if w.handleBefore != nil {
-		return w.handleBefore(command.String())
+		return w.handleBefore(host, command.String())
 	}
 	return rex.Result{}
 }
@@ -93,11 +95,11 @@ func (w *WrapCommandTransport) Before(command rex.Cmd) rex.Result {
 // if one is set. The handleAfter function may or may not alter
 // the results of the input results or error condition.
 func (w *WrapCommandTransport) After(
-	command rex.Cmd, results rex.Results) rex.Result {
+	host string, command rex.Cmd, results rex.Results) rex.Result {
 
 	r := results[0]
 	if w.handleAfter != nil {
-		return w.handleAfter(command.String(), r)
+		return w.handleAfter(host, command.String(), r)
 	}
 	return r
 }
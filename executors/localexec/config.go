@@ -0,0 +1,23 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package localexec
+
+import (
+	"github.com/heketi/heketi/executors/cmdexec"
+)
+
+type LocalConfig struct {
+	cmdexec.CmdConfig
+
+	// Hosts lists additional names or addresses that refer to the
+	// local system. The loopback names, the system's hostname, and
+	// the addresses of the local interfaces are always accepted.
+	Hosts []string `json:"hosts"`
+}
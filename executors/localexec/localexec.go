@@ -0,0 +1,161 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package localexec
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors/cmdexec"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+	"github.com/heketi/heketi/pkg/remoteexec/local"
+)
+
+// LocalExecutor runs the gluster and lvm commands directly on the
+// system heketi is running on. It is meant for single node
+// deployments where heketi runs next to glusterd.
+type LocalExecutor struct {
+	cmdexec.CmdExecutor
+
+	exec   *local.LocalExec
+	config *LocalConfig
+	hosts  map[string]bool
+}
+
+var (
+	// for testing
+	osHostname        = os.Hostname
+	netInterfaceAddrs = net.InterfaceAddrs
+)
+
+func setWithEnvVariables(config *LocalConfig) {
+	var env string
+
+	env = os.Getenv("HEKETI_FSTAB")
+	if "" != env {
+		config.Fstab = env
+	}
+
+	env = os.Getenv("HEKETI_SNAPSHOT_LIMIT")
+	if "" != env {
+		i, err := strconv.Atoi(env)
+		if err == nil {
+			config.SnapShotLimit = i
+		}
+	}
+
+	env = os.Getenv("HEKETI_LOCAL_HOSTS")
+	if "" != env {
+		config.Hosts = strings.Split(env, ",")
+	}
+}
+
+func NewLocalExecutor(config *LocalConfig) (*LocalExecutor, error) {
+	// Override configuration
+	setWithEnvVariables(config)
+
+	l := &LocalExecutor{}
+	l.CmdExecutor.Init(&config.CmdConfig)
+	l.RemoteExecutor = l
+
+	if config.Fstab == "" {
+		l.Fstab = "/etc/fstab"
+	} else {
+		l.Fstab = config.Fstab
+	}
+
+	l.BackupLVM = config.BackupLVM
+
+	// Save the configuration
+	l.config = config
+
+	l.hosts = localHosts(config.Hosts)
+	l.exec = local.NewLocalExec(l.Logger())
+
+	godbc.Ensure(l != nil)
+	godbc.Ensure(l.config == config)
+	godbc.Ensure(l.Fstab != "")
+
+	return l, nil
+}
+
+// localHosts returns the set of names and addresses that
+// refer to the local system.
+func localHosts(extra []string) map[string]bool {
+	hosts := map[string]bool{
+		"localhost": true,
+		"127.0.0.1": true,
+		"::1":       true,
+	}
+	if name, err := osHostname(); err == nil {
+		hosts[name] = true
+		// also accept the short name
+		hosts[strings.SplitN(name, ".", 2)[0]] = true
+	}
+	if addrs, err := netInterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				hosts[ipnet.IP.String()] = true
+			}
+		}
+	}
+	for _, h := range extra {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts[h] = true
+		}
+	}
+	return hosts
+}
+
+func (l *LocalExecutor) ExecCommands(ctx context.Context,
+	host string, commands rex.Cmds, timeout time.Duration) (rex.Results, error) {
+
+	if !l.hosts[host] {
+		return nil, fmt.Errorf(
+			"Local executor can not run commands on host %v: "+
+				"host is not the local system", host)
+	}
+
+	// Throttle. All host names refer to the same system.
+	if err := l.AccessConnectionContext(ctx, "localhost"); err != nil {
+		return nil, err
+	}
+	defer l.FreeConnection("localhost")
+
+	// Execute
+	return l.exec.ExecCommands(ctx, host, commands, timeout, l.config.Sudo)
+}
+
+func (l *LocalExecutor) RebalanceOnExpansion() bool {
+	return l.config.RebalanceOnExpansion
+}
+
+func (l *LocalExecutor) SnapShotLimit() int {
+	return l.config.SnapShotLimit
+}
+
+// ActiveCommands returns the tracker for commands currently running
+// on the local system.
+func (l *LocalExecutor) ActiveCommands() *rex.ActiveCommands {
+	return l.exec.ActiveCommands()
+}
+
+// SetCommandTrail sets the trail that records the commands run
+// on the local system.
+func (l *LocalExecutor) SetCommandTrail(t *rex.CommandTrail) {
+	l.exec.SetCommandTrail(t)
+}
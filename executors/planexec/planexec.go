@@ -0,0 +1,170 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package planexec
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/cmdexec"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+// PlannedCommand is a command that would have been run on a node.
+type PlannedCommand struct {
+	Host    string
+	Command string
+}
+
+// canned outputs for the commands whose output is parsed by the
+// command based executor in the middle of a change
+var cannedOutputs = []struct {
+	prefix string
+	output string
+}{
+	{"lvs --noheadings --options=thin_count", "0"},
+	{"gluster-block create",
+		`{"IQN":"iqn.2016-12.org.gluster-block:planned","RESULT":"SUCCESS"}`},
+	{"gluster-block delete", `{"RESULT":"SUCCESS"}`},
+}
+
+// PlanExecutor records the commands the command based executors
+// would run to make a change, without running them. Queries that do
+// not change the nodes are answered by the real executor so that the
+// plan matches the current state of the nodes.
+type PlanExecutor struct {
+	cmdexec.CmdExecutor
+
+	real   executors.Executor
+	config *cmdexec.CmdConfig
+
+	lock     sync.Mutex
+	commands []PlannedCommand
+}
+
+// NewPlanExecutor returns a PlanExecutor that renders commands
+// using the given configuration and passes queries to real.
+func NewPlanExecutor(real executors.Executor, config *cmdexec.CmdConfig) *PlanExecutor {
+	p := &PlanExecutor{
+		real:   real,
+		config: config,
+	}
+	p.CmdExecutor.Init(config)
+	p.RemoteExecutor = p
+
+	if config.Fstab == "" {
+		p.Fstab = "/etc/fstab"
+	} else {
+		p.Fstab = config.Fstab
+	}
+	p.BackupLVM = config.BackupLVM
+	return p
+}
+
+func (p *PlanExecutor) ExecCommands(ctx context.Context,
+	host string, commands rex.Cmds, timeout time.Duration) (rex.Results, error) {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	results := make(rex.Results, len(commands))
+	for i, c := range commands {
+		cmd := c.String()
+		p.commands = append(p.commands, PlannedCommand{
+			Host:    host,
+			Command: cmd,
+		})
+		results[i].Completed = true
+		for _, canned := range cannedOutputs {
+			if strings.HasPrefix(cmd, canned.prefix) {
+				results[i].Output = canned.output
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+// Commands returns the commands recorded so far, in order.
+func (p *PlanExecutor) Commands() []PlannedCommand {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]PlannedCommand{}, p.commands...)
+}
+
+// ForOperation returns the plan executor itself. Planned commands
+// are never run and so are not marked with an operation.
+func (p *PlanExecutor) ForOperation(id string) executors.Executor {
+	return p
+}
+
+// WithContext returns the plan executor itself. Planned commands
+// are never run and so can not be cancelled.
+func (p *PlanExecutor) WithContext(ctx context.Context) executors.Executor {
+	return p
+}
+
+func (p *PlanExecutor) RebalanceOnExpansion() bool {
+	return p.config.RebalanceOnExpansion
+}
+
+func (p *PlanExecutor) SnapShotLimit() int {
+	return p.config.SnapShotLimit
+}
+
+// The functions below do not change the nodes and are answered
+// by the real executor.
+
+func (p *PlanExecutor) GlusterdCheck(host string) error {
+	return p.real.GlusterdCheck(host)
+}
+
+func (p *PlanExecutor) GetDeviceInfo(host string, dh *executors.DeviceVgHandle) (*executors.DeviceInfo, error) {
+	return p.real.GetDeviceInfo(host, dh)
+}
+
+func (p *PlanExecutor) VolumeDestroyCheck(host, volume string) error {
+	return p.real.VolumeDestroyCheck(host, volume)
+}
+
+func (p *PlanExecutor) VolumeInfo(host string, volume string) (*executors.Volume, error) {
+	return p.real.VolumeInfo(host, volume)
+}
+
+func (p *PlanExecutor) VolumesInfo(host string) (*executors.VolInfo, error) {
+	return p.real.VolumesInfo(host)
+}
+
+func (p *PlanExecutor) HealInfo(host string, volume string) (*executors.HealInfo, error) {
+	return p.real.HealInfo(host, volume)
+}
+
+func (p *PlanExecutor) PVS(host string) (*executors.PVSCommandOutput, error) {
+	return p.real.PVS(host)
+}
+
+func (p *PlanExecutor) VGS(host string) (*executors.VGSCommandOutput, error) {
+	return p.real.VGS(host)
+}
+
+func (p *PlanExecutor) LVS(host string) (*executors.LVSCommandOutput, error) {
+	return p.real.LVS(host)
+}
+
+func (p *PlanExecutor) GetBrickMountStatus(host string) (*executors.BricksMountStatus, error) {
+	return p.real.GetBrickMountStatus(host)
+}
+
+func (p *PlanExecutor) ListBlockVolumes(host string, blockhostingvolume string) ([]string, error) {
+	return p.real.ListBlockVolumes(host, blockhostingvolume)
+}
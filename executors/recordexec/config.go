@@ -0,0 +1,36 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package recordexec
+
+import (
+	"github.com/heketi/heketi/executors/cmdexec"
+)
+
+const (
+	// MatchExact answers each command batch with a recorded batch
+	// with the same host and commands, regardless of order.
+	MatchExact = "exact"
+	// MatchOrdered answers the command batches for each host with
+	// the recorded batches for the host in the order recorded.
+	MatchOrdered = "ordered"
+)
+
+type RecordConfig struct {
+	// command settings used when replaying
+	cmdexec.CmdConfig
+
+	// File the commands are recorded to or replayed from
+	File string `json:"file"`
+	// Match is one of "exact" (the default) or "ordered"
+	Match string `json:"match"`
+	// DeterministicIds makes the server generate the same ids in
+	// every run so that the commands of a replay match the recording
+	DeterministicIds bool `json:"deterministic_ids"`
+}
@@ -0,0 +1,196 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package recordexec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/executors/cmdexec"
+	"github.com/heketi/heketi/executors/localexec"
+	"github.com/heketi/heketi/executors/sshexec"
+	"github.com/heketi/heketi/pkg/logging"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+var (
+	logger = logging.NewLogger("[recordexec]", logging.LEVEL_DEBUG)
+)
+
+// Record is a single call to a command transport and its outcome
+// as stored in a recording file. A recording file is a stream of
+// JSON encoded records.
+type Record struct {
+	Host     string         `json:"host"`
+	Commands []string       `json:"commands"`
+	Results  []RecordResult `json:"results"`
+	Err      string         `json:"error,omitempty"`
+	// ConnectionError is set if Err was a failure to reach the host
+	ConnectionError bool `json:"connection_error,omitempty"`
+}
+
+// RecordResult is the recorded form of a rex.Result.
+type RecordResult struct {
+	Completed  bool   `json:"completed"`
+	Output     string `json:"output,omitempty"`
+	ErrOutput  string `json:"err_output,omitempty"`
+	Err        string `json:"err,omitempty"`
+	ExitStatus int    `json:"exit_status,omitempty"`
+}
+
+func newRecord(
+	host string, commands rex.Cmds, results rex.Results, err error) *Record {
+
+	r := &Record{
+		Host:     host,
+		Commands: make([]string, len(commands)),
+		Results:  make([]RecordResult, len(results)),
+	}
+	for i, c := range commands {
+		r.Commands[i] = c.String()
+	}
+	for i, res := range results {
+		r.Results[i] = RecordResult{
+			Completed:  res.Completed,
+			Output:     res.Output,
+			ErrOutput:  res.ErrOutput,
+			ExitStatus: res.ExitStatus,
+		}
+		if res.Err != nil {
+			r.Results[i].Err = res.Err.Error()
+		}
+	}
+	if err != nil {
+		r.Err = err.Error()
+		r.ConnectionError = rex.IsConnectionError(err)
+	}
+	return r
+}
+
+// Outcome converts the record back to the values returned by
+// a command transport.
+func (r *Record) Outcome() (rex.Results, error) {
+	var results rex.Results
+	if r.Results != nil {
+		results = make(rex.Results, len(r.Results))
+	}
+	for i, res := range r.Results {
+		results[i] = rex.Result{
+			Completed:  res.Completed,
+			Output:     res.Output,
+			ErrOutput:  res.ErrOutput,
+			ExitStatus: res.ExitStatus,
+		}
+		if res.Err != "" {
+			results[i].Err = errors.New(res.Err)
+		}
+	}
+	switch {
+	case r.ConnectionError:
+		return results, &rex.ConnectionError{
+			Host: r.Host, Err: errors.New(r.Err)}
+	case r.Err != "":
+		return results, errors.New(r.Err)
+	}
+	return results, nil
+}
+
+// key identifies the host and commands of the record.
+func (r *Record) key() string {
+	return r.Host + "\n" + strings.Join(r.Commands, "\n")
+}
+
+// ReadRecords reads all records from a recording.
+func ReadRecords(rd io.Reader) ([]*Record, error) {
+	records := []*Record{}
+	dec := json.NewDecoder(rd)
+	for {
+		r := &Record{}
+		err := dec.Decode(r)
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid record %v: %v",
+				len(records)+1, err)
+		}
+		records = append(records, r)
+	}
+}
+
+// RecordingTransport passes commands to a real transport and writes
+// every call and its outcome to a recording.
+type RecordingTransport struct {
+	cmdexec.RemoteCommandTransport
+
+	lock sync.Mutex
+	enc  *json.Encoder
+}
+
+func NewRecordingTransport(
+	t cmdexec.RemoteCommandTransport, w io.Writer) *RecordingTransport {
+
+	return &RecordingTransport{
+		RemoteCommandTransport: t,
+		enc:                    json.NewEncoder(w),
+	}
+}
+
+func (t *RecordingTransport) ExecCommands(ctx context.Context,
+	host string, commands rex.Cmds, timeout time.Duration) (rex.Results, error) {
+
+	results, err := t.RemoteCommandTransport.ExecCommands(
+		ctx, host, commands, timeout)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if werr := t.enc.Encode(newRecord(host, commands, results, err)); werr != nil {
+		logger.LogError("Unable to record commands: %v", werr)
+	}
+	return results, err
+}
+
+// WrapRecorder makes the given command based executor write all of the
+// commands it runs, and their results, to the file in the config.
+// The executor is otherwise unchanged. The returned file must be
+// closed once the executor is no longer used.
+func WrapRecorder(e executors.Executor, config *RecordConfig) (io.Closer, error) {
+	if config.File == "" {
+		return nil, fmt.Errorf("Missing recording file in configuration")
+	}
+
+	var ce *cmdexec.CmdExecutor
+	switch x := e.(type) {
+	case *sshexec.SshExecutor:
+		ce = &x.CmdExecutor
+	case *localexec.LocalExecutor:
+		ce = &x.CmdExecutor
+	case *cmdexec.CmdExecutor:
+		ce = x
+	default:
+		return nil, fmt.Errorf("Can not record executor without known transport (%T)", e)
+	}
+
+	fp, err := os.OpenFile(config.File,
+		os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	ce.RemoteExecutor = NewRecordingTransport(ce.RemoteExecutor, fp)
+	logger.Info("Recording commands to %v", config.File)
+	return fp, nil
+}
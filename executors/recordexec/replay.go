@@ -0,0 +1,140 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package recordexec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors/cmdexec"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+// ReplayExecutor is a command based executor that does not run any
+// commands. Instead it answers commands with the results from a
+// recording made with WrapRecorder.
+type ReplayExecutor struct {
+	cmdexec.CmdExecutor
+
+	config *RecordConfig
+
+	lock    sync.Mutex
+	ordered bool
+	// exact mode: records by host and commands
+	byKey map[string][]*Record
+	// ordered mode: records by host
+	byHost map[string][]*Record
+}
+
+func NewReplayExecutor(config *RecordConfig) (*ReplayExecutor, error) {
+	if config.File == "" {
+		return nil, fmt.Errorf("Missing recording file in configuration")
+	}
+
+	r := &ReplayExecutor{}
+	r.CmdExecutor.Init(&config.CmdConfig)
+	r.RemoteExecutor = r
+	r.config = config
+
+	switch config.Match {
+	case MatchExact, "":
+	case MatchOrdered:
+		r.ordered = true
+	default:
+		return nil, fmt.Errorf("Invalid replay match mode: %v", config.Match)
+	}
+
+	if config.Fstab == "" {
+		r.Fstab = "/etc/fstab"
+	} else {
+		r.Fstab = config.Fstab
+	}
+	r.BackupLVM = config.BackupLVM
+
+	fp, err := os.Open(config.File)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	records, err := ReadRecords(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	r.byKey = map[string][]*Record{}
+	r.byHost = map[string][]*Record{}
+	for _, rec := range records {
+		r.byKey[rec.key()] = append(r.byKey[rec.key()], rec)
+		r.byHost[rec.Host] = append(r.byHost[rec.Host], rec)
+	}
+	logger.Info("Loaded %v recorded command batches from %v",
+		len(records), config.File)
+
+	godbc.Ensure(r.Fstab != "")
+	return r, nil
+}
+
+func (r *ReplayExecutor) ExecCommands(ctx context.Context,
+	host string, commands rex.Cmds, timeout time.Duration) (rex.Results, error) {
+
+	cmds := make([]string, len(commands))
+	for i, c := range commands {
+		cmds[i] = c.String()
+	}
+	want := &Record{Host: host, Commands: cmds}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var rec *Record
+	if r.ordered {
+		queue := r.byHost[host]
+		if len(queue) == 0 {
+			return nil, fmt.Errorf("replay: no more recorded commands for %v: [%v]",
+				host, strings.Join(cmds, "; "))
+		}
+		rec = queue[0]
+		if rec.key() != want.key() {
+			return nil, fmt.Errorf(
+				"replay: commands for %v do not match the recording:"+
+					" expected [%v], got [%v]",
+				host, strings.Join(rec.Commands, "; "), strings.Join(cmds, "; "))
+		}
+		r.byHost[host] = queue[1:]
+	} else {
+		queue := r.byKey[want.key()]
+		if len(queue) == 0 {
+			return nil, fmt.Errorf("replay: no recorded result for %v: [%v]",
+				host, strings.Join(cmds, "; "))
+		}
+		rec = queue[0]
+		// repeated commands get the recorded results in order, with
+		// the last result answering any further repeats
+		if len(queue) > 1 {
+			r.byKey[want.key()] = queue[1:]
+		}
+	}
+	logger.Debug("replay: answering [%v] on %v from recording",
+		strings.Join(cmds, "; "), host)
+	return rec.Outcome()
+}
+
+func (r *ReplayExecutor) RebalanceOnExpansion() bool {
+	return r.config.RebalanceOnExpansion
+}
+
+func (r *ReplayExecutor) SnapShotLimit() int {
+	return r.config.SnapShotLimit
+}
@@ -0,0 +1,144 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package simexec
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/idgen"
+)
+
+func (v *simVolume) blockUsedKb() uint64 {
+	var used uint64
+	for _, bv := range v.blocks {
+		used += uint64(bv.sizeGb) * 1024 * 1024
+	}
+	return used
+}
+
+func (s *SimExecutor) BlockVolumeCreate(host string,
+	volume *executors.BlockVolumeRequest) (*executors.BlockVolumeInfo, error) {
+
+	godbc.Require(volume != nil)
+	godbc.Require(volume.Name != "")
+
+	n, err := s.enter(host, "BlockVolumeCreate")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	fail := func(e error) (*executors.BlockVolumeInfo, error) {
+		return nil, logger.LogError("Failed to create block volume: %v", e)
+	}
+	v, err := n.pool.volume(volume.GlusterVolumeName)
+	if err != nil {
+		return fail(err)
+	}
+	if !v.started {
+		return fail(fmt.Errorf("volume %v is not started", v.name))
+	}
+	if _, ok := v.blocks[volume.Name]; ok {
+		return fail(fmt.Errorf("BLOCK with name: '%v' already EXIST",
+			volume.Name))
+	}
+	if volume.Hacount > len(volume.BlockHosts) {
+		return fail(fmt.Errorf("Insufficient hosts for ha count %v",
+			volume.Hacount))
+	}
+	for _, h := range volume.BlockHosts {
+		bn := s.node(h)
+		if bn.pool != n.pool || bn.down {
+			return fail(fmt.Errorf("host %v is not connected", h))
+		}
+	}
+	size := uint64(volume.Size) * 1024 * 1024
+	var free uint64
+	if usable, used := v.usableKb(), v.blockUsedKb(); usable > used {
+		free = usable - used
+	}
+	if size > free {
+		return fail(fmt.Errorf(
+			"Not enough space on block hosting volume %v: "+
+				"%vKiB available, %vKiB required",
+			v.name, free, size))
+	}
+
+	id := idgen.GenUUID()
+	bv := &simBlockVolume{
+		name:    volume.Name,
+		sizeGb:  volume.Size,
+		hacount: volume.Hacount,
+		hosts:   append([]string{}, volume.BlockHosts...),
+		iqn:     "iqn.2016-12.org.gluster-block:" + id,
+	}
+	if volume.Auth {
+		bv.username = id
+		bv.password = idgen.GenUUID()
+	}
+	v.blocks[bv.name] = bv
+
+	return &executors.BlockVolumeInfo{
+		Name:              bv.name,
+		Size:              bv.sizeGb,
+		GlusterVolumeName: v.name,
+		GlusterNode:       volume.GlusterNode,
+		Hacount:           bv.hacount,
+		BlockHosts:        bv.hosts,
+		Iqn:               bv.iqn,
+		Username:          bv.username,
+		Password:          bv.password,
+	}, nil
+}
+
+func (s *SimExecutor) BlockVolumeDestroy(host string, blockHostingVolumeName string, blockVolumeName string) error {
+	n, err := s.enter(host, "BlockVolumeDestroy")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	v, ok := n.pool.volumes[blockHostingVolumeName]
+	if !ok {
+		return &executors.VolumeDoesNotExistErr{Name: blockVolumeName}
+	}
+	if _, ok := v.blocks[blockVolumeName]; !ok {
+		return &executors.VolumeDoesNotExistErr{Name: blockVolumeName}
+	}
+	delete(v.blocks, blockVolumeName)
+	return nil
+}
+
+func (s *SimExecutor) ListBlockVolumes(host string, blockhostingvolume string) ([]string, error) {
+	godbc.Require(blockhostingvolume != "")
+
+	n, err := s.enter(host, "ListBlockVolumes")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	v, err := n.pool.volume(blockhostingvolume)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"unable to list blockvolumes on block hosting volume %v : %v",
+			blockhostingvolume, err)
+	}
+	names := []string{}
+	for name := range v.blocks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,155 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package simexec
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/paths"
+)
+
+const brickMountOptions = "rw,inode64,noatime,nouuid"
+
+func (s *SimExecutor) BrickCreate(host string,
+	brick *executors.BrickRequest) (*executors.BrickInfo, error) {
+
+	godbc.Require(brick != nil)
+	godbc.Require(brick.Name != "")
+	godbc.Require(brick.Size > 0)
+	godbc.Require(brick.TpSize >= brick.Size)
+	godbc.Require(brick.VgId != "")
+	godbc.Require(brick.Path != "")
+
+	n, err := s.enter(host, "BrickCreate")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	mountPath := paths.BrickMountFromPath(brick.Path)
+	if _, ok := n.mounts[mountPath]; ok {
+		return nil, fmt.Errorf("mount: %v: mount point is busy", mountPath)
+	}
+
+	vg, err := n.findVG(paths.VgIdToName(brick.VgId))
+	if err != nil {
+		return nil, err
+	}
+	pool, err := vg.allocate(brick.TpName,
+		brick.TpSize+brick.PoolMetadataSize)
+	if err != nil {
+		return nil, err
+	}
+	pool.kind = lvThinPool
+	lv, err := vg.addThin(brick.LvName, pool, brick.Size)
+	if err != nil {
+		delete(vg.lvs, pool.name)
+		return nil, err
+	}
+
+	n.mounts[mountPath] = &simMount{
+		device:     lv.devNode(),
+		mountPoint: mountPath,
+		options:    brickMountOptions,
+		mounted:    true,
+		fstab:      true,
+		lv:         lv,
+	}
+
+	return &executors.BrickInfo{
+		Path: brick.Path,
+	}, nil
+}
+
+func (s *SimExecutor) BrickDestroy(host string,
+	brick *executors.BrickRequest) (bool, error) {
+
+	godbc.Require(brick != nil)
+	godbc.Require(brick.Name != "")
+	godbc.Require(brick.VgId != "")
+	godbc.Require(brick.Path != "")
+	godbc.Require(brick.TpName != "")
+	godbc.Require(brick.LvName != "")
+
+	n, err := s.enter(host, "BrickDestroy")
+	if err != nil {
+		return false, err
+	}
+	defer s.leave()
+
+	// the brick process of a started volume keeps the brick busy
+	if v := n.pool.volumeOf(n, brick.Path); v != nil && v.started {
+		if m := n.mountOf(brick.Path); m != nil && m.mounted {
+			return false, fmt.Errorf("umount: %v: target is busy.",
+				brick.Path)
+		}
+	}
+	mountPath := paths.BrickMountFromPath(brick.Path)
+	delete(n.mounts, mountPath)
+
+	vg, err := n.findVG(paths.VgIdToName(brick.VgId))
+	if err != nil {
+		// without the vg, the bricks take up no space
+		logger.Warning("did not delete brick on missing vg: %v", err)
+		return true, nil
+	}
+	if _, ok := vg.lvs[brick.LvName]; ok {
+		delete(vg.lvs, brick.LvName)
+	} else {
+		logger.Warning("did not delete missing lv: %v/%v",
+			vg.name, brick.LvName)
+	}
+
+	pool, ok := vg.lvs[brick.TpName]
+	if !ok {
+		logger.Warning("did not delete missing thin pool: %v/%v",
+			vg.name, brick.TpName)
+		return true, nil
+	}
+	if len(vg.thinLvs(pool)) > 0 {
+		return false, nil
+	}
+	delete(vg.lvs, pool.name)
+	return true, nil
+}
+
+func (s *SimExecutor) GetBrickMountStatus(host string) (*executors.BricksMountStatus, error) {
+	n, err := s.enter(host, "GetBrickMountStatus")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	mountPoints := []string{}
+	for mp, m := range n.mounts {
+		if m.fstab {
+			mountPoints = append(mountPoints, mp)
+		}
+	}
+	sort.Strings(mountPoints)
+
+	var brickMounts executors.BricksMountStatus
+	for _, mp := range mountPoints {
+		m := n.mounts[mp]
+		brickMounts.Statuses = append(brickMounts.Statuses,
+			executors.BrickMountStatus{
+				Device:       m.device,
+				MountPoint:   m.mountPoint,
+				Type:         "xfs",
+				MountOptions: m.options,
+				Mounted:      m.mounted,
+			})
+	}
+	return &brickMounts, nil
+}
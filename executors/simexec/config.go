@@ -0,0 +1,64 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package simexec
+
+const (
+	// FailError fails the call with an error (the default)
+	FailError = "error"
+	// FailDown takes the node down and fails the call as if the
+	// node could not be reached
+	FailDown = "down"
+	// FailFill fills the devices of the node and lets the call run
+	FailFill = "fill"
+	// FailUnmount unmounts the bricks of the node and lets the
+	// call run
+	FailUnmount = "unmount"
+)
+
+// Failure describes a failure point of the simulated nodes.
+type Failure struct {
+	// Host the failure applies to. Empty matches every host.
+	Host string `json:"host"`
+	// Operation is the name of the executor function the failure
+	// applies to, e.g. "BrickCreate". Empty matches every function.
+	Operation string `json:"operation"`
+	// Skip lets the given number of matching calls pass first
+	Skip int `json:"skip"`
+	// Count is the number of matching calls the failure applies to.
+	// Zero applies it to every matching call.
+	Count int `json:"count"`
+	// Action is one of "error", "down", "fill" or "unmount"
+	Action string `json:"action"`
+	// Device limits the "fill" action to a single device
+	Device string `json:"device"`
+	// Message is the error returned by the "error" action
+	Message string `json:"message"`
+}
+
+type SimConfig struct {
+	// DeviceSizeGb is the size of every simulated device that is
+	// not listed in Devices
+	DeviceSizeGb uint64 `json:"device_size_gb"`
+	// Devices sets the size in GB of individual devices. Keys are
+	// either a device path or "host:path".
+	Devices map[string]uint64 `json:"devices"`
+	// ExtentSizeKb is the physical extent size of the simulated VGs
+	ExtentSizeKb uint64 `json:"extent_size_kb"`
+	// Aliases maps other names of a node, such as its storage
+	// hostname, to the name used to manage the node
+	Aliases map[string]string `json:"aliases"`
+	// DownHosts lists the nodes that are down at startup
+	DownHosts []string `json:"down_hosts"`
+	// Failures are checked, in order, on every call
+	Failures []Failure `json:"failures"`
+
+	RebalanceOnExpansion bool `json:"rebalance_on_expansion"`
+	SnapShotLimit        int  `json:"snapshot_limit"`
+}
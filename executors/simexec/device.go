@@ -0,0 +1,324 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package simexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/idgen"
+	"github.com/heketi/heketi/pkg/paths"
+)
+
+// pvMetadataSizeKb matches the metadata size used when the real
+// executors create a pv
+const pvMetadataSizeKb = 128 * 1024
+
+func (s *SimExecutor) DeviceSetup(host, device, vgid string, destroy bool) (*executors.DeviceInfo, error) {
+	n, err := s.enter(host, "DeviceSetup")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	vgname := paths.VgIdToName(vgid)
+	if pv, ok := n.pvs[device]; ok {
+		if !destroy {
+			e := &executors.DeviceNotAvailableErr{
+				OriginalError: fmt.Errorf(
+					"Can't initialize physical volume \"%v\" without -ff",
+					device),
+				Path:         device,
+				ConnectionOk: true,
+				CurrentMeta:  &executors.DeviceHandle{UUID: pv.uuid},
+			}
+			return nil, e
+		}
+		logger.Info("Data on device %v (host %v) will be destroyed",
+			device, host)
+		if err := n.wipe(pv); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := n.vgs[vgname]; ok {
+		return nil, fmt.Errorf(
+			"A volume group called %v already exists.", vgname)
+	}
+
+	size := s.deviceSizeKb(n, device)
+	if size <= pvMetadataSizeKb+s.config.ExtentSizeKb {
+		return nil, &executors.DeviceNotAvailableErr{
+			OriginalError: fmt.Errorf("Device %v is too small", device),
+			Path:          device,
+			ConnectionOk:  true,
+		}
+	}
+	pv := &simPV{
+		device: device,
+		uuid:   idgen.GenUUID(),
+		sizeKb: size,
+	}
+	vg := &simVG{
+		name:         vgname,
+		pv:           pv,
+		extentSizeKb: s.config.ExtentSizeKb,
+		extents:      (size - pvMetadataSizeKb) / s.config.ExtentSizeKb,
+		lvs:          map[string]*simLV{},
+	}
+	pv.vg = vg
+	n.pvs[device] = pv
+	n.vgs[vgname] = vg
+
+	return deviceInfo(vg), nil
+}
+
+// wipe removes the pv and everything on it. Fails if the device is
+// in use.
+func (n *simNode) wipe(pv *simPV) error {
+	if pv.vg != nil {
+		for _, lv := range pv.vg.lvs {
+			if m := n.mountOfLv(lv); m != nil && m.mounted {
+				return fmt.Errorf(
+					"%v: probing initialization failed: Device or resource busy",
+					pv.device)
+			}
+		}
+		for mp, m := range n.mounts {
+			if m.lv != nil && m.lv.vg == pv.vg {
+				delete(n.mounts, mp)
+			}
+		}
+		delete(n.vgs, pv.vg.name)
+	}
+	delete(n.pvs, pv.device)
+	return nil
+}
+
+func deviceInfo(vg *simVG) *executors.DeviceInfo {
+	return &executors.DeviceInfo{
+		TotalSize:  vg.extents * vg.extentSizeKb,
+		FreeSize:   vg.freeExtents() * vg.extentSizeKb,
+		UsedSize:   vg.usedExtents() * vg.extentSizeKb,
+		ExtentSize: vg.extentSizeKb,
+		Meta: &executors.DeviceHandle{
+			UUID:  vg.pv.uuid,
+			Paths: []string{vg.pv.device},
+		},
+	}
+}
+
+// findDevice returns the vg identified by a device handle.
+func (n *simNode) findDevice(dh *executors.DeviceVgHandle) (*simVG, error) {
+	vg, err := n.findVG(paths.VgIdToName(dh.VgId))
+	if err != nil {
+		return nil, err
+	}
+	if dh.UUID != "" && dh.UUID != vg.pv.uuid {
+		return nil, fmt.Errorf(
+			"Volume group %v is not on the device with PV UUID %v",
+			vg.name, dh.UUID)
+	}
+	return vg, nil
+}
+
+func (s *SimExecutor) GetDeviceInfo(host string, dh *executors.DeviceVgHandle) (*executors.DeviceInfo, error) {
+	n, err := s.enter(host, "GetDeviceInfo")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	vg, err := n.findDevice(dh)
+	if err != nil {
+		return nil, err
+	}
+	return deviceInfo(vg), nil
+}
+
+func (n *simNode) removeDevice(dh *executors.DeviceVgHandle) error {
+	vg, err := n.findDevice(dh)
+	if err != nil {
+		return err
+	}
+	if len(vg.lvs) > 0 {
+		return fmt.Errorf(
+			"Failed to delete device %v with id %v on host %v: "+
+				"Volume group \"%v\" still contains %v logical volume(s)",
+			vg.pv.device, dh.VgId, n.name, vg.name, len(vg.lvs))
+	}
+	delete(n.vgs, vg.name)
+	delete(n.pvs, vg.pv.device)
+	return nil
+}
+
+func (s *SimExecutor) DeviceTeardown(host string, dh *executors.DeviceVgHandle) error {
+	n, err := s.enter(host, "DeviceTeardown")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	return n.removeDevice(dh)
+}
+
+// DeviceForget attempts a best effort remove of the device's vg and
+// pv and always returns a nil error if the node can be reached.
+func (s *SimExecutor) DeviceForget(host string, dh *executors.DeviceVgHandle) error {
+	n, err := s.enter(host, "DeviceForget")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	if err := n.removeDevice(dh); err != nil {
+		logger.Warning("unable to forget device: %v", err)
+	}
+	return nil
+}
+
+func fmtKb(kb uint64) string {
+	return fmt.Sprintf("%.2fk", float64(kb))
+}
+
+// lvmReport converts the rows of a report into the output of the
+// lvm reporting commands.
+func lvmReport(key string, rows []map[string]string, out interface{}) error {
+	report := map[string][]map[string][]map[string]string{
+		"report": {{key: rows}},
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func (s *SimExecutor) PVS(host string) (*executors.PVSCommandOutput, error) {
+	n, err := s.enter(host, "PVS")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	devices := []string{}
+	for device := range n.pvs {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	rows := []map[string]string{}
+	for _, device := range devices {
+		pv := n.pvs[device]
+		row := map[string]string{
+			"pv_name": pv.device,
+			"vg_name": "",
+			"pv_fmt":  "lvm2",
+			"pv_attr": "---",
+			"pv_size": fmtKb(pv.sizeKb - pvMetadataSizeKb),
+			"pv_free": fmtKb(pv.sizeKb - pvMetadataSizeKb),
+		}
+		if pv.vg != nil {
+			row["vg_name"] = pv.vg.name
+			row["pv_attr"] = "a--"
+			row["pv_size"] = fmtKb(pv.vg.sizeKb())
+			row["pv_free"] = fmtKb(pv.vg.freeExtents() * pv.vg.extentSizeKb)
+		}
+		rows = append(rows, row)
+	}
+
+	var out executors.PVSCommandOutput
+	if err := lvmReport("pv", rows, &out); err != nil {
+		return nil, fmt.Errorf("Unable to determine LVM PVs : %v", err)
+	}
+	return &out, nil
+}
+
+func (s *SimExecutor) VGS(host string) (*executors.VGSCommandOutput, error) {
+	n, err := s.enter(host, "VGS")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	rows := []map[string]string{}
+	for _, vg := range n.sortedVGs() {
+		rows = append(rows, map[string]string{
+			"vg_name": vg.name,
+			// the tag of the field in VGSCommandOutput
+			"pv_count:":  "1",
+			"lv_count":   fmt.Sprintf("%v", len(vg.lvs)),
+			"snap_count": "0",
+			"vg_attr":    "wz--n-",
+			"vg_size":    fmtKb(vg.sizeKb()),
+			"vg_free":    fmtKb(vg.freeExtents() * vg.extentSizeKb),
+		})
+	}
+
+	var out executors.VGSCommandOutput
+	if err := lvmReport("vg", rows, &out); err != nil {
+		return nil, fmt.Errorf("Unable to determine LVM VGs : %v", err)
+	}
+	return &out, nil
+}
+
+func (s *SimExecutor) LVS(host string) (*executors.LVSCommandOutput, error) {
+	n, err := s.enter(host, "LVS")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	rows := []map[string]string{}
+	for _, vg := range n.sortedVGs() {
+		for _, lv := range vg.sortedLVs() {
+			row := map[string]string{
+				"lv_name":          lv.name,
+				"vg_name":          vg.name,
+				"lv_size":          fmtKb(lv.sizeKb()),
+				"pool_lv":          "",
+				"origin":           "",
+				"data_percent":     "",
+				"metadata_percent": "",
+				"move_pv":          "",
+				"mirror_log":       "",
+				"copy_percent":     "",
+				"convert_lv":       "",
+			}
+			switch lv.kind {
+			case lvThinPool:
+				row["lv_attr"] = "twi-aotz--"
+				row["data_percent"] = "0.00"
+				row["metadata_percent"] = "0.00"
+			case lvThin:
+				open := "-"
+				if m := n.mountOfLv(lv); m != nil && m.mounted {
+					open = "o"
+				}
+				row["lv_attr"] = "Vwi-a" + open + "tz--"
+				row["pool_lv"] = lv.pool.name
+				row["data_percent"] = "0.00"
+				if lv.origin != nil {
+					row["origin"] = lv.origin.name
+				}
+			default:
+				row["lv_attr"] = "-wi-a-----"
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	var out executors.LVSCommandOutput
+	if err := lvmReport("lv", rows, &out); err != nil {
+		return nil, fmt.Errorf("Unable to determine LVM LVs : %v", err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,79 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package simexec
+
+import (
+	"fmt"
+)
+
+func (s *SimExecutor) GlusterdCheck(host string) error {
+	_, err := s.enter(host, "GlusterdCheck")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+	return nil
+}
+
+func (s *SimExecutor) PeerProbe(exec_host, newnode string) error {
+	n, err := s.enter(exec_host, "PeerProbe")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	peer := s.node(newnode)
+	if peer.down {
+		return fmt.Errorf(
+			"peer probe: failed: Probe returned with Transport endpoint is not connected")
+	}
+	if peer.pool == n.pool {
+		return nil
+	}
+	if len(peer.pool.nodes) > 1 || len(peer.pool.volumes) > 0 {
+		return fmt.Errorf(
+			"peer probe: failed: %v is either already part of another "+
+				"cluster or having volumes configured", newnode)
+	}
+	logger.Info("Probing: %v -> %v", n.name, peer.name)
+	peer.pool = n.pool
+	n.pool.nodes[peer.name] = peer
+	return nil
+}
+
+func (s *SimExecutor) PeerDetach(exec_host, detachnode string) error {
+	n, err := s.enter(exec_host, "PeerDetach")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	// like the command based executors, a failed detach is only logged
+	peer := s.node(detachnode)
+	if peer.pool != n.pool || peer == n {
+		logger.LogError("peer detach: failed: %v is not part of cluster",
+			detachnode)
+		return nil
+	}
+	for _, v := range n.pool.volumes {
+		for _, b := range v.bricks {
+			if b.node == peer {
+				logger.LogError("peer detach: failed: Peer %v hosts "+
+					"one or more bricks of volume %v", detachnode, v.name)
+				return nil
+			}
+		}
+	}
+	logger.Info("Detaching node %v", peer.name)
+	delete(n.pool.nodes, peer.name)
+	peer.pool = newSimPool()
+	peer.pool.nodes[peer.name] = peer
+	return nil
+}
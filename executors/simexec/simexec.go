@@ -0,0 +1,316 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package simexec
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/heketi/heketi/pkg/logging"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+var (
+	logger = logging.NewLogger("[simexec]", logging.LEVEL_DEBUG)
+)
+
+const (
+	defaultDeviceSizeGb = 500
+	defaultExtentSizeKb = 4096
+)
+
+// SimExecutor is an executor that keeps the state of the storage
+// nodes in memory. Unlike the mock executor it models the lvm,
+// mount and gluster objects created on the nodes and enforces the
+// capacity of the devices, so that the results of the queries are
+// consistent with the changes made before. Failures of the nodes
+// can be scripted using the configuration or the Set* and Fill*
+// functions.
+type SimExecutor struct {
+	lock   sync.Mutex
+	config *SimConfig
+	nodes  map[string]*simNode
+
+	failures []*failurePoint
+}
+
+type failurePoint struct {
+	Failure
+	seen int
+}
+
+func setWithEnvVariables(config *SimConfig) {
+	var env string
+
+	env = os.Getenv("HEKETI_SIM_DEVICE_SIZE_GB")
+	if "" != env {
+		value, err := strconv.ParseUint(env, 10, 64)
+		if err == nil {
+			config.DeviceSizeGb = value
+		}
+	}
+
+	env = os.Getenv("HEKETI_SNAPSHOT_LIMIT")
+	if "" != env {
+		i, err := strconv.Atoi(env)
+		if err == nil {
+			config.SnapShotLimit = i
+		}
+	}
+}
+
+func NewSimExecutor(config *SimConfig) (*SimExecutor, error) {
+	// Override configuration
+	setWithEnvVariables(config)
+
+	if config.DeviceSizeGb == 0 {
+		config.DeviceSizeGb = defaultDeviceSizeGb
+	}
+	if config.ExtentSizeKb == 0 {
+		config.ExtentSizeKb = defaultExtentSizeKb
+	}
+
+	s := &SimExecutor{
+		config: config,
+		nodes:  map[string]*simNode{},
+	}
+	for _, f := range config.Failures {
+		if err := s.AddFailure(f); err != nil {
+			return nil, err
+		}
+	}
+	for _, host := range config.DownHosts {
+		s.SetNodeDown(host, true)
+	}
+	return s, nil
+}
+
+// resolve returns the name a node is managed by.
+func (s *SimExecutor) resolve(host string) string {
+	if name, ok := s.config.Aliases[host]; ok {
+		return name
+	}
+	return host
+}
+
+// node returns the node with the given name, creating it on first
+// use. Must be called with the lock held.
+func (s *SimExecutor) node(host string) *simNode {
+	name := s.resolve(host)
+	n, ok := s.nodes[name]
+	if !ok {
+		n = newSimNode(name)
+		s.nodes[name] = n
+	}
+	return n
+}
+
+// enter locks the simulator for a call of op on host. It applies
+// the scripted failures and returns the node the call runs on.
+// If an error is returned the lock is not held.
+func (s *SimExecutor) enter(host, op string) (*simNode, error) {
+	s.lock.Lock()
+	n := s.node(host)
+	if err := s.checkFailures(n, op); err != nil {
+		s.lock.Unlock()
+		logger.LogError("%v on %v: %v", op, host, err)
+		return nil, err
+	}
+	if n.down {
+		s.lock.Unlock()
+		return nil, downError(n)
+	}
+	return n, nil
+}
+
+func (s *SimExecutor) leave() {
+	s.lock.Unlock()
+}
+
+func downError(n *simNode) error {
+	return &rex.ConnectionError{
+		Host: n.name,
+		Err:  fmt.Errorf("Unable to connect to %v: node is down", n.name),
+	}
+}
+
+func (s *SimExecutor) checkFailures(n *simNode, op string) error {
+	for _, f := range s.failures {
+		if f.Host != "" && s.resolve(f.Host) != n.name {
+			continue
+		}
+		if f.Operation != "" && f.Operation != op {
+			continue
+		}
+		f.seen++
+		if f.seen <= f.Skip {
+			continue
+		}
+		if f.Count > 0 && f.seen > f.Skip+f.Count {
+			continue
+		}
+		switch f.Action {
+		case FailDown:
+			logger.Info("taking simulated node %v down", n.name)
+			n.down = true
+			return downError(n)
+		case FailFill:
+			if err := s.fill(n, f.Device); err != nil {
+				return err
+			}
+		case FailUnmount:
+			logger.Info("unmounting bricks of simulated node %v", n.name)
+			for _, m := range n.mounts {
+				m.mounted = false
+			}
+		default:
+			if f.Message != "" {
+				return fmt.Errorf("%v", f.Message)
+			}
+			return fmt.Errorf("simulated failure of %v on %v", op, n.name)
+		}
+	}
+	return nil
+}
+
+func validateFailure(f Failure) error {
+	switch f.Action {
+	case "", FailError, FailDown, FailFill, FailUnmount:
+	default:
+		return fmt.Errorf("invalid failure action: %v", f.Action)
+	}
+	if f.Skip < 0 || f.Count < 0 {
+		return fmt.Errorf("failure skip and count may not be negative")
+	}
+	return nil
+}
+
+// AddFailure adds a failure point to the simulated nodes.
+func (s *SimExecutor) AddFailure(f Failure) error {
+	if err := validateFailure(f); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures = append(s.failures, &failurePoint{Failure: f})
+	return nil
+}
+
+// ClearFailures removes all failure points.
+func (s *SimExecutor) ClearFailures() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures = nil
+}
+
+// SetNodeDown takes a simulated node down or brings it back up.
+// Every call on a node that is down fails with a connection error.
+func (s *SimExecutor) SetNodeDown(host string, down bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.node(host).down = down
+}
+
+// FillDevice takes up the free space of a device, or of all the
+// devices of the node if device is empty.
+func (s *SimExecutor) FillDevice(host, device string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.fill(s.node(host), device)
+}
+
+// ReleaseDevice returns the space taken by FillDevice.
+func (s *SimExecutor) ReleaseDevice(host, device string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, pv := range s.node(host).pvs {
+		if pv.vg != nil && (device == "" || device == pv.device) {
+			delete(pv.vg.lvs, fillLvName)
+		}
+	}
+}
+
+// UnmountBricks unmounts the bricks of a node, as if the node was
+// rebooted without the bricks in its fstab.
+func (s *SimExecutor) UnmountBricks(host string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, m := range s.node(host).mounts {
+		m.mounted = false
+	}
+}
+
+func (s *SimExecutor) fill(n *simNode, device string) error {
+	found := false
+	for _, pv := range n.pvs {
+		if device != "" && device != pv.device {
+			continue
+		}
+		found = true
+		if pv.vg == nil {
+			continue
+		}
+		logger.Info("filling device %v of simulated node %v",
+			pv.device, n.name)
+		free := pv.vg.freeExtents()
+		if lv, ok := pv.vg.lvs[fillLvName]; ok {
+			lv.extents += free
+		} else if free > 0 {
+			pv.vg.lvs[fillLvName] = &simLV{
+				name:    fillLvName,
+				vg:      pv.vg,
+				kind:    lvLinear,
+				extents: free,
+			}
+		}
+	}
+	if device != "" && !found {
+		return fmt.Errorf("Device %v not found on %v", device, n.name)
+	}
+	return nil
+}
+
+func (s *SimExecutor) deviceSizeKb(n *simNode, device string) uint64 {
+	size, ok := s.config.Devices[n.name+":"+device]
+	if !ok {
+		size, ok = s.config.Devices[device]
+	}
+	if !ok {
+		size = s.config.DeviceSizeGb
+	}
+	return size * 1024 * 1024
+}
+
+func (s *SimExecutor) SetLogLevel(level string) {
+	switch level {
+	case "none":
+		logger.SetLevel(logging.LEVEL_NOLOG)
+	case "critical":
+		logger.SetLevel(logging.LEVEL_CRITICAL)
+	case "error":
+		logger.SetLevel(logging.LEVEL_ERROR)
+	case "warning":
+		logger.SetLevel(logging.LEVEL_WARNING)
+	case "info":
+		logger.SetLevel(logging.LEVEL_INFO)
+	case "debug":
+		logger.SetLevel(logging.LEVEL_DEBUG)
+	}
+}
+
+func (s *SimExecutor) RebalanceOnExpansion() bool {
+	return s.config.RebalanceOnExpansion
+}
+
+func (s *SimExecutor) SnapShotLimit() int {
+	return s.config.SnapShotLimit
+}
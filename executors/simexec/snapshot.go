@@ -0,0 +1,280 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package simexec
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/idgen"
+)
+
+// snapMountRoot is where glusterd mounts the bricks of clones
+const snapMountRoot = "/run/gluster/snaps"
+
+func lvPrefix(uuid string) string {
+	return strings.Replace(uuid, "-", "", -1)
+}
+
+// freeLvName returns the first unused lv name in vg made of the
+// prefix and an index.
+func (vg *simVG) freeLvName(prefix string) string {
+	for i := 0; ; i++ {
+		name := fmt.Sprintf("%v_%v", prefix, i)
+		if _, ok := vg.lvs[name]; !ok {
+			return name
+		}
+	}
+}
+
+func (s *SimExecutor) snapshot(n *simNode,
+	vsr *executors.VolumeSnapshotRequest) (*simSnapshot, error) {
+
+	v, err := n.pool.volume(vsr.Volume)
+	if err != nil {
+		return nil, err
+	}
+	if !v.started {
+		return nil, fmt.Errorf("Volume (%v) is not started", v.name)
+	}
+	if _, ok := n.pool.snapshots[vsr.Snapshot]; ok {
+		return nil, fmt.Errorf("Snapshot %v already exists", vsr.Snapshot)
+	}
+	limit := s.config.SnapShotLimit
+	if limit > 0 && len(n.pool.snapshotsOf(v)) >= limit {
+		return nil, fmt.Errorf(
+			"The number of existing snaps has reached the effective "+
+				"maximum limit of %v, for the volume (%v)", limit, v.name)
+	}
+
+	snap := &simSnapshot{
+		name:   vsr.Snapshot,
+		uuid:   idgen.GenUUID(),
+		volume: v,
+		bricks: append([]simBrick{}, v.bricks...),
+	}
+	// check all the bricks before changing anything
+	for _, b := range v.bricks {
+		if b.node.down {
+			return nil, fmt.Errorf("Host %v not connected", b.host)
+		}
+		m := b.node.mountOf(b.path)
+		if m == nil || m.lv == nil || m.lv.kind != lvThin {
+			return nil, fmt.Errorf(
+				"Snapshot is supported only for thin provisioned LV. "+
+					"Ensure that all bricks of %v are thinly provisioned LV.",
+				v.name)
+		}
+	}
+	prefix := lvPrefix(snap.uuid)
+	for _, b := range v.bricks {
+		origin := b.node.mountOf(b.path).lv
+		vg := origin.vg
+		lv, err := vg.addThin(vg.freeLvName(prefix), origin.pool,
+			origin.virtualKb)
+		if err != nil {
+			snap.remove()
+			return nil, err
+		}
+		lv.origin = origin
+		snap.lvs = append(snap.lvs, lv)
+	}
+	n.pool.snapshots[snap.name] = snap
+	return snap, nil
+}
+
+func (snap *simSnapshot) remove() {
+	for _, lv := range snap.lvs {
+		delete(lv.vg.lvs, lv.name)
+	}
+	snap.lvs = nil
+}
+
+func (s *SimExecutor) cloneSnapshot(n *simNode,
+	scr *executors.SnapshotCloneRequest) (*simVolume, error) {
+
+	snap, ok := n.pool.snapshots[scr.Snapshot]
+	if !ok {
+		return nil, fmt.Errorf("Unable to clone snapshot %v: "+
+			"Snapshot (%v) does not exist", scr.Snapshot, scr.Snapshot)
+	}
+	if _, ok := n.pool.volumes[scr.Volume]; ok {
+		return nil, fmt.Errorf("Failed to clone snapshot %v to volume %v: "+
+			"Volume with name:%v already exists",
+			scr.Snapshot, scr.Volume, scr.Volume)
+	}
+	for _, b := range snap.bricks {
+		if b.node.down {
+			return nil, fmt.Errorf("Failed to clone snapshot %v: "+
+				"Host %v not connected", scr.Snapshot, b.host)
+		}
+	}
+
+	orig := snap.volume
+	clone := &simVolume{
+		name:       scr.Volume,
+		id:         idgen.GenUUID(),
+		durability: orig.durability,
+		replica:    orig.replica,
+		arbiter:    orig.arbiter,
+		data:       orig.data,
+		redundancy: orig.redundancy,
+		options:    append([]executors.Option{}, orig.options...),
+		blocks:     map[string]*simBlockVolume{},
+		started:    true,
+	}
+	prefix := lvPrefix(clone.id)
+	for i, b := range snap.bricks {
+		slv := snap.lvs[i]
+		lv, err := slv.vg.addThin(slv.vg.freeLvName(prefix), slv.pool,
+			slv.virtualKb)
+		if err != nil {
+			clone.remove()
+			return nil, err
+		}
+		lv.origin = slv.origin
+		mountPath := path.Join(snapMountRoot, prefix,
+			fmt.Sprintf("brick%v", i+1))
+		b.node.mounts[mountPath] = &simMount{
+			device:     lv.devNode(),
+			mountPoint: mountPath,
+			options:    brickMountOptions,
+			mounted:    true,
+			lv:         lv,
+		}
+		clone.bricks = append(clone.bricks, simBrick{
+			node: b.node,
+			host: b.host,
+			path: path.Join(mountPath, path.Base(b.path)),
+			uuid: idgen.GenUUID(),
+		})
+	}
+	n.pool.volumes[clone.name] = clone
+	return clone, nil
+}
+
+// remove removes the bricks of a partially created clone.
+func (v *simVolume) remove() {
+	for _, b := range v.bricks {
+		if m := b.node.mountOf(b.path); m != nil {
+			delete(b.node.mounts, m.mountPoint)
+			delete(m.lv.vg.lvs, m.lv.name)
+		}
+	}
+	v.bricks = nil
+}
+
+func (s *SimExecutor) VolumeSnapshot(host string, vsr *executors.VolumeSnapshotRequest) (*executors.Snapshot, error) {
+	godbc.Require(vsr != nil)
+
+	n, err := s.enter(host, "VolumeSnapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	snap, err := s.snapshot(n, vsr)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create snapshot of volume %v: %v",
+			vsr.Volume, err)
+	}
+	return &executors.Snapshot{
+		Name: snap.name,
+		UUID: snap.uuid,
+	}, nil
+}
+
+func (s *SimExecutor) VolumeClone(host string, vcr *executors.VolumeCloneRequest) (*executors.Volume, error) {
+	godbc.Require(vcr != nil)
+
+	n, err := s.enter(host, "VolumeClone")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	vsr := executors.VolumeSnapshotRequest{
+		Volume:   vcr.Volume,
+		Snapshot: "tmpsnap_" + idgen.GenUUID(),
+	}
+	snap, err := s.snapshot(n, &vsr)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create snapshot of volume %v: %v",
+			vsr.Volume, err)
+	}
+
+	// we do not want snapshots sticking around
+	defer func() {
+		snap.remove()
+		delete(n.pool.snapshots, snap.name)
+	}()
+
+	clone, err := s.cloneSnapshot(n, &executors.SnapshotCloneRequest{
+		Snapshot: snap.name,
+		Volume:   vcr.Clone,
+	})
+	if err != nil {
+		return nil, err
+	}
+	info := clone.info()
+	return &info, nil
+}
+
+func (s *SimExecutor) SnapshotCloneVolume(host string, scr *executors.SnapshotCloneRequest) (*executors.Volume, error) {
+	godbc.Require(scr != nil)
+
+	n, err := s.enter(host, "SnapshotCloneVolume")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	clone, err := s.cloneSnapshot(n, scr)
+	if err != nil {
+		return nil, err
+	}
+	info := clone.info()
+	return &info, nil
+}
+
+func (s *SimExecutor) SnapshotCloneBlockVolume(host string, scr *executors.SnapshotCloneRequest) (*executors.BlockVolumeInfo, error) {
+	_, err := s.enter(host, "SnapshotCloneBlockVolume")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	return nil, fmt.Errorf(
+		"block snapshot %v can not be cloned, not implemented yet",
+		scr.Snapshot)
+}
+
+func (s *SimExecutor) SnapshotDestroy(host string, snapshot string) error {
+	godbc.Require(snapshot != "")
+
+	n, err := s.enter(host, "SnapshotDestroy")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	snap, ok := n.pool.snapshots[snapshot]
+	if !ok {
+		return fmt.Errorf("Failed to delete snapshot %v: "+
+			"Snapshot (%v) does not exist", snapshot, snapshot)
+	}
+	snap.remove()
+	delete(n.pool.snapshots, snapshot)
+	return nil
+}
@@ -0,0 +1,376 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package simexec
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/idgen"
+)
+
+const (
+	lvLinear = iota
+	lvThinPool
+	lvThin
+)
+
+// fillLvName is the name of the lv used to take up the free space
+// of a filled device
+const fillLvName = "sim_fill"
+
+type simPV struct {
+	device string
+	uuid   string
+	sizeKb uint64
+	vg     *simVG
+}
+
+type simVG struct {
+	name         string
+	pv           *simPV
+	extentSizeKb uint64
+	extents      uint64
+	lvs          map[string]*simLV
+}
+
+type simLV struct {
+	name string
+	vg   *simVG
+	kind int
+	// extents allocated from the vg
+	extents uint64
+	// virtual size of a thin lv
+	virtualKb uint64
+	// the thin pool of a thin lv
+	pool *simLV
+	// the lv a snapshot or clone was taken from
+	origin *simLV
+}
+
+type simMount struct {
+	device     string
+	mountPoint string
+	options    string
+	mounted    bool
+	// fstab is set if the mount is listed in the fstab
+	fstab bool
+	lv    *simLV
+}
+
+type simNode struct {
+	name string
+	uuid string
+	down bool
+	pool *simPool
+
+	pvs    map[string]*simPV
+	vgs    map[string]*simVG
+	mounts map[string]*simMount
+}
+
+type simBrick struct {
+	node *simNode
+	// host is the name the brick was added with
+	host string
+	path string
+	uuid string
+}
+
+func (b simBrick) String() string {
+	return b.host + ":" + b.path
+}
+
+type simBlockVolume struct {
+	name     string
+	sizeGb   int
+	hacount  int
+	hosts    []string
+	iqn      string
+	username string
+	password string
+}
+
+type simVolume struct {
+	name       string
+	id         string
+	durability executors.DurabilityType
+	replica    int
+	arbiter    bool
+	data       int
+	redundancy int
+	bricks     []simBrick
+	options    []executors.Option
+	started    bool
+	blocks     map[string]*simBlockVolume
+}
+
+type simSnapshot struct {
+	name   string
+	uuid   string
+	volume *simVolume
+	bricks []simBrick
+	lvs    []*simLV
+}
+
+// simPool is a trusted storage pool. Gluster volumes and snapshots
+// are shared by all the nodes of a pool.
+type simPool struct {
+	nodes     map[string]*simNode
+	volumes   map[string]*simVolume
+	snapshots map[string]*simSnapshot
+}
+
+func newSimPool() *simPool {
+	return &simPool{
+		nodes:     map[string]*simNode{},
+		volumes:   map[string]*simVolume{},
+		snapshots: map[string]*simSnapshot{},
+	}
+}
+
+func newSimNode(name string) *simNode {
+	n := &simNode{
+		name:   name,
+		uuid:   idgen.GenUUID(),
+		pvs:    map[string]*simPV{},
+		vgs:    map[string]*simVG{},
+		mounts: map[string]*simMount{},
+	}
+	n.pool = newSimPool()
+	n.pool.nodes[name] = n
+	return n
+}
+
+func (vg *simVG) usedExtents() uint64 {
+	var used uint64
+	for _, lv := range vg.lvs {
+		used += lv.extents
+	}
+	return used
+}
+
+func (vg *simVG) freeExtents() uint64 {
+	return vg.extents - vg.usedExtents()
+}
+
+// toExtents returns the number of extents needed to hold sizeKb.
+func (vg *simVG) toExtents(sizeKb uint64) uint64 {
+	return (sizeKb + vg.extentSizeKb - 1) / vg.extentSizeKb
+}
+
+func (vg *simVG) allocate(name string, sizeKb uint64) (*simLV, error) {
+	if _, ok := vg.lvs[name]; ok {
+		return nil, fmt.Errorf(
+			"Logical Volume \"%v\" already exists in volume group \"%v\"",
+			name, vg.name)
+	}
+	need := vg.toExtents(sizeKb)
+	if free := vg.freeExtents(); need > free {
+		return nil, fmt.Errorf(
+			"Volume group \"%v\" has insufficient free space "+
+				"(%v extents): %v required.",
+			vg.name, free, need)
+	}
+	lv := &simLV{
+		name:    name,
+		vg:      vg,
+		extents: need,
+	}
+	vg.lvs[name] = lv
+	return lv, nil
+}
+
+// thinLvs returns the thin lvs using the given thin pool.
+func (vg *simVG) thinLvs(pool *simLV) []*simLV {
+	lvs := []*simLV{}
+	for _, lv := range vg.lvs {
+		if lv.pool == pool {
+			lvs = append(lvs, lv)
+		}
+	}
+	return lvs
+}
+
+// addThin creates a thin lv in the given thin pool. Thin lvs do not
+// take space from the vg.
+func (vg *simVG) addThin(name string, pool *simLV, sizeKb uint64) (*simLV, error) {
+	if _, ok := vg.lvs[name]; ok {
+		return nil, fmt.Errorf(
+			"Logical Volume \"%v\" already exists in volume group \"%v\"",
+			name, vg.name)
+	}
+	lv := &simLV{
+		name:      name,
+		vg:        vg,
+		kind:      lvThin,
+		virtualKb: sizeKb,
+		pool:      pool,
+	}
+	vg.lvs[name] = lv
+	return lv, nil
+}
+
+func (vg *simVG) sizeKb() uint64 {
+	return vg.extents * vg.extentSizeKb
+}
+
+func (lv *simLV) sizeKb() uint64 {
+	if lv.kind == lvThin {
+		return lv.virtualKb
+	}
+	return lv.extents * lv.vg.extentSizeKb
+}
+
+func (lv *simLV) devNode() string {
+	return "/dev/mapper/" + lv.vg.name + "-" + lv.name
+}
+
+// findVG returns the vg with the given name.
+func (n *simNode) findVG(name string) (*simVG, error) {
+	vg, ok := n.vgs[name]
+	if !ok {
+		return nil, fmt.Errorf("Volume group \"%v\" not found", name)
+	}
+	return vg, nil
+}
+
+// mountOf returns the mount holding the given brick path.
+func (n *simNode) mountOf(brickPath string) *simMount {
+	for mp, m := range n.mounts {
+		if brickPath == mp || len(brickPath) > len(mp) &&
+			brickPath[:len(mp)+1] == mp+"/" {
+			return m
+		}
+	}
+	return nil
+}
+
+// mountOfLv returns the mount of the given lv, if any.
+func (n *simNode) mountOfLv(lv *simLV) *simMount {
+	for _, m := range n.mounts {
+		if m.lv == lv {
+			return m
+		}
+	}
+	return nil
+}
+
+func (n *simNode) sortedVGs() []*simVG {
+	names := []string{}
+	for name := range n.vgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	vgs := []*simVG{}
+	for _, name := range names {
+		vgs = append(vgs, n.vgs[name])
+	}
+	return vgs
+}
+
+func (vg *simVG) sortedLVs() []*simLV {
+	names := []string{}
+	for name := range vg.lvs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lvs := []*simLV{}
+	for _, name := range names {
+		lvs = append(lvs, vg.lvs[name])
+	}
+	return lvs
+}
+
+// usableKb returns the capacity of a gluster volume as seen by
+// its clients.
+func (v *simVolume) usableKb() uint64 {
+	var (
+		total uint64
+		inSet int
+	)
+	switch v.durability {
+	case executors.DurabilityReplica:
+		inSet = v.replica
+	case executors.DurabilityDispersion:
+		inSet = v.data + v.redundancy
+	default:
+		inSet = 1
+	}
+	for i := 0; i+inSet <= len(v.bricks); i += inSet {
+		set := v.bricks[i : i+inSet]
+		if v.durability == executors.DurabilityReplica && v.arbiter {
+			// the arbiter brick holds no data
+			set = set[:len(set)-1]
+		}
+		var smallest uint64
+		for j, b := range set {
+			size := brickSizeKb(b)
+			if j == 0 || size < smallest {
+				smallest = size
+			}
+		}
+		if v.durability == executors.DurabilityDispersion {
+			total += smallest * uint64(v.data)
+		} else {
+			total += smallest
+		}
+	}
+	return total
+}
+
+func brickSizeKb(b simBrick) uint64 {
+	if m := b.node.mountOf(b.path); m != nil && m.lv != nil {
+		return m.lv.sizeKb()
+	}
+	return 0
+}
+
+func (v *simVolume) hasBrick(n *simNode, path string) bool {
+	for _, vb := range v.bricks {
+		if vb.node == n && vb.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *simPool) volumeOf(n *simNode, path string) *simVolume {
+	for _, v := range p.volumes {
+		if v.hasBrick(n, path) {
+			return v
+		}
+	}
+	return nil
+}
+
+func (p *simPool) snapshotsOf(v *simVolume) []*simSnapshot {
+	snaps := []*simSnapshot{}
+	for _, s := range p.snapshots {
+		if s.volume == v {
+			snaps = append(snaps, s)
+		}
+	}
+	return snaps
+}
+
+func (p *simPool) sortedVolumes() []*simVolume {
+	names := []string{}
+	for name := range p.volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	vols := []*simVolume{}
+	for _, name := range names {
+		vols = append(vols, p.volumes[name])
+	}
+	return vols
+}
@@ -0,0 +1,418 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package simexec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lpabon/godbc"
+
+	"github.com/heketi/heketi/executors"
+	"github.com/heketi/heketi/pkg/idgen"
+)
+
+// gluster volume types and states, as reported by volume info
+const (
+	volTypeDistribute = 0
+	volTypeReplicate  = 2
+	volTypeDisperse   = 4
+
+	volStatusStarted = 1
+	volStatusStopped = 2
+)
+
+// newBrick returns the brick at host:path, which must be usable by
+// a volume of the pool n belongs to.
+func (s *SimExecutor) newBrick(n *simNode, bi executors.BrickInfo) (simBrick, error) {
+	b := simBrick{
+		node: s.node(bi.Host),
+		host: bi.Host,
+		path: bi.Path,
+		uuid: idgen.GenUUID(),
+	}
+	if b.node.pool != n.pool {
+		return b, fmt.Errorf(
+			"Host %v is not in 'Peer in Cluster' state", bi.Host)
+	}
+	if b.node.down {
+		return b, fmt.Errorf("Host %v not connected", bi.Host)
+	}
+	if m := b.node.mountOf(bi.Path); m == nil || !m.mounted {
+		return b, fmt.Errorf(
+			"Failed to find brick directory %v for volume. "+
+				"Reason : No such file or directory", b)
+	}
+	if n.pool.volumeOf(b.node, b.path) != nil {
+		return b, fmt.Errorf("%v is already part of a volume", b)
+	}
+	return b, nil
+}
+
+func (s *SimExecutor) newBricks(n *simNode, bricks []executors.BrickInfo) ([]simBrick, error) {
+	added := []simBrick{}
+	for _, bi := range bricks {
+		b, err := s.newBrick(n, bi)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range added {
+			if a.node == b.node && a.path == b.path {
+				return nil, fmt.Errorf("Found duplicate exports %v", b)
+			}
+		}
+		added = append(added, b)
+	}
+	return added, nil
+}
+
+func (v *simVolume) setOptions(options []string) {
+	for _, o := range options {
+		if o == "" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(o), " ", 2)
+		opt := executors.Option{Name: parts[0]}
+		if len(parts) > 1 {
+			opt.Value = strings.TrimSpace(parts[1])
+		}
+		replaced := false
+		for i := range v.options {
+			if v.options[i].Name == opt.Name {
+				v.options[i] = opt
+				replaced = true
+			}
+		}
+		if !replaced {
+			v.options = append(v.options, opt)
+		}
+	}
+}
+
+func (v *simVolume) inSet() int {
+	switch v.durability {
+	case executors.DurabilityReplica:
+		return v.replica
+	case executors.DurabilityDispersion:
+		return v.data + v.redundancy
+	}
+	return 1
+}
+
+func (s *SimExecutor) VolumeCreate(host string,
+	volume *executors.VolumeRequest) (*executors.Volume, error) {
+
+	godbc.Require(volume != nil)
+	godbc.Require(len(volume.Bricks) > 0)
+	godbc.Require(volume.Name != "")
+
+	n, err := s.enter(host, "VolumeCreate")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	if _, ok := n.pool.volumes[volume.Name]; ok {
+		return nil, fmt.Errorf(
+			"volume create: %v: failed: Volume %v already exists",
+			volume.Name, volume.Name)
+	}
+	v := &simVolume{
+		name:       volume.Name,
+		id:         idgen.GenUUID(),
+		durability: volume.Type,
+		replica:    volume.Replica,
+		arbiter:    volume.Arbiter,
+		data:       volume.Data,
+		redundancy: volume.Redundancy,
+		blocks:     map[string]*simBlockVolume{},
+	}
+	if len(volume.Bricks)%v.inSet() != 0 {
+		return nil, fmt.Errorf(
+			"volume create: %v: failed: Incorrect number of bricks "+
+				"supplied %v with count %v",
+			volume.Name, len(volume.Bricks), v.inSet())
+	}
+	v.bricks, err = s.newBricks(n, volume.Bricks)
+	if err != nil {
+		return nil, fmt.Errorf("volume create: %v: failed: %v",
+			volume.Name, err)
+	}
+	v.setOptions(volume.GlusterVolumeOptions)
+	v.started = true
+	n.pool.volumes[v.name] = v
+	logger.Info("Created volume %v with %v bricks", v.name, len(v.bricks))
+
+	return &executors.Volume{}, nil
+}
+
+func (s *SimExecutor) VolumeExpand(host string,
+	volume *executors.VolumeRequest) (*executors.Volume, error) {
+
+	godbc.Require(volume != nil)
+	godbc.Require(len(volume.Bricks) > 0)
+	godbc.Require(volume.Name != "")
+
+	n, err := s.enter(host, "VolumeExpand")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	v, err := n.pool.volume(volume.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(volume.Bricks)%v.inSet() != 0 {
+		return nil, fmt.Errorf(
+			"volume add-brick: failed: Incorrect number of bricks "+
+				"supplied %v with count %v",
+			len(volume.Bricks), v.inSet())
+	}
+	bricks, err := s.newBricks(n, volume.Bricks)
+	if err != nil {
+		return nil, fmt.Errorf("volume add-brick: failed: %v", err)
+	}
+	v.bricks = append(v.bricks, bricks...)
+
+	return &executors.Volume{}, nil
+}
+
+func (p *simPool) volume(name string) (*simVolume, error) {
+	v, ok := p.volumes[name]
+	if !ok {
+		return nil, &executors.VolumeDoesNotExistErr{Name: name}
+	}
+	return v, nil
+}
+
+func (s *SimExecutor) VolumeDestroy(host string, volume string) error {
+	godbc.Require(volume != "")
+
+	n, err := s.enter(host, "VolumeDestroy")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	v, err := n.pool.volume(volume)
+	if err != nil {
+		return logger.Err(fmt.Errorf(
+			"Unable to delete volume %v: %v", volume, err))
+	}
+	if snaps := n.pool.snapshotsOf(v); len(snaps) > 0 {
+		return logger.Err(fmt.Errorf(
+			"Unable to delete volume %v: Cannot delete Volume %v, "+
+				"as it has %v snapshots", volume, volume, len(snaps)))
+	}
+	v.started = false
+	delete(n.pool.volumes, volume)
+	return nil
+}
+
+func (s *SimExecutor) VolumeDestroyCheck(host, volume string) error {
+	godbc.Require(volume != "")
+
+	n, err := s.enter(host, "VolumeDestroyCheck")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	v, err := n.pool.volume(volume)
+	if err != nil {
+		return err
+	}
+	if snaps := n.pool.snapshotsOf(v); len(snaps) > 0 {
+		return fmt.Errorf(
+			"Unable to delete volume %v because it contains %v snapshots",
+			volume, len(snaps))
+	}
+	return nil
+}
+
+func (s *SimExecutor) VolumeReplaceBrick(host string, volume string,
+	oldBrick *executors.BrickInfo, newBrick *executors.BrickInfo) error {
+
+	godbc.Require(volume != "")
+	godbc.Require(oldBrick != nil)
+	godbc.Require(newBrick != nil)
+
+	n, err := s.enter(host, "VolumeReplaceBrick")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	fail := func(e error) error {
+		return logger.Err(fmt.Errorf(
+			"Unable to replace brick %v:%v with %v:%v for volume %v: %v",
+			oldBrick.Host, oldBrick.Path, newBrick.Host, newBrick.Path,
+			volume, e))
+	}
+	v, err := n.pool.volume(volume)
+	if err != nil {
+		return fail(err)
+	}
+	old := s.node(oldBrick.Host)
+	for i, b := range v.bricks {
+		if b.node != old || b.path != oldBrick.Path {
+			continue
+		}
+		nb, err := s.newBrick(n, *newBrick)
+		if err != nil {
+			return fail(err)
+		}
+		v.bricks[i] = nb
+		return nil
+	}
+	return fail(fmt.Errorf("brick %v:%v is not a brick of the volume",
+		oldBrick.Host, oldBrick.Path))
+}
+
+// info returns the volume as reported by gluster volume info.
+func (v *simVolume) info() executors.Volume {
+	inSet := v.inSet()
+	vol := executors.Volume{
+		VolumeName:  v.name,
+		ID:          v.id,
+		BrickCount:  len(v.bricks),
+		DistCount:   inSet,
+		StripeCount: 1,
+		Transport:   0,
+		OptCount:    len(v.options),
+	}
+	if v.started {
+		vol.Status = volStatusStarted
+		vol.StatusStr = "Started"
+	} else {
+		vol.Status = volStatusStopped
+		vol.StatusStr = "Stopped"
+	}
+	distributed := len(v.bricks) > inSet
+	switch v.durability {
+	case executors.DurabilityReplica:
+		vol.Type = volTypeReplicate
+		vol.TypeStr = "Replicate"
+		vol.ReplicaCount = v.replica
+		if v.arbiter {
+			vol.ArbiterCount = 1
+		}
+	case executors.DurabilityDispersion:
+		vol.Type = volTypeDisperse
+		vol.TypeStr = "Disperse"
+		vol.ReplicaCount = 1
+		vol.DisperseCount = v.data + v.redundancy
+		vol.RedundancyCount = v.redundancy
+	default:
+		vol.Type = volTypeDistribute
+		vol.TypeStr = "Distribute"
+		vol.ReplicaCount = 1
+		distributed = false
+	}
+	if distributed {
+		vol.TypeStr = "Distributed-" + vol.TypeStr
+	}
+	for i, b := range v.bricks {
+		brick := executors.Brick{
+			UUID:     b.uuid,
+			Name:     b.String(),
+			HostUUID: b.node.uuid,
+		}
+		if v.arbiter && i%inSet == inSet-1 {
+			brick.IsArbiter = 1
+		}
+		vol.Bricks.BrickList = append(vol.Bricks.BrickList, brick)
+	}
+	vol.Options.OptionList = append(vol.Options.OptionList, v.options...)
+	return vol
+}
+
+func (s *SimExecutor) VolumeInfo(host string, volume string) (*executors.Volume, error) {
+	godbc.Require(volume != "")
+
+	n, err := s.enter(host, "VolumeInfo")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	v, err := n.pool.volume(volume)
+	if err != nil {
+		return nil, err
+	}
+	info := v.info()
+	return &info, nil
+}
+
+func (s *SimExecutor) VolumesInfo(host string) (*executors.VolInfo, error) {
+	n, err := s.enter(host, "VolumesInfo")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	volinfo := &executors.VolInfo{}
+	for _, v := range n.pool.sortedVolumes() {
+		volinfo.Volumes.VolumeList = append(volinfo.Volumes.VolumeList,
+			v.info())
+	}
+	volinfo.Volumes.Count = len(volinfo.Volumes.VolumeList)
+	return volinfo, nil
+}
+
+// VolumeModify is used to alter the configuration of an existing volume.
+func (s *SimExecutor) VolumeModify(host string, mod *executors.VolumeModifyRequest) error {
+	n, err := s.enter(host, "VolumeModify")
+	if err != nil {
+		return err
+	}
+	defer s.leave()
+
+	v, err := n.pool.volume(mod.Name)
+	if err != nil {
+		return err
+	}
+	v.setOptions(mod.GlusterVolumeOptions)
+	return nil
+}
+
+func (s *SimExecutor) HealInfo(host string, volume string) (*executors.HealInfo, error) {
+	godbc.Require(volume != "")
+
+	n, err := s.enter(host, "HealInfo")
+	if err != nil {
+		return nil, err
+	}
+	defer s.leave()
+
+	v, err := n.pool.volume(volume)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get heal info of volume : %v", volume)
+	}
+	if v.durability == executors.DurabilityNone {
+		return nil, fmt.Errorf("Unable to get heal info of volume : %v", volume)
+	}
+	healInfo := &executors.HealInfo{}
+	for _, b := range v.bricks {
+		status := executors.BrickHealStatus{
+			HostUUID:        b.node.uuid,
+			Name:            b.String(),
+			Status:          "Connected",
+			NumberOfEntries: "0",
+		}
+		if m := b.node.mountOf(b.path); b.node.down || m == nil || !m.mounted {
+			status.Status = "Transport endpoint is not connected"
+			status.NumberOfEntries = "-"
+		}
+		healInfo.Bricks.BrickList = append(healInfo.Bricks.BrickList, status)
+	}
+	return healInfo, nil
+}
@@ -19,4 +19,21 @@ type SshConfig struct {
 	PrivateKeyFile string `json:"keyfile"`
 	User           string `json:"user"`
 	Port           string `json:"port"`
+
+	// CredentialsFile holds named keys and per node settings
+	CredentialsFile string `json:"credentials_file"`
+
+	// timeouts, in seconds
+	DialTimeout      uint32 `json:"dial_timeout"`
+	KeepaliveTimeout uint32 `json:"keepalive_timeout"`
+
+	// connection pool settings
+	DisablePool           bool   `json:"disable_connection_pool"`
+	PoolIdleTimeout       uint32 `json:"connection_idle_timeout"`
+	PoolKeepaliveInterval uint32 `json:"connection_keepalive_interval"`
+
+	// circuit breaker settings
+	DisableBreaker     bool   `json:"disable_circuit_breaker"`
+	BreakerFailures    uint32 `json:"circuit_breaker_failures"`
+	BreakerOpenTimeout uint32 `json:"circuit_breaker_timeout"`
 }
@@ -0,0 +1,127 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package sshexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/heketi/heketi/executors"
+)
+
+// Credentials is the content of the ssh credentials file. Keys maps
+// the names used to refer to private keys to the key files. Hosts
+// holds the connection settings of nodes that differ from the
+// executor's defaults.
+type Credentials struct {
+	Keys  map[string]string                   `json:"keys"`
+	Hosts map[string]executors.HostConnection `json:"hosts"`
+}
+
+// hostSetter is implemented by Sshers that can use a different user
+// or key per host
+type hostSetter interface {
+	SetHostSettings(host, user, keyfile string) error
+}
+
+func loadCredentials(path string) (*Credentials, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	c := &Credentials{}
+	if err := json.NewDecoder(fp).Decode(c); err != nil {
+		return nil, fmt.Errorf("Unable to parse %v: %v", path, err)
+	}
+	return c, nil
+}
+
+// mergeHostConnection returns the settings of base updated with
+// the non-empty fields of override.
+func mergeHostConnection(
+	base, override executors.HostConnection) executors.HostConnection {
+
+	if override.User != "" {
+		base.User = override.User
+	}
+	if override.Port != "" {
+		base.Port = override.Port
+	}
+	if override.Key != "" {
+		base.Key = override.Key
+	}
+	if override.Sudo != nil {
+		base.Sudo = override.Sudo
+	}
+	return base
+}
+
+// SetHostConnection sets the connection settings for the given
+// node. The settings are applied on top of those in the credentials
+// file, if any. A nil value reverts to the credentials file.
+func (s *SshExecutor) SetHostConnection(
+	host string, hc *executors.HostConnection) error {
+
+	var merged executors.HostConnection
+	if s.credentials != nil {
+		merged = s.credentials.Hosts[host]
+	}
+	if hc != nil {
+		merged = mergeHostConnection(merged, *hc)
+	}
+
+	keyfile := ""
+	if merged.Key != "" {
+		var ok bool
+		if s.credentials != nil {
+			keyfile, ok = s.credentials.Keys[merged.Key]
+		}
+		if !ok {
+			return fmt.Errorf("Unknown ssh key %#v for host %v",
+				merged.Key, host)
+		}
+	}
+	if hs, ok := s.exec.(hostSetter); ok {
+		if err := hs.SetHostSettings(host, merged.User, keyfile); err != nil {
+			return err
+		}
+	} else if merged.User != "" || keyfile != "" {
+		return fmt.Errorf("Per host ssh users and keys are not supported")
+	}
+
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	if merged == (executors.HostConnection{}) {
+		delete(s.conns, host)
+	} else {
+		s.conns[host] = &merged
+	}
+	return nil
+}
+
+// hostPortSudo returns the port and sudo setting used for host.
+func (s *SshExecutor) hostPortSudo(host string) (string, bool) {
+	port, sudo := s.port, s.config.Sudo
+
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	if hc, ok := s.conns[host]; ok {
+		if hc.Port != "" {
+			port = hc.Port
+		}
+		if hc.Sudo != nil {
+			sudo = *hc.Sudo
+		}
+	}
+	return port, sudo
+}
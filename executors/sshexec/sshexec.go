@@ -10,13 +10,17 @@
 package sshexec
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/lpabon/godbc"
 
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/executors/cmdexec"
 	"github.com/heketi/heketi/pkg/logging"
 	rex "github.com/heketi/heketi/pkg/remoteexec"
@@ -24,7 +28,35 @@ import (
 )
 
 type Ssher interface {
-	ExecCommands(host string, commands rex.Cmds, timeoutMinutes int, useSudo bool) (rex.Results, error)
+	ExecCommands(ctx context.Context, host string, commands rex.Cmds, timeout time.Duration, useSudo bool) (rex.Results, error)
+}
+
+// pooler is implemented by Sshers that can reuse connections
+type pooler interface {
+	EnablePool(opts ssh.PoolOptions)
+	PoolStats() rex.PoolStats
+	Close() error
+}
+
+// breaker is implemented by Sshers that can stop connecting to
+// unreachable hosts
+type breaker interface {
+	EnableBreaker(opts rex.BreakerOptions)
+	BreakerStats() rex.BreakerStats
+	HostCircuit(host string) rex.CircuitState
+}
+
+// dialTimeouter is implemented by Sshers with a configurable
+// connection timeout
+type dialTimeouter interface {
+	SetDialTimeout(d time.Duration)
+}
+
+// hostKeyPinner is implemented by Sshers that can verify hosts
+// against pinned host keys
+type hostKeyPinner interface {
+	PinHostKey(host, key string) error
+	ScanHostKey(host string) (string, error)
 }
 
 type SshExecutor struct {
@@ -36,6 +68,11 @@ type SshExecutor struct {
 	exec            Ssher
 	config          *SshConfig
 	port            string
+
+	// per node connection settings
+	credentials *Credentials
+	connLock    sync.RWMutex
+	conns       map[string]*executors.HostConnection
 }
 
 var (
@@ -67,6 +104,11 @@ func setWithEnvVariables(config *SshConfig) {
 		config.Port = env
 	}
 
+	env = os.Getenv("HEKETI_SSH_CREDENTIALS_FILE")
+	if "" != env {
+		config.CredentialsFile = env
+	}
+
 	env = os.Getenv("HEKETI_FSTAB")
 	if "" != env {
 		config.Fstab = env
@@ -80,6 +122,22 @@ func setWithEnvVariables(config *SshConfig) {
 		}
 	}
 
+	env = os.Getenv("HEKETI_SSH_DISABLE_POOL")
+	if "" != env {
+		b, err := strconv.ParseBool(env)
+		if err == nil {
+			config.DisablePool = b
+		}
+	}
+
+	env = os.Getenv("HEKETI_SSH_DISABLE_CIRCUIT_BREAKER")
+	if "" != env {
+		b, err := strconv.ParseBool(env)
+		if err == nil {
+			config.DisableBreaker = b
+		}
+	}
+
 }
 
 func NewSshExecutor(config *SshConfig) (*SshExecutor, error) {
@@ -126,6 +184,44 @@ func NewSshExecutor(config *SshConfig) (*SshExecutor, error) {
 		s.Logger().Err(err)
 		return nil, err
 	}
+	if d, ok := s.exec.(dialTimeouter); ok && config.DialTimeout > 0 {
+		d.SetDialTimeout(time.Second * time.Duration(config.DialTimeout))
+	}
+	if p, ok := s.exec.(pooler); ok {
+		p.EnablePool(ssh.PoolOptions{
+			Disabled: config.DisablePool,
+			IdleTimeout: time.Second *
+				time.Duration(config.PoolIdleTimeout),
+			KeepaliveInterval: time.Second *
+				time.Duration(config.PoolKeepaliveInterval),
+			KeepaliveTimeout: time.Second *
+				time.Duration(config.KeepaliveTimeout),
+		})
+	}
+	if b, ok := s.exec.(breaker); ok {
+		b.EnableBreaker(rex.BreakerOptions{
+			Disabled: config.DisableBreaker,
+			Failures: int(config.BreakerFailures),
+			OpenTimeout: time.Second *
+				time.Duration(config.BreakerOpenTimeout),
+		})
+	}
+
+	// Load per node settings
+	s.conns = map[string]*executors.HostConnection{}
+	if config.CredentialsFile != "" {
+		s.credentials, err = loadCredentials(config.CredentialsFile)
+		if err != nil {
+			s.Logger().Err(err)
+			return nil, err
+		}
+		for host := range s.credentials.Hosts {
+			if err := s.SetHostConnection(host, nil); err != nil {
+				s.Logger().Err(err)
+				return nil, err
+			}
+		}
+	}
 
 	godbc.Ensure(s != nil)
 	godbc.Ensure(s.config == config)
@@ -137,15 +233,18 @@ func NewSshExecutor(config *SshConfig) (*SshExecutor, error) {
 	return s, nil
 }
 
-func (s *SshExecutor) ExecCommands(
-	host string, commands rex.Cmds, timeoutMinutes int) (rex.Results, error) {
+func (s *SshExecutor) ExecCommands(ctx context.Context,
+	host string, commands rex.Cmds, timeout time.Duration) (rex.Results, error) {
 
 	// Throttle
-	s.AccessConnection(host)
+	if err := s.AccessConnectionContext(ctx, host); err != nil {
+		return nil, err
+	}
 	defer s.FreeConnection(host)
 
 	// Execute
-	return s.exec.ExecCommands(host+":"+s.port, commands, timeoutMinutes, s.config.Sudo)
+	port, sudo := s.hostPortSudo(host)
+	return s.exec.ExecCommands(ctx, host+":"+port, commands, timeout, sudo)
 }
 
 func (s *SshExecutor) RebalanceOnExpansion() bool {
@@ -155,3 +254,74 @@ func (s *SshExecutor) RebalanceOnExpansion() bool {
 func (s *SshExecutor) SnapShotLimit() int {
 	return s.config.SnapShotLimit
 }
+
+// ActiveCommands returns the tracker for commands currently running
+// on the nodes, if the underlying ssh implementation supports it.
+func (s *SshExecutor) ActiveCommands() *rex.ActiveCommands {
+	if r, ok := s.exec.(executors.ActiveCommandsReporter); ok {
+		return r.ActiveCommands()
+	}
+	return nil
+}
+
+// SetCommandTrail sets the trail that records the commands run
+// on the nodes, if the underlying ssh implementation supports it.
+func (s *SshExecutor) SetCommandTrail(t *rex.CommandTrail) {
+	if r, ok := s.exec.(executors.CommandTrailSetter); ok {
+		r.SetCommandTrail(t)
+	}
+}
+
+// ConnectionPoolStats returns the state of the pooled ssh
+// connections to the nodes.
+func (s *SshExecutor) ConnectionPoolStats() rex.PoolStats {
+	if p, ok := s.exec.(pooler); ok {
+		return p.PoolStats()
+	}
+	return rex.PoolStats{}
+}
+
+// Close closes the pooled ssh connections to the nodes.
+func (s *SshExecutor) Close() error {
+	if p, ok := s.exec.(pooler); ok {
+		return p.Close()
+	}
+	return nil
+}
+
+// CircuitBreakerStats returns the state of the circuits to the
+// nodes that failed to connect.
+func (s *SshExecutor) CircuitBreakerStats() rex.BreakerStats {
+	if b, ok := s.exec.(breaker); ok {
+		return b.BreakerStats()
+	}
+	return rex.BreakerStats{}
+}
+
+// HostCircuit returns the state of the circuit to the given node.
+// Nodes are always reachable if the ssh implementation does not
+// break circuits.
+func (s *SshExecutor) HostCircuit(host string) rex.CircuitState {
+	if b, ok := s.exec.(breaker); ok {
+		return b.HostCircuit(host)
+	}
+	return rex.CircuitClosed
+}
+
+// ScanHostKey returns the ssh host key presented by the given node.
+func (s *SshExecutor) ScanHostKey(host string) (string, error) {
+	if p, ok := s.exec.(hostKeyPinner); ok {
+		port, _ := s.hostPortSudo(host)
+		return p.ScanHostKey(host + ":" + port)
+	}
+	return "", nil
+}
+
+// PinHostKey sets the ssh host key the given node must present.
+// An empty key accepts any key from the node.
+func (s *SshExecutor) PinHostKey(host, key string) error {
+	if p, ok := s.exec.(hostKeyPinner); ok {
+		return p.PinHostKey(host, key)
+	}
+	return nil
+}
@@ -10,6 +10,8 @@
 package stack
 
 import (
+	"context"
+
 	"github.com/heketi/heketi/executors"
 )
 
@@ -34,6 +36,32 @@ func (es *ExecutorStack) SetExec(e []executors.Executor) {
 	es.executors = e
 }
 
+// WithContext returns a stack of the same executors, each running
+// within ctx if it supports contexts.
+func (es *ExecutorStack) WithContext(ctx context.Context) executors.Executor {
+	e := make([]executors.Executor, len(es.executors))
+	for i := range es.executors {
+		e[i] = executors.WithContext(es.executors[i], ctx)
+	}
+	return &ExecutorStack{
+		executors:        e,
+		CheckAllGlusterd: es.CheckAllGlusterd,
+	}
+}
+
+// ForOperation returns a stack of the same executors, each marking
+// its commands with the operation id if it supports it.
+func (es *ExecutorStack) ForOperation(id string) executors.Executor {
+	e := make([]executors.Executor, len(es.executors))
+	for i := range es.executors {
+		e[i] = executors.ForOperation(es.executors[i], id)
+	}
+	return &ExecutorStack{
+		executors:        e,
+		CheckAllGlusterd: es.CheckAllGlusterd,
+	}
+}
+
 func (es *ExecutorStack) GlusterdCheck(host string) error {
 	err := NotSupportedError
 	for _, e := range es.executors {
@@ -27,6 +27,7 @@ import (
 
 	"github.com/heketi/heketi/apps/glusterfs"
 	"github.com/heketi/heketi/middleware"
+	"github.com/heketi/heketi/pkg/utils"
 	"github.com/heketi/heketi/server/admin"
 	"github.com/heketi/heketi/server/config"
 	"github.com/heketi/heketi/server/profiling"
@@ -515,6 +516,9 @@ func main() {
 	// Negroni
 	n := negroni.New(negroni.NewRecovery(), negroni.NewLogger())
 
+	// Reply with json errors to the clients that ask for them
+	n.UseFunc(utils.NegotiateErrors)
+
 	// Setup a new GlusterFS application
 	app := setupApp(options)
 
@@ -527,6 +531,12 @@ func main() {
 			fmt.Fprint(w, "Hello from Heketi")
 		})
 
+	// Add /metrics router if the metrics need no token. Otherwise
+	// they are served by the app, behind the authorization checks
+	if app.MetricsReadableBy("") {
+		router.Methods("GET").Path("/metrics").Name("Metrics").
+			HandlerFunc(app.Metrics)
+	}
 
 	// Enable profiling on "/debug/pprof"
 	if options.Profiling {
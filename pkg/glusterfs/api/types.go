@@ -17,12 +17,15 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"sort"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
+
+	"github.com/heketi/heketi/pkg/utils"
 )
 
 var (
@@ -37,6 +40,11 @@ var (
 	blockVolNameRe = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
 
 	tagNameRe = regexp.MustCompile("^[a-zA-Z0-9_.-]+$")
+
+	// user names as accepted by useradd and names of keys in
+	// the credentials file
+	connUserRe = regexp.MustCompile("^[a-z_][a-z0-9_-]*[$]?$")
+	connKeyRe  = regexp.MustCompile("^[a-zA-Z0-9_.-]+$")
 )
 
 // ValidateUUID is written this way because heketi UUID does not
@@ -192,11 +200,31 @@ type DeviceInfoResponse struct {
 }
 
 // Node
+// ConnectionSettings override how the server connects to a node.
+// Settings on a node take precedence over those of its cluster.
+// Empty fields keep the server's defaults.
+type ConnectionSettings struct {
+	User string `json:"user,omitempty"`
+	Port string `json:"port,omitempty"`
+	// Key is the name of a key in the server's credentials file
+	Key  string `json:"key,omitempty"`
+	Sudo *bool  `json:"sudo,omitempty"`
+}
+
+func (cs ConnectionSettings) Validate() error {
+	return validation.ValidateStruct(&cs,
+		validation.Field(&cs.User, validation.Match(connUserRe)),
+		validation.Field(&cs.Port, is.Port),
+		validation.Field(&cs.Key, validation.Match(connKeyRe)),
+	)
+}
+
 type NodeAddRequest struct {
-	Zone      int               `json:"zone"`
-	Hostnames HostAddresses     `json:"hostnames"`
-	ClusterId string            `json:"cluster"`
-	Tags      map[string]string `json:"tags,omitempty"`
+	Zone       int                 `json:"zone"`
+	Hostnames  HostAddresses       `json:"hostnames"`
+	ClusterId  string              `json:"cluster"`
+	Tags       map[string]string   `json:"tags,omitempty"`
+	Connection *ConnectionSettings `json:"connection,omitempty"`
 }
 
 func (req NodeAddRequest) Validate() error {
@@ -205,6 +233,7 @@ func (req NodeAddRequest) Validate() error {
 		validation.Field(&req.Hostnames, validation.Required),
 		validation.Field(&req.ClusterId, validation.Required, validation.By(ValidateUUID)),
 		validation.Field(&req.Tags, validation.By(ValidateTags)),
+		validation.Field(&req.Connection),
 	)
 }
 
@@ -217,6 +246,33 @@ type NodeInfoResponse struct {
 	NodeInfo
 	State       EntryState           `json:"state"`
 	DevicesInfo []DeviceInfoResponse `json:"devices"`
+	// fingerprint of the ssh host key pinned for the node
+	HostKeyFingerprint string `json:"host_key_fingerprint,omitempty"`
+	// software found on the node when it was last probed
+	Capabilities *NodeCapabilities `json:"capabilities,omitempty"`
+}
+
+// NodeCapabilities describes the versions and features of the
+// software on a node. Empty versions could not be determined.
+type NodeCapabilities struct {
+	GlusterVersion      string `json:"gluster_version"`
+	OpVersion           int    `json:"op_version"`
+	GlusterBlock        bool   `json:"gluster_block"`
+	GlusterBlockVersion string `json:"gluster_block_version,omitempty"`
+	LvmVersion          string `json:"lvm_version"`
+	LvmJson             bool   `json:"lvm_json"`
+	KernelVersion       string `json:"kernel_version"`
+	Xfs                 bool   `json:"xfs"`
+	XfsVersion          string `json:"xfs_version"`
+	// time of the probe in seconds since the epoch
+	Probed int64 `json:"probed"`
+}
+
+// NodeHostKeyRequest re-pins the ssh host key of a node. If no key
+// is given the key currently presented by the node is pinned.
+type NodeHostKeyRequest struct {
+	// key in the ssh authorized keys format
+	Key string `json:"key,omitempty"`
 }
 
 // Cluster
@@ -239,8 +295,177 @@ type TopologyInfoResponse struct {
 	ClusterList []Cluster `json:"clusters"`
 }
 
+// TopologyInfoOptions limits the topology reported by the server.
+// The zero value reports the whole topology.
+type TopologyInfoOptions struct {
+	// Cluster limits the topology to the cluster with this id
+	Cluster string
+	// Node limits the topology to the node with this id and the
+	// volumes with bricks on the node
+	Node string
+	// NoVolumes omits the volumes and block volumes
+	NoVolumes bool
+	// NoBricks omits the bricks of the devices and volumes
+	NoBricks bool
+}
+
+// TopologyFile describes the clusters, nodes and devices of a
+// topology in the format used by heketi-cli topology load.
+type TopologyFile struct {
+	Clusters []TopologyFileCluster `json:"clusters"`
+}
+
+func (tf TopologyFile) Validate() error {
+	for i, c := range tf.Clusters {
+		for j, n := range c.Nodes {
+			if err := n.Validate(); err != nil {
+				return fmt.Errorf("clusters[%v].nodes[%v]: %v", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+type TopologyFileCluster struct {
+	Nodes []TopologyFileNode `json:"nodes"`
+	Block *bool              `json:"block,omitempty"`
+	File  *bool              `json:"file,omitempty"`
+}
+
+type TopologyFileNode struct {
+	Devices []*TopologyFileDevice `json:"devices"`
+	Node    NodeAddRequest        `json:"node"`
+}
+
+// Validate checks the node as NodeAddRequest.Validate does except
+// that the cluster of the node is given by its place in the file.
+func (tfn TopologyFileNode) Validate() error {
+	node := tfn.Node
+	err := validation.ValidateStruct(&node,
+		validation.Field(&node.Zone, validation.Required, validation.Min(1)),
+		validation.Field(&node.Hostnames, validation.Required),
+		validation.Field(&node.Tags, validation.By(ValidateTags)),
+		validation.Field(&node.Connection),
+	)
+	if err != nil {
+		return err
+	}
+	for i, d := range tfn.Devices {
+		if d == nil {
+			return fmt.Errorf("devices[%v]: missing device", i)
+		}
+		if err := d.Device.Validate(); err != nil {
+			return fmt.Errorf("devices[%v]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+type TopologyFileDevice struct {
+	Device
+	DestroyData bool `json:"destroydata,omitempty"`
+}
+
+// UnmarshalJSON accepts a plain string as a device so that older
+// topology files that list the devices by name can still be used.
+func (tfd *TopologyFileDevice) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*tfd = TopologyFileDevice{}
+		tfd.Name = s
+		return nil
+	}
+
+	// decode through a type without this method to avoid recursion
+	type topologyFileDevice TopologyFileDevice
+	var d topologyFileDevice
+	if err := json.Unmarshal(b, &d); err != nil {
+		return err
+	}
+	*tfd = TopologyFileDevice(d)
+	return nil
+}
+
+// TopologyMissing sets what a topology apply does with the nodes and
+// devices that are known to the server but not in the topology.
+type TopologyMissing string
+
+const (
+	TopologyMissingKeep    TopologyMissing = "keep"
+	TopologyMissingDisable TopologyMissing = "disable"
+	TopologyMissingRemove  TopologyMissing = "remove"
+)
+
+// TopologyApplyRequest asks the server to make its topology match
+// the given topology. Nodes are matched by their first manage
+// hostname and devices by their name.
+type TopologyApplyRequest struct {
+	Topology TopologyFile `json:"topology"`
+	// Missing defaults to keeping the nodes and devices
+	Missing TopologyMissing `json:"missing,omitempty"`
+}
+
+func (tar TopologyApplyRequest) Validate() error {
+	err := validation.ValidateStruct(&tar,
+		validation.Field(&tar.Missing, validation.In(
+			TopologyMissingKeep, TopologyMissingDisable, TopologyMissingRemove)),
+	)
+	if err != nil {
+		return err
+	}
+	return tar.Topology.Validate()
+}
+
+type TopologyChangeType string
+
+const (
+	TopologyAddCluster    TopologyChangeType = "add-cluster"
+	TopologyAddNode       TopologyChangeType = "add-node"
+	TopologyAddDevice     TopologyChangeType = "add-device"
+	TopologyDisableNode   TopologyChangeType = "disable-node"
+	TopologyDisableDevice TopologyChangeType = "disable-device"
+	TopologyRemoveNode    TopologyChangeType = "remove-node"
+	TopologyRemoveDevice  TopologyChangeType = "remove-device"
+	TopologySetNodeZone   TopologyChangeType = "set-node-zone"
+	TopologySetNodeTags   TopologyChangeType = "set-node-tags"
+	TopologySetDeviceTags TopologyChangeType = "set-device-tags"
+)
+
+// TopologyChange is a single step of a topology apply. The ids of
+// clusters, nodes and devices that do not exist yet are empty. Nodes
+// added without a cluster id go in the cluster of the preceding
+// add-cluster change.
+type TopologyChange struct {
+	Change    TopologyChangeType `json:"change"`
+	ClusterId string             `json:"cluster,omitempty"`
+	NodeId    string             `json:"node,omitempty"`
+	DeviceId  string             `json:"device,omitempty"`
+	Hostname  string             `json:"hostname,omitempty"`
+	Device    string             `json:"device_name,omitempty"`
+	// the flags of a new cluster
+	Flags *ClusterFlags `json:"flags,omitempty"`
+	// the zone or tags before and after the change
+	OldZone int               `json:"old_zone,omitempty"`
+	Zone    int               `json:"zone,omitempty"`
+	OldTags map[string]string `json:"old_tags,omitempty"`
+	Tags    map[string]string `json:"tags,omitempty"`
+}
+
+// TopologyDiff lists the changes, in order, needed to make the
+// topology of the server match a topology file.
+type TopologyDiff struct {
+	Changes []TopologyChange `json:"changes"`
+}
+
 type ClusterCreateRequest struct {
 	ClusterFlags
+	Connection *ConnectionSettings `json:"connection,omitempty"`
+}
+
+func (req ClusterCreateRequest) Validate() error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Connection),
+	)
 }
 
 type ClusterSetFlagsRequest struct {
@@ -252,11 +477,16 @@ type ClusterInfoResponse struct {
 	Nodes   sort.StringSlice `json:"nodes"`
 	Volumes sort.StringSlice `json:"volumes"`
 	ClusterFlags
-	BlockVolumes sort.StringSlice `json:"blockvolumes"`
+	BlockVolumes sort.StringSlice    `json:"blockvolumes"`
+	Connection   *ConnectionSettings `json:"connection,omitempty"`
 }
 
 type ClusterListResponse struct {
 	Clusters []string `json:"clusters"`
+	// info of the clusters, if requested
+	Details []ClusterInfoResponse `json:"details,omitempty"`
+	// token of the next page, if there are more clusters
+	Continue string `json:"continue,omitempty"`
 }
 
 // Durabilities
@@ -354,6 +584,80 @@ type VolumeInfoResponse struct {
 
 type VolumeListResponse struct {
 	Volumes []string `json:"volumes"`
+	// info of the volumes, if requested
+	Details []VolumeInfoResponse `json:"details,omitempty"`
+	// token of the next page, if there are more volumes
+	Continue string `json:"continue,omitempty"`
+}
+
+// Orders of the lists
+const (
+	ListSortId   = "id"
+	ListSortName = "name"
+	ListSortSize = "size"
+)
+
+// ListOptions selects, orders and pages the items of the volume,
+// block volume and cluster lists. The zero value lists all the
+// items, ordered by id. Not every filter applies to every list.
+type ListOptions struct {
+	// Limit is the most items returned at once. Zero returns all
+	Limit int
+	// Continue is the token returned with the previous page
+	Continue string
+	// Cluster selects the volumes and block volumes of a cluster
+	Cluster string
+	// NamePrefix selects the volumes and block volumes with a name
+	// starting with the prefix
+	NamePrefix string
+	// Block selects the block hosting volumes, or clusters allowing
+	// block volumes, if true and the others if false
+	Block *bool
+	// MinSize and MaxSize, in GiB, select the volumes and block
+	// volumes within the sizes. Zero is no limit
+	MinSize int
+	MaxSize int
+	// Tag, as name or name=value, selects the items with bricks, or
+	// nodes, on devices or nodes with the tag
+	Tag string
+	// Sort is one of id, name or size, prefixed by - to reverse
+	// the order
+	Sort string
+	// Detail returns the info of each item with the list
+	Detail bool
+}
+
+type VolumeBatchCreateRequest struct {
+	Volumes []VolumeCreateRequest `json:"volumes"`
+	// AllOrNothing creates none of the volumes if any one of
+	// them can not be created
+	AllOrNothing bool `json:"all_or_nothing,omitempty"`
+}
+
+func (vbcr VolumeBatchCreateRequest) Validate() error {
+	err := validation.ValidateStruct(&vbcr,
+		validation.Field(&vbcr.Volumes, validation.Required, validation.Length(1, 100)),
+	)
+	if err != nil {
+		return err
+	}
+	for i, v := range vbcr.Volumes {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("volumes[%v]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+type VolumeBatchCreateResult struct {
+	Name  string `json:"name"`
+	Id    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type VolumeBatchCreateResponse struct {
+	Id      string                    `json:"id"`
+	Volumes []VolumeBatchCreateResult `json:"volumes"`
 }
 
 type VolumeExpandRequest struct {
@@ -430,6 +734,10 @@ type BlockVolumeInfoResponse struct {
 
 type BlockVolumeListResponse struct {
 	BlockVolumes []string `json:"blockvolumes"`
+	// info of the block volumes, if requested
+	Details []BlockVolumeInfoResponse `json:"details,omitempty"`
+	// token of the next page, if there are more block volumes
+	Continue string `json:"continue,omitempty"`
 }
 
 type LogLevelInfo struct {
@@ -601,6 +909,8 @@ type OperationsInfo struct {
 	Stale  uint64 `json:"stale"`
 	Failed uint64 `json:"failed"`
 	New    uint64 `json:"new"`
+	// in-flight operations past their deadline
+	Stuck uint64 `json:"stuck"`
 }
 
 type AdminState string
@@ -680,3 +990,126 @@ func ValidateIds(v interface{}) error {
 	}
 	return nil
 }
+
+// PlannedDeviceUsage summarizes how the storage of a device would
+// change. Sizes are in KiB.
+type PlannedDeviceUsage struct {
+	Id         string `json:"id"`
+	NodeId     string `json:"node"`
+	Name       string `json:"name"`
+	Used       int64  `json:"used"`
+	FreeBefore uint64 `json:"free_before"`
+	FreeAfter  uint64 `json:"free_after"`
+}
+
+// PlannedCommand is a command the server would run on a node.
+type PlannedCommand struct {
+	Host    string `json:"host"`
+	Command string `json:"command"`
+}
+
+// OperationPlan is returned in place of starting a change when
+// a request is made with the dry_run query parameter. SpaceUsed
+// is in KiB and negative if the change frees space.
+type OperationPlan struct {
+	Operation     string               `json:"operation"`
+	Cluster       string               `json:"cluster,omitempty"`
+	Bricks        []BrickInfo          `json:"bricks"`
+	RemovedBricks []BrickInfo          `json:"removed_bricks"`
+	Devices       []PlannedDeviceUsage `json:"devices"`
+	SpaceUsed     int64                `json:"space_used"`
+	Commands      []PlannedCommand     `json:"commands"`
+}
+
+type EventType string
+
+const (
+	EventVolumeCreated      EventType = "volume.created"
+	EventVolumeUpdated      EventType = "volume.updated"
+	EventVolumeDeleted      EventType = "volume.deleted"
+	EventBlockVolumeCreated EventType = "blockvolume.created"
+	EventBlockVolumeUpdated EventType = "blockvolume.updated"
+	EventBlockVolumeDeleted EventType = "blockvolume.deleted"
+	EventDeviceCreated      EventType = "device.created"
+	EventDeviceUpdated      EventType = "device.updated"
+	EventDeviceDeleted      EventType = "device.deleted"
+	EventNodeStateChanged   EventType = "node.state_changed"
+	EventNodeHealthChanged  EventType = "node.health_changed"
+	EventOperationStarted   EventType = "operation.started"
+	EventOperationFinished  EventType = "operation.finished"
+	EventOperationFailed    EventType = "operation.failed"
+	// EventsLost is sent, without a sequence number, when events
+	// after the requested one are no longer in the journal
+	EventsLost EventType = "events.lost"
+)
+
+// Event reports a change to a resource or an operation. Seq goes up
+// by one with each event the server records.
+type Event struct {
+	Seq  uint64    `json:"seq"`
+	Type EventType `json:"type"`
+	// seconds since the epoch
+	Time int64 `json:"time"`
+	// id of the volume, block volume, device, node or operation
+	Id string `json:"id,omitempty"`
+	// the resource is part of an operation that has not completed
+	Pending bool `json:"pending,omitempty"`
+	// state of a device or node, and the state it changed from if
+	// the state has changed
+	State    EntryState `json:"state,omitempty"`
+	OldState EntryState `json:"old_state,omitempty"`
+	// label and error of an operation
+	Label string `json:"label,omitempty"`
+	Error string `json:"error,omitempty"`
+	// health of a node
+	Up *bool `json:"up,omitempty"`
+}
+
+// The error format is defined in pkg/utils, which writes the error
+// responses of the server. The names below keep it available here.
+
+// ErrorVersion is the version of the error format.
+const ErrorVersion = utils.ErrorVersion
+
+// ErrorVersionHeader is the request header a client sets to the
+// version of the error format it reads.
+const ErrorVersionHeader = utils.ErrorVersionHeader
+
+// ErrorCode identifies the kind of an error returned by the server.
+type ErrorCode = utils.ErrorCode
+
+const (
+	ErrorCodeInvalidRequest  = utils.ErrorCodeInvalidRequest
+	ErrorCodeUnauthorized    = utils.ErrorCodeUnauthorized
+	ErrorCodeForbidden       = utils.ErrorCodeForbidden
+	ErrorCodeNotFound        = utils.ErrorCodeNotFound
+	ErrorCodeConflict        = utils.ErrorCodeConflict
+	ErrorCodeNoSpace         = utils.ErrorCodeNoSpace
+	ErrorCodeTooManyRequests = utils.ErrorCodeTooManyRequests
+	ErrorCodeUnavailable     = utils.ErrorCodeUnavailable
+	ErrorCodeInternal        = utils.ErrorCodeInternal
+)
+
+// ErrorDetail is one of the errors an error is made of.
+type ErrorDetail = utils.ErrorDetail
+
+// Error is the body of the error responses of the server.
+type Error = utils.Error
+
+// ErrorCodeForStatus returns the code of the errors returned with
+// the http status when no more specific code is known.
+func ErrorCodeForStatus(status int) ErrorCode {
+	return utils.ErrorCodeForStatus(status)
+}
+
+// NewError returns an error of the current version with the given
+// code and message.
+func NewError(code ErrorCode, message string) *Error {
+	return utils.NewError(code, message)
+}
+
+// ErrorCodeOf returns the code of an error returned by the server,
+// or an empty code for other errors.
+func ErrorCodeOf(err error) ErrorCode {
+	return utils.ErrorCodeOf(err)
+}
@@ -0,0 +1,174 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+// Package metrics writes metrics in the prometheus text exposition
+// format.
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type Type string
+
+const (
+	Gauge   Type = "gauge"
+	Counter Type = "counter"
+	// Summary families only report the sum and the count of the
+	// observed values, without quantiles
+	Summary Type = "summary"
+)
+
+// Label is a name and value pair identifying a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+type sample struct {
+	suffix string
+	labels string
+	value  float64
+}
+
+// Family is a set of samples sharing a metric name.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	samples []sample
+}
+
+// Add adds a sample with the given value and labels.
+func (f *Family) Add(value float64, labels ...Label) {
+	f.samples = append(f.samples, sample{
+		labels: formatLabels(labels),
+		value:  value,
+	})
+}
+
+// AddSummary adds the sum and the count of the values observed for
+// the given labels to a summary family.
+func (f *Family) AddSummary(sum float64, count uint64, labels ...Label) {
+	l := formatLabels(labels)
+	f.samples = append(f.samples,
+		sample{suffix: "_sum", labels: l, value: sum},
+		sample{suffix: "_count", labels: l, value: float64(count)})
+}
+
+// Set collects the metric families to be written in one exposition.
+type Set struct {
+	families map[string]*Family
+}
+
+func NewSet() *Set {
+	return &Set{
+		families: map[string]*Family{},
+	}
+}
+
+// Family returns the family of the given name, creating it if this
+// is the first use of the name.
+func (s *Set) Family(name, help string, t Type) *Family {
+	f, ok := s.families[name]
+	if !ok {
+		f = &Family{Name: name, Help: help, Type: t}
+		s.families[name] = f
+	}
+	return f
+}
+
+func (s *Set) Gauge(name, help string) *Family {
+	return s.Family(name, help, Gauge)
+}
+
+func (s *Set) Counter(name, help string) *Family {
+	return s.Family(name, help, Counter)
+}
+
+func (s *Set) Summary(name, help string) *Family {
+	return s.Family(name, help, Summary)
+}
+
+// WriteTo writes the families with at least one sample to w, in the
+// order of their names. The samples of a family are ordered by their
+// labels.
+func (s *Set) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(s.families))
+	for name, f := range s.families {
+		if len(f.samples) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, name := range names {
+		f := s.families[name]
+		bw.WriteString("# HELP " + name + " " + helpEscaper.Replace(f.Help) + "\n")
+		bw.WriteString("# TYPE " + name + " " + string(f.Type) + "\n")
+		sort.SliceStable(f.samples, func(i, j int) bool {
+			return f.samples[i].labels < f.samples[j].labels
+		})
+		for _, smp := range f.samples {
+			bw.WriteString(name + smp.suffix + smp.labels + " " +
+				formatValue(smp.value) + "\n")
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	valueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = l.Name + `="` + valueEscaper.Replace(l.Value) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
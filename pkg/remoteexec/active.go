@@ -0,0 +1,112 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package remoteexec
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ActiveCommand describes a command that a transport has started
+// on a remote host but that has not yet completed.
+type ActiveCommand struct {
+	Host    string
+	Command string
+	Started time.Time
+	// Operation is the id of the operation the command is run for
+	Operation string
+
+	cancel func()
+}
+
+// ActiveCommands keeps track of the commands currently running
+// through a transport. A nil ActiveCommands is valid and tracks
+// nothing.
+type ActiveCommands struct {
+	lock sync.Mutex
+	next uint64
+	cmds map[uint64]*ActiveCommand
+}
+
+// NewActiveCommands returns a new, empty, ActiveCommands.
+func NewActiveCommands() *ActiveCommands {
+	return &ActiveCommands{
+		cmds: map[uint64]*ActiveCommand{},
+	}
+}
+
+// Start records that the given command has started on host. The
+// optional cancel function will be called if the command is cancelled.
+// The returned value must be passed to Done once the command ends.
+func (a *ActiveCommands) Start(host string, c Cmd, cancel func()) uint64 {
+	if a == nil {
+		return 0
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.next++
+	a.cmds[a.next] = &ActiveCommand{
+		Host:      host,
+		Command:   c.String(),
+		Started:   time.Now(),
+		Operation: c.Opts().Operation,
+		cancel:    cancel,
+	}
+	return a.next
+}
+
+// Done removes a command from the set of active commands.
+func (a *ActiveCommands) Done(id uint64) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.cmds, id)
+}
+
+// List returns the currently active commands ordered by the
+// time they were started.
+func (a *ActiveCommands) List() []ActiveCommand {
+	if a == nil {
+		return nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	out := make([]ActiveCommand, 0, len(a.cmds))
+	for _, ac := range a.cmds {
+		out = append(out, *ac)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Started.Before(out[j].Started)
+	})
+	return out
+}
+
+// CancelOperation cancels all active commands run for the operation
+// with the given id and returns the number of commands that were
+// cancelled.
+func (a *ActiveCommands) CancelOperation(id string) int {
+	if a == nil || id == "" {
+		return 0
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	count := 0
+	for _, ac := range a.cmds {
+		if ac.Operation != id || ac.cancel == nil {
+			continue
+		}
+		ac.cancel()
+		count++
+	}
+	return count
+}
@@ -0,0 +1,242 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package remoteexec
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBreakerFailures    = 3
+	DefaultBreakerOpenTimeout = 30 * time.Second
+)
+
+// CircuitState is the state of the circuit to a single host.
+type CircuitState string
+
+const (
+	// CircuitClosed lets commands through to the host
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen fails commands to the host without connecting
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single connection through to find out
+	// if the host can be reached again
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+var (
+	ErrCircuitOpen = errors.New("host unreachable, not retrying yet")
+)
+
+// BreakerOptions controls when the circuit to a host opens and for
+// how long it stays open.
+type BreakerOptions struct {
+	Disabled bool
+	// Failures is the number of connection failures in a row that
+	// open the circuit to a host
+	Failures int
+	// OpenTimeout is the time the circuit stays open before a
+	// connection to the host is tried again
+	OpenTimeout time.Duration
+}
+
+// HostCircuit describes the circuit to a single host. It exists to
+// aid debugging.
+type HostCircuit struct {
+	Host  string       `json:"host"`
+	State CircuitState `json:"state"`
+	// Failures is the number of connection failures in a row
+	Failures int `json:"failures"`
+	// Trips is the number of times the circuit opened
+	Trips uint64 `json:"trips"`
+	// Rejected is the number of connections failed while open
+	Rejected    uint64    `json:"rejected"`
+	LastFailure time.Time `json:"last_failure"`
+	OpenedAt    time.Time `json:"opened_at"`
+}
+
+// BreakerStats is a snapshot of the circuits held by a transport.
+type BreakerStats struct {
+	Enabled     bool          `json:"enabled"`
+	Failures    int           `json:"failures"`
+	OpenTimeout string        `json:"open_timeout"`
+	Hosts       []HostCircuit `json:"hosts"`
+}
+
+type circuit struct {
+	HostCircuit
+	// probing is true while the single connection allowed by a
+	// half-open circuit is in progress
+	probing bool
+}
+
+// CircuitBreaker keeps a circuit per host so that a transport stops
+// trying to connect to hosts that repeatedly failed to connect. Once
+// a circuit has been open for a while a single connection is let
+// through. If it succeeds the circuit closes, otherwise it opens
+// again. A nil CircuitBreaker lets every connection through.
+type CircuitBreaker struct {
+	lock     sync.Mutex
+	opts     BreakerOptions
+	circuits map[string]*circuit
+
+	// to allow the clock to be replaced
+	now func() time.Time
+}
+
+// NewCircuitBreaker returns a new CircuitBreaker, or nil if the
+// options disable it.
+func NewCircuitBreaker(opts BreakerOptions) *CircuitBreaker {
+	if opts.Disabled {
+		return nil
+	}
+	if opts.Failures <= 0 {
+		opts.Failures = DefaultBreakerFailures
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = DefaultBreakerOpenTimeout
+	}
+	return &CircuitBreaker{
+		opts:     opts,
+		circuits: map[string]*circuit{},
+		now:      time.Now,
+	}
+}
+
+func (b *CircuitBreaker) circuit(host string) *circuit {
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{HostCircuit: HostCircuit{
+			Host:  host,
+			State: CircuitClosed,
+		}}
+		b.circuits[host] = c
+	}
+	return c
+}
+
+// state returns the state of c, moving an open circuit to half-open
+// once it has been open for long enough.
+func (b *CircuitBreaker) state(c *circuit) CircuitState {
+	if c.State == CircuitOpen &&
+		b.now().Sub(c.OpenedAt) >= b.opts.OpenTimeout {
+		c.State = CircuitHalfOpen
+		c.probing = false
+	}
+	return c.State
+}
+
+// Allow returns nil if a connection to host may be tried. Otherwise
+// it returns a ConnectionError without trying to connect. Every
+// allowed connection must be followed by a call to Success or
+// Failure.
+func (b *CircuitBreaker) Allow(host string) error {
+	if b == nil {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c, ok := b.circuits[host]
+	if !ok {
+		return nil
+	}
+	switch b.state(c) {
+	case CircuitOpen:
+	case CircuitHalfOpen:
+		if !c.probing {
+			c.probing = true
+			return nil
+		}
+	default:
+		return nil
+	}
+	c.Rejected++
+	return &ConnectionError{Host: host, Err: ErrCircuitOpen}
+}
+
+// Success records that a connection to host worked, closing the
+// circuit to the host.
+func (b *CircuitBreaker) Success(host string) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c, ok := b.circuits[host]
+	if !ok {
+		return
+	}
+	c.State = CircuitClosed
+	c.Failures = 0
+	c.probing = false
+}
+
+// Failure records that a connection to host failed. The circuit to
+// the host opens if the probe of a half-open circuit failed or if
+// there were too many failures in a row.
+func (b *CircuitBreaker) Failure(host string) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c := b.circuit(host)
+	c.Failures++
+	c.LastFailure = b.now()
+	if b.state(c) == CircuitHalfOpen || c.Failures >= b.opts.Failures {
+		if c.State != CircuitOpen {
+			c.Trips++
+		}
+		c.State = CircuitOpen
+		c.OpenedAt = c.LastFailure
+		c.probing = false
+	}
+}
+
+// State returns the state of the circuit to host.
+func (b *CircuitBreaker) State(host string) CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c, ok := b.circuits[host]
+	if !ok {
+		return CircuitClosed
+	}
+	return b.state(c)
+}
+
+// Stats returns a snapshot of the circuits of all the hosts that
+// failed to connect at some point.
+func (b *CircuitBreaker) Stats() BreakerStats {
+	if b == nil {
+		return BreakerStats{}
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	bs := BreakerStats{
+		Enabled:     true,
+		Failures:    b.opts.Failures,
+		OpenTimeout: b.opts.OpenTimeout.String(),
+		Hosts:       make([]HostCircuit, 0, len(b.circuits)),
+	}
+	for _, c := range b.circuits {
+		b.state(c)
+		bs.Hosts = append(bs.Hosts, c.HostCircuit)
+	}
+	sort.Slice(bs.Hosts, func(i, j int) bool {
+		return bs.Hosts[i].Host < bs.Hosts[j].Host
+	})
+	return bs
+}
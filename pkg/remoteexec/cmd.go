@@ -9,9 +9,16 @@
 
 package remoteexec
 
+import (
+	"regexp"
+	"strings"
+)
+
 type CmdOpts struct {
 	Quiet   bool // suppress output logging on success
 	ErrorOk bool // treat error conditions same as successes
+	// Operation is the id of the operation the command is run for
+	Operation string
 }
 
 type Cmd interface {
@@ -32,8 +39,85 @@ func (sc StringCmd) Opts() CmdOpts {
 	return sc.Options
 }
 
+// ArgvCmd is a command given as the program to run followed by its
+// arguments. Transports that can start programs directly run it
+// without a shell, so the arguments reach the program unchanged.
+// Transports that need a command line use String, which quotes any
+// argument the shell would otherwise interpret.
+type ArgvCmd struct {
+	Argv    []string
+	Options CmdOpts
+}
+
+// Args returns the program and its arguments.
+func (ac ArgvCmd) Args() []string {
+	return ac.Argv
+}
+
+func (ac ArgvCmd) String() string {
+	quoted := make([]string, len(ac.Argv))
+	for i, arg := range ac.Argv {
+		quoted[i] = ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func (ac ArgvCmd) Opts() CmdOpts {
+	return ac.Options
+}
+
+// Argver is implemented by commands that can be run without a shell.
+type Argver interface {
+	Cmd
+	Args() []string
+}
+
 type Cmds []Cmd
 
+// operationCmd marks a command as run on behalf of an operation.
+type operationCmd struct {
+	Cmd
+	operation string
+}
+
+func (oc operationCmd) Opts() CmdOpts {
+	o := oc.Cmd.Opts()
+	o.Operation = oc.operation
+	return o
+}
+
+// Args returns the argv of the command if it can be run without
+// a shell and nil otherwise.
+func (oc operationCmd) Args() []string {
+	if ac, ok := oc.Cmd.(Argver); ok {
+		return ac.Args()
+	}
+	return nil
+}
+
+// WithOperation returns the commands marked as run on behalf of
+// the operation with the given id.
+func WithOperation(commands Cmds, id string) Cmds {
+	out := make(Cmds, len(commands))
+	for i, c := range commands {
+		out[i] = operationCmd{Cmd: c, operation: id}
+	}
+	return out
+}
+
+// shellSafe matches strings that a shell leaves unchanged as a word.
+var shellSafe = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// ShellQuote returns s quoted so that a POSIX shell reads it back
+// as a single word equal to s. Strings that need no quoting are
+// returned as is.
+func ShellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
 // conversion functions
 
 // ToCmd converts a single string representing a command to a StringCmd.
@@ -50,6 +134,12 @@ func ToCmds(s []string) Cmds {
 	return c
 }
 
+// Argv returns an ArgvCmd that runs the program with the given
+// arguments.
+func Argv(args ...string) ArgvCmd {
+	return ArgvCmd{Argv: args}
+}
+
 // OneCmd converts a single string representing a command to a Cmds group
 // containing just one command.
 func OneCmd(s string) Cmds {
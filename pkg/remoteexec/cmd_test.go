@@ -0,0 +1,75 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package remoteexec
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/heketi/tests"
+)
+
+func TestShellQuote(t *testing.T) {
+	for s, expected := range map[string]string{
+		"vg_123":         "vg_123",
+		"/dev/sdb":       "/dev/sdb",
+		"size=10G":       "size=10G",
+		"":               "''",
+		"a b":            "'a b'",
+		"it's":           `'it'\''s'`,
+		"$HOME":          "'$HOME'",
+		"`id`":           "'`id`'",
+		"a;rm -rf /":     "'a;rm -rf /'",
+		"'":              `''\'''`,
+		"tab\there":      "'tab\there'",
+		"*.brick":        "'*.brick'",
+		"x$(touch y)'z'": `'x$(touch y)'\''z'\'''`,
+	} {
+		q := ShellQuote(s)
+		tests.Assert(t, q == expected,
+			"quoting", s, "expected", expected, "got", q)
+	}
+}
+
+func TestArgvCmdString(t *testing.T) {
+	ac := Argv("mkdir", "-p", "/var/lib/a b", "it's", "$HOME")
+	expected := `mkdir -p '/var/lib/a b' 'it'\''s' '$HOME'`
+	tests.Assert(t, ac.String() == expected,
+		"expected", expected, "got", ac.String())
+}
+
+func TestArgvCmdStringShellRoundTrip(t *testing.T) {
+	args := []string{"a b", "it's", "$HOME", "`id`", "'", "", "x;y", "*"}
+	ac := Argv(append([]string{"printf", "%s|"}, args...)...)
+
+	out, err := exec.Command("/bin/bash", "-c", ac.String()).Output()
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	expected := ""
+	for _, a := range args {
+		expected += a + "|"
+	}
+	tests.Assert(t, string(out) == expected,
+		"expected", expected, "got", string(out))
+}
+
+func TestWithOperationKeepsArgv(t *testing.T) {
+	cmds := WithOperation(Cmds{Argv("ls", "a b"), ToCmd("ls")}, "op1")
+
+	ac, ok := cmds[0].(Argver)
+	tests.Assert(t, ok, "expected an argv command")
+	tests.Assert(t, len(ac.Args()) == 2 && ac.Args()[1] == "a b",
+		"unexpected args", ac.Args())
+	tests.Assert(t, cmds[0].Opts().Operation == "op1",
+		"expected op1, got", cmds[0].Opts().Operation)
+
+	sc, ok := cmds[1].(Argver)
+	tests.Assert(t, ok && sc.Args() == nil, "expected no args")
+	tests.Assert(t, cmds[1].String() == "ls", "unexpected command", cmds[1])
+}
@@ -0,0 +1,33 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package remoteexec
+
+// ConnectionError is returned by a transport when it could not
+// establish or keep a connection to a remote host. Commands that
+// were not started on the host are not reflected in the results.
+// Connection errors are generally transient in nature.
+type ConnectionError struct {
+	Host string
+	Err  error
+}
+
+// Error returns the message of the original error so that
+// wrapping a transport error does not change the text seen by
+// clients.
+func (ce *ConnectionError) Error() string {
+	return ce.Err.Error()
+}
+
+// IsConnectionError returns true if the given error is a
+// ConnectionError.
+func IsConnectionError(err error) bool {
+	_, ok := err.(*ConnectionError)
+	return ok
+}
@@ -0,0 +1,176 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/heketi/heketi/pkg/logging"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+	rexlog "github.com/heketi/heketi/pkg/remoteexec/log"
+)
+
+// LocalExec runs commands on the system heketi itself is running on.
+type LocalExec struct {
+	logger *logging.Logger
+	active *rex.ActiveCommands
+	trail  *rex.CommandTrail
+}
+
+func NewLocalExec(logger *logging.Logger) *LocalExec {
+	return &LocalExec{
+		logger: logger,
+		active: rex.NewActiveCommands(),
+	}
+}
+
+// ExecCommands runs the given commands, in order, on the local system.
+// The host is only used for logging. The commands stop when ctx is
+// done or, if timeout is not zero, once they have run for longer than
+// timeout. Execution stops at the first command that fails.
+func (l *LocalExec) ExecCommands(
+	ctx context.Context, host string, commands rex.Cmds,
+	timeout time.Duration, useSudo bool) (rex.Results, error) {
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	results := make(rex.Results, len(commands))
+	cmdlog := rexlog.NewCommandLogger(l.logger)
+
+	for index, cmd := range commands {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		cmdlog.Before(cmd, host)
+
+		var b bytes.Buffer
+		var berr bytes.Buffer
+		args := []string{"-c", cmd.String()}
+		name := "/bin/bash"
+		if ac, ok := cmd.(rex.Argver); ok && len(ac.Args()) > 0 {
+			// run the program directly, without a shell
+			name, args = ac.Args()[0], ac.Args()[1:]
+		}
+		if useSudo {
+			args = append([]string{"-n", name}, args...)
+			name = "sudo"
+		}
+		c := exec.Command(name, args...)
+		c.Stdout = &b
+		c.Stderr = &berr
+		// run the command in its own process group so that stopping
+		// it also stops anything it started
+		c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+		started := time.Now()
+		err := c.Start()
+		if err != nil {
+			// like a shell on a remote host would, report a
+			// missing program as a failed command
+			l.logger.LogError("Unable to start command [%v]: %v", cmd, err)
+			r := rex.Result{
+				Completed:  true,
+				ErrOutput:  err.Error(),
+				Err:        err,
+				ExitStatus: -1,
+			}
+			l.trail.Record(rex.NewCommandRecord(host, cmd, started, r))
+			results[index] = r
+			return results, nil
+		}
+		// cancelling the command ends the wait below the same way
+		// a timeout does, so it gets reported as a cancellation
+		cmdCtx, cancelCmd := context.WithCancel(ctx)
+		activeId := l.active.Start(host, cmd, cancelCmd)
+
+		errch := make(chan error, 1)
+		go func() {
+			errch <- c.Wait()
+		}()
+
+		select {
+		case err := <-errch:
+			l.active.Done(activeId)
+			cancelCmd()
+			r := rex.Result{
+				Completed: true,
+				Output:    b.String(),
+				ErrOutput: berr.String(),
+				Err:       err,
+			}
+			if err == nil {
+				cmdlog.Success(cmd, host, r.Output, r.ErrOutput)
+			} else {
+				cmdlog.Error(cmd, err, host, r.Output, r.ErrOutput)
+				r.ExitStatus = exitStatus(err)
+			}
+			l.trail.Record(rex.NewCommandRecord(host, cmd, started, r))
+			results[index] = r
+			if r.ExitStatus != 0 {
+				// stop running commands on error
+				return results, nil
+			}
+
+		case <-cmdCtx.Done():
+			l.active.Done(activeId)
+			err := errors.New("Local command timeout")
+			if cmdCtx.Err() == context.Canceled {
+				err = errors.New("Local command cancelled")
+			}
+			if kerr := syscall.Kill(-c.Process.Pid, syscall.SIGKILL); kerr != nil {
+				l.logger.LogError("Unable to kill command [%v]: %v", cmd, kerr)
+			}
+			// the output buffers are written to until the
+			// command has been reaped
+			<-errch
+			cmdlog.Timeout(cmd, err, host, b.String(), berr.String())
+			l.trail.Record(rex.NewCommandRecord(host, cmd, started, rex.Result{
+				Output:     b.String(),
+				ErrOutput:  berr.String(),
+				Err:        err,
+				ExitStatus: -1,
+			}))
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+// SetCommandTrail sets the trail that records every command run
+// through this LocalExec.
+func (l *LocalExec) SetCommandTrail(t *rex.CommandTrail) {
+	l.trail = t
+}
+
+// ActiveCommands returns the tracker for commands currently
+// running through this LocalExec.
+func (l *LocalExec) ActiveCommands() *rex.ActiveCommands {
+	return l.active
+}
+
+// exitStatus extracts the exit code of a failed command if possible.
+func exitStatus(err error) int {
+	if ee, ok := err.(*exec.ExitError); ok {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Exited() {
+			return ws.ExitStatus()
+		}
+	}
+	return 1
+}
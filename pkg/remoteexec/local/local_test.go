@@ -0,0 +1,76 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package local
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heketi/heketi/pkg/logging"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+	"github.com/heketi/tests"
+)
+
+func TestLocalExecCancelOperation(t *testing.T) {
+	l := NewLocalExec(logging.NewLogger("[test]", logging.LEVEL_CRITICAL))
+
+	go func() {
+		// cancel once the command is running
+		for l.ActiveCommands().CancelOperation("op1") == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	start := time.Now()
+	_, err := l.ExecCommands(context.Background(), "localhost",
+		rex.WithOperation(rex.OneCmd("sleep 10"), "op1"), 0, false)
+	tests.Assert(t, err != nil, "expected err != nil")
+	tests.Assert(t, strings.Contains(err.Error(), "cancelled"),
+		"expected a cancellation, got", err)
+	tests.Assert(t, time.Since(start) < 5*time.Second,
+		"expected the command to be stopped")
+	tests.Assert(t, len(l.ActiveCommands().List()) == 0,
+		"expected no active commands")
+}
+
+func TestLocalExecTimeout(t *testing.T) {
+	l := NewLocalExec(logging.NewLogger("[test]", logging.LEVEL_CRITICAL))
+
+	// the background sleep keeps the output open unless the whole
+	// process group gets killed
+	start := time.Now()
+	r, err := l.ExecCommands(context.Background(), "localhost",
+		rex.Cmds{rex.ToCmd("echo started; sleep 10 & sleep 10")},
+		200*time.Millisecond, false)
+	tests.Assert(t, err != nil, "expected err != nil")
+	tests.Assert(t, strings.Contains(err.Error(), "timeout"),
+		"expected a timeout, got", err)
+	tests.Assert(t, time.Since(start) < 5*time.Second,
+		"expected the commands to be stopped")
+	tests.Assert(t, !r[0].Completed, "expected the command not to complete")
+
+	// later commands still run
+	r, err = l.ExecCommands(context.Background(), "localhost",
+		rex.OneCmd("echo done"), time.Second, false)
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, r[0].Output == "done\n", "unexpected output", r[0].Output)
+}
+
+func TestLocalExecArgvWithoutShell(t *testing.T) {
+	l := NewLocalExec(logging.NewLogger("[test]", logging.LEVEL_CRITICAL))
+
+	r, err := l.ExecCommands(context.Background(), "localhost",
+		rex.Cmds{rex.Argv("printf", "%s|", "a b", "it's", "$HOME", "`id`")},
+		time.Second, false)
+	tests.Assert(t, err == nil, "expected err == nil, got", err)
+	tests.Assert(t, r[0].Output == "a b|it's|$HOME|`id`|",
+		"expected the arguments unchanged, got", r[0].Output)
+}
@@ -0,0 +1,42 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package remoteexec
+
+import (
+	"time"
+)
+
+// ConnectionStats describes the pooled connection to a single host
+// held by a transport. It exists to aid debugging.
+type ConnectionStats struct {
+	Host string `json:"host"`
+	// Open is true if a connection to the host is currently held
+	Open bool `json:"open"`
+	// InUse is the number of command batches using the connection
+	InUse int `json:"in_use"`
+	// Dials is the number of connections made to the host
+	Dials uint64 `json:"dials"`
+	// Reuses is the number of times an existing connection was used
+	Reuses uint64 `json:"reuses"`
+	// Failures is the number of connections dropped due to errors
+	Failures uint64 `json:"failures"`
+	// Expired is the number of connections closed for being idle
+	Expired  uint64    `json:"expired"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// PoolStats is a snapshot of the connections held by a transport.
+type PoolStats struct {
+	Enabled           bool              `json:"enabled"`
+	IdleTimeout       string            `json:"idle_timeout"`
+	KeepaliveInterval string            `json:"keepalive_interval"`
+	KeepaliveTimeout  string            `json:"keepalive_timeout"`
+	Hosts             []ConnectionStats `json:"hosts"`
+}
@@ -0,0 +1,114 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	errHostKeyCaptured = errors.New("host key captured")
+)
+
+// HostKeyMismatchError is returned when a host presents a key that
+// differs from the key pinned for the host.
+type HostKeyMismatchError struct {
+	Host     string
+	Expected string
+	Got      string
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf(
+		"host key for %v does not match the pinned key: expected %v, got %v",
+		e.Host, e.Expected, e.Got)
+}
+
+// hostKeys holds the host keys pinned for the nodes. Hosts without
+// a pinned key are checked by the fallback callback only.
+type hostKeys struct {
+	lock   sync.RWMutex
+	pinned map[string]ssh.PublicKey
+}
+
+func newHostKeys() *hostKeys {
+	return &hostKeys{
+		pinned: map[string]ssh.PublicKey{},
+	}
+}
+
+// hostOnly strips the port from an address, if present.
+func hostOnly(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+func (hk *hostKeys) Pin(host string, key ssh.PublicKey) {
+	hk.lock.Lock()
+	defer hk.lock.Unlock()
+	if key == nil {
+		delete(hk.pinned, hostOnly(host))
+		return
+	}
+	hk.pinned[hostOnly(host)] = key
+}
+
+// Callback returns a host key callback that checks hosts against
+// their pinned keys and passes hosts without a pinned key on to the
+// fallback callback.
+func (hk *hostKeys) Callback(fallback ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		hk.lock.RLock()
+		pinned, ok := hk.pinned[hostOnly(hostname)]
+		hk.lock.RUnlock()
+		if !ok {
+			return fallback(hostname, remote, key)
+		}
+		if !bytes.Equal(pinned.Marshal(), key.Marshal()) {
+			return &HostKeyMismatchError{
+				Host:     hostOnly(hostname),
+				Expected: ssh.FingerprintSHA256(pinned),
+				Got:      ssh.FingerprintSHA256(key),
+			}
+		}
+		return nil
+	}
+}
+
+// ParseHostKey parses a host key in the authorized keys format.
+func ParseHostKey(key string) (ssh.PublicKey, error) {
+	pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	return pk, err
+}
+
+// FormatHostKey returns the host key in the authorized keys format.
+func FormatHostKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// HostKeyFingerprint returns the SHA256 fingerprint of a host key
+// in the authorized keys format, or an empty string if the key can
+// not be parsed.
+func HostKeyFingerprint(key string) string {
+	pk, err := ParseHostKey(key)
+	if err != nil {
+		return ""
+	}
+	return ssh.FingerprintSHA256(pk)
+}
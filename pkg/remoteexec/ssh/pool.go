@@ -0,0 +1,283 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package ssh
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+)
+
+const (
+	DefaultPoolIdleTimeout       = 5 * time.Minute
+	DefaultPoolKeepaliveInterval = 30 * time.Second
+	DefaultPoolKeepaliveTimeout  = 15 * time.Second
+)
+
+// PoolOptions controls the reuse of ssh connections.
+type PoolOptions struct {
+	Disabled          bool
+	IdleTimeout       time.Duration
+	KeepaliveInterval time.Duration
+	// KeepaliveTimeout is the time allowed for a host to reply to
+	// a keepalive before the connection is considered dead
+	KeepaliveTimeout time.Duration
+}
+
+var (
+	errKeepaliveTimeout = errors.New("keepalive timeout")
+)
+
+type dialFunc func(host string) (*ssh.Client, error)
+
+type pooledClient struct {
+	client   *ssh.Client
+	inUse    int
+	lastUsed time.Time
+}
+
+// clientPool keeps one long-lived ssh client per host. An ssh client
+// multiplexes sessions over a single connection so the client can
+// be shared by concurrent users.
+type clientPool struct {
+	lock    sync.Mutex
+	dial    dialFunc
+	opts    PoolOptions
+	clients map[string]*pooledClient
+	stats   map[string]*rex.ConnectionStats
+
+	// to stop the keepalive loop
+	stop     chan<- interface{}
+	stopOnce sync.Once
+}
+
+func newClientPool(dial dialFunc, opts PoolOptions) *clientPool {
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = DefaultPoolIdleTimeout
+	}
+	if opts.KeepaliveInterval == 0 {
+		opts.KeepaliveInterval = DefaultPoolKeepaliveInterval
+	}
+	if opts.KeepaliveTimeout == 0 {
+		opts.KeepaliveTimeout = DefaultPoolKeepaliveTimeout
+	}
+	return &clientPool{
+		dial:    dial,
+		opts:    opts,
+		clients: map[string]*pooledClient{},
+		stats:   map[string]*rex.ConnectionStats{},
+	}
+}
+
+func (p *clientPool) hostStats(host string) *rex.ConnectionStats {
+	st, ok := p.stats[host]
+	if !ok {
+		st = &rex.ConnectionStats{Host: host}
+		p.stats[host] = st
+	}
+	return st
+}
+
+// Get returns a client connected to host, dialing a new connection
+// if there is no pooled client for the host. Every client returned
+// by Get must be handed back with Put or Discard.
+func (p *clientPool) Get(host string) (*ssh.Client, error) {
+	p.lock.Lock()
+	if pc, ok := p.clients[host]; ok {
+		pc.inUse++
+		pc.lastUsed = time.Now()
+		p.hostStats(host).Reuses++
+		p.lock.Unlock()
+		return pc.client, nil
+	}
+	p.lock.Unlock()
+
+	// dial without holding the lock so a slow host does not
+	// block the users of other hosts
+	client, err := p.dial(host)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	st := p.hostStats(host)
+	if err != nil {
+		st.Failures++
+		return nil, err
+	}
+	st.Dials++
+	if pc, ok := p.clients[host]; ok {
+		// lost a race with another dial to the same host
+		client.Close()
+		pc.inUse++
+		pc.lastUsed = time.Now()
+		return pc.client, nil
+	}
+	p.clients[host] = &pooledClient{
+		client:   client,
+		inUse:    1,
+		lastUsed: time.Now(),
+	}
+	return client, nil
+}
+
+// Put returns a healthy client to the pool. Clients that were
+// dropped from the pool while in use are closed.
+func (p *clientPool) Put(host string, client *ssh.Client) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if pc, ok := p.clients[host]; ok && pc.client == client {
+		pc.inUse--
+		pc.lastUsed = time.Now()
+		p.hostStats(host).LastUsed = pc.lastUsed
+		return
+	}
+	client.Close()
+}
+
+// DropHost removes the clients connected to the given host, on any
+// port, from the pool. Idle clients are closed right away and
+// clients in use are closed once they are put back.
+func (p *clientPool) DropHost(host string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for addr, pc := range p.clients {
+		if hostOnly(addr) != host {
+			continue
+		}
+		delete(p.clients, addr)
+		if pc.inUse == 0 {
+			pc.client.Close()
+		}
+	}
+}
+
+// Discard closes a client that failed and removes it from the pool.
+// The next Get for the host will dial a new connection.
+func (p *clientPool) Discard(host string, client *ssh.Client) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if pc, ok := p.clients[host]; ok && pc.client == client {
+		delete(p.clients, host)
+		p.hostStats(host).Failures++
+	}
+	client.Close()
+}
+
+// Start creates a background goroutine that checks idle clients
+// with keepalive requests and closes clients that have been idle
+// for too long.
+func (p *clientPool) Start() {
+	ticker := time.NewTicker(p.opts.KeepaliveInterval)
+	stop := make(chan interface{})
+	p.stop = stop
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.expire()
+				p.keepalive()
+			}
+		}
+	}()
+}
+
+// Stop the background goroutine and close all pooled clients.
+// Stopping a pool more than once has no effect.
+func (p *clientPool) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for host, pc := range p.clients {
+		pc.client.Close()
+		delete(p.clients, host)
+	}
+}
+
+func (p *clientPool) expire() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := time.Now()
+	for host, pc := range p.clients {
+		if pc.inUse == 0 && now.Sub(pc.lastUsed) > p.opts.IdleTimeout {
+			pc.client.Close()
+			delete(p.clients, host)
+			p.hostStats(host).Expired++
+		}
+	}
+}
+
+func (p *clientPool) keepalive() {
+	p.lock.Lock()
+	clients := map[string]*ssh.Client{}
+	for host, pc := range p.clients {
+		clients[host] = pc.client
+	}
+	p.lock.Unlock()
+
+	// requests are sent outside of the lock as a dead
+	// connection may take a while to fail. connections in use
+	// are checked too so commands on a dead host fail early
+	// rather than waiting for the command timeout.
+	for host, client := range clients {
+		if err := p.ping(client); err != nil {
+			p.Discard(host, client)
+		}
+	}
+}
+
+func (p *clientPool) ping(client *ssh.Client) error {
+	errch := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		errch <- err
+	}()
+	select {
+	case err := <-errch:
+		return err
+	case <-time.After(p.opts.KeepaliveTimeout):
+		// closing the client by discarding it unblocks the request
+		return errKeepaliveTimeout
+	}
+}
+
+// Stats returns a snapshot of the pool's connections.
+func (p *clientPool) Stats() rex.PoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	ps := rex.PoolStats{
+		Enabled:           true,
+		IdleTimeout:       p.opts.IdleTimeout.String(),
+		KeepaliveInterval: p.opts.KeepaliveInterval.String(),
+		KeepaliveTimeout:  p.opts.KeepaliveTimeout.String(),
+		Hosts:             make([]rex.ConnectionStats, 0, len(p.stats)),
+	}
+	for host, st := range p.stats {
+		s := *st
+		if pc, ok := p.clients[host]; ok {
+			s.Open = true
+			s.InUse = pc.inUse
+		}
+		ps.Hosts = append(ps.Hosts, s)
+	}
+	sort.Slice(ps.Hosts, func(i, j int) bool {
+		return ps.Hosts[i].Host < ps.Hosts[j].Host
+	})
+	return ps
+}
@@ -11,13 +11,16 @@ package ssh
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -32,8 +35,23 @@ import (
 type SshExec struct {
 	clientConfig *ssh.ClientConfig
 	logger       *logging.Logger
+	active       *rex.ActiveCommands
+	trail        *rex.CommandTrail
+	pool         *clientPool
+	breaker      *rex.CircuitBreaker
+	hostKeys     *hostKeys
+	knownHosts   ssh.HostKeyCallback
+	dialTimeout  time.Duration
+
+	// client configs of hosts with their own user or key
+	hostLock    sync.RWMutex
+	hostConfigs map[string]*ssh.ClientConfig
 }
 
+const (
+	DefaultDialTimeout = 30 * time.Second
+)
+
 func getKeyFile(file string) (key ssh.Signer, err error) {
 	buf, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -50,6 +68,10 @@ func NewSshExecWithAuth(logger *logging.Logger, user string) *SshExec {
 
 	sshexec := &SshExec{}
 	sshexec.logger = logger
+	sshexec.active = rex.NewActiveCommands()
+	sshexec.hostKeys = newHostKeys()
+	sshexec.knownHosts = getHostKeyCallback()
+	sshexec.dialTimeout = DefaultDialTimeout
 
 	authSocket := os.Getenv("SSH_AUTH_SOCK")
 	if authSocket == "" {
@@ -73,7 +95,7 @@ func NewSshExecWithAuth(logger *logging.Logger, user string) *SshExec {
 	sshexec.clientConfig = &ssh.ClientConfig{
 		User:            user,
 		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
-		HostKeyCallback: getHostKeyCallback(),
+		HostKeyCallback: sshexec.hostKeys.Callback(sshexec.knownHosts),
 	}
 
 	return sshexec
@@ -86,6 +108,10 @@ func NewSshExecWithKeyFile(logger *logging.Logger, user string, file string) *Ss
 
 	sshexec := &SshExec{}
 	sshexec.logger = logger
+	sshexec.active = rex.NewActiveCommands()
+	sshexec.hostKeys = newHostKeys()
+	sshexec.knownHosts = getHostKeyCallback()
+	sshexec.dialTimeout = DefaultDialTimeout
 
 	// Now in the main function DO:
 	if key, err = getKeyFile(file); err != nil {
@@ -98,7 +124,7 @@ func NewSshExecWithKeyFile(logger *logging.Logger, user string, file string) *Ss
 		Auth: []ssh.AuthMethod{
 			ssh.PublicKeys(key),
 		},
-		HostKeyCallback: getHostKeyCallback(),
+		HostKeyCallback: sshexec.hostKeys.Callback(sshexec.knownHosts),
 	}
 
 	return sshexec
@@ -121,36 +147,64 @@ func getHostKeyCallback() ssh.HostKeyCallback {
 // This function was based from https://github.com/coreos/etcd-manager/blob/master/main.go
 func (s *SshExec) ConnectAndExec(host string, commands []string, timeoutMinutes int, useSudo bool) ([]string, error) {
 
-	results, err := s.ExecCommands(host, rex.ToCmds(commands), timeoutMinutes, useSudo)
+	results, err := s.ExecCommands(context.Background(), host,
+		rex.ToCmds(commands), time.Minute*time.Duration(timeoutMinutes), useSudo)
 	if err != nil {
 		return nil, err
 	}
 	return results.SquashErrors()
 }
 
+// ExecCommands runs the given commands, in order, on host. The
+// commands stop when ctx is done or, if timeout is not zero, once
+// they have run for longer than timeout. Execution stops at the
+// first command that fails.
 func (s *SshExec) ExecCommands(
-	host string, commands rex.Cmds,
-	timeoutMinutes int, useSudo bool) (rex.Results, error) {
+	ctx context.Context, host string, commands rex.Cmds,
+	timeout time.Duration, useSudo bool) (rex.Results, error) {
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	results := make(rex.Results, len(commands))
 	cmdlog := rexlog.NewCommandLogger(s.logger)
 
-	// :TODO: Will need a timeout here in case the server does not respond
-	client, err := ssh.Dial("tcp", host, s.clientConfig)
+	client, err := s.connect(host)
 	if err != nil {
-		s.logger.Warning("Failed to create SSH connection to %v: %v", host, err)
 		return nil, err
 	}
-	defer client.Close()
+	broken := false
+	defer func() {
+		s.releaseClient(host, client, broken)
+	}()
 
 	// Execute each command
 	for index, cmd := range commands {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
 		cmdlog.Before(cmd, host)
 
 		session, err := client.NewSession()
+		if err != nil && s.pool != nil {
+			// the pooled connection may have gone stale, reconnect once
+			s.logger.Warning("Reconnecting to %v: %v", host, err)
+			s.pool.Discard(host, client)
+			client, err = s.connect(host)
+			if err != nil {
+				client = nil
+				return nil, err
+			}
+			session, err = client.NewSession()
+		}
 		if err != nil {
 			s.logger.LogError("Unable to create SSH session: %v", err)
-			return nil, err
+			s.breaker.Failure(hostOnly(host))
+			broken = true
+			return nil, &rex.ConnectionError{Host: host, Err: err}
 		}
 		defer session.Close()
 
@@ -164,30 +218,41 @@ func (s *SshExec) ExecCommands(
 		if useSudo {
 			command = "sudo " + command
 		}
-		// Execute command in a shell
+		// Execute command in a shell. The ssh protocol always hands
+		// the remote side a command line, so commands given as argv
+		// are run through the shell using their quoted form.
 		command = "/bin/bash -c '" +
 			// Escape single quotes in commands (' -> '\'')
 			strings.Replace(command, `'`, `'\''`, -1) +
 			"'"
 
 		// Execute command
+		started := time.Now()
 		err = session.Start(command)
 		if err != nil {
+			s.trail.Record(rex.NewCommandRecord(host, cmd, started, rex.Result{
+				Err:        err,
+				ExitStatus: -1,
+			}))
 			return nil, err
 		}
-
-		// Spawn function to wait for results
-		errch := make(chan error)
+		// cancelling the command ends the wait below the same way
+		// a timeout does, so it gets reported as a cancellation
+		cmdCtx, cancelCmd := context.WithCancel(ctx)
+		activeId := s.active.Start(host, cmd, cancelCmd)
+
+		// Spawn function to wait for results. The channel is
+		// buffered so the function can end after a cancellation.
+		errch := make(chan error, 1)
 		go func() {
 			errch <- session.Wait()
 		}()
 
-		// Set the timeout
-		timeout := time.After(time.Minute * time.Duration(timeoutMinutes))
-
-		// Wait for either the command completion or timeout
+		// Wait for either the command completion or the end of ctx
 		select {
 		case err := <-errch:
+			s.active.Done(activeId)
+			cancelCmd()
 			r := rex.Result{
 				Completed: true,
 				Output:    b.String(),
@@ -205,6 +270,7 @@ func (s *SshExec) ExecCommands(
 					r.ExitStatus = 1
 				}
 			}
+			s.trail.Record(rex.NewCommandRecord(host, cmd, started, r))
 			results[index] = r
 			if r.ExitStatus != 0 {
 				// stop running commands on error
@@ -212,16 +278,281 @@ func (s *SshExec) ExecCommands(
 				return results, nil
 			}
 
-		case <-timeout:
-			cmdlog.Timeout(cmd, err, host, b.String(), berr.String())
-			err := session.Signal(ssh.SIGKILL)
-			if err != nil {
+		case <-cmdCtx.Done():
+			s.active.Done(activeId)
+			err := errors.New("SSH command timeout")
+			if cmdCtx.Err() == context.Canceled {
+				err = errors.New("SSH command cancelled")
+			}
+			if kerr := session.Signal(ssh.SIGKILL); kerr != nil {
 				s.logger.LogError("Unable to send kill signal to command [%v] on host [%v]: %v",
-					command, host, err)
+					command, host, kerr)
 			}
-			return results, errors.New("SSH command timeout")
+			// closing the session ends the wait, after which the
+			// output buffers are no longer written to
+			session.Close()
+			<-errch
+			cmdlog.Timeout(cmd, err, host, b.String(), berr.String())
+			s.trail.Record(rex.NewCommandRecord(host, cmd, started, rex.Result{
+				Output:     b.String(),
+				ErrOutput:  berr.String(),
+				Err:        err,
+				ExitStatus: -1,
+			}))
+			return results, err
 		}
 	}
 
 	return results, nil
 }
+
+// EnablePool makes the SshExec keep connections open between
+// calls to ExecCommands. Connections are checked with keepalive
+// requests and closed once they have been idle for too long.
+func (s *SshExec) EnablePool(opts PoolOptions) {
+	if opts.Disabled || s.pool != nil {
+		return
+	}
+	s.pool = newClientPool(s.dial, opts)
+	s.pool.Start()
+}
+
+// Close closes the pooled connections and stops checking them.
+func (s *SshExec) Close() error {
+	if s.pool != nil {
+		s.pool.Stop()
+	}
+	return nil
+}
+
+// PoolStats returns the state of the pooled connections.
+func (s *SshExec) PoolStats() rex.PoolStats {
+	if s.pool == nil {
+		return rex.PoolStats{}
+	}
+	return s.pool.Stats()
+}
+
+// EnableBreaker makes the SshExec stop connecting to hosts that
+// failed to connect repeatedly, failing the commands for those hosts
+// right away until a new connection is let through.
+func (s *SshExec) EnableBreaker(opts rex.BreakerOptions) {
+	if s.breaker != nil {
+		return
+	}
+	s.breaker = rex.NewCircuitBreaker(opts)
+}
+
+// BreakerStats returns the state of the circuits to the hosts.
+func (s *SshExec) BreakerStats() rex.BreakerStats {
+	return s.breaker.Stats()
+}
+
+// HostCircuit returns the state of the circuit to the given host.
+func (s *SshExec) HostCircuit(host string) rex.CircuitState {
+	return s.breaker.State(hostOnly(host))
+}
+
+// SetDialTimeout sets the time allowed to connect to a host and
+// complete the ssh handshake. Zero means no timeout.
+func (s *SshExec) SetDialTimeout(d time.Duration) {
+	s.dialTimeout = d
+}
+
+// SetHostSettings connects to the given host as a different user or
+// with a different private key than the defaults. Empty values keep
+// the defaults.
+func (s *SshExec) SetHostSettings(host, user, keyfile string) error {
+	host = hostOnly(host)
+	if user == "" && keyfile == "" {
+		s.setHostConfig(host, nil)
+		return nil
+	}
+	config := *s.clientConfig
+	if user != "" {
+		config.User = user
+	}
+	if keyfile != "" {
+		key, err := getKeyFile(keyfile)
+		if err != nil {
+			return fmt.Errorf("Unable to get keyfile for %v: %v", host, err)
+		}
+		config.Auth = []ssh.AuthMethod{ssh.PublicKeys(key)}
+	}
+	s.setHostConfig(host, &config)
+	return nil
+}
+
+func (s *SshExec) setHostConfig(host string, config *ssh.ClientConfig) {
+	s.hostLock.Lock()
+	if s.hostConfigs == nil {
+		s.hostConfigs = map[string]*ssh.ClientConfig{}
+	}
+	if config == nil {
+		delete(s.hostConfigs, host)
+	} else {
+		s.hostConfigs[host] = config
+	}
+	s.hostLock.Unlock()
+
+	// connections made with the old settings must not be reused
+	if s.pool != nil {
+		s.pool.DropHost(host)
+	}
+}
+
+// configFor returns the client config used to connect to addr.
+func (s *SshExec) configFor(addr string) *ssh.ClientConfig {
+	s.hostLock.RLock()
+	defer s.hostLock.RUnlock()
+	if config, ok := s.hostConfigs[hostOnly(addr)]; ok {
+		return config
+	}
+	return s.clientConfig
+}
+
+func (s *SshExec) dial(host string) (*ssh.Client, error) {
+	return s.dialWithConfig(host, s.configFor(host))
+}
+
+func (s *SshExec) dialWithConfig(
+	host string, config *ssh.ClientConfig) (*ssh.Client, error) {
+
+	conn, err := net.DialTimeout("tcp", host, s.dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	// the deadline covers the ssh handshake too. it is cleared
+	// once the connection is established
+	if s.dialTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.dialTimeout))
+	}
+	// the ssh package flattens the errors of the host key check
+	// into a string, keep the original to return to the caller
+	var keyErr error
+	checked := *config
+	checked.HostKeyCallback = func(
+		hostname string, remote net.Addr, key ssh.PublicKey) error {
+
+		err := config.HostKeyCallback(hostname, remote, key)
+		if _, ok := err.(*HostKeyMismatchError); ok {
+			keyErr = err
+		}
+		return err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, host, &checked)
+	if err != nil {
+		conn.Close()
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// PinHostKey pins the host key, in authorized keys format, that the
+// given host must present. An empty key removes the pinned key.
+func (s *SshExec) PinHostKey(host, key string) error {
+	if key == "" {
+		s.hostKeys.Pin(host, nil)
+		return nil
+	}
+	pk, err := ParseHostKey(key)
+	if err != nil {
+		return fmt.Errorf("invalid host key for %v: %v", host, err)
+	}
+	s.hostKeys.Pin(host, pk)
+	return nil
+}
+
+// ScanHostKey connects to host and returns the host key, in
+// authorized keys format, that the host presents. The key is checked
+// against the known hosts but not against the key pinned for host.
+func (s *SshExec) ScanHostKey(host string) (string, error) {
+	var captured ssh.PublicKey
+	base := s.configFor(host)
+	config := *base
+	config.HostKeyCallback = func(
+		hostname string, remote net.Addr, key ssh.PublicKey) error {
+
+		// the key pinned for the host, if any, is not checked so
+		// that a node with a new key can be scanned while other
+		// connections to it still require the old key
+		err := s.knownHosts(hostname, remote, key)
+		if err != nil {
+			return err
+		}
+		captured = key
+		// no need to authenticate once the key is known
+		return errHostKeyCaptured
+	}
+
+	client, err := s.dialWithConfig(host, &config)
+	if client != nil {
+		client.Close()
+	}
+	if captured != nil {
+		return FormatHostKey(captured), nil
+	}
+	return "", &rex.ConnectionError{Host: host, Err: err}
+}
+
+// getClient returns a pooled client for host or, if pooling is not
+// enabled, a new client.
+// connect returns a client for host if the circuit breaker of the
+// host lets the connection be attempted, and reports the outcome of
+// the attempt to the breaker.
+func (s *SshExec) connect(host string) (*ssh.Client, error) {
+	if err := s.breaker.Allow(hostOnly(host)); err != nil {
+		s.logger.Warning("Not connecting to %v: %v", host, err)
+		return nil, err
+	}
+	client, err := s.getClient(host)
+	if _, ok := err.(*HostKeyMismatchError); ok {
+		// not a connection problem, retrying will not help
+		s.breaker.Success(hostOnly(host))
+		s.logger.LogError("Refusing SSH connection to %v: %v", host, err)
+		return nil, err
+	} else if err != nil {
+		s.breaker.Failure(hostOnly(host))
+		s.logger.Warning("Failed to create SSH connection to %v: %v", host, err)
+		return nil, &rex.ConnectionError{Host: host, Err: err}
+	}
+	s.breaker.Success(hostOnly(host))
+	return client, nil
+}
+
+func (s *SshExec) getClient(host string) (*ssh.Client, error) {
+	if s.pool == nil {
+		return s.dial(host)
+	}
+	return s.pool.Get(host)
+}
+
+// releaseClient hands a client obtained from getClient back to the
+// pool, closing it if it is not pooled or no longer usable.
+func (s *SshExec) releaseClient(host string, client *ssh.Client, broken bool) {
+	switch {
+	case client == nil:
+	case s.pool == nil:
+		client.Close()
+	case broken:
+		s.pool.Discard(host, client)
+	default:
+		s.pool.Put(host, client)
+	}
+}
+
+// SetCommandTrail sets the trail that records every command run
+// through this SshExec.
+func (s *SshExec) SetCommandTrail(t *rex.CommandTrail) {
+	s.trail = t
+}
+
+// ActiveCommands returns the tracker for commands currently
+// running through this SshExec.
+func (s *SshExec) ActiveCommands() *rex.ActiveCommands {
+	return s.active
+}
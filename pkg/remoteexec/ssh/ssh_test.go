@@ -0,0 +1,40 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package ssh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/heketi/heketi/pkg/logging"
+	rex "github.com/heketi/heketi/pkg/remoteexec"
+	"github.com/heketi/tests"
+)
+
+func TestSshExecConnectOpenCircuit(t *testing.T) {
+	s := &SshExec{
+		logger: logging.NewLogger("[test]", logging.LEVEL_CRITICAL),
+		breaker: rex.NewCircuitBreaker(rex.BreakerOptions{
+			Failures:    1,
+			OpenTimeout: time.Hour,
+		}),
+	}
+	s.breaker.Failure("h1")
+
+	// an open circuit refuses the connection without dialing
+	client, err := s.connect("h1:22")
+	tests.Assert(t, client == nil, "expected no client")
+	cerr, ok := err.(*rex.ConnectionError)
+	tests.Assert(t, ok, "expected a connection error, got", err)
+	tests.Assert(t, cerr.Err == rex.ErrCircuitOpen,
+		"expected", rex.ErrCircuitOpen, "got", cerr.Err)
+	tests.Assert(t, s.breaker.Stats().Hosts[0].Rejected == 1,
+		"expected a rejected connection")
+}
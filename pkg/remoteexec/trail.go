@@ -0,0 +1,284 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package remoteexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTrailSize        = 1000
+	DefaultTrailOutputLimit = 4096
+)
+
+// CommandRecord describes a command that a transport has run.
+type CommandRecord struct {
+	Time       time.Time `json:"time"`
+	Host       string    `json:"host"`
+	Command    string    `json:"command"`
+	Operation  string    `json:"operation,omitempty"`
+	ExitStatus int       `json:"exit_status"`
+	Duration   string    `json:"duration"`
+	Stdout     string    `json:"stdout"`
+	Stderr     string    `json:"stderr"`
+	Error      string    `json:"error,omitempty"`
+
+	elapsed time.Duration
+}
+
+// HostCommandStats counts the commands run on a single host since
+// the trail was created.
+type HostCommandStats struct {
+	Host     string
+	Commands uint64
+	// Errors is the number of commands that failed or timed out
+	Errors   uint64
+	Duration time.Duration
+}
+
+// NewCommandRecord returns the record of a command that was started
+// on host at the given time and ended with result r.
+func NewCommandRecord(
+	host string, c Cmd, started time.Time, r Result) CommandRecord {
+
+	elapsed := time.Since(started)
+	cr := CommandRecord{
+		Time:       started,
+		Host:       host,
+		Command:    c.String(),
+		Operation:  c.Opts().Operation,
+		ExitStatus: r.ExitStatus,
+		Duration:   elapsed.String(),
+		Stdout:     r.Output,
+		Stderr:     r.ErrOutput,
+		elapsed:    elapsed,
+	}
+	if r.Err != nil {
+		cr.Error = r.Err.Error()
+	}
+	if c.Opts().Quiet && r.Ok() {
+		cr.Stdout = "(filtered)"
+	}
+	return cr
+}
+
+// CommandTrail keeps the most recent commands run through the
+// transports in memory and optionally appends them to a file that is
+// rotated once it grows too large. A nil CommandTrail is valid and
+// records nothing.
+type CommandTrail struct {
+	lock        sync.Mutex
+	records     []CommandRecord
+	next        int
+	full        bool
+	outputLimit int
+	hosts       map[string]*HostCommandStats
+
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	fileSize   int64
+}
+
+// NewCommandTrail returns a trail that keeps up to size records and
+// truncates the output of commands to outputLimit bytes.
+func NewCommandTrail(size, outputLimit int) *CommandTrail {
+	if size <= 0 {
+		size = DefaultTrailSize
+	}
+	if outputLimit <= 0 {
+		outputLimit = DefaultTrailOutputLimit
+	}
+	return &CommandTrail{
+		records:     make([]CommandRecord, size),
+		outputLimit: outputLimit,
+		hosts:       map[string]*HostCommandStats{},
+	}
+}
+
+// SetFile makes the trail append each record, as a line of json, to
+// the file at path. Once the file is larger than maxBytes it is
+// renamed with a numeric suffix and up to maxBackups old files are
+// kept. A maxBytes of zero disables rotation.
+func (t *CommandTrail) SetFile(path string, maxBytes int64, maxBackups int) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.file != nil {
+		t.file.Close()
+	}
+	t.path = path
+	t.maxBytes = maxBytes
+	t.maxBackups = maxBackups
+	t.file = f
+	t.fileSize = st.Size()
+	return nil
+}
+
+// Close closes the file of the trail, if any.
+func (t *CommandTrail) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	return err
+}
+
+// Record adds a command record to the trail.
+func (t *CommandTrail) Record(cr CommandRecord) {
+	if t == nil {
+		return
+	}
+	cr.Stdout = truncate(cr.Stdout, t.outputLimit)
+	cr.Stderr = truncate(cr.Stderr, t.outputLimit)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.records[t.next] = cr
+	t.next = (t.next + 1) % len(t.records)
+	if t.next == 0 {
+		t.full = true
+	}
+	hs, ok := t.hosts[cr.Host]
+	if !ok {
+		hs = &HostCommandStats{Host: cr.Host}
+		t.hosts[cr.Host] = hs
+	}
+	hs.Commands++
+	hs.Duration += cr.elapsed
+	if cr.ExitStatus != 0 || cr.Error != "" {
+		hs.Errors++
+	}
+	if t.file != nil {
+		t.write(cr)
+	}
+}
+
+// List returns the records, oldest first, that match the host and
+// operation id. An empty host or operation matches any value.
+func (t *CommandTrail) List(host, operation string) []CommandRecord {
+	out := []CommandRecord{}
+	if t == nil {
+		return out
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	start, count := 0, t.next
+	if t.full {
+		start, count = t.next, len(t.records)
+	}
+	for i := 0; i < count; i++ {
+		cr := t.records[(start+i)%len(t.records)]
+		if host != "" && cr.Host != host {
+			continue
+		}
+		if operation != "" && cr.Operation != operation {
+			continue
+		}
+		out = append(out, cr)
+	}
+	return out
+}
+
+// HostStats returns the number of commands run on each host, ordered
+// by host. Unlike the records these are never dropped.
+func (t *CommandTrail) HostStats() []HostCommandStats {
+	out := []HostCommandStats{}
+	if t == nil {
+		return out
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, hs := range t.hosts {
+		out = append(out, *hs)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Host < out[j].Host
+	})
+	return out
+}
+
+// write appends a record to the file. Errors writing the file must
+// not fail commands, so they are reported on stderr and the file is
+// dropped.
+func (t *CommandTrail) write(cr CommandRecord) {
+	b, err := json.Marshal(cr)
+	if err == nil {
+		b = append(b, '\n')
+		var n int
+		n, err = t.file.Write(b)
+		t.fileSize += int64(n)
+	}
+	if err == nil && t.maxBytes > 0 && t.fileSize >= t.maxBytes {
+		err = t.rotate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr,
+			"command trail: unable to write %v: %v\n", t.path, err)
+		if t.file != nil {
+			t.file.Close()
+			t.file = nil
+		}
+	}
+}
+
+func (t *CommandTrail) rotate() error {
+	if err := t.file.Close(); err != nil {
+		return err
+	}
+	t.file = nil
+	if t.maxBackups > 0 {
+		for i := t.maxBackups - 1; i > 0; i-- {
+			older := fmt.Sprintf("%v.%v", t.path, i)
+			if _, err := os.Stat(older); err == nil {
+				os.Rename(older, fmt.Sprintf("%v.%v", t.path, i+1))
+			}
+		}
+		if err := os.Rename(t.path, t.path+".1"); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(
+		t.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	t.file = f
+	t.fileSize = 0
+	return nil
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + fmt.Sprintf("... (%v bytes truncated)", len(s)-limit)
+}
@@ -0,0 +1,117 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package utils
+
+import (
+	"net/http"
+)
+
+// ErrorVersion is the version of the error format below.
+const ErrorVersion = 1
+
+// ErrorVersionHeader is the request header a client sets to the
+// version of the error format it reads. The server replies to
+// requests with the header, or that accept json, with errors in
+// the json format below.
+const ErrorVersionHeader = "X-Heketi-Error-Version"
+
+// ErrorCode identifies the kind of an error returned by the server.
+type ErrorCode string
+
+const (
+	ErrorCodeInvalidRequest  ErrorCode = "invalid_request"
+	ErrorCodeUnauthorized    ErrorCode = "unauthorized"
+	ErrorCodeForbidden       ErrorCode = "forbidden"
+	ErrorCodeNotFound        ErrorCode = "not_found"
+	ErrorCodeConflict        ErrorCode = "conflict"
+	ErrorCodeNoSpace         ErrorCode = "no_space"
+	ErrorCodeTooManyRequests ErrorCode = "too_many_requests"
+	ErrorCodeUnavailable     ErrorCode = "unavailable"
+	ErrorCodeInternal        ErrorCode = "internal"
+)
+
+// Retryable returns true if a request that failed with the code may
+// succeed if it is sent again later.
+func (c ErrorCode) Retryable() bool {
+	switch c {
+	case ErrorCodeTooManyRequests, ErrorCodeUnavailable:
+		return true
+	}
+	return false
+}
+
+// ErrorCodeForStatus returns the code of the errors returned with
+// the http status when no more specific code is known.
+func ErrorCodeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrorCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusConflict:
+		return ErrorCodeConflict
+	case http.StatusTooManyRequests:
+		return ErrorCodeTooManyRequests
+	case http.StatusServiceUnavailable:
+		return ErrorCodeUnavailable
+	}
+	return ErrorCodeInternal
+}
+
+// ErrorDetail is one of the errors an error is made of, such as the
+// error of one of the clusters a volume could not be created on.
+type ErrorDetail struct {
+	Cluster string `json:"cluster,omitempty"`
+	Host    string `json:"host,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error is the body of the error responses of the server.
+type Error struct {
+	Version   int           `json:"version"`
+	Code      ErrorCode     `json:"code"`
+	Message   string        `json:"message"`
+	Details   []ErrorDetail `json:"details,omitempty"`
+	Retryable bool          `json:"retryable"`
+
+	// http status of the response the error was read from
+	StatusCode int `json:"-"`
+}
+
+// NewError returns an error of the current version with the given
+// code and message.
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{
+		Version:   ErrorVersion,
+		Code:      code,
+		Message:   message,
+		Retryable: code.Retryable(),
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCodeOf returns the code of an error returned by the server,
+// or an empty code for other errors.
+func ErrorCodeOf(err error) ErrorCode {
+	if e, ok := err.(*Error); ok {
+		return e.Code
+	}
+	return ""
+}
@@ -13,7 +13,7 @@
 package utils
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -31,7 +31,9 @@ func GetStringFromResponse(r *http.Response) (string, error) {
 	return string(body), nil
 }
 
-// Return the body from a response as an error
+// Return the body from a response as an error. Errors in the json
+// error format are returned as is, other bodies become the message
+// of an error with the code of the status of the response.
 func GetErrorFromResponse(r *http.Response) error {
 	s, err := GetStringFromResponse(r)
 	if err != nil {
@@ -40,7 +42,21 @@ func GetErrorFromResponse(r *http.Response) error {
 
 	s = strings.TrimSpace(s)
 	if len(s) == 0 {
-		return fmt.Errorf("server did not provide a message (status %v: %v)", r.StatusCode, http.StatusText(r.StatusCode))
+		s = fmt.Sprintf("server did not provide a message (status %v: %v)", r.StatusCode, http.StatusText(r.StatusCode))
+	} else {
+		var e Error
+		if json.Unmarshal([]byte(s), &e) == nil && e.Version > 0 && e.Code != "" {
+			e.StatusCode = r.StatusCode
+			return &e
+		}
+	}
+
+	// plain text errors, from servers older than the json format
+	code := ErrorCodeForStatus(r.StatusCode)
+	return &Error{
+		Code:       code,
+		Message:    s,
+		Retryable:  code.Retryable(),
+		StatusCode: r.StatusCode,
 	}
-	return errors.New(s)
 }
@@ -0,0 +1,99 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HttpError replies to the request with the message and the http
+// status. The code of the error is the one of the status. It is used
+// in place of http.Error.
+func HttpError(w http.ResponseWriter, message string, status int) {
+	WriteError(w, NewError(ErrorCodeForStatus(status), message), status)
+}
+
+// WriteError replies to the request with the error and the http
+// status. Requests that went through NegotiateErrors and asked for
+// json get the error in the json error format, where errors other
+// than *Error get the code of the status. Other requests get the
+// message as plain text, like from http.Error.
+func WriteError(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, ok := w.(*jsonErrorWriter); !ok {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+
+	var e Error
+	if ae, ok := err.(*Error); ok {
+		e = *ae
+		e.Version = ErrorVersion
+	} else {
+		e = *NewError(ErrorCodeForStatus(status), err.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		panic(err)
+	}
+}
+
+// WantsJsonErrors returns true if the client of the request reads
+// errors in the json error format, because it accepts json or sent
+// the version of the error format it reads.
+func WantsJsonErrors(r *http.Request) bool {
+	if r.Header.Get(ErrorVersionHeader) != "" {
+		return true
+	}
+	for _, accept := range r.Header["Accept"] {
+		for _, t := range strings.Split(accept, ",") {
+			if i := strings.Index(t, ";"); i >= 0 {
+				t = t[:i]
+			}
+			if strings.EqualFold(strings.TrimSpace(t), "application/json") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NegotiateErrors is a negroni middleware that makes HttpError and
+// WriteError reply in the json error format to the requests that
+// ask for it. It has to run before the handlers that write errors.
+func NegotiateErrors(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if WantsJsonErrors(r) {
+		w = &jsonErrorWriter{w}
+	}
+	next(w, r)
+}
+
+// jsonErrorWriter marks the response to a request that reads errors
+// in the json error format.
+type jsonErrorWriter struct {
+	http.ResponseWriter
+}
+
+// Flush keeps streamed responses working through the writer.
+func (w *jsonErrorWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
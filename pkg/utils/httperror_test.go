@@ -0,0 +1,136 @@
+//
+// Copyright (c) 2019 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/heketi/tests"
+)
+
+func negotiated(r *http.Request, h http.HandlerFunc) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	NegotiateErrors(w, r, h)
+	return w
+}
+
+func TestWantsJsonErrors(t *testing.T) {
+	for _, c := range []struct {
+		header, value string
+		want          bool
+	}{
+		{"", "", false},
+		{"Accept", "text/plain", false},
+		{"Accept", "*/*", false},
+		{"Accept", "application/json", true},
+		{"Accept", "text/html, application/json;q=0.9", true},
+		{"Accept", "Application/JSON", true},
+		{ErrorVersionHeader, "1", true},
+	} {
+		r := httptest.NewRequest("GET", "/volumes", nil)
+		if c.header != "" {
+			r.Header.Set(c.header, c.value)
+		}
+		tests.Assert(t, WantsJsonErrors(r) == c.want,
+			"expected", c.want, "for", c.header, c.value)
+	}
+}
+
+func TestHttpErrorPlainText(t *testing.T) {
+	r := httptest.NewRequest("GET", "/volumes", nil)
+	w := negotiated(r, func(w http.ResponseWriter, r *http.Request) {
+		HttpError(w, "Id not found", http.StatusNotFound)
+	})
+
+	tests.Assert(t, w.Code == http.StatusNotFound, "got", w.Code)
+	tests.Assert(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"),
+		"got", w.Header().Get("Content-Type"))
+	tests.Assert(t, w.Body.String() == "Id not found\n", "got", w.Body.String())
+}
+
+func TestHttpErrorJson(t *testing.T) {
+	for _, header := range []string{"Accept", ErrorVersionHeader} {
+		r := httptest.NewRequest("GET", "/volumes", nil)
+		r.Header.Set(header, "application/json")
+		w := negotiated(r, func(w http.ResponseWriter, r *http.Request) {
+			HttpError(w, "Id not found", http.StatusNotFound)
+		})
+
+		tests.Assert(t, w.Code == http.StatusNotFound, "got", w.Code)
+		tests.Assert(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"),
+			"got", w.Header().Get("Content-Type"))
+		var e Error
+		err := json.Unmarshal(w.Body.Bytes(), &e)
+		tests.Assert(t, err == nil, "got", err)
+		tests.Assert(t, e.Version == ErrorVersion, "got", e.Version)
+		tests.Assert(t, e.Code == ErrorCodeNotFound, "got", e.Code)
+		tests.Assert(t, e.Message == "Id not found", "got", e.Message)
+		tests.Assert(t, !e.Retryable)
+	}
+}
+
+func TestWriteErrorJsonKeepsDetails(t *testing.T) {
+	r := httptest.NewRequest("GET", "/volumes", nil)
+	r.Header.Set(ErrorVersionHeader, "1")
+	ae := NewError(ErrorCodeNoSpace, "No space")
+	ae.Details = []ErrorDetail{{Cluster: "a", Message: "No space"}}
+	w := negotiated(r, func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, ae, http.StatusInternalServerError)
+	})
+
+	var e Error
+	err := json.Unmarshal(w.Body.Bytes(), &e)
+	tests.Assert(t, err == nil, "got", err)
+	tests.Assert(t, e.Code == ErrorCodeNoSpace, "got", e.Code)
+	tests.Assert(t, len(e.Details) == 1 && e.Details[0].Cluster == "a",
+		"got", e.Details)
+}
+
+func TestNegotiateErrorsFlushes(t *testing.T) {
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set("Accept", "application/json")
+	w := negotiated(r, func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		tests.Assert(t, ok, "expected a flusher")
+		f.Flush()
+	})
+	tests.Assert(t, w.Flushed)
+}
+
+func TestGetErrorFromResponse(t *testing.T) {
+	for _, header := range []string{"", "Accept"} {
+		r := httptest.NewRequest("GET", "/volumes", nil)
+		if header != "" {
+			r.Header.Set(header, "application/json")
+		}
+		w := negotiated(r, func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, errors.New("Server busy"), http.StatusTooManyRequests)
+		})
+
+		res := w.Result()
+		// like the server, which sets the length of short replies
+		res.ContentLength = int64(w.Body.Len())
+		err := GetErrorFromResponse(res)
+		e, ok := err.(*Error)
+		tests.Assert(t, ok, "expected *Error, got", err)
+		tests.Assert(t, e.Code == ErrorCodeTooManyRequests, "got", e.Code)
+		tests.Assert(t, e.Message == "Server busy", "got", e.Message)
+		tests.Assert(t, e.Retryable)
+		tests.Assert(t, e.StatusCode == http.StatusTooManyRequests, "got", e.StatusCode)
+	}
+}
@@ -47,7 +47,7 @@ func (s *ServerState) ServeHTTP(
 	w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 
 	if !s.AllowRequest(r) {
-		http.Error(w,
+		utils.HttpError(w,
 			"Service disabled for maintenance",
 			http.StatusServiceUnavailable)
 		return
@@ -85,14 +85,14 @@ func (s *ServerState) SetAdminState(w http.ResponseWriter, r *http.Request) {
 	msg := api.AdminStatus{}
 	err := utils.GetJsonFromRequest(r, &msg)
 	if err != nil {
-		http.Error(w,
+		utils.HttpError(w,
 			fmt.Sprintf("request unable to be parsed: %s", err.Error()),
 			http.StatusBadRequest)
 		return
 	}
 
 	if err := msg.Validate(); err != nil {
-		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		utils.HttpError(w, "validation failed: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
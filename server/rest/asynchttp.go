@@ -17,6 +17,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/heketi/heketi/pkg/idgen"
 	"github.com/heketi/heketi/pkg/logging"
+	"github.com/heketi/heketi/pkg/utils"
 	"github.com/lpabon/godbc"
 )
 
@@ -38,6 +39,10 @@ type AsyncHttpManager struct {
 	lock     sync.RWMutex
 	route    string
 	handlers map[string]*AsyncHttpHandler
+
+	// ErrorFunc, if set, converts the errors of the failed handlers
+	// before they are returned to the clients
+	ErrorFunc func(error) error
 }
 
 // Creates a new manager
@@ -155,7 +160,7 @@ func (a *AsyncHttpManager) AsyncHttpRedirectUsing(w http.ResponseWriter,
 // 		200 Operation is still pending
 //		404 Id requested does not exist
 //		500 Operation finished and has failed.  Body will be filled in with the
-//			error in plain text.
+//			error in the json error format.
 //		303 Operation finished and has setup a new location to retreive data.
 //		204 Operation finished and has no data to return
 //
@@ -196,7 +201,11 @@ func (a *AsyncHttpManager) HandlerStatus(w http.ResponseWriter, r *http.Request)
 			if handler.err != nil {
 
 				// Return 500 status
-				http.Error(w, handler.err.Error(), http.StatusInternalServerError)
+				err := handler.err
+				if a.ErrorFunc != nil {
+					err = a.ErrorFunc(err)
+				}
+				utils.WriteError(w, err, http.StatusInternalServerError)
 			} else {
 				if handler.location != "" {
 
@@ -219,7 +228,7 @@ func (a *AsyncHttpManager) HandlerStatus(w http.ResponseWriter, r *http.Request)
 		}
 
 	} else {
-		http.Error(w, "Id not found", http.StatusNotFound)
+		utils.HttpError(w, "Id not found", http.StatusNotFound)
 	}
 }
 